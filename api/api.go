@@ -5,9 +5,11 @@ Created On: Aug 21, 2023
 package api
 
 import (
+    "context"
     "errors"
     "fmt"
     "strconv"
+    "strings"
     "time"
 )
 
@@ -20,6 +22,18 @@ var (
     ErrNoOffer = errors.New("table is not offered on given date")
     ErrNoPayInfo = errors.New("no payment info on account")
     ErrImperva = errors.New("imperva challenge detected: cookies expired or invalid")
+    ErrCancelled = errors.New("request cancelled before completion")
+    ErrSchema = errors.New("unexpected response schema")
+    ErrNoVenue = errors.New("venue not found")
+    ErrPaymentPolicy = errors.New("slot payment terms exceed the allowed limits")
+    ErrRateLimited = errors.New("rate limited by the reservation service")
+    ErrAuthExpired = errors.New("auth token expired or revoked")
+    ErrSlotTaken = errors.New("slot was taken before it could be booked")
+    ErrPaymentDeclined = errors.New("payment method was declined")
+    ErrVenueClosed = errors.New("venue is closed on the given date")
+    ErrPartySize = errors.New("party size is not allowed at this venue")
+    ErrBookUncertain = errors.New("book request failed after it was sent, the table may have been booked")
+    ErrAlreadyCancelled = errors.New("reservation was already cancelled")
 )
 
 // NetworkError wraps ErrNetwork with additional context about what failed
@@ -45,6 +59,176 @@ func NewNetworkError(step string, status int, message string) *NetworkError {
     return &NetworkError{Step: step, Status: status, Message: message}
 }
 
+// CancelError wraps ErrCancelled with the step that was interrupted and
+// the context error that caused it (context.Canceled or
+// context.DeadlineExceeded), so callers can match either with errors.Is
+type CancelError struct {
+    Step    string // e.g., "login", "find", "book"
+    Cause   error  // the caller's ctx.Err()
+}
+
+func (e *CancelError) Error() string {
+    return fmt.Sprintf("request cancelled at %s step: %v", e.Step, e.Cause)
+}
+
+func (e *CancelError) Unwrap() []error {
+    return []error{ErrCancelled, e.Cause}
+}
+
+// NewCancelError creates a new CancelError for the given step
+func NewCancelError(step string, cause error) *CancelError {
+    return &CancelError{Step: step, Cause: cause}
+}
+
+// UncertainBookError wraps ErrBookUncertain when a book request was
+// sent but failed in a way that doesn't say whether it went through,
+// like a 5xx, a timeout or a dropped connection. Booking again could
+// book twice, so callers should look at the user's reservations first.
+// Slot is what the request tried to book
+type UncertainBookError struct {
+    Step    string // e.g., "book"
+    Slot    *Slot
+    Cause   error  // what went wrong, usually a NetworkError
+}
+
+func (e *UncertainBookError) Error() string {
+    return fmt.Sprintf("book outcome unknown at %s step: %v", e.Step, e.Cause)
+}
+
+func (e *UncertainBookError) Unwrap() []error {
+    return []error{ErrBookUncertain, e.Cause}
+}
+
+// NewUncertainBookError creates a new UncertainBookError for the given step
+func NewUncertainBookError(step string, slot *Slot, cause error) *UncertainBookError {
+    return &UncertainBookError{Step: step, Slot: slot, Cause: cause}
+}
+
+// SchemaError wraps ErrSchema when a provider answers with a payload
+// that doesn't match the shape we decode, usually because the provider
+// changed its API. Path is the JSON path of the offending value, e.g.
+// "results.venues[0].slots[2].config.token"
+type SchemaError struct {
+    Step    string // e.g., "login", "find", "book"
+    Path    string // JSON path, empty for the document root
+    Reason  string // e.g., "missing field", "unknown field", "expected string"
+}
+
+func (e *SchemaError) Error() string {
+    path := e.Path
+    if path == "" {
+        path = "(root)"
+    }
+    return fmt.Sprintf("unexpected response schema at %s step: %s: %s", e.Step, path, e.Reason)
+}
+
+func (e *SchemaError) Unwrap() error {
+    return ErrSchema
+}
+
+// NewSchemaError creates a new SchemaError for the given step and path
+func NewSchemaError(step string, path string, reason string) *SchemaError {
+    return &SchemaError{Step: step, Path: path, Reason: reason}
+}
+
+// PaymentPolicyError wraps ErrPaymentPolicy when a Reserve call booked
+// nothing and turned down at least one slot it would otherwise have
+// tried for costing more than its PaymentPolicy allows
+type PaymentPolicyError struct {
+    Fee     string  // "deposit" or "cancellation fee"
+    Amount  float64 // what the slot asks for
+    Limit   float64 // the most the policy allows
+}
+
+func (e *PaymentPolicyError) Error() string {
+    return fmt.Sprintf("slot payment terms exceed the allowed limits: %s of %.2f is over %.2f", e.Fee, e.Amount, e.Limit)
+}
+
+func (e *PaymentPolicyError) Unwrap() error {
+    return ErrPaymentPolicy
+}
+
+// NewPaymentPolicyError creates a new PaymentPolicyError for the given fee
+func NewPaymentPolicyError(fee string, amount float64, limit float64) *PaymentPolicyError {
+    return &PaymentPolicyError{Fee: fee, Amount: amount, Limit: limit}
+}
+
+// RateLimitError wraps ErrRateLimited when a provider answers 429.
+// RetryAfter is how long it asked us to wait, 0 if it didn't say
+type RateLimitError struct {
+    Step       string        // e.g., "find", "book"
+    RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+    if e.RetryAfter > 0 {
+        return fmt.Sprintf("rate limited at %s step, retry after %v", e.Step, e.RetryAfter)
+    }
+    return fmt.Sprintf("rate limited at %s step", e.Step)
+}
+
+func (e *RateLimitError) Unwrap() error {
+    return ErrRateLimited
+}
+
+// NewRateLimitError creates a new RateLimitError for the given step
+func NewRateLimitError(step string, retryAfter time.Duration) *RateLimitError {
+    return &RateLimitError{Step: step, RetryAfter: retryAfter}
+}
+
+// AuthError wraps ErrAuthExpired when a provider rejects the auth token
+// from Login, because it expired or the user revoked it. Logging in
+// again is the only fix
+type AuthError struct {
+    Step    string // e.g., "find", "book"
+    Status  int    // HTTP status code
+}
+
+func (e *AuthError) Error() string {
+    return fmt.Sprintf("auth token rejected at %s step (HTTP %d)", e.Step, e.Status)
+}
+
+func (e *AuthError) Unwrap() error {
+    return ErrAuthExpired
+}
+
+// NewAuthError creates a new AuthError for the given step
+func NewAuthError(step string, status int) *AuthError {
+    return &AuthError{Step: step, Status: status}
+}
+
+// RefusalError is a provider turning a booking down for a reason it
+// states: Reason is one of ErrSlotTaken, ErrPaymentDeclined,
+// ErrVenueClosed or ErrPartySize (or, turning down a cancel,
+// ErrAlreadyCancelled), and Message the provider's words.
+// A taken slot also matches ErrNoTable, which is what callers saw for
+// it before the reasons were told apart
+type RefusalError struct {
+    Step    string // e.g., "detail", "book"
+    Status  int    // HTTP status code if available
+    Reason  error
+    Message string
+}
+
+func (e *RefusalError) Error() string {
+    if e.Message == "" {
+        return fmt.Sprintf("%v at %s step", e.Reason, e.Step)
+    }
+    return fmt.Sprintf("%v at %s step: %s", e.Reason, e.Step, e.Message)
+}
+
+func (e *RefusalError) Unwrap() []error {
+    if e.Reason == ErrSlotTaken {
+        return []error{ErrSlotTaken, ErrNoTable}
+    }
+    return []error{e.Reason}
+}
+
+// NewRefusalError creates a new RefusalError for the given step and reason
+func NewRefusalError(step string, status int, reason error, message string) *RefusalError {
+    return &RefusalError{Step: step, Status: status, Reason: reason, Message: message}
+}
+
 
 /*
 Name: LoginParam
@@ -98,6 +282,14 @@ Purpose: Input information to the 'Search' api function
 type SearchParam struct {
     Name            string
     Limit           int
+    Page            int     // 1-based page of Limit results, 0 for the first
+    Offset          int     // Results of Page already returned, from a previous NextOffset
+    Latitude        float64 // Searches near this point when either is non-zero
+    Longitude       float64
+    RadiusMeters    int     // Only with a location, 0 for the provider's default
+    Neighborhood    string
+    Cuisine         string
+    PriceBand       int     // 1 ($) to 4 ($$$$), 0 for any
 }
 
 /*
@@ -106,20 +298,30 @@ Type: API Func Output Struct
 Purpose: Output information from 'Search' api function 
 */
 type SearchResponse struct {
-    Results []SearchResult
+    Results     []SearchResult
+    NextPage    int // Page to ask for the results after these, 0 if there are none
+    NextOffset  int // Offset to pass along with NextPage
 }
 
 /*
 Name: SeachResult
 Type: API Output Struct
 Purpose: Output specific results from 'Search' api function 
+Note: Provider names the service VenueID belongs to, see Registry.
+DistanceMeters is only set when the search had a location
 */
 type SearchResult struct {
-    VenueID         int64  `json:"venue_id"`
-    Name            string `json:"name"`
-    Region          string `json:"region"`
-    Locality        string `json:"locality"`
-    Neighborhood    string `json:"neighborhood"`
+    Provider        string   `json:"provider"`
+    VenueID         int64    `json:"venue_id"`
+    Name            string   `json:"name"`
+    Region          string   `json:"region"`
+    Locality        string   `json:"locality"`
+    Neighborhood    string   `json:"neighborhood"`
+    Latitude        float64  `json:"latitude,omitempty"`
+    Longitude       float64  `json:"longitude,omitempty"`
+    Cuisines        []string `json:"cuisines,omitempty"`
+    PriceBand       int      `json:"price_band,omitempty"`
+    DistanceMeters  float64  `json:"distance_meters,omitempty"`
 }
 
 /*
@@ -147,18 +349,314 @@ Purpose: Input information to the 'Reserve' api function
 type ReserveParam struct {
     VenueID          int64
     ReservationTimes []time.Time
+    Window           TimeWindow
     PartySize        int
     TableTypes       []TableType
     LoginResp        LoginResponse
+    DryRun           bool // Stop just before booking and report what would have been booked
+    Payment          PaymentPolicy
+    Requests         SpecialRequests // Occasion and notes sent with the booking, nothing if zero
+}
+
+/*
+Name: ReserveParam.Dates
+Type: API Func
+Purpose: List the distinct calendar days (YYYY-MM-DD in loc) that the
+reservation times fall on, in the order they first appear, so a
+provider can fetch each day's slots once
+*/
+func (p ReserveParam) Dates(loc *time.Location) ([]string) {
+    dates := make([]string, 0, len(p.ReservationTimes))
+    seen := make(map[string]bool, len(p.ReservationTimes))
+    for _, t := range p.ReservationTimes {
+        day := t.In(loc).Format("2006-01-02")
+        if !seen[day] {
+            seen[day] = true
+            dates = append(dates, day)
+        }
+    }
+    return dates
 }
 
 /*
 Name: ReserveResponse
 Type: API Func Output Struct
 Purpose: Output information from the 'Reserve' api function 
+Note: ReservationID is the service's own reference for the booking
+(Resy's reservation_id, an OpenTable confirmation number) and 
+ReservationToken is what 'Cancel' takes to undo it. Both are empty
+for a dry run
 */
 type ReserveResponse struct {
-    ReservationTime time.Time
+    ReservationTime  time.Time
+    DryRun           bool          // Nothing was booked, the rest describes what would have been
+    Slot             *Slot         // The slot booked (or that would have been)
+    Terms            *BookingTerms // What booking Slot commits the diner to, nil if unknown
+    VenueID          int64
+    PartySize        int
+    TableType        string        // Seating actually booked, as the service names it
+    ReservationID    string
+    ReservationToken string
+}
+
+/*
+Name: BookingTerms
+Type: API Output Struct
+Purpose: The money and cancellation rules attached to booking a
+slot, as the service states them right before the booking step
+Note: PaymentRequired means a card must be on the account, whether
+for a deposit or to guarantee a cancellation fee.
+CancellationCutoff is when the fee starts to apply, nil if not stated
+*/
+type BookingTerms struct {
+    PaymentRequired    bool       `json:"payment_required"`
+    DepositFee         float64    `json:"deposit_fee,omitempty"`
+    CancellationFee    float64    `json:"cancellation_fee,omitempty"`
+    CancellationCutoff *time.Time `json:"cancellation_cutoff,omitempty"`
+    Policy             string     `json:"policy,omitempty"`
+}
+
+/*
+Name: CancelParam
+Type: API Func Input Struct
+Purpose: Input information to the 'Cancel' api function 
+Note: ReservationToken identifies a booked reservation on the
+external service. For Resy, it is the reservation's resy_token
+*/
+type CancelParam struct {
+    ReservationToken string
+    LoginResp        LoginResponse
+}
+
+/*
+Name: CancelResponse
+Type: API Func Output Struct
+Purpose: Output information from the 'Cancel' api function 
+Note: Refund reports whether the service refunded a charge
+(deposit or prepayment) as part of the cancellation, with
+RefundAmount set when the service says how much
+*/
+type CancelResponse struct {
+    Refund          bool
+    RefundAmount    float64
+}
+
+/*
+Name: ListReservationsParam
+Type: API Func Input Struct
+Purpose: Input information to the 'ListReservations' api function 
+Note: A nonpositive Limit leaves the page size up to the service
+*/
+type ListReservationsParam struct {
+    LoginResp        LoginResponse
+    Limit            int
+}
+
+/*
+Name: BookedReservation
+Type: API Output Struct
+Purpose: A reservation the logged in user currently holds.
+ReservationToken is the value to pass to 'Cancel'
+*/
+type BookedReservation struct {
+    VenueID          int64     `json:"venue_id"`
+    VenueName        string    `json:"venue_name"`
+    ReservationTime  time.Time `json:"reservation_time"`
+    PartySize        int       `json:"party_size"`
+    TableType        string    `json:"table_type,omitempty"`
+    ReservationToken string    `json:"reservation_token"`
+}
+
+/*
+Name: ListReservationsResponse
+Type: API Func Output Struct
+Purpose: Output information from the 'ListReservations' api function 
+*/
+type ListReservationsResponse struct {
+    Reservations []BookedReservation
+}
+
+/*
+Name: NotifyParam
+Type: API Func Input Struct
+Purpose: Input information to the 'Notify' api function, asking to be
+told when a table opens up at a venue on a day for a party size
+Note: Date is a calendar day (YYYY-MM-DD) in the venue's timezone.
+Earliest and Latest are times of day there, as in TimeWindow, and a
+zero value leaves that end of the day open
+*/
+type NotifyParam struct {
+    VenueID          int64
+    Date             string
+    PartySize        int
+    Earliest         time.Duration
+    Latest           time.Duration
+    LoginResp        LoginResponse
+}
+
+/*
+Name: NotifyParam.Validate
+Type: API Func
+Purpose: Check the date parses, the party has someone in it and the
+time range is in order
+*/
+func (p NotifyParam) Validate() (error) {
+    if _, err := time.Parse("2006-01-02", p.Date); err != nil {
+        return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", p.Date)
+    }
+    if p.PartySize < 1 {
+        return fmt.Errorf("party size must be at least 1")
+    }
+    return TimeWindow{Earliest: p.Earliest, Latest: p.Latest}.Validate()
+}
+
+/*
+Name: WaitlistEntry
+Type: API Output Struct
+Purpose: A standing Notify registration held by the logged in user.
+NotifyID is the value to pass to 'RemoveNotify'
+*/
+type WaitlistEntry struct {
+    NotifyID         string
+    VenueID          int64
+    Date             string
+    PartySize        int
+    Earliest         time.Duration
+    Latest           time.Duration
+}
+
+/*
+Name: ListNotifiesParam
+Type: API Func Input Struct
+Purpose: Input information to the 'ListNotifies' api function 
+*/
+type ListNotifiesParam struct {
+    LoginResp        LoginResponse
+}
+
+/*
+Name: ListNotifiesResponse
+Type: API Func Output Struct
+Purpose: Output information from the 'ListNotifies' api function 
+*/
+type ListNotifiesResponse struct {
+    Entries []WaitlistEntry
+}
+
+/*
+Name: RemoveNotifyParam
+Type: API Func Input Struct
+Purpose: Input information to the 'RemoveNotify' api function 
+*/
+type RemoveNotifyParam struct {
+    NotifyID         string
+    LoginResp        LoginResponse
+}
+
+/*
+Name: AvailabilityParam
+Type: API Func Input Struct
+Purpose: Input information to the 'Availability' api function 
+Note: Date is a calendar day (YYYY-MM-DD) in the venue's timezone.
+LoginResp is optional, but some services only show member slots
+to a logged in user
+*/
+type AvailabilityParam struct {
+    VenueID          int64
+    Date             string
+    PartySize        int
+    LoginResp        LoginResponse
+}
+
+/*
+Name: SlotPayment
+Type: API Output Struct
+Purpose: Describe any money attached to a slot, as advertised
+before booking
+*/
+type SlotPayment struct {
+    IsPaid          bool    `json:"is_paid"`
+    DepositFee      float64 `json:"deposit_fee,omitempty"`
+    CancellationFee float64 `json:"cancellation_fee,omitempty"`
+}
+
+/*
+Name: Slot
+Type: API Output Struct
+Purpose: A single bookable time at a venue. TableType is the
+service's own seating label (e.g. "Dining Room") and ConfigToken
+is the opaque handle the service needs to book that exact slot
+*/
+type Slot struct {
+    Start           time.Time    `json:"start"`
+    TableType       string       `json:"table_type"`
+    ConfigToken     string       `json:"config_token"`
+    Payment         *SlotPayment `json:"payment,omitempty"`
+}
+
+/*
+Name: AvailabilityResponse
+Type: API Func Output Struct
+Purpose: Output information from the 'Availability' api function 
+*/
+type AvailabilityResponse struct {
+    Slots []Slot
+}
+
+/*
+Name: GetVenueParam
+Type: API Func Input Struct
+Purpose: Input information to the 'GetVenue' api function 
+*/
+type GetVenueParam struct {
+    VenueID          int64
+}
+
+/*
+Name: BookingWindow
+Type: API Output Struct
+Purpose: When a venue releases tables, i.e. DaysInAdvance days
+ahead of the reservation date at ReleaseHour:ReleaseMinute in the
+venue's TimeZone
+*/
+type BookingWindow struct {
+    DaysInAdvance   int `json:"days_in_advance"`
+    ReleaseHour     int `json:"release_hour"`
+    ReleaseMinute   int `json:"release_minute"`
+}
+
+/*
+Name: FeePolicy
+Type: API Output Struct
+Purpose: The deposit and cancellation fee rules a venue
+advertises. CancellationCutoff is how long before the
+reservation a cancellation becomes chargeable, 0 if unknown
+*/
+type FeePolicy struct {
+    DepositFee         float64       `json:"deposit_fee,omitempty"`
+    CancellationFee    float64       `json:"cancellation_fee,omitempty"`
+    CancellationCutoff time.Duration `json:"cancellation_cutoff,omitempty"`
+    Description        string        `json:"description,omitempty"`
+}
+
+/*
+Name: Venue
+Type: API Func Output Struct
+Purpose: Output information from the 'GetVenue' api function 
+Note: TimeZone is an IANA name such as "America/New_York".
+BookingWindow and Fees are nil when the service doesn't say
+*/
+type Venue struct {
+    Provider         string         `json:"provider"`
+    VenueID          int64          `json:"venue_id"`
+    Name             string         `json:"name"`
+    Slug             string         `json:"slug,omitempty"`
+    Address          string         `json:"address"`
+    City             string         `json:"city"`
+    TimeZone         string         `json:"time_zone"`
+    SeatingTypes     []string       `json:"seating_types"`
+    BookingWindow    *BookingWindow `json:"booking_window,omitempty"`
+    Fees             *FeePolicy     `json:"fees,omitempty"`
 }
 
 /*
@@ -167,14 +665,61 @@ Type: Interface
 Purpose: Provide a minimal enough abstraction of common behavior
 among external reservation services to allow cross-platform
 application production
+Note: Every network-bound method takes a context first. Implementations
+must stop work when the context is done and report it as ErrCancelled
+(usually via a CancelError), distinct from ErrNetwork
 */
 type API interface {
-    Login(params LoginParam) (*LoginResponse, error)
-    Search(params SearchParam) (*SearchResponse, error)
-    Reserve(params ReserveParam) (*ReserveResponse, error)
+    Login(ctx context.Context, params LoginParam) (*LoginResponse, error)
+    Search(ctx context.Context, params SearchParam) (*SearchResponse, error)
+    Reserve(ctx context.Context, params ReserveParam) (*ReserveResponse, error)
+    Availability(ctx context.Context, params AvailabilityParam) (*AvailabilityResponse, error)
+    Cancel(ctx context.Context, params CancelParam) (*CancelResponse, error)
+    ListReservations(ctx context.Context, params ListReservationsParam) (*ListReservationsResponse, error)
+    GetVenue(ctx context.Context, params GetVenueParam) (*Venue, error)
     AuthMinExpire() (time.Duration)
 }
 
+/*
+Name: Prewarmer
+Type: Interface
+Purpose: Optionally implemented by providers that can get ready for
+a Reserve call on a venue ahead of time, e.g. by loading cookies and
+opening the connection the call will reuse, so a snipe at release
+time doesn't pay for that setup
+Note: Prewarm failing is not fatal, Reserve does its own setup anyway
+*/
+type Prewarmer interface {
+    Prewarm(ctx context.Context, venueID int64) (error)
+}
+
+/*
+Name: ServiceClock
+Type: Interface
+Purpose: Optionally implemented by providers that estimate their
+service's clock. ServiceNow is the current time by that clock, which
+is the one a release happens on, so a scheduler firing at release
+time should wait on it rather than on time.Now
+*/
+type ServiceClock interface {
+    ServiceNow() (time.Time)
+}
+
+/*
+Name: Notifier
+Type: Interface
+Purpose: Optionally implemented by providers with a waitlist, where a
+user registers interest in a venue, day, party size and time range
+and the service tells them (by its own email or text) if a matching
+table opens up. It's a fallback for when Reserve finds nothing
+Note: Removing an entry that no longer exists is not an error
+*/
+type Notifier interface {
+    Notify(ctx context.Context, params NotifyParam) (*WaitlistEntry, error)
+    ListNotifies(ctx context.Context, params ListNotifiesParam) (*ListNotifiesResponse, error)
+    RemoveNotify(ctx context.Context, params RemoveNotifyParam) (error)
+}
+
 /*
 Name: SearchResponse.ToString 
 Type: Stringify Func
@@ -187,9 +732,21 @@ func (sr *SearchResponse) ToString() (string) {
         respStr += "\n"
         respStr += "\tName: " + e.Name + "\n"
         respStr += "\t\tVenueID: " + strconv.FormatInt(e.VenueID, 10) + "\n"
+        if e.Provider != "" {
+            respStr += "\t\tProvider: " + e.Provider + "\n"
+        }
         respStr += "\t\tRegion: " + e.Region + "\n"
         respStr += "\t\tLocality: " + e.Locality + "\n"
         respStr += "\t\tNeighborhood: " + e.Neighborhood +"\n"
+        if len(e.Cuisines) > 0 {
+            respStr += "\t\tCuisine: " + strings.Join(e.Cuisines, ", ") + "\n"
+        }
+        if e.PriceBand > 0 {
+            respStr += "\t\tPrice: " + strings.Repeat("$", e.PriceBand) + "\n"
+        }
+        if e.DistanceMeters > 0 {
+            respStr += "\t\tDistance: " + strconv.FormatFloat(e.DistanceMeters, 'f', 0, 64) + "m\n"
+        }
     }
     return respStr
 }
@@ -0,0 +1,56 @@
+package api
+
+import (
+    "errors"
+)
+
+// errorCodes pairs each sentinel with its code, most specific first so
+// an error matching several (a taken slot is also ErrNoTable) gets the
+// more useful code
+var errorCodes = []struct {
+    err  error
+    code string
+}{
+    {ErrCancelled, "cancelled"},
+    {ErrBookUncertain, "book_uncertain"},
+    {ErrRateLimited, "rate_limited"},
+    {ErrAuthExpired, "auth_expired"},
+    {ErrSlotTaken, "slot_taken"},
+    {ErrPaymentDeclined, "payment_declined"},
+    {ErrVenueClosed, "venue_closed"},
+    {ErrPartySize, "party_size_not_allowed"},
+    {ErrAlreadyCancelled, "already_cancelled"},
+    {ErrPaymentPolicy, "payment_policy"},
+    {ErrNoTable, "no_table"},
+    {ErrNoOffer, "no_offer"},
+    {ErrNoVenue, "no_venue"},
+    {ErrLoginWrong, "login_wrong"},
+    {ErrNoPayInfo, "no_payment_info"},
+    {ErrImperva, "imperva"},
+    {ErrSchema, "schema"},
+    {ErrPastDate, "past_date"},
+    {ErrTimeNull, "no_times"},
+    {ErrUnknownProvider, "unknown_provider"},
+    {ErrNetwork, "network_error"},
+}
+
+/*
+Name: ErrorCode
+Type: API Func
+Purpose: A stable, machine-readable name for an error from an API
+call, for clients that need to tell failures apart without parsing
+messages. Errors matching no sentinel are "error", and nil is ""
+Note: Codes are part of the server's public contract. Add new ones,
+but never rename or reuse one
+*/
+func ErrorCode(err error) (string) {
+    if err == nil {
+        return ""
+    }
+    for _, ec := range errorCodes {
+        if errors.Is(err, ec.err) {
+            return ec.code
+        }
+    }
+    return "error"
+}
@@ -0,0 +1,47 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{api.ErrNoTable, "no_table"},
+		{api.NewRefusalError("book", 412, api.ErrSlotTaken, "gone"), "slot_taken"},
+		{api.NewRefusalError("find", 400, api.ErrPartySize, "too many"), "party_size_not_allowed"},
+		{api.NewRateLimitError("find", 0), "rate_limited"},
+		{api.NewAuthError("book", 419), "auth_expired"},
+		{api.NewNetworkError("find", 500, "boom"), "network_error"},
+		{api.NewCancelError("book", context.Canceled), "cancelled"},
+		{api.NewUncertainBookError("book", nil, api.NewNetworkError("book", 503, "unavailable")), "book_uncertain"},
+		{api.NewPaymentPolicyError("deposit", 50, 25), "payment_policy"},
+		{api.NewRefusalError("cancel", 412, api.ErrAlreadyCancelled, "already cancelled"), "already_cancelled"},
+		{fmt.Errorf("reserving: %w", api.ErrImperva), "imperva"},
+		{errors.New("something else"), "error"},
+	}
+
+	for _, tt := range tests {
+		if got := api.ErrorCode(tt.err); got != tt.want {
+			t.Errorf("ErrorCode(%v) = %q, expected %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRefusalError_SlotTakenIsNoTable(t *testing.T) {
+	err := api.NewRefusalError("book", 412, api.ErrSlotTaken, "gone")
+	if !errors.Is(err, api.ErrNoTable) {
+		t.Error("expected a taken slot to match ErrNoTable")
+	}
+	if errors.Is(api.NewRefusalError("book", 402, api.ErrPaymentDeclined, ""), api.ErrNoTable) {
+		t.Error("expected a declined card not to match ErrNoTable")
+	}
+}
@@ -15,11 +15,47 @@ General Purpose:
 
 API:
 
-    The API interface specifies 3 methods:
+    The API interface specifies 7 network methods:
     
-        Login(params LoginParam) (*LoginResponse, error)
-        Reserve(params ReserveParam) (*ReserveResponse, error)
-        Search(params SearchParam) (*SearchResponse, error)
+        Login(ctx context.Context, params LoginParam) (*LoginResponse, error)
+        Reserve(ctx context.Context, params ReserveParam) (*ReserveResponse, error)
+        Search(ctx context.Context, params SearchParam) (*SearchResponse, error)
+        Availability(ctx context.Context, params AvailabilityParam) (*AvailabilityResponse, error)
+        Cancel(ctx context.Context, params CancelParam) (*CancelResponse, error)
+        ListReservations(ctx context.Context, params ListReservationsParam) (*ListReservationsResponse, error)
+        GetVenue(ctx context.Context, params GetVenueParam) (*Venue, error)
+
+    Each takes a context.Context as its first argument. When that 
+    context is cancelled or its deadline passes, the call stops at
+    the next opportunity and returns an error matching ErrCancelled
+    (and the underlying context error) under errors.Is. Callers can
+    therefore tell "the user or scheduler gave up" apart from 
+    ErrNetwork and friends.
+
+    When a service answers with a payload whose shape doesn't match
+    what the implementation decodes (a required field missing, a
+    value of the wrong JSON type), the call returns a SchemaError,
+    which matches ErrSchema and names the step and JSON path at fault,
+    so an upstream API change surfaces as a clear error rather than
+    a panic or a misleading ErrNoTable.
+
+    Failures the service explains have their own errors too. A 429
+    is a RateLimitError (ErrRateLimited) carrying any Retry-After,
+    and a rejected auth token an AuthError (ErrAuthExpired). When the
+    service turns a booking down for a stated reason, the call
+    returns a RefusalError whose Reason is ErrSlotTaken, 
+    ErrPaymentDeclined, ErrVenueClosed or ErrPartySize. A taken slot
+    also matches ErrNoTable, which is what it used to be reported as.
+
+    A booking request that fails once it has been sent, with a 5xx,
+    a timeout or a dropped connection, says nothing about whether the
+    table was booked. Reserve returns an UncertainBookError
+    (ErrBookUncertain) naming the slot instead of trying another, and
+    callers should look at ListReservations before booking again.
+
+    ErrorCode maps any of these errors to a short stable string, e.g.
+    "slot_taken" or "rate_limited", for clients that must tell them
+    apart without parsing messages. Codes are never renamed.
     
 **********************************************************************
 
@@ -44,6 +80,59 @@ Reserve:
     must be obtained by a 'Login' api function call, though such a
     value only needs to be obtained before a series of Reserve calls.
 
+    The times may fall on different days ("Fri or Sat, 7pm"). 
+    Implementations look up each distinct day once (see 
+    ReserveParam.Dates), match every time against the slots of its
+    own day, and still try the times strictly in the order given,
+    so an earlier entry wins over a later one on any day. ErrNoOffer
+    means none of the days had a listing at all.
+
+    Which slot counts as a match for a time is set by the param's 
+    TimeWindow. By default a slot must start within 30 minutes of 
+    the time and the closest one is booked. Earliest and Latest bound
+    the acceptable times of day instead ("anything between 6:30 and
+    8:00"), and Preference books the earliest, latest or closest 
+    slot inside them.
+
+    Setting DryRun goes through every step short of booking, or of
+    holding a table for booking, and returns the slot that would
+    have been booked, marked DryRun, so a job can be rehearsed
+    against the real venue. Every response 
+    carries the Slot and, where the service states them, the 
+    BookingTerms (card requirement, deposit, cancellation fee, cutoff
+    and policy) of what was or would have been booked.
+
+    A booking also comes back with what identifies it on the service:
+    ReservationID is the service's own reference, to show the diner,
+    and ReservationToken is what Cancel takes. Alongside them are the
+    VenueID, PartySize and the TableType actually booked, which may 
+    differ from the TableTypes asked for.
+
+    A PaymentPolicy caps the deposit and cancellation fee a booking 
+    may commit the diner to. Slots whose advertised terms are over a
+    limit are left out before ranking (see PaymentPolicy.Select), and
+    a slot whose terms only turn out to be over a limit at the step 
+    before booking is skipped there. If nothing is booked and a slot
+    was turned down this way, Reserve returns a PaymentPolicyError,
+    which matches ErrPaymentPolicy, rather than ErrNoTable.
+
+    ReserveParam.Requests carries SpecialRequests: an Occasion, a
+    dietary note and a seating request for the venue. They are
+    passed on with the booking and don't change which slot is 
+    picked; a service with a single free-text field gets Note. 
+    Reserve rejects an unknown Occasion or a note longer than 
+    MaxNoteLength before contacting the service.
+
+    Ranking the open slots against those preferences is left to a
+    SlotSelector, a pure function from slots and SlotPreferences to
+    an ordered list of candidates, which providers book down until 
+    one succeeds. PrioritySelector is the original ordering: table
+    types are the outer priority and times the inner one. 
+    WeightedSelector instead scores each slot by minutes from the 
+    ideal time, the rank of its seating and the rank of its target,
+    so those can be traded off by tuning three weights. Providers 
+    take a selector as a field and use PrioritySelector without one.
+
 **********************************************************************   
 
 Search:
@@ -54,9 +143,70 @@ Search:
     contain necessary and helpful data both for identifying the 
     intended restaurant to reserve at and also for making a 
     reservation request.
+
+    A search may also be anchored at a latitude and longitude, with
+    an optional radius in meters, and narrowed by neighborhood,
+    cuisine and price band (1 to 4). Limit doubles as the page size
+    for Page, and Page counts the service's pages before any filter
+    the service doesn't understand is applied. Implementations pass
+    what the service understands along, read further pages when one
+    filtered page comes up short of Limit, and then run their
+    results through FilterSearchResults, which applies every filter
+    client-side, fills in DistanceMeters and orders located searches
+    nearest first, so the filters mean the same thing on every
+    provider. SearchParam.Validate rejects out of
+    range values before any request is sent. A response's NextPage
+    and NextOffset, passed back as Page and Offset, continue where
+    its Results stopped, even when a page of the service held more
+    matches than Limit had room for; NextPage is 0 at the end.
     
 **********************************************************************   
 
+Availability:
+
+    The Availability function takes in a venue, a calendar day and a
+    party size and produces the open slots for that day without 
+    booking or holding any of them. Each slot carries its start time,
+    the service's seating type, the token the service uses to 
+    identify the slot, and any payment (deposit, cancellation fee)
+    advertised for it. A day with nothing listed is an empty slice,
+    not an error.
+
+**********************************************************************   
+
+Cancel:
+
+    The Cancel function takes in the service's token for a booked
+    reservation and a LoginResponse for the account holding it, and
+    cancels that reservation on the external service. The response
+    reports whether a charge was refunded. This is unrelated to
+    cancelling an app operation, which only stops a pending attempt.
+
+**********************************************************************   
+
+ListReservations:
+
+    The ListReservations function takes in a LoginResponse and returns
+    the upcoming reservations the account holds on the external 
+    service, soonest first. Each entry carries the venue, time, party
+    size and the token 'Cancel' needs, making the service itself the
+    source of truth for what a user has actually booked.
+
+**********************************************************************   
+
+GetVenue:
+
+    The GetVenue function takes in a venue id and returns what the
+    external service publishes about that venue: name, address,
+    city, IANA timezone, the seating types it offers, when it
+    releases tables (BookingWindow) and its deposit and cancellation
+    fee rules (FeePolicy). Services that don't state a booking window
+    or fees leave those nil. An unknown venue is ErrNoVenue. This
+    call needs no login, and its result changes rarely enough that
+    callers are expected to cache it.
+
+**********************************************************************   
+
 AuthMinExpire:
 
     The AuthMinExpire function provides the minimum time irresepective
@@ -66,5 +216,60 @@ AuthMinExpire:
 
 **********************************************************************   
 
+Providers:
+
+    Each implementation of API is a 'provider' with a short name,
+    currently ProviderResy ("resy") and ProviderOpenTable 
+    ("opentable"). A Registry maps those names to implementations
+    so the layers above can store a provider name next to a venue, 
+    job or credential and dispatch on it later:
+
+        providers := api.NewRegistry()
+        providers.Register(api.ProviderResy, &resyAPI)
+        impl, err := providers.Get(job.Provider)
+
+    Get returns ErrUnknownProvider for names nothing is registered 
+    under. The empty name is treated as DefaultProvider (Resy), as
+    everything stored before providers existed was Resy's. Names are
+    matched regardless of case and surrounding space, and 
+    NormalizeProvider maps the aliases "open_table", "open-table" and
+    "open table" to ProviderOpenTable.
+
+    Venue IDs are only unique within a provider, so a VenueRef pairs
+    them up. Its string form is "provider:id", e.g. "opentable:1001",
+    and ParseVenueRef also accepts a bare id as a Resy venue.
+
+**********************************************************************   
+
+Prewarmer:
+
+    Providers may also implement the optional Prewarmer interface.
+    Prewarm(ctx, venueID) does the setup a Reserve call for the venue
+    would otherwise do first, such as loading cookies and opening a
+    connection, so a caller that knows when it will book (the
+    scheduler, a few seconds before a release) can get it out of the
+    way. It needs no login and books nothing; a failure only means
+    Reserve will do that setup itself.
+
+**********************************************************************   
+
+Notifier:
+
+    Providers with a notify list (a waitlist a diner joins to hear
+    when a table frees up) implement the optional Notifier interface:
+
+        Notify(ctx context.Context, params NotifyParam) (*WaitlistEntry, error)
+        ListNotifies(ctx context.Context, params ListNotifiesParam) (*ListNotifiesResponse, error)
+        RemoveNotify(ctx context.Context, params RemoveNotifyParam) (error)
+
+    Notify registers interest in a venue for a day, party size and 
+    optional Earliest/Latest time-of-day range (zero for an open end),
+    and returns the entry with the service's NotifyID. RemoveNotify 
+    takes that ID, and removing an entry the service no longer has 
+    is not an error. NotifyParam.Validate checks the param before 
+    anything is sent.
+
+**********************************************************************   
+
 */
 package api
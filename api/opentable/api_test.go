@@ -0,0 +1,618 @@
+package opentable_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+	"github.com/21Bruce/resolved-server/api/opentable"
+	"github.com/21Bruce/resolved-server/api/opentable/opentabletest"
+)
+
+const testRID = 1001
+
+func setupFake(t *testing.T, slots ...opentabletest.Slot) (*opentabletest.Server, *opentable.API) {
+	t.Helper()
+	srv := opentabletest.NewServer()
+	t.Cleanup(srv.Close)
+
+	srv.AddRestaurant(opentabletest.Restaurant{
+		RID:          testRID,
+		Name:         "Le Bernardin",
+		Metro:        "New York",
+		City:         "Manhattan",
+		Neighborhood: "Midtown",
+		TimeZone:     "America/New_York",
+	}, slots...)
+
+	return srv, &opentable.API{BaseURL: srv.URL, Client: srv.Client()}
+}
+
+func login(t *testing.T, a *opentable.API) api.LoginResponse {
+	t.Helper()
+	resp, err := a.Login(context.Background(), api.LoginParam{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Email:     "ada@example.com",
+		Mobile:    "+12125550100",
+	})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	return *resp
+}
+
+func nyc(t *testing.T, value string) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation failed: %v", err)
+	}
+	tm, err := time.ParseInLocation("2006-01-02T15:04", value, loc)
+	if err != nil {
+		t.Fatalf("ParseInLocation failed: %v", err)
+	}
+	return tm
+}
+
+func TestLogin(t *testing.T) {
+	_, a := setupFake(t)
+
+	resp := login(t, a)
+	if resp.AuthToken == "" {
+		t.Error("expected an auth token")
+	}
+	if resp.Email != "ada@example.com" || resp.FirstName != "Ada" {
+		t.Errorf("unexpected login response: %+v", resp)
+	}
+}
+
+func TestLogin_MissingFields(t *testing.T) {
+	_, a := setupFake(t)
+
+	_, err := a.Login(context.Background(), api.LoginParam{Email: "ada@example.com"})
+	if !errors.Is(err, api.ErrLoginWrong) {
+		t.Fatalf("expected ErrLoginWrong, got %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	_, a := setupFake(t)
+
+	resp, err := a.Search(context.Background(), api.SearchParam{Name: "bernardin", Limit: 5})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(resp.Results))
+	}
+	got := resp.Results[0]
+	if got.VenueID != testRID || got.Name != "Le Bernardin" || got.Neighborhood != "Midtown" {
+		t.Errorf("unexpected result: %+v", got)
+	}
+}
+
+func TestSearch_NextPage(t *testing.T) {
+	_, a := setupFake(t)
+
+	resp, err := a.Search(context.Background(), api.SearchParam{Name: "bernardin", Limit: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if resp.NextPage != 2 || resp.NextOffset != 0 {
+		t.Errorf("expected a full page to point at page 2, got %d/%d", resp.NextPage, resp.NextOffset)
+	}
+
+	resp, err = a.Search(context.Background(), api.SearchParam{Name: "bernardin", Limit: 1, Page: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Results) != 0 || resp.NextPage != 0 {
+		t.Errorf("expected an empty last page, got %+v", resp)
+	}
+}
+
+func TestSearch_NearbyAndFilters(t *testing.T) {
+	srv, a := setupFake(t)
+	srv.AddRestaurant(opentabletest.Restaurant{RID: 2002, Name: "Gramercy Tavern", Neighborhood: "Flatiron",
+		Latitude: 40.7385, Longitude: -73.9884, Cuisines: []string{"American"}, PriceBand: 4})
+	srv.AddRestaurant(opentabletest.Restaurant{RID: 2003, Name: "Union Square Cafe", Neighborhood: "Flatiron",
+		Latitude: 40.7376, Longitude: -73.9877, Cuisines: []string{"American"}, PriceBand: 3})
+
+	resp, err := a.Search(context.Background(), api.SearchParam{
+		Latitude:     40.7359,
+		Longitude:    -73.9911,
+		RadiusMeters: 1000,
+		Cuisine:      "american",
+		PriceBand:    3,
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].VenueID != 2003 {
+		t.Fatalf("expected only Union Square Cafe, got %+v", resp.Results)
+	}
+	if resp.Results[0].DistanceMeters <= 0 || resp.Results[0].Cuisines[0] != "American" {
+		t.Errorf("unexpected result: %+v", resp.Results[0])
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	_, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T19:00", SlotHash: "h1", SeatingType: "Dining Room"},
+		opentabletest.Slot{DateTime: "2026-11-20T21:00", SlotHash: "h2", SeatingType: "Bar", DepositAmount: 25},
+		opentabletest.Slot{DateTime: "2026-11-21T19:00", SlotHash: "h3", SeatingType: "Dining Room"},
+	)
+
+	resp, err := a.Availability(context.Background(), api.AvailabilityParam{
+		VenueID:   testRID,
+		Date:      "2026-11-20",
+		PartySize: 2,
+	})
+	if err != nil {
+		t.Fatalf("Availability failed: %v", err)
+	}
+	if len(resp.Slots) != 2 {
+		t.Fatalf("expected 2 slots, got %d", len(resp.Slots))
+	}
+	if !resp.Slots[0].Start.Equal(nyc(t, "2026-11-20T19:00")) {
+		t.Errorf("unexpected start: %v", resp.Slots[0].Start)
+	}
+	if resp.Slots[1].Payment == nil || resp.Slots[1].Payment.DepositFee != 25 {
+		t.Errorf("expected deposit on second slot, got %+v", resp.Slots[1].Payment)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	srv, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T18:45", SlotHash: "h1", SeatingType: "Dining Room"},
+		opentabletest.Slot{DateTime: "2026-11-20T19:15", SlotHash: "h2", SeatingType: "Dining Room"},
+	)
+	auth := login(t, a)
+
+	resp, err := a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:10")},
+		PartySize:        2,
+		LoginResp:        auth,
+	})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if want := nyc(t, "2026-11-20T19:15"); !resp.ReservationTime.Equal(want) {
+		t.Errorf("expected closest slot %v, got %v", want, resp.ReservationTime)
+	}
+
+	booked := srv.Reservations()
+	if len(booked) != 1 || booked[0].DateTime != "2026-11-20T19:15" {
+		t.Fatalf("unexpected bookings: %+v", booked)
+	}
+	if resp.ReservationID != booked[0].ConfirmationNumber || resp.ReservationToken != booked[0].ConfirmationNumber {
+		t.Errorf("expected confirmation %q, got %q, %q", booked[0].ConfirmationNumber, resp.ReservationID, resp.ReservationToken)
+	}
+	if resp.VenueID != testRID || resp.PartySize != 2 || resp.TableType != "Dining Room" {
+		t.Errorf("unexpected booking details %+v", resp)
+	}
+}
+
+func TestReserve_TableTypePreference(t *testing.T) {
+	_, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T19:00", SlotHash: "h1", SeatingType: "Dining Room"},
+		opentabletest.Slot{DateTime: "2026-11-20T19:00", SlotHash: "h2", SeatingType: "Bar"},
+	)
+	auth := login(t, a)
+
+	_, err := a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00")},
+		PartySize:        2,
+		TableTypes:       []api.TableType{api.Bar},
+		LoginResp:        auth,
+	})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+}
+
+func TestReserve_MultipleDates(t *testing.T) {
+	srv, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T21:30", SlotHash: "h1", SeatingType: "Dining Room"},
+		opentabletest.Slot{DateTime: "2026-11-21T19:00", SlotHash: "h2", SeatingType: "Dining Room"},
+	)
+	auth := login(t, a)
+
+	// Friday has nothing near 7pm, so the Saturday target should win
+	resp, err := a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00"), nyc(t, "2026-11-21T19:00")},
+		PartySize:        2,
+		LoginResp:        auth,
+	})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if want := nyc(t, "2026-11-21T19:00"); !resp.ReservationTime.Equal(want) {
+		t.Errorf("expected %v, got %v", want, resp.ReservationTime)
+	}
+
+	booked := srv.Reservations()
+	if len(booked) != 1 || booked[0].DateTime != "2026-11-21T19:00" {
+		t.Errorf("unexpected bookings: %+v", booked)
+	}
+}
+
+func TestReserve_TimeWindow(t *testing.T) {
+	srv, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T18:45", SlotHash: "h1", SeatingType: "Dining Room"},
+		opentabletest.Slot{DateTime: "2026-11-20T19:45", SlotHash: "h2", SeatingType: "Dining Room"},
+		opentabletest.Slot{DateTime: "2026-11-20T21:00", SlotHash: "h3", SeatingType: "Dining Room"},
+	)
+	auth := login(t, a)
+
+	// Anything between 6:30 and 8:00, prefer later
+	resp, err := a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00")},
+		Window:           api.TimeWindow{Earliest: 18*time.Hour + 30*time.Minute, Latest: 20 * time.Hour, Preference: api.PreferLatest},
+		PartySize:        2,
+		LoginResp:        auth,
+	})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if want := nyc(t, "2026-11-20T19:45"); !resp.ReservationTime.Equal(want) {
+		t.Errorf("expected %v, got %v", want, resp.ReservationTime)
+	}
+
+	booked := srv.Reservations()
+	if len(booked) != 1 || booked[0].DateTime != "2026-11-20T19:45" {
+		t.Errorf("unexpected bookings: %+v", booked)
+	}
+}
+
+func TestReserve_DryRun(t *testing.T) {
+	srv, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T19:00", SlotHash: "h1", SeatingType: "Dining Room", CreditCardRequired: true, DepositAmount: 40},
+	)
+	auth := login(t, a)
+
+	params := api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00")},
+		PartySize:        2,
+		LoginResp:        auth,
+		DryRun:           true,
+	}
+	resp, err := a.Reserve(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if !resp.DryRun || resp.Slot == nil || resp.Slot.ConfigToken != "h1" {
+		t.Fatalf("expected a dry run for h1, got %+v", resp)
+	}
+	if resp.Terms == nil || !resp.Terms.PaymentRequired || resp.Terms.DepositFee != 40 {
+		t.Errorf("unexpected terms: %+v", resp.Terms)
+	}
+	if booked := srv.Reservations(); len(booked) != 0 {
+		t.Errorf("expected nothing booked, got %+v", booked)
+	}
+
+	// The slot was never locked, so it can still be booked for real
+	params.DryRun = false
+	if resp, err := a.Reserve(context.Background(), params); err != nil || resp.DryRun {
+		t.Fatalf("expected a real booking after the dry run, got %+v, %v", resp, err)
+	}
+}
+
+func TestReserve_PaymentPolicy(t *testing.T) {
+	_, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T19:00", SlotHash: "h1", SeatingType: "Dining Room", CreditCardRequired: true, DepositAmount: 40},
+	)
+	auth := login(t, a)
+
+	maxDeposit := 25.0
+	_, err := a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00")},
+		PartySize:        2,
+		LoginResp:        auth,
+		Payment:          api.PaymentPolicy{MaxDeposit: &maxDeposit},
+	})
+	var policyErr *api.PaymentPolicyError
+	if !errors.As(err, &policyErr) || policyErr.Fee != "deposit" || policyErr.Amount != 40 {
+		t.Fatalf("expected a deposit PaymentPolicyError, got %v", err)
+	}
+}
+
+func TestReserve_SpecialRequests(t *testing.T) {
+	srv, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T19:00", SlotHash: "h1", SeatingType: "Dining Room"},
+	)
+	auth := login(t, a)
+
+	_, err := a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00")},
+		PartySize:        2,
+		LoginResp:        auth,
+		Requests:         api.SpecialRequests{Occasion: api.Birthday, Dietary: "Vegetarian"},
+	})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+
+	booked := srv.Reservations()
+	if len(booked) != 1 || booked[0].Occasion != "birthday" || booked[0].SpecialRequest != "Dietary: Vegetarian" {
+		t.Errorf("expected the occasion and note on the booking, got %+v", booked)
+	}
+}
+
+func TestReserve_VenueTimezone(t *testing.T) {
+	srv, a := setupFake(t)
+	srv.AddRestaurant(opentabletest.Restaurant{RID: 2002, Name: "Providence", TimeZone: "America/Los_Angeles"},
+		opentabletest.Slot{DateTime: "2026-11-20T22:30", SlotHash: "late", SeatingType: "Dining Room"},
+	)
+	auth := login(t, a)
+
+	// 10:30 PM in Los Angeles is already the next day in New York
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatalf("LoadLocation failed: %v", err)
+	}
+	target := time.Date(2026, 11, 20, 22, 30, 0, 0, la)
+	resp, err := a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          2002,
+		ReservationTimes: []time.Time{target},
+		PartySize:        2,
+		LoginResp:        auth,
+	})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if !resp.ReservationTime.Equal(target) {
+		t.Errorf("expected %v, got %v", target, resp.ReservationTime)
+	}
+}
+
+func TestReserve_SkipsFailedDay(t *testing.T) {
+	srv, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T19:00", SlotHash: "h1", SeatingType: "Dining Room"},
+		opentabletest.Slot{DateTime: "2026-11-21T19:00", SlotHash: "h2", SeatingType: "Dining Room"},
+	)
+	srv.FailAvailability("2026-11-20")
+	auth := login(t, a)
+
+	resp, err := a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00"), nyc(t, "2026-11-21T19:00")},
+		PartySize:        2,
+		LoginResp:        auth,
+	})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if want := nyc(t, "2026-11-21T19:00"); !resp.ReservationTime.Equal(want) {
+		t.Errorf("expected the day that loaded to be booked, got %v", resp.ReservationTime)
+	}
+
+	// With every day failing the error comes back
+	srv.FailAvailability("2026-11-21")
+	_, err = a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00"), nyc(t, "2026-11-21T19:00")},
+		PartySize:        2,
+		LoginResp:        auth,
+	})
+	if !errors.Is(err, api.ErrNetwork) {
+		t.Errorf("expected ErrNetwork, got %v", err)
+	}
+}
+
+func TestReserve_ReleasesLockOnFailedBook(t *testing.T) {
+	srv, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T19:00", SlotHash: "h1", SeatingType: "Dining Room"},
+	)
+	srv.FailBooks(500)
+	auth := login(t, a)
+
+	params := api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00")},
+		PartySize:        2,
+		LoginResp:        auth,
+	}
+	if _, err := a.Reserve(context.Background(), params); !errors.Is(err, api.ErrBookUncertain) {
+		t.Fatalf("expected a 500 from book to leave the outcome uncertain, got %v", err)
+	}
+	if n := srv.Locks(); n != 0 {
+		t.Fatalf("expected the lock to be released, %d held", n)
+	}
+
+	// The table is back on the market for the next attempt
+	srv.FailBooks(0)
+	if _, err := a.Reserve(context.Background(), params); err != nil {
+		t.Fatalf("expected the released slot to be bookable, got %v", err)
+	}
+}
+
+func TestReserve_MissingConfirmationIsUncertain(t *testing.T) {
+	srv, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T19:00", SlotHash: "h1", SeatingType: "Dining Room"},
+		opentabletest.Slot{DateTime: "2026-11-20T19:15", SlotHash: "h2", SeatingType: "Dining Room"},
+	)
+	srv.HideConfirmations(true)
+	auth := login(t, a)
+
+	_, err := a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00"), nyc(t, "2026-11-20T19:15")},
+		PartySize:        2,
+		LoginResp:        auth,
+	})
+	var uncertain *api.UncertainBookError
+	if !errors.As(err, &uncertain) || !errors.Is(err, api.ErrSchema) {
+		t.Fatalf("expected an UncertainBookError for the missing confirmation, got %v", err)
+	}
+	if uncertain.Slot == nil || uncertain.Slot.ConfigToken != "h1" {
+		t.Errorf("expected the error to name h1, got %+v", uncertain.Slot)
+	}
+
+	// The first slot was booked, so the second must not be
+	if n := len(srv.Reservations()); n != 1 {
+		t.Errorf("expected the one booking, got %d", n)
+	}
+	if n := srv.Locks(); n != 0 {
+		t.Errorf("expected no other slot to be locked, %d held", n)
+	}
+}
+
+func TestReserve_NoOffer(t *testing.T) {
+	_, a := setupFake(t)
+	auth := login(t, a)
+
+	_, err := a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00")},
+		PartySize:        2,
+		LoginResp:        auth,
+	})
+	if !errors.Is(err, api.ErrNoOffer) {
+		t.Fatalf("expected ErrNoOffer, got %v", err)
+	}
+}
+
+func TestReserve_NoTable(t *testing.T) {
+	_, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T22:00", SlotHash: "h1", SeatingType: "Dining Room"},
+	)
+	auth := login(t, a)
+
+	_, err := a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00")},
+		PartySize:        2,
+		LoginResp:        auth,
+	})
+	if !errors.Is(err, api.ErrNoTable) {
+		t.Fatalf("expected ErrNoTable, got %v", err)
+	}
+}
+
+func TestReserve_SlotTaken(t *testing.T) {
+	srv, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T19:00", SlotHash: "h1", SeatingType: "Dining Room"},
+	)
+	first := login(t, a)
+	second := login(t, a)
+
+	params := api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00")},
+		PartySize:        2,
+		LoginResp:        first,
+	}
+	if _, err := a.Reserve(context.Background(), params); err != nil {
+		t.Fatalf("first Reserve failed: %v", err)
+	}
+
+	params.LoginResp = second
+	if _, err := a.Reserve(context.Background(), params); !errors.Is(err, api.ErrNoOffer) {
+		t.Fatalf("expected ErrNoOffer once the only slot is gone, got %v", err)
+	}
+	if n := len(srv.Reservations()); n != 1 {
+		t.Errorf("expected 1 booking, got %d", n)
+	}
+}
+
+func TestCancelAndListReservations(t *testing.T) {
+	srv, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T19:00", SlotHash: "h1", SeatingType: "Dining Room"},
+	)
+	auth := login(t, a)
+
+	if _, err := a.Reserve(context.Background(), api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00")},
+		PartySize:        4,
+		LoginResp:        auth,
+	}); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+
+	list, err := a.ListReservations(context.Background(), api.ListReservationsParam{LoginResp: auth})
+	if err != nil {
+		t.Fatalf("ListReservations failed: %v", err)
+	}
+	if len(list.Reservations) != 1 {
+		t.Fatalf("expected 1 reservation, got %d", len(list.Reservations))
+	}
+	got := list.Reservations[0]
+	if got.VenueName != "Le Bernardin" || got.PartySize != 4 || got.ReservationToken == "" {
+		t.Errorf("unexpected reservation: %+v", got)
+	}
+
+	if _, err := a.Cancel(context.Background(), api.CancelParam{
+		ReservationToken: got.ReservationToken,
+		LoginResp:        auth,
+	}); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if n := len(srv.Reservations()); n != 0 {
+		t.Errorf("expected no bookings after cancel, got %d", n)
+	}
+}
+
+func TestReserve_Cancelled(t *testing.T) {
+	_, a := setupFake(t,
+		opentabletest.Slot{DateTime: "2026-11-20T19:00", SlotHash: "h1", SeatingType: "Dining Room"},
+	)
+	auth := login(t, a)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := a.Reserve(ctx, api.ReserveParam{
+		VenueID:          testRID,
+		ReservationTimes: []time.Time{nyc(t, "2026-11-20T19:00")},
+		PartySize:        2,
+		LoginResp:        auth,
+	})
+	if !errors.Is(err, api.ErrCancelled) {
+		t.Fatalf("expected ErrCancelled, got %v", err)
+	}
+}
+
+func TestGetVenue(t *testing.T) {
+	srv, a := setupFake(t)
+	srv.AddRestaurant(opentabletest.Restaurant{
+		RID:           3003,
+		Name:          "Atomix",
+		City:          "New York",
+		TimeZone:      "America/New_York",
+		Address:       "104 E 30th St",
+		SeatingTypes:  []string{"Counter"},
+		DaysInAdvance: 28,
+		Deposit:       &opentabletest.DepositPolicy{Amount: 100, CancellationCutoffHours: 72},
+	})
+
+	venue, err := a.GetVenue(context.Background(), api.GetVenueParam{VenueID: 3003})
+	if err != nil {
+		t.Fatalf("GetVenue failed: %v", err)
+	}
+	if venue.Provider != api.ProviderOpenTable || venue.Name != "Atomix" || venue.Address != "104 E 30th St" || venue.SeatingTypes[0] != "Counter" {
+		t.Errorf("unexpected venue: %+v", venue)
+	}
+	// No release time means midnight
+	if w := venue.BookingWindow; w == nil || w.DaysInAdvance != 28 || w.ReleaseHour != 0 {
+		t.Errorf("unexpected booking window: %+v", venue.BookingWindow)
+	}
+	if f := venue.Fees; f == nil || f.DepositFee != 100 || f.CancellationCutoff != 72*time.Hour {
+		t.Errorf("unexpected fees: %+v", venue.Fees)
+	}
+
+	if _, err := a.GetVenue(context.Background(), api.GetVenueParam{VenueID: 9}); !errors.Is(err, api.ErrNoVenue) {
+		t.Errorf("expected ErrNoVenue, got %v", err)
+	}
+}
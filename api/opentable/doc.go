@@ -0,0 +1,227 @@
+/*
+**********************************************************************
+
+General Purpose:
+
+    The api/opentable pkg is an implementation of the 'API' interface
+    using OpenTable as an external reservation service. This
+    documentation covers how we map that api spec onto OpenTable.
+
+**********************************************************************
+
+General Overview of OpenTable API:
+
+    Like Resy, OpenTable has no public API, and what follows is
+    inferred from the JSON traffic of its mobile app. All bodies are
+    JSON over HTTPS, rooted at:
+
+        https://mobile-api.opentable.com
+
+    The root is the 'BaseURL' field of the API struct so tests can
+    point it at the fake server in api/opentable/opentabletest.
+
+    Unlike Resy, OpenTable books as a guest: there is no password.
+    Login registers the diner's name, email and phone number and
+    returns a guest token, which goes in every user specific request:
+
+        Headers:
+
+            Authorization: Bearer ###TOK###
+
+    Restaurants are identified by a numeric 'rid', which is what we
+    use as the VenueID. Times are sent and received as local
+    restaurant time in the "2006-01-02T15:04" layout, and the
+    restaurant's IANA timezone is given alongside them.
+
+**********************************************************************
+
+Login:
+
+    A POST to /api/v3/user/guest with the body:
+
+        {
+            "first_name": "###FST###",
+            "last_name": "###LST###",
+            "email": "###EM###",
+            "phone_number": "###MOB###"
+        }
+
+    All four LoginParam fields are required. A 401 means OpenTable
+    rejected the guest details. On success:
+
+        {
+            "gpid": ###UID###,
+            "token": "###TOK###",
+            "first_name": "###FST###",
+            "last_name": "###LST###",
+            "email": "###EM###",
+            "phone_number": "###MOB###"
+        }
+
+    OpenTable keeps card details per booking, so PaymentMethodID in
+    the LoginResponse is always 0.
+
+**********************************************************************
+
+Search:
+
+    A GET to /api/v3/restaurant/search?term=###NAME###&limit=###LIM###
+    with no auth. The optional filters travel as the query parameters
+    page, latitude, longitude, radius (meters), neighborhood, cuisine
+    and price_band. The response is:
+
+        {
+            "restaurants":
+                [
+                    {
+                        "rid": ###ID###,
+                        "name": "###NAME###",
+                        "metro": "###REG###",
+                        "city": "###LOC###",
+                        "neighborhood": "###NEIG###",
+                        "latitude": ###LAT###,
+                        "longitude": ###LNG###,
+                        "cuisines": ["###CUIS###", ...],
+                        "price_band": ###PRICE###
+                    },
+                    ...
+                ]
+        }
+
+**********************************************************************
+
+Availability:
+
+    A GET to /api/v3/restaurant/###ID###/availability?date=###DAY###&party_size=###PS###
+    where ###DAY### is YYYY-MM-DD. The response lists every open slot
+    that day:
+
+        {
+            "time_zone": "###TZ###",
+            "slots":
+                [
+                    {
+                        "date_time": "###YR###-###MT###-###DY###T###HR###:###MN###",
+                        "slot_hash": "###HASH###",
+                        "seating_type": "###TABLETYPE###",
+                        "credit_card_required": ###CC###,
+                        "deposit_amount": ###DEP###
+                    },
+                    ...
+                ]
+        }
+
+    ###HASH### plays the role of Resy's config token.
+
+**********************************************************************
+
+Reserve:
+
+    Reserve is two requests after an Availability lookup (one per
+    distinct day among the reservation times, in the restaurant's
+    time zone). Only availability states that zone, so the days are
+    first taken in NYC time and any others the zone implies are then
+    fetched too. A day whose lookup fails is skipped rather than
+    failing the others. First the chosen slot is locked with a POST
+    to /api/v3/reservation/lock:
+
+        {
+            "rid": ###ID###,
+            "slot_hash": "###HASH###",
+            "date_time": "###DATETIME###",
+            "party_size": ###PS###
+        }
+
+    A 409 means somebody else took the slot, and we move on to the
+    next candidate. Otherwise we get back {"lock_id": "###LOCK###"}
+    and confirm with a POST to /api/v3/reservation:
+
+        {
+            "lock_id": "###LOCK###",
+            "rid": ###ID###,
+            "date_time": "###DATETIME###",
+            "party_size": ###PS###
+        }
+
+    With an Occasion set, "occasion" is added to that body with its
+    name (e.g. "birthday"), and dietary and seating notes go in
+    "special_request" as one line, e.g. "Dietary: vegetarian".
+
+    Which answers with the confirmation:
+
+        {
+            "confirmation_number": "###CONF###",
+            "date_time": "###DATETIME###"
+        }
+
+    If the confirmation fails, the lock is given up with a DELETE to
+    /api/v3/reservation/lock/###LOCK### so the table isn't held until
+    the lock times out, from other diners or from our own next
+    attempt. A confirmation that fails with a 5xx, a dropped
+    connection or an unreadable body may still have booked the
+    table, so it ends the call with an UncertainBookError rather than
+    moving on to another slot. So does a 2xx without a number, which
+    keeps the lock as well.
+
+    The confirmation number is both the ReservationID and the
+    ReservationToken of the ReserveResponse, as it is what Cancel
+    takes. A lock holds the table from other diners, so a dry run stops
+    before it and returns the chosen slot. Booking terms come from
+    the slot's credit_card_required and deposit_amount, as OpenTable
+    states nothing more before booking.
+
+**********************************************************************
+
+Cancel and ListReservations:
+
+    A POST to /api/v3/reservation/###CONF###/cancel cancels a booking
+    and answers {"refund": ###REF###}. A GET to
+    /api/v3/user/reservations?type=upcoming lists bookings:
+
+        {
+            "reservations":
+                [
+                    {
+                        "confirmation_number": "###CONF###",
+                        "rid": ###ID###,
+                        "restaurant_name": "###NAME###",
+                        "time_zone": "###TZ###",
+                        "date_time": "###DATETIME###",
+                        "party_size": ###PS###,
+                        "seating_type": "###TABLETYPE###"
+                    },
+                    ...
+                ]
+        }
+
+**********************************************************************
+
+GetVenue:
+
+    A GET to /api/v3/restaurant/###ID### with no auth answers 404
+    for an unknown restaurant and otherwise:
+
+        {
+            "rid": ###ID###,
+            "name": "###NAME###",
+            "address": "###ADDR###",
+            "city": "###LOC###",
+            "time_zone": "###TZ###",
+            "seating_types": ["###TABLETYPE###", ...],
+            "max_days_in_advance": ###DAYS###,
+            "release_time": "###HR###:###MN###",
+            "deposit_policy":
+                {
+                    "deposit_amount": ###DEP###,
+                    "cancellation_fee": ###CXL###,
+                    "cancellation_cutoff_hours": ###HRS###,
+                    "description": "###TEXT###"
+                }
+        }
+
+    An empty release_time means new days open at midnight, and
+    deposit_policy is null for restaurants that don't take one.
+
+**********************************************************************
+*/
+package opentable
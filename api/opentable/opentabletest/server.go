@@ -0,0 +1,549 @@
+// Package opentabletest provides an in-memory fake of the OpenTable
+// mobile API for tests. Point opentable.API.BaseURL at Server.URL.
+package opentabletest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+// Restaurant is a venue the fake knows about
+type Restaurant struct {
+	RID          int64
+	Name         string
+	Metro        string
+	City         string
+	Neighborhood string
+	TimeZone     string
+	Latitude     float64
+	Longitude    float64
+	Cuisines     []string
+	PriceBand    int // 1 to 4
+
+	// Served by /api/v3/restaurant/{rid}
+	Address       string
+	SeatingTypes  []string
+	DaysInAdvance int    // 0 leaves the booking window out
+	ReleaseTime   string // "15:04" in TimeZone
+	Deposit       *DepositPolicy
+}
+
+// DepositPolicy is a restaurant's deposit and cancellation policy
+type DepositPolicy struct {
+	Amount                  float64
+	CancellationFee         float64
+	CancellationCutoffHours float64
+	Description             string
+}
+
+// Slot is an open time at a restaurant, DateTime in "2006-01-02T15:04" local time
+type Slot struct {
+	DateTime           string
+	SlotHash           string
+	SeatingType        string
+	PartySize          int // 0 matches any party size
+	CreditCardRequired bool
+	DepositAmount      float64
+}
+
+// Reservation is a booking made against the fake
+type Reservation struct {
+	ConfirmationNumber string
+	RID                int64
+	DateTime           string
+	PartySize          int
+	SeatingType        string
+	Token              string
+	Occasion           string // As sent with the booking, empty if none
+	SpecialRequest     string
+}
+
+// Server is a fake OpenTable. Seed it with AddRestaurant and inspect
+// bookings with Reservations
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	restaurants  map[int64]Restaurant
+	slots        map[int64][]Slot
+	locks        map[string]lock
+	reservations []Reservation
+	tokens       map[string]int64
+	nextID       int
+	failDates    map[string]bool // Availability for these dates answers 500
+	bookStatus   int             // Non-zero makes booking answer with this status
+	hideNumbers  bool            // Booking succeeds but answers without a confirmation number
+}
+
+// lock is a slot held off the market until it is booked or released
+type lock struct {
+	rid  int64
+	slot Slot
+}
+
+// NewServer starts a fake OpenTable server. Callers must Close it
+func NewServer() *Server {
+	s := &Server{
+		restaurants: make(map[int64]Restaurant),
+		slots:       make(map[int64][]Slot),
+		locks:       make(map[string]lock),
+		tokens:      make(map[string]int64),
+		failDates:   make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/user/guest", s.handleGuest)
+	mux.HandleFunc("/api/v3/user/reservations", s.handleListReservations)
+	mux.HandleFunc("/api/v3/restaurant/search", s.handleSearch)
+	mux.HandleFunc("/api/v3/restaurant/", s.handleAvailability)
+	mux.HandleFunc("/api/v3/reservation/lock", s.handleLock)
+	mux.HandleFunc("/api/v3/reservation/lock/", s.handleRelease)
+	mux.HandleFunc("/api/v3/reservation", s.handleBook)
+	mux.HandleFunc("/api/v3/reservation/", s.handleCancel)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// AddRestaurant registers a restaurant along with its open slots
+func (s *Server) AddRestaurant(r Restaurant, slots ...Slot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restaurants[r.RID] = r
+	s.slots[r.RID] = append(s.slots[r.RID], slots...)
+}
+
+// Reservations returns a copy of the bookings made so far
+func (s *Server) Reservations() []Reservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Reservation, len(s.reservations))
+	copy(out, s.reservations)
+	return out
+}
+
+// FailAvailability makes availability for a date answer 500
+func (s *Server) FailAvailability(date string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failDates[date] = true
+}
+
+// FailBooks makes every booking answer with status, locks still succeed.
+// Zero restores normal booking
+func (s *Server) FailBooks(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bookStatus = status
+}
+
+// HideConfirmations makes bookings go through but answer without a
+// confirmation number, as if the response were cut short
+func (s *Server) HideConfirmations(hide bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hideNumbers = hide
+}
+
+// Locks returns how many slots are locked but neither booked nor released
+func (s *Server) Locks() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.locks)
+}
+
+func (s *Server) id() int {
+	s.nextID++
+	return s.nextID
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+// authorized reports the guest a bearer token belongs to
+func (s *Server) authorized(r *http.Request) (int64, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gpid, ok := s.tokens[token]
+	return gpid, ok
+}
+
+func (s *Server) handleGuest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var in struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Email     string `json:"email"`
+		Phone     string `json:"phone_number"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Email == "" || in.Phone == "" {
+		writeError(w, http.StatusUnauthorized, "invalid guest details")
+		return
+	}
+
+	s.mu.Lock()
+	gpid := int64(s.id())
+	token := fmt.Sprintf("guest-token-%d", gpid)
+	s.tokens[token] = gpid
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"gpid":         gpid,
+		"token":        token,
+		"first_name":   in.FirstName,
+		"last_name":    in.LastName,
+		"email":        in.Email,
+		"phone_number": in.Phone,
+	})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	term := strings.ToLower(q.Get("term"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	page, _ := strconv.Atoi(q.Get("page"))
+	latitude, _ := strconv.ParseFloat(q.Get("latitude"), 64)
+	longitude, _ := strconv.ParseFloat(q.Get("longitude"), 64)
+	radius, _ := strconv.ParseFloat(q.Get("radius"), 64)
+	priceBand, _ := strconv.Atoi(q.Get("price_band"))
+
+	s.mu.Lock()
+	matched := make([]Restaurant, 0)
+	for _, rest := range s.restaurants {
+		if term != "" && !strings.Contains(strings.ToLower(rest.Name), term) {
+			continue
+		}
+		if n := q.Get("neighborhood"); n != "" && !strings.EqualFold(n, rest.Neighborhood) {
+			continue
+		}
+		if c := q.Get("cuisine"); c != "" && !containsFold(rest.Cuisines, c) {
+			continue
+		}
+		if priceBand > 0 && rest.PriceBand != priceBand {
+			continue
+		}
+		if radius > 0 && api.DistanceMeters(latitude, longitude, rest.Latitude, rest.Longitude) > radius {
+			continue
+		}
+		matched = append(matched, rest)
+	}
+	s.mu.Unlock()
+
+	// Restaurants live in a map, so order them for stable pages
+	sort.Slice(matched, func(i, j int) bool { return matched[i].RID < matched[j].RID })
+	if limit > 0 {
+		if page < 1 {
+			page = 1
+		}
+		from := (page - 1) * limit
+		if from > len(matched) {
+			from = len(matched)
+		}
+		to := from + limit
+		if to > len(matched) {
+			to = len(matched)
+		}
+		matched = matched[from:to]
+	}
+
+	results := make([]map[string]interface{}, 0, len(matched))
+	for _, rest := range matched {
+		results = append(results, map[string]interface{}{
+			"rid":          rest.RID,
+			"name":         rest.Name,
+			"metro":        rest.Metro,
+			"city":         rest.City,
+			"neighborhood": rest.Neighborhood,
+			"latitude":     rest.Latitude,
+			"longitude":    rest.Longitude,
+			"cuisines":     rest.Cuisines,
+			"price_band":   rest.PriceBand,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"restaurants": results})
+}
+
+// handleRestaurant serves /api/v3/restaurant/{rid}
+func (s *Server) handleRestaurant(w http.ResponseWriter, r *http.Request, ridStr string) {
+	rid, err := strconv.ParseInt(ridStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "unknown restaurant")
+		return
+	}
+
+	s.mu.Lock()
+	rest, ok := s.restaurants[rid]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown restaurant")
+		return
+	}
+
+	seatingTypes := rest.SeatingTypes
+	if seatingTypes == nil {
+		seatingTypes = []string{}
+	}
+	out := map[string]interface{}{
+		"rid":                 rest.RID,
+		"name":                rest.Name,
+		"address":             rest.Address,
+		"city":                rest.City,
+		"time_zone":           rest.TimeZone,
+		"seating_types":       seatingTypes,
+		"max_days_in_advance": rest.DaysInAdvance,
+		"release_time":        rest.ReleaseTime,
+		"deposit_policy":      nil,
+	}
+	if rest.Deposit != nil {
+		out["deposit_policy"] = map[string]interface{}{
+			"deposit_amount":            rest.Deposit.Amount,
+			"cancellation_fee":          rest.Deposit.CancellationFee,
+			"cancellation_cutoff_hours": rest.Deposit.CancellationCutoffHours,
+			"description":               rest.Deposit.Description,
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleAvailability(w http.ResponseWriter, r *http.Request) {
+	// /api/v3/restaurant/{rid}/availability
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v3/restaurant/"), "/")
+	if len(parts) == 1 {
+		s.handleRestaurant(w, r, parts[0])
+		return
+	}
+	if len(parts) != 2 || parts[1] != "availability" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	rid, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "unknown restaurant")
+		return
+	}
+	date := r.URL.Query().Get("date")
+	partySize, _ := strconv.Atoi(r.URL.Query().Get("party_size"))
+
+	s.mu.Lock()
+	if s.failDates[date] {
+		s.mu.Unlock()
+		writeError(w, http.StatusInternalServerError, "availability unavailable")
+		return
+	}
+	rest, ok := s.restaurants[rid]
+	var slots []map[string]interface{}
+	for _, slot := range s.slots[rid] {
+		if !strings.HasPrefix(slot.DateTime, date+"T") {
+			continue
+		}
+		if slot.PartySize != 0 && slot.PartySize != partySize {
+			continue
+		}
+		slots = append(slots, map[string]interface{}{
+			"date_time":            slot.DateTime,
+			"slot_hash":            slot.SlotHash,
+			"seating_type":         slot.SeatingType,
+			"credit_card_required": slot.CreditCardRequired,
+			"deposit_amount":       slot.DepositAmount,
+		})
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown restaurant")
+		return
+	}
+	if slots == nil {
+		slots = []map[string]interface{}{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"time_zone": rest.TimeZone,
+		"slots":     slots,
+	})
+}
+
+func (s *Server) handleLock(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authorized(r); !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	var in struct {
+		RID      int64  `json:"rid"`
+		SlotHash string `json:"slot_hash"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, "bad request")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, slot := range s.slots[in.RID] {
+		if slot.SlotHash == in.SlotHash {
+			// Locking takes the slot off the market
+			s.slots[in.RID] = append(s.slots[in.RID][:i:i], s.slots[in.RID][i+1:]...)
+			lockID := fmt.Sprintf("lock-%d", s.id())
+			s.locks[lockID] = lock{rid: in.RID, slot: slot}
+			writeJSON(w, http.StatusOK, map[string]string{"lock_id": lockID})
+			return
+		}
+	}
+	writeError(w, http.StatusConflict, "slot no longer available")
+}
+
+func (s *Server) handleRelease(w http.ResponseWriter, r *http.Request) {
+	// DELETE /api/v3/reservation/lock/{lock_id}
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if _, ok := s.authorized(r); !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	lockID := strings.TrimPrefix(r.URL.Path, "/api/v3/reservation/lock/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	held, ok := s.locks[lockID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "lock not found")
+		return
+	}
+	// Releasing puts the slot back on the market
+	delete(s.locks, lockID)
+	s.slots[held.rid] = append(s.slots[held.rid], held.slot)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleBook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if _, ok := s.authorized(r); !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	var in struct {
+		LockID         string `json:"lock_id"`
+		RID            int64  `json:"rid"`
+		PartySize      int    `json:"party_size"`
+		Occasion       string `json:"occasion"`
+		SpecialRequest string `json:"special_request"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, "bad request")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bookStatus != 0 {
+		writeError(w, s.bookStatus, "booking failed")
+		return
+	}
+	held, ok := s.locks[in.LockID]
+	if !ok {
+		writeError(w, http.StatusConflict, "lock expired")
+		return
+	}
+	slot := held.slot
+	delete(s.locks, in.LockID)
+	res := Reservation{
+		ConfirmationNumber: strconv.Itoa(100000 + s.id()),
+		RID:                in.RID,
+		DateTime:           slot.DateTime,
+		PartySize:          in.PartySize,
+		SeatingType:        slot.SeatingType,
+		Token:              strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "),
+		Occasion:           in.Occasion,
+		SpecialRequest:     in.SpecialRequest,
+	}
+	s.reservations = append(s.reservations, res)
+	if s.hideNumbers {
+		writeJSON(w, http.StatusOK, map[string]string{"date_time": res.DateTime})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"confirmation_number": res.ConfirmationNumber,
+		"date_time":           res.DateTime,
+	})
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	// /api/v3/reservation/{confirmation}/cancel
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v3/reservation/"), "/")
+	if len(parts) != 2 || parts[1] != "cancel" || r.Method != http.MethodPost {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, res := range s.reservations {
+		if res.ConfirmationNumber == parts[0] && res.Token == token {
+			s.reservations = append(s.reservations[:i:i], s.reservations[i+1:]...)
+			writeJSON(w, http.StatusOK, map[string]interface{}{"refund": false})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "reservation not found")
+}
+
+func (s *Server) handleListReservations(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authorized(r); !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	s.mu.Lock()
+	out := make([]map[string]interface{}, 0)
+	for _, res := range s.reservations {
+		if res.Token != token {
+			continue
+		}
+		out = append(out, map[string]interface{}{
+			"confirmation_number": res.ConfirmationNumber,
+			"rid":                 res.RID,
+			"restaurant_name":     s.restaurants[res.RID].Name,
+			"time_zone":           s.restaurants[res.RID].TimeZone,
+			"date_time":           res.DateTime,
+			"party_size":          res.PartySize,
+			"seating_type":        res.SeatingType,
+		})
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"reservations": out})
+}
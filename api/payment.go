@@ -0,0 +1,80 @@
+package api
+
+import (
+    "fmt"
+)
+
+/*
+Name: PaymentPolicy
+Type: API Input Struct
+Purpose: Per-request limits on the money a booking may commit the
+diner to. Reserve skips any slot whose BookingTerms go over them
+Note: A nil limit is no limit, which is the zero value, so callers
+that don't set a policy book as they always have. A MaxCancellationFee
+of 0 refuses every slot with a cancellation (no-show) fee
+*/
+type PaymentPolicy struct {
+    MaxDeposit         *float64
+    MaxCancellationFee *float64
+}
+
+/*
+Name: PaymentPolicy.Validate
+Type: API Func
+Purpose: Check the limits aren't negative
+*/
+func (p PaymentPolicy) Validate() (error) {
+    if p.MaxDeposit != nil && *p.MaxDeposit < 0 {
+        return fmt.Errorf("max deposit must not be negative")
+    }
+    if p.MaxCancellationFee != nil && *p.MaxCancellationFee < 0 {
+        return fmt.Errorf("max cancellation fee must not be negative")
+    }
+    return nil
+}
+
+/*
+Name: PaymentPolicy.Check
+Type: API Func
+Purpose: Return a PaymentPolicyError if booking a slot on these terms
+would go over a limit, nil if it's allowed or the terms are unknown
+*/
+func (p PaymentPolicy) Check(terms *BookingTerms) (error) {
+    if terms == nil {
+        return nil
+    }
+    if p.MaxDeposit != nil && terms.DepositFee > *p.MaxDeposit {
+        return NewPaymentPolicyError("deposit", terms.DepositFee, *p.MaxDeposit)
+    }
+    if p.MaxCancellationFee != nil && terms.CancellationFee > *p.MaxCancellationFee {
+        return NewPaymentPolicyError("cancellation fee", terms.CancellationFee, *p.MaxCancellationFee)
+    }
+    return nil
+}
+
+/*
+Name: PaymentPolicy.Select
+Type: API Func
+Purpose: Rank slots with a selector after leaving out those whose
+advertised terms (as given by terms) go over the limits, so the next
+best slot is tried instead. The error is a PaymentPolicyError for the
+best ranked slot left out, nil if every slot that fit the preferences
+was allowed
+*/
+func (p PaymentPolicy) Select(selector SlotSelector, slots []Slot, prefs SlotPreferences, terms func(Slot) *BookingTerms) ([]Slot, error) {
+    allowed := make([]Slot, 0, len(slots))
+    refused := make([]Slot, 0)
+    for _, slot := range slots {
+        if p.Check(terms(slot)) != nil {
+            refused = append(refused, slot)
+        } else {
+            allowed = append(allowed, slot)
+        }
+    }
+
+    var err error
+    if candidates := selector.Select(refused, prefs); len(candidates) > 0 {
+        err = p.Check(terms(candidates[0]))
+    }
+    return selector.Select(allowed, prefs), err
+}
@@ -0,0 +1,68 @@
+package api_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+func TestPaymentPolicyCheck(t *testing.T) {
+	twenty, zero := 20.0, 0.0
+
+	tests := []struct {
+		name   string
+		policy api.PaymentPolicy
+		terms  *api.BookingTerms
+		want   string // fee named in the error, empty if allowed
+	}{
+		{name: "no limits", policy: api.PaymentPolicy{}, terms: &api.BookingTerms{DepositFee: 500, CancellationFee: 100}},
+		{name: "unknown terms", policy: api.PaymentPolicy{MaxDeposit: &zero}, terms: nil},
+		{name: "deposit within limit", policy: api.PaymentPolicy{MaxDeposit: &twenty}, terms: &api.BookingTerms{DepositFee: 20}},
+		{name: "deposit over limit", policy: api.PaymentPolicy{MaxDeposit: &twenty}, terms: &api.BookingTerms{DepositFee: 25}, want: "deposit"},
+		{name: "no cancellation fee allowed", policy: api.PaymentPolicy{MaxCancellationFee: &zero}, terms: &api.BookingTerms{CancellationFee: 10}, want: "cancellation fee"},
+		{name: "card hold without fees", policy: api.PaymentPolicy{MaxDeposit: &zero, MaxCancellationFee: &zero}, terms: &api.BookingTerms{PaymentRequired: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.terms)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			var policyErr *api.PaymentPolicyError
+			if !errors.As(err, &policyErr) || !errors.Is(err, api.ErrPaymentPolicy) || policyErr.Fee != tt.want {
+				t.Fatalf("expected a %s PaymentPolicyError, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestPaymentPolicySelect(t *testing.T) {
+	twenty := 20.0
+	policy := api.PaymentPolicy{MaxDeposit: &twenty}
+	deposits := map[string]float64{"2026-11-20 19:00 Dining Room": 50}
+	terms := func(slot api.Slot) *api.BookingTerms {
+		return &api.BookingTerms{DepositFee: deposits[slot.ConfigToken]}
+	}
+	prefs := api.SlotPreferences{Times: []time.Time{at(t, "2026-11-20 19:00")}}
+
+	// The exact match asks too much, so the next closest is offered
+	slots := []api.Slot{slot(t, "2026-11-20 19:00", "Dining Room"), slot(t, "2026-11-20 19:15", "Dining Room")}
+	candidates, err := policy.Select(api.PrioritySelector{}, slots, prefs, terms)
+	assertOrder(t, candidates, []string{"2026-11-20 19:15 Dining Room"})
+	if !errors.Is(err, api.ErrPaymentPolicy) {
+		t.Errorf("expected the refused slot to be reported, got %v", err)
+	}
+
+	// A refused slot that wouldn't have been tried anyway isn't reported
+	slots = []api.Slot{slot(t, "2026-11-20 19:00", "Dining Room")}
+	prefs.Times = []time.Time{at(t, "2026-11-20 21:00")}
+	if candidates, err := policy.Select(api.PrioritySelector{}, slots, prefs, terms); len(candidates) != 0 || err != nil {
+		t.Errorf("expected nothing, got %v, %v", tokens(candidates), err)
+	}
+}
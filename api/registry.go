@@ -0,0 +1,156 @@
+package api
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+var (
+    ErrUnknownProvider = errors.New("unknown reservation provider")
+    ErrVenueRef = errors.New("invalid venue reference")
+)
+
+// Names of the reservation services we know how to talk to
+const (
+    ProviderResy      = "resy"
+    ProviderOpenTable = "opentable"
+
+    // DefaultProvider is assumed wherever a provider name is missing,
+    // since every record written before providers existed was Resy's
+    DefaultProvider   = ProviderResy
+)
+
+/*
+Name: Registry
+Type: External API Struct
+Purpose: Map provider names to the API implementation that
+talks to that service, so callers holding a provider name can
+dispatch without knowing about concrete packages
+Note: A Registry is safe for concurrent use. Looking up the
+empty name returns the DefaultProvider
+*/
+type Registry struct {
+    mu          sync.RWMutex
+    providers   map[string]API
+}
+
+/*
+Name: NewRegistry
+Type: External Func
+Purpose: Create an empty Registry
+*/
+func NewRegistry() (*Registry) {
+    return &Registry{providers: make(map[string]API)}
+}
+
+/*
+Name: Register
+Type: Registry Func
+Purpose: Make an API implementation available under a provider
+name, replacing whatever was registered there before
+*/
+func (r *Registry) Register(name string, impl API) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.providers[NormalizeProvider(name)] = impl
+}
+
+/*
+Name: Get
+Type: Registry Func
+Purpose: Look up the API implementation for a provider name.
+Returns ErrUnknownProvider if nothing is registered under it
+*/
+func (r *Registry) Get(name string) (API, error) {
+    name = NormalizeProvider(name)
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    impl, ok := r.providers[name]
+    if !ok {
+        return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+    }
+    return impl, nil
+}
+
+/*
+Name: Names
+Type: Registry Func
+Purpose: List registered provider names in sorted order
+*/
+func (r *Registry) Names() ([]string) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    names := make([]string, 0, len(r.providers))
+    for name := range r.providers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// Other spellings of provider names that users type
+var providerAliases = map[string]string{
+    "open_table":   ProviderOpenTable,
+    "open-table":   ProviderOpenTable,
+    "open table":   ProviderOpenTable,
+}
+
+/*
+Name: NormalizeProvider
+Type: External Func
+Purpose: Canonicalize a provider name, mapping the empty name
+to DefaultProvider and known aliases to the name they stand for
+*/
+func NormalizeProvider(name string) string {
+    name = strings.ToLower(strings.TrimSpace(name))
+    if name == "" {
+        return DefaultProvider
+    }
+    if canonical, ok := providerAliases[name]; ok {
+        return canonical
+    }
+    return name
+}
+
+/*
+Name: VenueRef
+Type: API Struct
+Purpose: Identify a venue across providers. Venue IDs are only
+unique within one service, so anything that stores or displays
+a venue alongside others should carry the provider too
+*/
+type VenueRef struct {
+    Provider    string
+    VenueID     int64
+}
+
+/*
+Name: String
+Type: VenueRef Func
+Purpose: Format a VenueRef as "provider:id", e.g. "opentable:1001"
+*/
+func (v VenueRef) String() (string) {
+    return NormalizeProvider(v.Provider) + ":" + strconv.FormatInt(v.VenueID, 10)
+}
+
+/*
+Name: ParseVenueRef
+Type: External Func
+Purpose: Parse a venue reference of the form "provider:id".
+A bare id is accepted and taken to be the DefaultProvider's
+*/
+func ParseVenueRef(s string) (VenueRef, error) {
+    provider, idStr, found := strings.Cut(strings.TrimSpace(s), ":")
+    if !found {
+        provider, idStr = "", provider
+    }
+    id, err := strconv.ParseInt(idStr, 10, 64)
+    if err != nil || id <= 0 {
+        return VenueRef{}, fmt.Errorf("%w: %q", ErrVenueRef, s)
+    }
+    return VenueRef{Provider: NormalizeProvider(provider), VenueID: id}, nil
+}
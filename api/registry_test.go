@@ -0,0 +1,120 @@
+package api_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+// namedAPI stands in for a provider, only its name is looked at
+type namedAPI struct {
+	api.API
+	name string
+}
+
+func TestNormalizeProvider(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", api.DefaultProvider},
+		{"   ", api.DefaultProvider},
+		{"resy", api.ProviderResy},
+		{"Resy", api.ProviderResy},
+		{" RESY ", api.ProviderResy},
+		{"OpenTable", api.ProviderOpenTable},
+		{"open_table", api.ProviderOpenTable},
+		{"Open-Table", api.ProviderOpenTable},
+		{"open table", api.ProviderOpenTable},
+		{"Tock", "tock"},
+	}
+
+	for _, tt := range tests {
+		if got := api.NormalizeProvider(tt.name); got != tt.want {
+			t.Errorf("NormalizeProvider(%q) = %q, expected %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := api.NewRegistry()
+	registry.Register("Resy", namedAPI{name: "resy"})
+	registry.Register("open-table", namedAPI{name: "opentable"})
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"resy", "resy"},
+		{"RESY", "resy"},
+		{"", "resy"},
+		{"OpenTable", "opentable"},
+		{"open_table", "opentable"},
+	}
+	for _, tt := range tests {
+		impl, err := registry.Get(tt.name)
+		if err != nil {
+			t.Errorf("Get(%q) failed: %v", tt.name, err)
+			continue
+		}
+		if got := impl.(namedAPI).name; got != tt.want {
+			t.Errorf("Get(%q) = %s, expected %s", tt.name, got, tt.want)
+		}
+	}
+
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{"opentable", "resy"}) {
+		t.Errorf("expected sorted canonical names, got %v", names)
+	}
+
+	registry.Register("resy", namedAPI{name: "replacement"})
+	if impl, _ := registry.Get("resy"); impl.(namedAPI).name != "replacement" {
+		t.Errorf("expected Register to replace the earlier implementation, got %v", impl)
+	}
+}
+
+func TestRegistry_UnknownProvider(t *testing.T) {
+	registry := api.NewRegistry()
+	registry.Register("resy", namedAPI{name: "resy"})
+
+	for _, name := range []string{"tock", "opentable"} {
+		if _, err := registry.Get(name); !errors.Is(err, api.ErrUnknownProvider) {
+			t.Errorf("Get(%q): expected ErrUnknownProvider, got %v", name, err)
+		}
+	}
+}
+
+func TestParseVenueRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want api.VenueRef
+	}{
+		{"resy:86907", api.VenueRef{Provider: api.ProviderResy, VenueID: 86907}},
+		{"OpenTable:1001", api.VenueRef{Provider: api.ProviderOpenTable, VenueID: 1001}},
+		{"open_table:1001", api.VenueRef{Provider: api.ProviderOpenTable, VenueID: 1001}},
+		{" 86907 ", api.VenueRef{Provider: api.DefaultProvider, VenueID: 86907}},
+		{":86907", api.VenueRef{Provider: api.DefaultProvider, VenueID: 86907}},
+	}
+	for _, tt := range tests {
+		got, err := api.ParseVenueRef(tt.ref)
+		if err != nil {
+			t.Errorf("ParseVenueRef(%q) failed: %v", tt.ref, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseVenueRef(%q) = %+v, expected %+v", tt.ref, got, tt.want)
+		}
+		if again, err := api.ParseVenueRef(got.String()); err != nil || again != got {
+			t.Errorf("expected %q to parse back to %+v, got %+v, %v", got.String(), got, again, err)
+		}
+	}
+}
+
+func TestParseVenueRef_Malformed(t *testing.T) {
+	for _, ref := range []string{"", "resy:", "resy:abc", "resy:-5", "resy:0", "opentable:1001:2", "resy:1.5", "resy"} {
+		if _, err := api.ParseVenueRef(ref); !errors.Is(err, api.ErrVenueRef) {
+			t.Errorf("ParseVenueRef(%q): expected ErrVenueRef, got %v", ref, err)
+		}
+	}
+}
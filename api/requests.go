@@ -0,0 +1,117 @@
+package api
+
+import (
+    "fmt"
+    "strings"
+    "unicode/utf8"
+)
+
+/*
+Name: Occasion
+Type: API Input Struct
+Purpose: What a booking is for, so the venue can mark it
+*/
+type Occasion string
+
+const (
+    NoOccasion   Occasion = ""
+    Birthday     Occasion = "birthday"
+    Anniversary  Occasion = "anniversary"
+    DateNight    Occasion = "date_night"
+    BusinessMeal Occasion = "business_meal"
+    Celebration  Occasion = "celebration"
+)
+
+/*
+Name: ParseOccasion
+Type: API Func
+Purpose: Validate an occasion name, "" meaning NoOccasion
+*/
+func ParseOccasion(name string) (Occasion, error) {
+    occasion := Occasion(strings.ToLower(strings.TrimSpace(name)))
+    switch occasion {
+    case NoOccasion, Birthday, Anniversary, DateNight, BusinessMeal, Celebration:
+        return occasion, nil
+    }
+    return NoOccasion, fmt.Errorf("unknown occasion %q, expected birthday, anniversary, date_night, business_meal or celebration", name)
+}
+
+/*
+Name: Occasion.Label
+Type: API Func
+Purpose: The occasion as a venue would read it, e.g. "Date night"
+*/
+func (o Occasion) Label() (string) {
+    label := strings.ReplaceAll(string(o), "_", " ")
+    if label == "" {
+        return ""
+    }
+    return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// MaxNoteLength is the most characters Dietary or Seating may hold
+const MaxNoteLength = 500
+
+/*
+Name: SpecialRequests
+Type: API Input Struct
+Purpose: What the diner wants the venue to know about a booking: the
+occasion, dietary requirements and allergies, and a seating request
+Note: The zero value asks for nothing, so callers that don't set it
+book as they always have. Seating is a wish passed on to the venue
+("a quiet corner"), unlike TableTypes which decides what is booked.
+Services that take a single free-text field get Note
+*/
+type SpecialRequests struct {
+    Occasion Occasion
+    Dietary  string
+    Seating  string
+}
+
+/*
+Name: SpecialRequests.Validate
+Type: API Func
+Purpose: Check the occasion is known and the notes aren't too long
+*/
+func (r SpecialRequests) Validate() (error) {
+    if _, err := ParseOccasion(string(r.Occasion)); err != nil {
+        return err
+    }
+    if utf8.RuneCountInString(r.Dietary) > MaxNoteLength {
+        return fmt.Errorf("dietary note must be at most %d characters", MaxNoteLength)
+    }
+    if utf8.RuneCountInString(r.Seating) > MaxNoteLength {
+        return fmt.Errorf("seating request must be at most %d characters", MaxNoteLength)
+    }
+    return nil
+}
+
+/*
+Name: SpecialRequests.IsZero
+Type: API Func
+Purpose: Report whether there is nothing to pass on
+*/
+func (r SpecialRequests) IsZero() (bool) {
+    return r.Occasion == NoOccasion && strings.TrimSpace(r.Dietary) == "" && strings.TrimSpace(r.Seating) == ""
+}
+
+/*
+Name: SpecialRequests.Note
+Type: API Func
+Purpose: Everything set, as one note for services with a single
+special request field, e.g. "Occasion: Birthday. Dietary: no
+shellfish. Seating: booth if possible"
+*/
+func (r SpecialRequests) Note() (string) {
+    var parts []string
+    if r.Occasion != NoOccasion {
+        parts = append(parts, "Occasion: " + r.Occasion.Label())
+    }
+    if dietary := strings.TrimSpace(r.Dietary); dietary != "" {
+        parts = append(parts, "Dietary: " + strings.TrimRight(dietary, "."))
+    }
+    if seating := strings.TrimSpace(r.Seating); seating != "" {
+        parts = append(parts, "Seating: " + strings.TrimRight(seating, "."))
+    }
+    return strings.Join(parts, ". ")
+}
@@ -0,0 +1,64 @@
+package api_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+func TestParseOccasion(t *testing.T) {
+	for name, want := range map[string]api.Occasion{
+		"":            api.NoOccasion,
+		"Birthday":    api.Birthday,
+		" date_night": api.DateNight,
+	} {
+		got, err := api.ParseOccasion(name)
+		if err != nil || got != want {
+			t.Errorf("ParseOccasion(%q) = %q, %v, expected %q", name, got, err, want)
+		}
+	}
+	if _, err := api.ParseOccasion("wedding"); err == nil {
+		t.Error("expected an unknown occasion to be rejected")
+	}
+}
+
+func TestSpecialRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests api.SpecialRequests
+		note     string
+		invalid  bool
+	}{
+		{name: "none", requests: api.SpecialRequests{}},
+		{name: "occasion only", requests: api.SpecialRequests{Occasion: api.DateNight}, note: "Occasion: Date night"},
+		{
+			name:     "everything",
+			requests: api.SpecialRequests{Occasion: api.Birthday, Dietary: " Nut allergy. ", Seating: "By the window"},
+			note:     "Occasion: Birthday. Dietary: Nut allergy. Seating: By the window",
+		},
+		{name: "unknown occasion", requests: api.SpecialRequests{Occasion: "wedding"}, invalid: true},
+		{name: "dietary too long", requests: api.SpecialRequests{Dietary: strings.Repeat("x", api.MaxNoteLength+1)}, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.requests.Validate()
+			if tt.invalid {
+				if err == nil {
+					t.Fatal("expected Validate to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate failed: %v", err)
+			}
+			if got := tt.requests.Note(); got != tt.note {
+				t.Errorf("expected note %q, got %q", tt.note, got)
+			}
+			if tt.requests.IsZero() != (tt.note == "") {
+				t.Errorf("IsZero disagrees with the note %q", tt.note)
+			}
+		})
+	}
+}
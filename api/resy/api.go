@@ -8,18 +8,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/21Bruce/resolved-server/api"
 	"github.com/21Bruce/resolved-server/config"
-	"github.com/21Bruce/resolved-server/store"
+	"github.com/21Bruce/resolved-server/egress"
+	"github.com/21Bruce/resolved-server/httprec"
 )
 
 /*
@@ -30,14 +33,31 @@ api interface.
 Note: The only known working APIKey value can be located and
 defaulted using the GetDefaultAPI function, but we leave
 it exposed so front-facing wrappers may expose it as a
-setting
+setting. BaseURL and Client are exposed so tests can point
+the client at a fake server such as api/resy/resytest.
+Timeouts sets the deadline for each step (see WithTimeouts).
+StrictSchema also rejects response fields we don't decode,
+which is only safe against a fake that sends nothing extra.
+Selector swaps how Reserve ranks slots. Recorder keeps what
+calls send and receive (see WithRecorder)
 */
 type API struct {
-	APIKey    string
-	Cookies   []*http.Cookie // Imperva cookies for bypassing WAF
-	UserAgent string         // User agent matching the cookies
+	APIKey       string
+	BaseURL      string            // Root of the Resy API, DefaultBaseURL if empty
+	Client       *http.Client      // Client for every request, a shared tuned one if nil
+	Timeouts     Timeouts          // Per-step deadlines, DefaultTimeouts for zero fields
+	Clock        *ClockSkew        // Fed from Calibrate and Prewarm responses if set
+	Egress       *egress.Pool      // Proxies to send requests through, direct if nil
+	Recorder     *httprec.Recorder // Records labelled attempts' traffic, off if nil
+	Cookies      []*http.Cookie    // Imperva cookies for venues with none stored, see SetCookies
+	UserAgent    string            // User agent matching Cookies
+	StrictSchema bool              // Treat unknown response fields as an api.SchemaError
+	Selector     api.SlotSelector  // Ranks slots in Reserve, api.PrioritySelector if nil
 }
 
+// DefaultBaseURL is the root of Resy's API
+const DefaultBaseURL = "https://api.resy.com"
+
 /*
 Name: isCodeFail
 Type: Internal Func
@@ -80,121 +100,110 @@ func min(a, b int) int {
 	return b
 }
 
-func truncateForLog(body []byte, max int) string {
-	if len(body) <= max {
-		return string(body)
+/*
+Name: endpoint
+Type: Internal Func
+Purpose: Join an API path such as "/4/find" onto the base URL
+*/
+func (a *API) endpoint(path string) string {
+	base := a.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
 	}
-	return string(body[:max]) + "..."
+	return strings.TrimRight(base, "/") + path
 }
 
 /*
-Name: SetCookies
-Type: API Func
-Purpose: Set Imperva cookies and user agent for the API client
+Name: httpClient
+Type: Internal Func
+Purpose: Return the injected http.Client, or the package's shared
+one so connections are reused across calls. Deadlines come from the
+per-step contexts rather than a client-wide timeout
 */
-func (a *API) SetCookies(cookies []*http.Cookie, userAgent string) {
-	a.Cookies = cookies
-	if userAgent != "" {
-		a.UserAgent = userAgent
-	} else {
-		// Default user agent if none provided
-		a.UserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+func (a *API) httpClient() *http.Client {
+	if a.Client != nil {
+		return a.recorded(a.Client)
 	}
+	return a.recorded(sharedClient)
 }
 
 /*
-Name: addCookiesToRequest
+Name: timeouts
 Type: Internal Func
-Purpose: Add Imperva cookies and user agent to HTTP request
+Purpose: Return the per-step deadlines with defaults filled in
 */
-func (a *API) addCookiesToRequest(req *http.Request) {
-	// Add cookies to request
-	if len(a.Cookies) > 0 {
-		for _, cookie := range a.Cookies {
-			req.AddCookie(cookie)
-		}
+func (a *API) timeouts() Timeouts {
+	return a.Timeouts.withDefaults()
+}
+
+/*
+Name: selector
+Type: Internal Func
+Purpose: Return the injected SlotSelector, or the original
+table type then time ordering
+*/
+func (a *API) selector() api.SlotSelector {
+	if a.Selector != nil {
+		return a.Selector
 	}
+	return api.PrioritySelector{}
+}
 
-	// Set user agent if available
-	if a.UserAgent != "" {
-		req.Header.Set("User-Agent", a.UserAgent)
+/*
+Name: stepError
+Type: Internal Func
+Purpose: Translate a failed request into the error the api layer
+expects. If the caller's context is done we report api.ErrCancelled,
+if only the step's own deadline elapsed we report a NetworkError,
+and anything else is passed through untouched
+*/
+func stepError(ctx context.Context, step string, err error) error {
+	if ctx.Err() != nil {
+		return api.NewCancelError(step, ctx.Err())
 	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return api.NewNetworkError(step, 0, "deadline exceeded")
+	}
+	return err
 }
 
 /*
-Name: extractCookiesFromResponse
+Name: bookError
 Type: Internal Func
-Purpose: Extract cookies from HTTP response headers and update API client cookies
+Purpose: Translate a book request that failed without a readable
+response. The request may well have reached Resy and booked the table,
+so it's an api.UncertainBookError unless Imperva turned it away first
+or the caller gave up
 */
-func (a *API) extractCookiesFromResponse(resp *http.Response) {
-	// Check if this is an Imperva response
-	if resp.Header.Get("X-Cdn") == "Imperva" || resp.Header.Get("Server") == "nginx" {
-		log.Printf("Imperva challenge detected, extracting cookies")
-
-		// Parse Set-Cookie headers
-		for _, cookieStr := range resp.Header.Values("Set-Cookie") {
-			// Parse the cookie string manually
-			parts := strings.Split(cookieStr, ";")
-			if len(parts) > 0 {
-				nameValue := strings.SplitN(parts[0], "=", 2)
-				if len(nameValue) == 2 {
-					cookieName := strings.TrimSpace(nameValue[0])
-					cookieValue := nameValue[1]
-
-					// Check if it's an Imperva cookie
-					if strings.HasPrefix(cookieName, "_incap_") ||
-						strings.HasPrefix(cookieName, "incap_ses_") ||
-						strings.HasPrefix(cookieName, "_visid_") ||
-						strings.HasPrefix(cookieName, "visid_incap_") ||
-						strings.HasPrefix(cookieName, "nlbi_") {
-
-						cookie := &http.Cookie{
-							Name:   cookieName,
-							Value:  cookieValue,
-							Domain: ".resy.com",
-							Path:   "/",
-						}
-
-						// Parse additional attributes
-						for i := 1; i < len(parts); i++ {
-							part := strings.TrimSpace(parts[i])
-							if strings.HasPrefix(strings.ToLower(part), "domain=") {
-								cookie.Domain = strings.TrimPrefix(part, "domain=")
-							} else if strings.HasPrefix(strings.ToLower(part), "path=") {
-								cookie.Path = strings.TrimPrefix(part, "path=")
-							} else if strings.ToLower(part) == "secure" {
-								cookie.Secure = true
-							} else if strings.ToLower(part) == "httponly" {
-								cookie.HttpOnly = true
-							} else if strings.HasPrefix(strings.ToLower(part), "expires=") {
-								// Parse expiration if needed
-								expiresStr := strings.TrimPrefix(part, "expires=")
-								if t, err := time.Parse(time.RFC1123, expiresStr); err == nil {
-									cookie.Expires = t
-								}
-							}
-						}
-
-						// Add or update cookie
-						found := false
-						for i, existingCookie := range a.Cookies {
-							if existingCookie.Name == cookie.Name {
-								a.Cookies[i] = cookie
-								found = true
-								break
-							}
-						}
-						if !found {
-							a.Cookies = append(a.Cookies, cookie)
-						}
-					}
-				}
-			}
-		}
+func bookError(ctx context.Context, slot *api.Slot, err error) error {
+	err = stepError(ctx, "book", err)
+	if errors.Is(err, api.ErrImperva) || errors.Is(err, api.ErrCancelled) {
+		return err
+	}
+	return api.NewUncertainBookError("book", slot, err)
+}
 
-		if len(a.Cookies) > 0 {
-			log.Printf("Updated cookies from Imperva response: %d cookies", len(a.Cookies))
-		}
+func truncateForLog(body []byte, max int) string {
+	if len(body) <= max {
+		return string(body)
+	}
+	return string(body[:max]) + "..."
+}
+
+/*
+Name: SetCookies
+Type: API Func
+Purpose: Set the Imperva cookies and user agent calls start from
+when their venue has none stored
+Note: Calls only read these, each keeping its own copy (see session),
+so set them before the API is shared, not while calls are running
+*/
+func (a *API) SetCookies(cookies []*http.Cookie, userAgent string) {
+	a.Cookies = cookies
+	if userAgent != "" {
+		a.UserAgent = userAgent
+	} else {
+		a.UserAgent = defaultUserAgent
 	}
 }
 
@@ -224,9 +233,10 @@ Name: doRequestWithRetry
 Type: Internal Func
 Purpose: Execute HTTP request with automatic retry on Imperva challenge
 Note: For POST requests, the bodyBytes should be provided to recreate the request on retry
-Returns api.ErrImperva if all retries fail due to Imperva challenge
+Returns api.ErrImperva if all retries fail due to Imperva challenge, or the
+context's error if ctx is done while waiting between retries
 */
-func (a *API) doRequestWithRetry(client *http.Client, req *http.Request, bodyBytes []byte, maxRetries int, venueID int64) (*http.Response, error) {
+func (a *API) doRequestWithRetry(ctx context.Context, client *http.Client, req *http.Request, bodyBytes []byte, maxRetries int, venueID int64) (*http.Response, error) {
 	// Store original headers for retry
 	originalHeaders := make(map[string][]string)
 	for key, values := range req.Header {
@@ -245,7 +255,7 @@ func (a *API) doRequestWithRetry(client *http.Client, req *http.Request, bodyByt
 			// Recreate request with body for POST requests
 			if bodyBytes != nil {
 				var err error
-				req, err = http.NewRequest(originalMethod, originalURL, bytes.NewBuffer(bodyBytes))
+				req, err = http.NewRequestWithContext(ctx, originalMethod, originalURL, bytes.NewBuffer(bodyBytes))
 				if err != nil {
 					return nil, fmt.Errorf("failed to recreate request: %w", err)
 				}
@@ -261,8 +271,12 @@ func (a *API) doRequestWithRetry(client *http.Client, req *http.Request, bodyByt
 			// Re-add cookies in case they were updated
 			a.addCookiesToRequest(req)
 
-			// Small delay before retry
-			time.Sleep(1 * time.Second)
+			// Small delay before retry, abandoned if the caller gives up
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(1 * time.Second):
+			}
 		}
 
 		resp, err := client.Do(req)
@@ -286,46 +300,41 @@ func (a *API) doRequestWithRetry(client *http.Client, req *http.Request, bodyByt
 				// Retries exhausted - return ErrImperva
 				resp.Body.Close()
 				log.Printf("Imperva challenge unresolved after %d retries", maxRetries+1)
+				a.rotateOnImperva(ctx)
 				return nil, api.ErrImperva
 			}
 		}
 
+		// Imperva accepted the cookies it handed out, worth keeping
+		if s := sessionFrom(ctx); s != nil && lastImpervaResponse {
+			s.refreshed.Store(true)
+		}
 		lastImpervaResponse = false
 		return resp, nil
 	}
 
 	if lastImpervaResponse {
+		a.rotateOnImperva(ctx)
 		return nil, api.ErrImperva
 	}
 	return nil, fmt.Errorf("max retries exceeded")
 }
 
-/*
-Name: LoadCookiesFromStore
-Type: API Func
-Purpose: Load cookies from Redis store for a venue
-*/
-func (a *API) LoadCookiesFromStore(venueID int64) error {
-	ctx := context.Background()
-	cookieData, err := store.GetCookies(ctx, venueID)
-	if err != nil {
-		return err
-	}
-	a.SetCookies(cookieData.Cookies, cookieData.UserAgent)
-	log.Printf("Loaded %d cookies for venue %d", len(cookieData.Cookies), venueID)
-	return nil
-}
-
 /*
 Name: GetDefaultAPI
 Type: External Func
 Purpose: Function that provides an out of the box
-working API struct
+working API struct, adjusted by any options
 */
-func GetDefaultAPI() API {
-	return API{
-		APIKey: config.Get().ResyAPIKey,
+func GetDefaultAPI(opts ...Option) API {
+	a := API{
+		APIKey:  config.Get().ResyAPIKey,
+		BaseURL: DefaultBaseURL,
+	}
+	for _, opt := range opts {
+		opt(&a)
 	}
+	return a
 }
 
 /*
@@ -335,14 +344,19 @@ Purpose: Resy implementation of the Login api func
 Note: The only required login fields for this func
 are Email and Password.
 */
-func (a *API) Login(params api.LoginParam) (*api.LoginResponse, error) {
-	authUrl := "https://api.resy.com/3/auth/password"
+func (a *API) Login(ctx context.Context, params api.LoginParam) (*api.LoginResponse, error) {
+	ctx = a.begin(ctx, 0, "")
+	defer a.end(ctx)
+	authUrl := a.endpoint("/3/auth/password")
 	email := url.QueryEscape(params.Email)
 	password := url.QueryEscape(params.Password)
 	bodyStr := `email=` + email + `&password=` + password
 	bodyBytes := []byte(bodyStr)
 
-	request, err := http.NewRequest("POST", authUrl, bytes.NewBuffer(bodyBytes))
+	loginCtx, loginCancel := context.WithTimeout(ctx, a.timeouts().Login)
+	defer loginCancel()
+
+	request, err := http.NewRequestWithContext(loginCtx, "POST", authUrl, bytes.NewBuffer(bodyBytes))
 	if err != nil {
 		return nil, err
 	}
@@ -353,11 +367,10 @@ func (a *API) Login(params api.LoginParam) (*api.LoginResponse, error) {
 	// Add Imperva cookies and user agent
 	a.addCookiesToRequest(request)
 
-	client := &http.Client{}
-	response, err := client.Do(request)
+	response, err := a.clientFor(ctx).Do(request)
 
 	if err != nil {
-		return nil, err
+		return nil, stepError(ctx, "login", err)
 	}
 
 	defer response.Body.Close()
@@ -367,54 +380,150 @@ func (a *API) Login(params api.LoginParam) (*api.LoginResponse, error) {
 		return nil, api.ErrLoginWrong
 	}
 
-	if isCodeFail(response.StatusCode) {
-		return nil, api.ErrNetwork
-	}
-
 	responseBody, err := io.ReadAll(response.Body)
 
 	if err != nil {
-		return nil, err
+		return nil, stepError(ctx, "login", err)
 	}
 
-	var jsonMap map[string]interface{}
-	err = json.Unmarshal(responseBody, &jsonMap)
-	if err != nil {
+	if isCodeFail(response.StatusCode) {
+		return nil, responseError("login", response, responseBody)
+	}
+
+	var auth authResponse
+	if err := decodePayload("login", responseBody, &auth, a.StrictSchema); err != nil {
 		return nil, err
 	}
 
-	if jsonMap["payment_method_id"] == nil {
+	if auth.PaymentMethodID == nil {
 		return nil, api.ErrNoPayInfo
 	}
 
 	loginResponse := api.LoginResponse{
-		ID:              int64(jsonMap["id"].(float64)),
-		FirstName:       jsonMap["first_name"].(string),
-		LastName:        jsonMap["last_name"].(string),
-		Mobile:          jsonMap["mobile_number"].(string),
-		Email:           jsonMap["em_address"].(string),
-		PaymentMethodID: int64(jsonMap["payment_method_id"].(float64)),
-		AuthToken:       jsonMap["token"].(string),
+		ID:              auth.ID,
+		FirstName:       auth.FirstName,
+		LastName:        auth.LastName,
+		Mobile:          auth.MobileNumber,
+		Email:           auth.EmAddress,
+		PaymentMethodID: *auth.PaymentMethodID,
+		AuthToken:       auth.Token,
 	}
 
 	return &loginResponse, nil
 
 }
 
+/*
+Name: maxSearchPages
+Type: Internal Const
+Purpose: How many of Resy's pages one filtered Search reads at most
+while looking for Limit matches
+*/
+const maxSearchPages = 5
+
 /*
 Name: Search
 Type: API Func
 Purpose: Resy implementation of the Search api func
+Note: Resy's venue search only understands a name and a location, so
+neighborhood, cuisine and price band are filtered here. With any of
+them set, a page of Limit hits can hold fewer than Limit matches, so
+Search keeps reading Resy's pages from Page on until Limit venues
+match, a page comes back short or maxSearchPages have been read.
+Page therefore counts Resy's unfiltered pages, and Offset skips the
+matches of Page an earlier response already returned. NextPage and
+NextOffset say where the results stopped, so passing them back pages
+through the matches without repeating or missing any
 */
-func (a *API) Search(params api.SearchParam) (*api.SearchResponse, error) {
-	searchUrl := "https://api.resy.com/3/venuesearch/search"
+func (a *API) Search(ctx context.Context, params api.SearchParam) (*api.SearchResponse, error) {
+	ctx = a.begin(ctx, 0, "")
+	defer a.end(ctx)
 
-	bodyStr := `{"query":"` + params.Name + `"}`
-	bodyBytes := []byte(bodyStr)
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	page, pages := params.Page, 1
+	if page < 1 {
+		page = 1
+	}
+	if params.Limit > 0 && (params.Neighborhood != "" || params.Cuisine != "" || params.PriceBand > 0) {
+		pages = maxSearchPages
+	}
+
+	// Matches are taken in Resy's order, so a cursor into a page stays
+	// valid; the Limit is applied here rather than by the filter
+	unlimited := params
+	unlimited.Limit = 0
+
+	var searchResponse api.SearchResponse
+	var matches []api.SearchResult
+	offset := params.Offset
+	for i := 0; i < pages; i++ {
+		hits, more, err := a.searchPage(ctx, params, page+i)
+		if err != nil {
+			return nil, err
+		}
+		pageMatches := api.FilterSearchResults(unlimited, hits)
+		if offset > len(pageMatches) {
+			offset = len(pageMatches)
+		}
+		pageMatches = pageMatches[offset:]
+
+		if need := params.Limit - len(matches); params.Limit > 0 && len(pageMatches) > need {
+			// The rest of this page is for the next response
+			matches = append(matches, pageMatches[:need]...)
+			searchResponse.NextPage, searchResponse.NextOffset = page+i, offset+need
+			break
+		}
+		matches = append(matches, pageMatches...)
+		offset = 0
+		if !more {
+			searchResponse.NextPage = 0
+			break
+		}
+		searchResponse.NextPage = page + i + 1
+		if params.Limit > 0 && len(matches) >= params.Limit {
+			break
+		}
+	}
 
-	request, err := http.NewRequest("POST", searchUrl, bytes.NewBuffer(bodyBytes))
+	searchResponse.Results = api.FilterSearchResults(params, matches)
+	return &searchResponse, nil
+}
+
+/*
+Name: searchPage
+Type: Internal Func
+Purpose: Fetch one page of Resy's venue search, unfiltered. The bool
+reports whether the page came back full, so a next one may exist
+*/
+func (a *API) searchPage(ctx context.Context, params api.SearchParam, page int) ([]api.SearchResult, bool, error) {
+	searchUrl := a.endpoint("/3/venuesearch/search")
+
+	requestBody := searchRequest{
+		Query:   params.Name,
+		PerPage: params.Limit,
+		Page:    page,
+	}
+	if params.HasLocation() {
+		requestBody.Geo = &searchGeo{
+			Latitude:  params.Latitude,
+			Longitude: params.Longitude,
+			Radius:    params.RadiusMeters,
+		}
+	}
+	bodyBytes, err := json.Marshal(requestBody)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+
+	searchCtx, searchCancel := context.WithTimeout(ctx, a.timeouts().Search)
+	defer searchCancel()
+
+	request, err := http.NewRequestWithContext(searchCtx, "POST", searchUrl, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, false, err
 	}
 
 	request.Header.Set("Content-Type", "application/json")
@@ -425,11 +534,10 @@ func (a *API) Search(params api.SearchParam) (*api.SearchResponse, error) {
 	// Add Imperva cookies and user agent
 	a.addCookiesToRequest(request)
 
-	client := &http.Client{}
-	response, err := client.Do(request)
+	response, err := a.clientFor(ctx).Do(request)
 
 	if err != nil {
-		return nil, err
+		return nil, false, stepError(ctx, "search", err)
 	}
 
 	defer response.Body.Close()
@@ -437,136 +545,74 @@ func (a *API) Search(params api.SearchParam) (*api.SearchResponse, error) {
 	if isCodeFail(response.StatusCode) {
 		responseBody, _ := io.ReadAll(response.Body)
 		log.Printf("Search failed: status %d, body: %s", response.StatusCode, truncateForLog(responseBody, 200))
-		return nil, api.ErrNetwork
+		return nil, false, responseError("search", response, responseBody)
 	}
 
 	responseBody, err := io.ReadAll(response.Body)
 	if err != nil {
-		return nil, err
-	}
-
-	var jsonTopLevelMap map[string]interface{}
-	err = json.Unmarshal(responseBody, &jsonTopLevelMap)
-	if err != nil {
-		log.Printf("Search unmarshal error: %v", err)
-		return nil, err
-	}
-
-	// Check if "search" key exists
-	searchValue, ok := jsonTopLevelMap["search"]
-	if !ok {
-		log.Printf("Search response missing 'search' key")
-		return nil, api.ErrNetwork
-	}
-
-	jsonSearchMap, ok := searchValue.(map[string]interface{})
-	if !ok {
-		log.Printf("Search response 'search' is not a map")
-		return nil, api.ErrNetwork
-	}
-
-	// Check if "hits" key exists
-	hitsValue, ok := jsonSearchMap["hits"]
-	if !ok {
-		log.Printf("Search response missing 'hits' key")
-		return nil, api.ErrNetwork
-	}
-
-	jsonHitsMap, ok := hitsValue.([]interface{})
-	if !ok {
-		log.Printf("Search response 'hits' is not an array")
-		return nil, api.ErrNetwork
+		return nil, false, stepError(ctx, "search", err)
 	}
 
-	numHits := len(jsonHitsMap)
-
-	// if input param limit is nonnegative, limit the search loop
-	var limit int
-	if params.Limit > 0 {
-		limit = min(params.Limit, numHits)
-	} else {
-		limit = numHits
+	var found searchResponse
+	if err := decodePayload("search", responseBody, &found, a.StrictSchema); err != nil {
+		return nil, false, err
 	}
 
-	searchResults := make([]api.SearchResult, 0, limit)
-	for i := 0; i < limit; i++ {
-		jsonHitMap, ok := jsonHitsMap[i].(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		// Safely extract fields with nil checks
-		objectID, ok := jsonHitMap["objectID"].(string)
-		if !ok {
-			continue
-		}
-
-		venueID, err := strconv.ParseInt(objectID, 10, 64)
+	searchResults := make([]api.SearchResult, 0, len(found.Search.Hits))
+	for _, hit := range found.Search.Hits {
+		venueID, err := strconv.ParseInt(hit.ObjectID, 10, 64)
 		if err != nil {
 			continue
 		}
 
-		name, _ := jsonHitMap["name"].(string)
-		region, _ := jsonHitMap["region"].(string)
-		locality, _ := jsonHitMap["locality"].(string)
-		neighborhood, _ := jsonHitMap["neighborhood"].(string)
-
-		searchResults = append(searchResults, api.SearchResult{
+		result := api.SearchResult{
+			Provider:     api.ProviderResy,
 			VenueID:      venueID,
-			Name:         name,
-			Region:       region,
-			Locality:     locality,
-			Neighborhood: neighborhood,
-		})
-	}
-
-	searchResponse := api.SearchResponse{
-		Results: searchResults,
+			Name:         hit.Name,
+			Region:       hit.Region,
+			Locality:     hit.Locality,
+			Neighborhood: hit.Neighborhood,
+			Cuisines:     hit.Cuisine,
+			PriceBand:    hit.PriceRangeID,
+		}
+		if hit.GeoLoc != nil {
+			result.Latitude = hit.GeoLoc.Lat
+			result.Longitude = hit.GeoLoc.Lng
+		}
+		searchResults = append(searchResults, result)
 	}
 
-	return &searchResponse, nil
+	return searchResults, params.Limit > 0 && len(found.Search.Hits) >= params.Limit, nil
 }
 
 /*
-Name: Reserve
-Type: API Func
-Purpose: Resy implementation of the Reserve api func
+Name: venueLocation
+Type: Internal Func
+Purpose: Resy reports slot times in the venue's local timezone,
+which for every venue we support is NYC
 */
-func (a *API) Reserve(params api.ReserveParam) (*api.ReserveResponse, error) {
-	if len(params.ReservationTimes) == 0 {
-		return nil, api.ErrTimeNull
-	}
-
-	// Try to load cookies from Redis store for this venue
-	if err := a.LoadCookiesFromStore(params.VenueID); err != nil {
-		log.Printf("Warning: cookies not found for venue %d: %v", params.VenueID, err)
-		// Continue anyway - cookies might have been set manually or we'll get Imperva error
-	}
-
-	// Converting fields to URL query format
-	// IMPORTANT: Convert to NYC timezone before extracting date components
-	// The reservation time is stored in UTC, but Resy expects the date in NYC timezone
-	nycLocation, err := time.LoadLocation("America/New_York")
+func venueLocation() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
 	if err != nil {
-		nycLocation = time.UTC
+		return time.UTC
 	}
-	reservationTimeNYC := params.ReservationTimes[0].In(nycLocation)
-
-	year := strconv.Itoa(reservationTimeNYC.Year())
-	monthInt := int(reservationTimeNYC.Month())
-	dayInt := reservationTimeNYC.Day()
-
-	// Zero-pad month and day
-	month := fmt.Sprintf("%02d", monthInt)
-	day := fmt.Sprintf("%02d", dayInt)
-
-	date := year + "-" + month + "-" + day
+	return loc
+}
 
+/*
+Name: find
+Type: Internal Func
+Purpose: Perform the 'find' step for a venue on a single day (YYYY-MM-DD
+in the venue's timezone) and return its open slots in typed form.
+Returns api.ErrNoOffer if Resy has no listing for the venue that day.
+Note: authToken may be empty, Resy serves anonymous find requests
+*/
+func (a *API) find(ctx context.Context, client *http.Client, venueID int64, day string, partySize int, authToken string) ([]api.Slot, error) {
 	// Use JSON body for find request (Resy API expects application/json)
 	requestBody := map[string]interface{}{
-		"day":        date,
-		"venue_id":   params.VenueID,
-		"party_size": params.PartySize,
+		"day":        day,
+		"venue_id":   venueID,
+		"party_size": partySize,
 		"lat":        0,
 		"long":       0,
 	}
@@ -575,9 +621,12 @@ func (a *API) Reserve(params api.ReserveParam) (*api.ReserveResponse, error) {
 		return nil, err
 	}
 
-	findUrl := "https://api.resy.com/4/find"
+	findUrl := a.endpoint("/4/find")
+
+	findCtx, findCancel := context.WithTimeout(ctx, a.timeouts().Find)
+	defer findCancel()
 
-	request, err := http.NewRequest("POST", findUrl, bytes.NewBuffer(bodyBytes))
+	request, err := http.NewRequestWithContext(findCtx, "POST", findUrl, bytes.NewBuffer(bodyBytes))
 	if err != nil {
 		return nil, err
 	}
@@ -585,25 +634,20 @@ func (a *API) Reserve(params api.ReserveParam) (*api.ReserveResponse, error) {
 	// Setting headers - Important: User-Agent needed to bypass Imperva WAF
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("Authorization", `ResyAPI api_key="`+a.APIKey+`"`)
-	request.Header.Set("X-Resy-Auth-Token", params.LoginResp.AuthToken)
-	request.Header.Set("X-Resy-Universal-Auth-Token", params.LoginResp.AuthToken)
+	if authToken != "" {
+		request.Header.Set("X-Resy-Auth-Token", authToken)
+		request.Header.Set("X-Resy-Universal-Auth-Token", authToken)
+	}
 	request.Header.Set("Referer", "https://resy.com/")
 	request.Header.Set("Origin", "https://resy.com")
 
-	// Add Imperva cookies and user agent (will override default User-Agent if set)
+	// Add Imperva cookies and user agent
 	a.addCookiesToRequest(request)
 
-	// Fallback to default User-Agent if not set via cookies
-	if a.UserAgent == "" {
-		request.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	}
-
-	client := &http.Client{Timeout: 12 * time.Second}
-
 	// Use retry logic for Imperva challenges (pass bodyBytes to recreate request on retry, and venueID for fallback)
-	response, err := a.doRequestWithRetry(client, request, bodyBytes, 2, params.VenueID)
+	response, err := a.doRequestWithRetry(findCtx, client, request, bodyBytes, 2, venueID)
 	if err != nil {
-		return nil, err
+		return nil, stepError(ctx, "find", err)
 	}
 
 	defer response.Body.Close()
@@ -611,400 +655,765 @@ func (a *API) Reserve(params api.ReserveParam) (*api.ReserveResponse, error) {
 	// Always read the response body, even on error, to see what the API says
 	responseBody, err := io.ReadAll(response.Body)
 	if err != nil {
-		return nil, err
+		return nil, stepError(ctx, "find", err)
 	}
 
 	if isCodeFail(response.StatusCode) {
-		errorMsg := truncateForLog(responseBody, 200)
-		var errorMap map[string]interface{}
-		if json.Unmarshal(responseBody, &errorMap) == nil {
-			if message, ok := errorMap["message"].(string); ok {
-				errorMsg = message
+		return nil, responseError("find", response, responseBody)
+	}
+
+	var found findResponse
+	if err := decodePayload("find", responseBody, &found, a.StrictSchema); err != nil {
+		return nil, err
+	}
+
+	if len(found.Results.Venues) == 0 {
+		return nil, api.ErrNoOffer
+	}
+
+	// Find the venue that matches the requested venue ID, falling back
+	// to the first one
+	venueIndex := 0
+	for i, venue := range found.Results.Venues {
+		if venue.Venue.ID.Resy == venueID {
+			venueIndex = i
+			break
+		}
+	}
+	venue := found.Results.Venues[venueIndex]
+
+	loc := venueLocation()
+	slots := make([]api.Slot, 0, len(venue.Slots))
+	for j, rawSlot := range venue.Slots {
+		// NOTE: Resy API returns times in the venue's local timezone (NYC), not UTC.
+		// Seconds are dropped, slots always start on the minute
+		slotTime, err := time.ParseInLocation("2006-01-02 15:04:05", rawSlot.Date.Start, loc)
+		if err != nil {
+			path := fmt.Sprintf("results.venues[%d].slots[%d].date.start", venueIndex, j)
+			return nil, api.NewSchemaError("find", path, fmt.Sprintf("unparseable time %q", rawSlot.Date.Start))
+		}
+		slotTime = slotTime.Truncate(time.Minute)
+
+		// A slot without a config token can't be booked, so it isn't offered
+		if rawSlot.Config.Token == "" {
+			continue
+		}
+
+		slot := api.Slot{
+			Start:       slotTime,
+			TableType:   rawSlot.Config.Type,
+			ConfigToken: rawSlot.Config.Token,
+		}
+
+		if rawSlot.Payment != nil {
+			slot.Payment = &api.SlotPayment{
+				IsPaid:          rawSlot.Payment.IsPaid,
+				DepositFee:      rawSlot.Payment.DepositFee,
+				CancellationFee: rawSlot.Payment.CancellationFee,
 			}
 		}
-		return nil, api.NewNetworkError("find", response.StatusCode, errorMsg)
+
+		slots = append(slots, slot)
+	}
+
+	return slots, nil
+}
+
+/*
+Name: closedOn
+Type: Internal Func
+Purpose: Report whether Resy's calendar lists the venue as closed on
+every one of the given days ("2006-01-02"). Anything that stops us
+telling counts as not closed, so callers keep the error they had
+*/
+func (a *API) closedOn(ctx context.Context, client *http.Client, venueID int64, partySize int, days []string) bool {
+	if len(days) == 0 {
+		return false
+	}
+	sorted := append([]string(nil), days...)
+	sort.Strings(sorted)
+
+	query := url.Values{}
+	query.Set("venue_id", strconv.FormatInt(venueID, 10))
+	query.Set("num_seats", strconv.Itoa(partySize))
+	query.Set("start_date", sorted[0])
+	query.Set("end_date", sorted[len(sorted)-1])
+
+	calendarCtx, calendarCancel := context.WithTimeout(ctx, a.timeouts().Other)
+	defer calendarCancel()
+
+	request, err := http.NewRequestWithContext(calendarCtx, "GET", a.endpoint("/4/venue/calendar?"+query.Encode()), nil)
+	if err != nil {
+		return false
+	}
+	request.Header.Set("Authorization", `ResyAPI api_key="`+a.APIKey+`"`)
+	request.Header.Set("Referer", "https://resy.com/")
+	request.Header.Set("Origin", "https://resy.com")
+	a.addCookiesToRequest(request)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return false
+	}
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil || isCodeFail(response.StatusCode) {
+		return false
+	}
+
+	var calendar calendarResponse
+	if err := decodePayload("calendar", responseBody, &calendar, a.StrictSchema); err != nil {
+		return false
+	}
+
+	reservations := make(map[string]string, len(calendar.Scheduled))
+	for _, day := range calendar.Scheduled {
+		reservations[day.Date] = day.Inventory.Reservation
+	}
+	for _, day := range days {
+		if reservations[day] != "closed" {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Name: Availability
+Type: API Func
+Purpose: Resy implementation of the Availability api func
+Note: This only performs the 'find' step of a reservation,
+nothing is held or booked
+*/
+func (a *API) Availability(ctx context.Context, params api.AvailabilityParam) (*api.AvailabilityResponse, error) {
+	ctx = a.begin(ctx, params.VenueID, params.LoginResp.AuthToken)
+	defer a.end(ctx)
+	if _, err := time.Parse("2006-01-02", params.Date); err != nil {
+		return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", params.Date)
 	}
 
-	var jsonTopLevelMap map[string]interface{}
-	err = json.Unmarshal(responseBody, &jsonTopLevelMap)
+	slots, err := a.find(ctx, a.clientFor(ctx), params.VenueID, params.Date, params.PartySize, params.LoginResp.AuthToken)
+	if err == api.ErrNoOffer {
+		// Nothing listed that day is still a valid (empty) answer
+		return &api.AvailabilityResponse{Slots: []api.Slot{}}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	jsonResultsMap, ok := jsonTopLevelMap["results"].(map[string]interface{})
-	if !ok {
-		return nil, api.NewNetworkError("find", 0, "invalid response: 'results' key not found")
+	return &api.AvailabilityResponse{Slots: slots}, nil
+}
+
+/*
+Name: bookingTerms
+Type: Internal Func
+Purpose: Combine what the find step advertised for a slot with the
+fee and cancellation rules the details step states, the latter
+winning where both are given
+Note: An unreadable date_cut_off only loses the cutoff, it isn't
+worth failing a booking over
+*/
+func bookingTerms(slot api.Slot, details detailsResponse) *api.BookingTerms {
+	terms := &api.BookingTerms{}
+	if slot.Payment != nil {
+		terms.PaymentRequired = slot.Payment.IsPaid
+		terms.DepositFee = slot.Payment.DepositFee
+		terms.CancellationFee = slot.Payment.CancellationFee
+	}
+
+	if details.Payment != nil {
+		if details.Payment.Amount > 0 {
+			terms.DepositFee = details.Payment.Amount
+		}
+		if details.Payment.Config != nil && details.Payment.Config.Type != "" && details.Payment.Config.Type != "free" {
+			terms.PaymentRequired = true
+		}
 	}
 
-	jsonVenuesList, ok := jsonResultsMap["venues"].([]interface{})
-	if !ok {
-		return nil, api.NewNetworkError("find", 0, "invalid response: 'venues' key not found")
+	if details.Cancellation != nil {
+		if fee := details.Cancellation.Fee; fee != nil {
+			if fee.Amount > 0 {
+				terms.CancellationFee = fee.Amount
+			}
+			if cutoff, err := time.Parse(time.RFC3339, fee.DateCutOff); err == nil {
+				terms.CancellationCutoff = &cutoff
+			}
+		}
+		if display := details.Cancellation.Display; display != nil {
+			terms.Policy = strings.Join(display.Policy, " ")
+		}
+	}
+
+	if terms.DepositFee > 0 || terms.CancellationFee > 0 {
+		terms.PaymentRequired = true
+	}
+	return terms
+}
+
+/*
+Name: Reserve
+Type: API Func
+Purpose: Resy implementation of the Reserve api func
+Note: With params.DryRun set, find runs as usual and details is
+asked with commit=0, which quotes the slot's terms without holding
+the table, and the slot that would have been booked is returned
+*/
+func (a *API) Reserve(ctx context.Context, params api.ReserveParam) (*api.ReserveResponse, error) {
+	ctx = a.begin(ctx, params.VenueID, params.LoginResp.AuthToken)
+	defer a.end(ctx)
+	if len(params.ReservationTimes) == 0 {
+		return nil, api.ErrTimeNull
+	}
+	if err := params.Window.Validate(); err != nil {
+		return nil, err
+	}
+	if err := params.Payment.Validate(); err != nil {
+		return nil, err
+	}
+	if err := params.Requests.Validate(); err != nil {
+		return nil, err
 	}
 
-	if len(jsonVenuesList) == 0 {
+	// IMPORTANT: Convert to NYC timezone before extracting date components
+	// The reservation time is stored in UTC, but Resy expects the date in NYC timezone
+	nycLocation := venueLocation()
+
+	client := a.clientFor(ctx)
+
+	// The times may span several days, find each day once and pool the
+	// slots so the priority loop below can match against any of them. A
+	// day that fails to load is skipped, its error is only returned if
+	// no day had a slot
+	var slots []api.Slot
+	var dayErr error
+	offered := false
+	for _, date := range params.Dates(nycLocation) {
+		daySlots, err := a.find(ctx, client, params.VenueID, date, params.PartySize, params.LoginResp.AuthToken)
+		if err == api.ErrNoOffer {
+			// Another day may still be listed
+			continue
+		}
+		if errors.Is(err, api.ErrCancelled) {
+			return nil, err
+		}
+		if err != nil {
+			dayErr = err
+			continue
+		}
+		offered = true
+		slots = append(slots, daySlots...)
+	}
+	if len(slots) == 0 && dayErr != nil {
+		return nil, dayErr
+	}
+	if len(slots) == 0 {
+		// Tell a venue that's shut on every day asked for from one
+		// that's booked up, retrying only helps with the latter
+		if a.closedOn(ctx, client, params.VenueID, params.PartySize, params.Dates(nycLocation)) {
+			return nil, api.NewRefusalError("calendar", 0, api.ErrVenueClosed, "closed on every requested day")
+		}
+	}
+	if !offered {
 		return nil, api.ErrNoOffer
 	}
 
-	// Find the venue that matches the requested venue ID
-	var jsonVenueMap map[string]interface{}
-	for _, v := range jsonVenuesList {
-		venue, ok := v.(map[string]interface{})
-		if !ok {
+	// Rank the slots whose advertised fees are within the limits, then
+	// work down the candidates until one books
+	candidates, policyErr := params.Payment.Select(a.selector(), slots, params.Preferences(), func(slot api.Slot) *api.BookingTerms {
+		return bookingTerms(slot, detailsResponse{})
+	})
+	var takenErr error
+	for _, bestSlot := range candidates {
+		// Don't start another details/book round if the caller gave up
+		if ctx.Err() != nil {
+			return nil, api.NewCancelError("reserve", ctx.Err())
+		}
+
+		detailUrl := a.endpoint("/3/details")
+
+		// commit=1 holds the table for the book step, which a dry run
+		// never reaches
+		commit := 1
+		if params.DryRun {
+			commit = 0
+		}
+
+		// Prepare the request body
+		requestBody := map[string]string{
+			"commit":     strconv.Itoa(commit),                // Convert integer commit to string
+			"config_id":  bestSlot.ConfigToken,                // Config token from the find step
+			"day":        bestSlot.Start.Format("2006-01-02"), // Day of the chosen slot, in NYC time
+			"party_size": strconv.Itoa(params.PartySize),      // Convert PartySize (an int) to string
+		}
+		jsonBody, err := json.Marshal(requestBody)
+		if err != nil {
+			continue
+		}
+
+		detailCtx, detailCancel := context.WithTimeout(ctx, a.timeouts().Details)
+		requestDetail, err := http.NewRequestWithContext(detailCtx, "POST", detailUrl, bytes.NewBuffer(jsonBody))
+		if err != nil {
+			detailCancel()
 			continue
 		}
 
-		// Try to extract venue ID from the response structure
-		// Resy API returns venue info nested under "venue" key
-		if venueInfo, ok := venue["venue"].(map[string]interface{}); ok {
-			if idInfo, ok := venueInfo["id"].(map[string]interface{}); ok {
-				if resyID, ok := idInfo["resy"].(float64); ok {
-					if int64(resyID) == params.VenueID {
-						jsonVenueMap = venue
-						break
-					}
-				}
+		// Setting headers for detail request
+		// Set the appropriate headers
+		requestDetail.Header.Set("Content-Type", "application/json")
+		requestDetail.Header.Set("Authorization", `ResyAPI api_key="`+a.APIKey+`"`)
+
+		// Add Imperva cookies and user agent
+		a.addCookiesToRequest(requestDetail)
+
+		responseDetail, err := a.doRequestWithRetry(detailCtx, client, requestDetail, jsonBody, 2, params.VenueID)
+		if err != nil {
+			detailCancel()
+			return nil, stepError(ctx, "detail", err)
+		}
+
+		responseDetailBody, err := io.ReadAll(responseDetail.Body)
+		responseDetail.Body.Close()
+		detailCancel()
+		if err != nil {
+			return nil, stepError(ctx, "detail", err)
+		}
+
+		if isCodeFail(responseDetail.StatusCode) {
+			err := responseError("detail", responseDetail, responseDetailBody)
+			if errors.Is(err, api.ErrSlotTaken) {
+				// Gone since find listed it, the next candidate may not be
+				takenErr = err
+				continue
 			}
+			return nil, err
 		}
-	}
 
-	// If no matching venue found, fall back to first venue
-	if jsonVenueMap == nil {
-		var ok bool
-		jsonVenueMap, ok = jsonVenuesList[0].(map[string]interface{})
-		if !ok {
-			return nil, api.NewNetworkError("find", 0, "invalid response: venue structure is invalid")
+		var details detailsResponse
+		if err := decodePayload("detail", responseDetailBody, &details, a.StrictSchema); err != nil {
+			return nil, err
+		}
+		terms := bookingTerms(bestSlot, details)
+		if err := params.Payment.Check(terms); err != nil {
+			// The details step can state fees find didn't advertise
+			policyErr = err
+			continue
 		}
-	}
 
-	jsonSlotsList, ok := jsonVenueMap["slots"].([]interface{})
-	if !ok {
-		return nil, api.NewNetworkError("find", 0, "invalid response: 'slots' key not found in venue")
-	}
+		if params.DryRun {
+			// Everything short of committing the table has run, so this
+			// is as far as a dry run goes
+			return &api.ReserveResponse{
+				ReservationTime: bestSlot.Start,
+				DryRun:          true,
+				Slot:            &bestSlot,
+				Terms:           terms,
+				VenueID:         params.VenueID,
+				PartySize:       params.PartySize,
+				TableType:       bestSlot.TableType,
+			}, nil
+		}
 
-	// Iterate over table types and reservation times
-	// If no table types specified, match any slot based on time only
-	hasTableTypePreference := len(params.TableTypes) > 0
+		if details.BookToken == nil {
+			return nil, api.NewSchemaError("detail", "book_token", "missing field")
+		}
+		bookToken := details.BookToken.Value
+
+		// Proceed to booking step
+		bookUrl := a.endpoint("/3/book")
+
+		bookField := "book_token=" + url.QueryEscape(bookToken)
+		paymentMethodStr := `{"id":` + strconv.FormatInt(params.LoginResp.PaymentMethodID, 10) + `}`
+		paymentMethodField := "struct_payment_method=" + url.QueryEscape(paymentMethodStr)
+		requestBookBodyStr := bookField + "&" + paymentMethodField + "&" + "source_id=resy.com-venue-details"
+		if note := params.Requests.Note(); note != "" {
+			// Resy has one free-text special request, the occasion included
+			requestBookBodyStr += "&special_request=" + url.QueryEscape(note)
+		}
 
-	for k := 0; k < len(params.TableTypes) || (!hasTableTypePreference && k == 0); k++ {
-		var currentTableType api.TableType
-		if hasTableTypePreference {
-			currentTableType = params.TableTypes[k]
+		bookCtx, bookCancel := context.WithTimeout(ctx, a.timeouts().Book)
+		requestBook, err := http.NewRequestWithContext(bookCtx, "POST", bookUrl, bytes.NewBuffer([]byte(requestBookBodyStr)))
+		if err != nil {
+			bookCancel()
+			continue
+		}
+		requestBook.Header.Set("Authorization", `ResyAPI api_key="`+a.APIKey+`"`)
+		requestBook.Header.Set("Content-Type", `application/x-www-form-urlencoded`)
+		requestBook.Header.Set("Host", `api.resy.com`)
+		requestBook.Header.Set("X-Resy-Auth-Token", params.LoginResp.AuthToken)
+		requestBook.Header.Set("X-Resy-Universal-Auth", params.LoginResp.AuthToken)
+		requestBook.Header.Set("Referer", "https://resy.com/")
+
+		// Add Imperva cookies and user agent
+		a.addCookiesToRequest(requestBook)
+
+		requestBookBytes := []byte(requestBookBodyStr)
+		responseBook, err := a.doRequestWithRetry(bookCtx, client, requestBook, requestBookBytes, 2, params.VenueID)
+		if err != nil {
+			bookCancel()
+			return nil, bookError(ctx, &bestSlot, err)
 		}
 
-		for i := 0; i < len(params.ReservationTimes); i++ {
-			currentTime := params.ReservationTimes[i]
+		responseBookBody, err := io.ReadAll(responseBook.Body)
+		responseBook.Body.Close()
+		bookCancel()
+		if err != nil {
+			return nil, bookError(ctx, &bestSlot, err)
+		}
 
-			// First pass: Try to find exact match, then closest match within window
-			var bestSlot map[string]interface{}
-			var bestSlotIndex int = -1
-			var bestSlotTime time.Time
-			var bestSlotConfigToken string
-			var bestTimeDiff time.Duration = 31 * time.Minute // Track smallest time difference found (start larger than max)
-			const maxTimeDiff = 30 * time.Minute              // Maximum allowed time difference
-			foundExactMatch := false
+		if isCodeFail(responseBook.StatusCode) {
+			err := responseError("book", responseBook, responseBookBody)
+			var netErr *api.NetworkError
+			switch {
+			case errors.Is(err, api.ErrSlotTaken):
+				takenErr = err
+				continue
+			case errors.As(err, &netErr) && netErr.Status >= 500:
+				// Resy may have booked the table before failing, so
+				// booking another slot could leave the user with two
+				return nil, api.NewUncertainBookError("book", &bestSlot, err)
+			case errors.As(err, &netErr):
+				// Other unexplained failures were turned away, so move
+				// on to the next slot
+				continue
+			}
+			// Rate limits, a rejected token or card fail every slot alike
+			return nil, err
+		}
 
-			for j := 0; j < len(jsonSlotsList); j++ {
-				jsonSlotMap, ok := jsonSlotsList[j].(map[string]interface{})
-				if !ok {
-					continue
-				}
+		// A 2xx we can't read may still have booked the table, so
+		// it's reported rather than moving on to another slot
+		var booked bookResponse
+		if err := decodePayload("book", responseBookBody, &booked, a.StrictSchema); err != nil {
+			return nil, err
+		}
 
-				jsonDateMap, ok := jsonSlotMap["date"].(map[string]interface{})
-				if !ok {
-					continue
-				}
+		resp := api.ReserveResponse{
+			ReservationTime:  bestSlot.Start,
+			Slot:             &bestSlot,
+			Terms:            terms,
+			VenueID:          params.VenueID,
+			PartySize:        params.PartySize,
+			TableType:        bestSlot.TableType,
+			ReservationID:    strconv.FormatInt(booked.ReservationID, 10),
+			ReservationToken: booked.ResyToken,
+		}
+		return &resp, nil
+	}
 
-				startRaw, ok := jsonDateMap["start"].(string)
-				if !ok {
-					continue
-				}
+	// If no table was found after all candidates
+	if policyErr != nil {
+		return nil, policyErr
+	}
+	if takenErr != nil {
+		return nil, takenErr
+	}
+	return nil, api.ErrNoTable
+}
 
-				startFields := strings.Split(startRaw, " ")
-				if len(startFields) != 2 {
-					continue
-				}
+/*
+Name: AuthMinExpire
+Type: API Func
+Purpose: Resy implementation of the AuthMinExpire api func.
+The largest minimum validity time is 6 days.
+*/
+func (a *API) AuthMinExpire() time.Duration {
+	/* 6 days */
+	var d time.Duration = time.Hour * 24 * 6
+	return d
+}
 
-				dateStr := startFields[0]
-				timeFields := strings.Split(startFields[1], ":")
-				if len(timeFields) != 3 {
-					continue
-				}
+/*
+Name: Cancel
+Type: API Func
+Purpose: Resy implementation of the Cancel api func
+Note: ReservationToken must be the resy_token of a booked
+reservation on the account that LoginResp belongs to. Cancelling
+one twice is a RefusalError matching api.ErrAlreadyCancelled
+*/
+func (a *API) Cancel(ctx context.Context, params api.CancelParam) (*api.CancelResponse, error) {
+	ctx = a.begin(ctx, 0, params.LoginResp.AuthToken)
+	defer a.end(ctx)
+	cancelUrl := a.endpoint("/3/cancel")
+	resyToken := url.QueryEscape(params.ReservationToken)
+	requestBodyStr := "resy_token=" + resyToken
+	cancelCtx, cancelCancel := context.WithTimeout(ctx, a.timeouts().Other)
+	defer cancelCancel()
+
+	request, err := http.NewRequestWithContext(cancelCtx, "POST", cancelUrl, bytes.NewBuffer([]byte(requestBodyStr)))
+	if err != nil {
+		return nil, err
+	}
 
-				// Parse the slot's full date/time
-				// NOTE: Resy API returns times in the venue's local timezone (NYC), not UTC
-				// We need to parse it as NYC time and compare with the requested time in NYC
-				dateTimeStr := dateStr + " " + timeFields[0] + ":" + timeFields[1] + ":00"
-				slotTime, err := time.ParseInLocation("2006-01-02 15:04:05", dateTimeStr, nycLocation)
-				if err != nil {
-					continue
-				}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", `ResyAPI api_key="`+a.APIKey+`"`)
+	request.Header.Set("X-Resy-Auth-Token", params.LoginResp.AuthToken)
+	request.Header.Set("X-Resy-Universal-Auth-Token", params.LoginResp.AuthToken)
+	request.Header.Set("Referer", "https://resy.com/")
+	request.Header.Set("Origin", "https://resy.com")
 
-				// Convert currentTime to NYC for comparison
-				currentTimeNYC := currentTime.In(nycLocation)
+	// Add Imperva cookies and user agent
+	a.addCookiesToRequest(request)
 
-				// Check if the slot is on the same date as the requested time (in NYC timezone)
-				if slotTime.Year() != currentTimeNYC.Year() ||
-					slotTime.Month() != currentTimeNYC.Month() ||
-					slotTime.Day() != currentTimeNYC.Day() {
-					continue
-				}
+	client := a.clientFor(ctx)
+	response, err := a.doRequestWithRetry(cancelCtx, client, request, []byte(requestBodyStr), 2, 0)
+	if err != nil {
+		return nil, stepError(ctx, "cancel", err)
+	}
 
-				// Check if the slot matches the desired time (exact match) using NYC times
-				timeMatches := slotTime.Hour() == currentTimeNYC.Hour() && slotTime.Minute() == currentTimeNYC.Minute()
+	defer response.Body.Close()
 
-				// Get config map to check table type
-				jsonConfigMap, ok := jsonSlotMap["config"].(map[string]interface{})
-				if !ok {
-					continue
-				}
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, stepError(ctx, "cancel", err)
+	}
 
-				// Check table type if preference is specified
-				if hasTableTypePreference {
-					tableType, ok := jsonConfigMap["type"].(string)
-					if !ok {
-						continue
-					}
+	if isCodeFail(response.StatusCode) {
+		return nil, responseError("cancel", response, responseBody)
+	}
 
-					if !strings.Contains(strings.ToLower(tableType), string(currentTableType)) {
-						continue
-					}
-				}
+	var cancelled cancelResponse
+	if err := decodePayload("cancel", responseBody, &cancelled, a.StrictSchema); err != nil {
+		return nil, err
+	}
 
-				// If exact time match, use it immediately
-				if timeMatches {
-					bestSlot = jsonSlotMap
-					bestSlotIndex = j
-					bestSlotTime = slotTime
-					configToken, ok := jsonConfigMap["token"].(string)
-					if ok {
-						bestSlotConfigToken = configToken
-					}
-					foundExactMatch = true
-					break
-				}
+	// Free reservations come back without any payment info, which means
+	// there was nothing to refund
+	cancelResp := api.CancelResponse{}
+	if cancelled.Payment == nil || cancelled.Payment.Transaction == nil {
+		return &cancelResp, nil
+	}
+	transaction := cancelled.Payment.Transaction
+	cancelResp.Refund = bool(transaction.Refund)
+	if cancelResp.Refund {
+		cancelResp.RefundAmount = transaction.Amount
+	}
 
-				// If no exact match yet, track the closest slot within the time window
-				// Compare using NYC times since slots are in NYC timezone
-				if !foundExactMatch {
-					timeDiff := slotTime.Sub(currentTimeNYC)
-					absTimeDiff := timeDiff
-					if absTimeDiff < 0 {
-						absTimeDiff = -absTimeDiff // Use absolute value
-					}
+	return &cancelResp, nil
+}
 
-					// Only consider slots within the max time window and that are better than current best
-					if absTimeDiff <= maxTimeDiff && absTimeDiff < bestTimeDiff {
-						bestTimeDiff = absTimeDiff
-						bestSlot = jsonSlotMap
-						bestSlotIndex = j
-						bestSlotTime = slotTime
-						configToken, ok := jsonConfigMap["token"].(string)
-						if ok {
-							bestSlotConfigToken = configToken
-						}
-					}
-				}
-			}
+/*
+Name: ListReservations
+Type: API Func
+Purpose: Resy implementation of the ListReservations api func
+Note: A reservation that can't be read fails the whole call with an
+api.SchemaError rather than being left out of the list
+*/
+func (a *API) ListReservations(ctx context.Context, params api.ListReservationsParam) (*api.ListReservationsResponse, error) {
+	ctx = a.begin(ctx, 0, params.LoginResp.AuthToken)
+	defer a.end(ctx)
+	query := url.Values{}
+	query.Set("type", "upcoming")
+	query.Set("book_on_behalf_of", "false")
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	listUrl := a.endpoint("/3/user/reservations?" + query.Encode())
 
-			// If we found a slot (exact or closest), proceed with booking
-			if bestSlotIndex >= 0 {
+	listCtx, listCancel := context.WithTimeout(ctx, a.timeouts().Other)
+	defer listCancel()
 
-				configToken := bestSlotConfigToken
-				if configToken == "" {
-					jsonConfigMap, ok := bestSlot["config"].(map[string]interface{})
-					if !ok {
-						continue
-					}
-					configToken, ok = jsonConfigMap["token"].(string)
-					if !ok {
-						continue
-					}
-				}
+	request, err := http.NewRequestWithContext(listCtx, "GET", listUrl, nil)
+	if err != nil {
+		return nil, err
+	}
 
-				detailUrl := "https://api.resy.com/3/details"
+	request.Header.Set("Authorization", `ResyAPI api_key="`+a.APIKey+`"`)
+	request.Header.Set("X-Resy-Auth-Token", params.LoginResp.AuthToken)
+	request.Header.Set("X-Resy-Universal-Auth-Token", params.LoginResp.AuthToken)
+	request.Header.Set("Referer", "https://resy.com/")
+	request.Header.Set("Origin", "https://resy.com")
 
-				// Prepare the request body
-				requestBody := map[string]string{
-					"commit":     strconv.Itoa(1),                // Convert integer 1 to string
-					"config_id":  configToken,                    // Assuming configToken is already a string
-					"day":        date,                           // Assuming date is already a string
-					"party_size": strconv.Itoa(params.PartySize), // Convert PartySize (an int) to string
-				}
-				jsonBody, err := json.Marshal(requestBody)
-				if err != nil {
-					continue
-				}
+	// Add Imperva cookies and user agent
+	a.addCookiesToRequest(request)
 
-				requestDetail, err := http.NewRequest("POST", detailUrl, bytes.NewBuffer(jsonBody))
-				if err != nil {
-					continue
-				}
+	client := a.clientFor(ctx)
+	response, err := a.doRequestWithRetry(listCtx, client, request, nil, 2, 0)
+	if err != nil {
+		return nil, stepError(ctx, "reservations", err)
+	}
 
-				// Setting headers for detail request
-				// Set the appropriate headers
-				requestDetail.Header.Set("Content-Type", "application/json")
-				requestDetail.Header.Set("Authorization", `ResyAPI api_key="`+a.APIKey+`"`)
+	defer response.Body.Close()
 
-				// Add Imperva cookies and user agent
-				a.addCookiesToRequest(requestDetail)
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, stepError(ctx, "reservations", err)
+	}
 
-				// Fallback to default User-Agent if not set via cookies
-				if a.UserAgent == "" {
-					requestDetail.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-				}
+	if isCodeFail(response.StatusCode) {
+		return nil, responseError("reservations", response, responseBody)
+	}
 
-				responseDetail, err := a.doRequestWithRetry(client, requestDetail, jsonBody, 2, params.VenueID)
-				if err != nil {
-					return nil, err
-				}
+	var listed reservationsResponse
+	if err := decodePayload("reservations", responseBody, &listed, a.StrictSchema); err != nil {
+		return nil, err
+	}
 
-				responseDetailBody, err := io.ReadAll(responseDetail.Body)
-				responseDetail.Body.Close()
-				if err != nil {
-					return nil, err
-				}
+	// A row we can't read is an error rather than skipped: a missing
+	// booking would tell the burst an uncertain book didn't go through
+	loc := venueLocation()
+	reservations := make([]api.BookedReservation, 0, len(listed.Reservations))
+	for i, entry := range listed.Reservations {
+		path := fmt.Sprintf("reservations[%d]", i)
+		if entry.ResyToken == "" {
+			return nil, api.NewSchemaError("reservations", path+".resy_token", "empty")
+		}
+		reservationTime, err := time.ParseInLocation("2006-01-02 15:04:05", entry.Day+" "+entry.TimeSlot, loc)
+		if err != nil {
+			return nil, api.NewSchemaError("reservations", path+".time_slot", "unreadable day and time: "+err.Error())
+		}
 
-				if isCodeFail(responseDetail.StatusCode) {
-					return nil, api.NewNetworkError("detail", responseDetail.StatusCode, truncateForLog(responseDetailBody, 200))
-				}
+		booked := api.BookedReservation{
+			VenueID:          entry.Venue.ID,
+			VenueName:        listed.Venues[strconv.FormatInt(entry.Venue.ID, 10)].Name,
+			ReservationTime:  reservationTime,
+			PartySize:        entry.NumSeats,
+			ReservationToken: entry.ResyToken,
+		}
+		if entry.Config != nil {
+			booked.TableType = entry.Config.Type
+		}
+		reservations = append(reservations, booked)
+	}
 
-				var detailTopLevelMap map[string]interface{}
-				err = json.Unmarshal(responseDetailBody, &detailTopLevelMap)
-				if err != nil {
-					return nil, err
-				}
+	sort.Slice(reservations, func(i, j int) bool {
+		return reservations[i].ReservationTime.Before(reservations[j].ReservationTime)
+	})
 
-				jsonBookTokenMap, ok := detailTopLevelMap["book_token"].(map[string]interface{})
-				if !ok {
-					continue
-				}
+	return &api.ListReservationsResponse{Reservations: reservations}, nil
+}
 
-				bookToken, ok := jsonBookTokenMap["value"].(string)
-				if !ok {
-					continue
-				}
+/*
+Name: GetVenue
+Type: API Func
+Purpose: Resy implementation of the GetVenue api func
+Note: A venue that doesn't report a usable timezone is assumed
+to be in NYC, like every slot time we parse
+*/
+func (a *API) GetVenue(ctx context.Context, params api.GetVenueParam) (*api.Venue, error) {
+	ctx = a.begin(ctx, params.VenueID, "")
+	defer a.end(ctx)
+
+	venueUrl := a.endpoint("/3/venue?id=" + strconv.FormatInt(params.VenueID, 10))
+	venueCtx, venueCancel := context.WithTimeout(ctx, a.timeouts().Other)
+	defer venueCancel()
 
-				// Proceed to booking step
-				bookUrl := "https://api.resy.com/3/book"
+	request, err := http.NewRequestWithContext(venueCtx, "GET", venueUrl, nil)
+	if err != nil {
+		return nil, err
+	}
 
-				bookField := "book_token=" + url.QueryEscape(bookToken)
-				paymentMethodStr := `{"id":` + strconv.FormatInt(params.LoginResp.PaymentMethodID, 10) + `}`
-				paymentMethodField := "struct_payment_method=" + url.QueryEscape(paymentMethodStr)
-				requestBookBodyStr := bookField + "&" + paymentMethodField + "&" + "source_id=resy.com-venue-details"
+	request.Header.Set("Authorization", `ResyAPI api_key="`+a.APIKey+`"`)
+	request.Header.Set("Referer", "https://resy.com/")
+	request.Header.Set("Origin", "https://resy.com")
 
-				requestBook, err := http.NewRequest("POST", bookUrl, bytes.NewBuffer([]byte(requestBookBodyStr)))
-				if err != nil {
-					continue
-				}
-				requestBook.Header.Set("Authorization", `ResyAPI api_key="`+a.APIKey+`"`)
-				requestBook.Header.Set("Content-Type", `application/x-www-form-urlencoded`)
-				requestBook.Header.Set("Host", `api.resy.com`)
-				requestBook.Header.Set("X-Resy-Auth-Token", params.LoginResp.AuthToken)
-				requestBook.Header.Set("X-Resy-Universal-Auth", params.LoginResp.AuthToken)
-				requestBook.Header.Set("Referer", "https://resy.com/")
-
-				// Add Imperva cookies and user agent
-				a.addCookiesToRequest(requestBook)
-
-				// Fallback to default User-Agent if not set via cookies
-				if a.UserAgent == "" {
-					requestBook.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-				}
+	// Add Imperva cookies and user agent
+	a.addCookiesToRequest(request)
 
-				requestBookBytes := []byte(requestBookBodyStr)
-				responseBook, err := a.doRequestWithRetry(client, requestBook, requestBookBytes, 2, params.VenueID)
-				if err != nil {
-					return nil, err
-				}
+	response, err := a.doRequestWithRetry(venueCtx, a.clientFor(ctx), request, nil, 2, params.VenueID)
+	if err != nil {
+		return nil, stepError(ctx, "venue", err)
+	}
 
-				responseBookBody, err := io.ReadAll(responseBook.Body)
-				responseBook.Body.Close()
-				if err != nil {
-					return nil, err
-				}
+	defer response.Body.Close()
 
-				if isCodeFail(responseBook.StatusCode) {
-					continue
-				}
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, stepError(ctx, "venue", err)
+	}
 
-				var bookTopLevelMap map[string]interface{}
-				err = json.Unmarshal(responseBookBody, &bookTopLevelMap)
-				if err != nil {
-					continue
-				}
+	if response.StatusCode == http.StatusNotFound {
+		return nil, api.ErrNoVenue
+	}
+	if isCodeFail(response.StatusCode) {
+		return nil, responseError("venue", response, responseBody)
+	}
 
-				// Check if booking was successful
-				if _, ok := bookTopLevelMap["reservation_id"]; ok {
-					resp := api.ReserveResponse{
-						ReservationTime: bestSlotTime,
-					}
-					return &resp, nil
-				} else {
-					// Booking response missing confirmation, try next slot
-					continue
-				}
+	var info venueResponse
+	if err := decodePayload("venue", responseBody, &info, a.StrictSchema); err != nil {
+		return nil, err
+	}
+
+	venue := api.Venue{
+		Provider:     api.ProviderResy,
+		VenueID:      info.ID.Resy,
+		Name:         info.Name,
+		Slug:         info.URLSlug,
+		Address:      info.Location.Address1,
+		City:         info.Location.Locality,
+		TimeZone:     venueLocation().String(),
+		SeatingTypes: info.Config.SeatingTypes,
+	}
+	if venue.SeatingTypes == nil {
+		venue.SeatingTypes = []string{}
+	}
+	if info.Location.TimeZone != "" {
+		if loc, err := time.LoadLocation(info.Location.TimeZone); err == nil {
+			venue.TimeZone = loc.String()
+		}
+	}
+
+	if info.Config.LeadTimeInDays > 0 {
+		window := &api.BookingWindow{DaysInAdvance: info.Config.LeadTimeInDays}
+		if info.Config.ReleaseTime != "" {
+			release, err := time.Parse("15:04", info.Config.ReleaseTime)
+			if err != nil {
+				return nil, api.NewSchemaError("venue", "config.release_time", fmt.Sprintf("unparseable time %q", info.Config.ReleaseTime))
 			}
+			window.ReleaseHour = release.Hour()
+			window.ReleaseMinute = release.Minute()
 		}
+		venue.BookingWindow = window
 	}
 
-	// If no table was found after all iterations
-	return nil, api.ErrNoTable
+	if info.Payment != nil {
+		venue.Fees = &api.FeePolicy{
+			DepositFee:         info.Payment.DepositFee,
+			CancellationFee:    info.Payment.CancellationFee,
+			CancellationCutoff: time.Duration(info.Payment.CancellationCutoffHours * float64(time.Hour)),
+			Description:        info.Payment.Policy,
+		}
+	}
+
+	return &venue, nil
 }
 
 /*
-Name: AuthMinExpire
+Name: Prewarm
 Type: API Func
-Purpose: Resy implementation of the AuthMinExpire api func.
-The largest minimum validity time is 6 days.
+Purpose: Resy implementation of api.Prewarmer. Sends a cheap venue
+lookup with the venue's Imperva cookies, so the shared
+client's connection (TCP, TLS and HTTP/2 setup) is already open
+when Reserve runs, and the cookies have been tried
+Note: The response is discarded, only a failed request is reported. A
+challenge is retried like any other call, so cookies Imperva hands out
+on the way are stored for the route before the run time. Cookies it
+keeps turning down come back as api.ErrImperva
 */
-func (a *API) AuthMinExpire() time.Duration {
-	/* 6 days */
-	var d time.Duration = time.Hour * 24 * 6
-	return d
-}
+func (a *API) Prewarm(ctx context.Context, venueID int64) error {
+	ctx = a.begin(ctx, venueID, "")
+	defer a.end(ctx)
+	prewarmCtx, prewarmCancel := context.WithTimeout(ctx, a.timeouts().Other)
+	defer prewarmCancel()
 
-//func (a *API) Cancel(params api.CancelParam) (*api.CancelResponse, error) {
-//    cancelUrl := `https://api.resy.com/3/cancel`
-//    resyToken := url.QueryEscape(params.ResyToken)
-//    requestBodyStr := "resy_token=" + resyToken
-//    request, err := http.NewRequest("POST", cancelUrl, bytes.NewBuffer([]byte(requestBodyStr)))
-//    if err != nil {
-//        return nil, err
-//    }
-//
-//    request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-//    request.Header.Set("Authorization", `ResyAPI api_key="` + a.APIKey + `"`)
-//    request.Header.Set("X-Resy-Auth-Token", params.AuthToken)
-//    request.Header.Set("X-Resy-Universal-Auth-Token", params.AuthToken)
-//    request.Header.Set("Referer", "https://resy.com/")
-//    request.Header.Set("Origin", "https://resy.com")
-//
-//
-//    client := &http.Client{}
-//    response, err := client.Do(request)
-//    if err != nil {
-//        return nil, err
-//    }
-//
-//    if isCodeFail(response.StatusCode) {
-//        return nil, api.ErrNetwork
-//    }
-//
-//    responseBody, err := io.ReadAll(response.Body)
-//    if err != nil {
-//        return nil, err
-//    }
-//
-//    defer response.Body.Close()
-//    var jsonTopLevelMap map[string]interface{}
-//    err = json.Unmarshal(responseBody, &jsonTopLevelMap)
-//    if err != nil {
-//        return nil, err
-//    }
-//
-//    jsonPaymentMap := jsonTopLevelMap["payment"].(map[string]interface{})
-//    jsonTransactionMap := jsonPaymentMap["transaction"].(map[string]interface{})
-//    refund := jsonTransactionMap["refund"].(int) == 1
-//    return &api.CancelResponse{Refund: refund}, nil
-//}
-//
+	request, err := http.NewRequestWithContext(prewarmCtx, "GET", a.endpoint("/3/venue?id="+strconv.FormatInt(venueID, 10)), nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", `ResyAPI api_key="`+a.APIKey+`"`)
+	request.Header.Set("Referer", "https://resy.com/")
+	request.Header.Set("Origin", "https://resy.com")
+	a.addCookiesToRequest(request)
+
+	sent := time.Now()
+	response, err := a.doRequestWithRetry(prewarmCtx, a.clientFor(ctx), request, nil, 2, venueID)
+	if err != nil {
+		return stepError(ctx, "prewarm", err)
+	}
+	defer response.Body.Close()
+	a.observeClock(sent, response)
+
+	// Read the body to the end so the connection goes back to the pool
+	_, err = io.Copy(io.Discard, response.Body)
+	return err
+}
@@ -0,0 +1,1373 @@
+package resy_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+	"github.com/21Bruce/resolved-server/api/resy"
+	"github.com/21Bruce/resolved-server/api/resy/resytest"
+	"github.com/21Bruce/resolved-server/store"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const testVenueID = 86907
+
+// setupFake starts a fake Resy seeded with one venue and one user, and
+// points the store at miniredis so cookie loading doesn't need a real Redis
+func setupFake(t *testing.T, slots ...resytest.Slot) (*resytest.Server, *resy.API, resytest.User) {
+	t.Helper()
+
+	store.ResetClient()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store.SetClient(client)
+
+	srv := resytest.NewServer()
+	t.Cleanup(func() {
+		srv.Close()
+		client.Close()
+		mr.Close()
+		store.ResetClient()
+	})
+
+	srv.AddVenue(resytest.Venue{
+		ID:           testVenueID,
+		Name:         "Crevette",
+		Region:       "NY",
+		Locality:     "New York",
+		Neighborhood: "West Village",
+	}, slots...)
+	user := srv.AddUser(resytest.User{
+		Email:           "diner@example.com",
+		Password:        "hunter2",
+		FirstName:       "Dana",
+		LastName:        "Diner",
+		Mobile:          "+12125550100",
+		PaymentMethodID: 4242,
+	})
+
+	return srv, &resy.API{APIKey: "test-key", BaseURL: srv.URL, Client: srv.Client(), StrictSchema: true}, user
+}
+
+func nyc(t *testing.T, value string) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation failed: %v", err)
+	}
+	tm, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	if err != nil {
+		t.Fatalf("ParseInLocation failed: %v", err)
+	}
+	return tm
+}
+
+func login(t *testing.T, a *resy.API, user resytest.User) api.LoginResponse {
+	t.Helper()
+	resp, err := a.Login(context.Background(), api.LoginParam{Email: user.Email, Password: user.Password})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	return *resp
+}
+
+func reserveParam(t *testing.T, auth api.LoginResponse, at string) api.ReserveParam {
+	return api.ReserveParam{
+		VenueID:          testVenueID,
+		ReservationTimes: []time.Time{nyc(t, at)},
+		PartySize:        2,
+		LoginResp:        auth,
+	}
+}
+
+func TestLogin(t *testing.T) {
+	_, a, user := setupFake(t)
+
+	resp := login(t, a, user)
+	if resp.AuthToken != user.Token || resp.PaymentMethodID != 4242 {
+		t.Errorf("unexpected login response: %+v", resp)
+	}
+}
+
+func TestLogin_419(t *testing.T) {
+	srv, a, user := setupFake(t)
+	srv.SetScenario(resytest.ScenarioLogin419)
+
+	_, err := a.Login(context.Background(), api.LoginParam{Email: user.Email, Password: user.Password})
+	if !errors.Is(err, api.ErrLoginWrong) {
+		t.Fatalf("expected ErrLoginWrong, got %v", err)
+	}
+}
+
+func TestLogin_NoPaymentMethod(t *testing.T) {
+	srv, a, _ := setupFake(t)
+	user := srv.AddUser(resytest.User{Email: "nocard@example.com", Password: "pw"})
+
+	_, err := a.Login(context.Background(), api.LoginParam{Email: user.Email, Password: user.Password})
+	if !errors.Is(err, api.ErrNoPayInfo) {
+		t.Fatalf("expected ErrNoPayInfo, got %v", err)
+	}
+}
+
+func TestLogin_NullName(t *testing.T) {
+	srv, a, user := setupFake(t)
+	srv.OverrideResponse("/3/auth/password", http.StatusOK,
+		`{"id":7,"first_name":null,"last_name":null,"mobile_number":null,"em_address":"diner@example.com","payment_method_id":4242,"token":"tok"}`)
+
+	resp := login(t, a, user)
+	if resp.FirstName != "" || resp.AuthToken != "tok" || resp.PaymentMethodID != 4242 {
+		t.Errorf("unexpected login response: %+v", resp)
+	}
+}
+
+func TestLogin_MissingToken(t *testing.T) {
+	srv, a, user := setupFake(t)
+	srv.OverrideResponse("/3/auth/password", http.StatusOK, `{"id":7,"payment_method_id":4242}`)
+
+	_, err := a.Login(context.Background(), api.LoginParam{Email: user.Email, Password: user.Password})
+	assertSchemaError(t, err, "login", "token")
+}
+
+func assertSchemaError(t *testing.T, err error, step, path string) {
+	t.Helper()
+	var schemaErr *api.SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("expected SchemaError, got %v", err)
+	}
+	if !errors.Is(err, api.ErrSchema) {
+		t.Errorf("expected error to match ErrSchema")
+	}
+	if schemaErr.Step != step || schemaErr.Path != path {
+		t.Errorf("expected schema error at %s %q, got %s %q (%s)", step, path, schemaErr.Step, schemaErr.Path, schemaErr.Reason)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	_, a, _ := setupFake(t)
+
+	resp, err := a.Search(context.Background(), api.SearchParam{Name: "crev", Limit: 5})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(resp.Results))
+	}
+	got := resp.Results[0]
+	if got.VenueID != testVenueID || got.Name != "Crevette" || got.Provider != api.ProviderResy {
+		t.Errorf("unexpected result: %+v", got)
+	}
+}
+
+func TestSearch_QuotedName(t *testing.T) {
+	srv, a, _ := setupFake(t)
+	srv.AddVenue(resytest.Venue{ID: 2, Name: `Joe's "Pizza"`})
+
+	resp, err := a.Search(context.Background(), api.SearchParam{Name: `joe's "pizza"`})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].VenueID != 2 {
+		t.Errorf("unexpected results: %+v", resp.Results)
+	}
+}
+
+func TestSearch_NearbyAndFilters(t *testing.T) {
+	srv, a, _ := setupFake(t)
+	// Around Washington Square Park, plus one venue in Brooklyn
+	srv.AddVenue(resytest.Venue{ID: 10, Name: "Via Carota", Neighborhood: "West Village",
+		Latitude: 40.7334, Longitude: -74.0036, Cuisines: []string{"Italian"}, PriceBand: 3})
+	srv.AddVenue(resytest.Venue{ID: 11, Name: "I Sodi", Neighborhood: "West Village",
+		Latitude: 40.7352, Longitude: -74.0052, Cuisines: []string{"Italian"}, PriceBand: 4})
+	srv.AddVenue(resytest.Venue{ID: 12, Name: "Lilia", Neighborhood: "Williamsburg",
+		Latitude: 40.7177, Longitude: -73.9526, Cuisines: []string{"Italian"}, PriceBand: 3})
+
+	params := api.SearchParam{Latitude: 40.7308, Longitude: -73.9973, RadiusMeters: 1500}
+	resp, err := a.Search(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].VenueID != 10 || resp.Results[1].VenueID != 11 {
+		t.Fatalf("expected the two nearby venues nearest first, got %+v", resp.Results)
+	}
+	if d := resp.Results[0].DistanceMeters; d <= 0 || d > 1500 {
+		t.Errorf("unexpected distance %v", d)
+	}
+
+	params.PriceBand = 4
+	resp, err = a.Search(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].VenueID != 11 || resp.Results[0].PriceBand != 4 {
+		t.Errorf("expected only the $$$$ venue, got %+v", resp.Results)
+	}
+
+	resp, err = a.Search(context.Background(), api.SearchParam{Cuisine: "italian", Neighborhood: "williamsburg"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].VenueID != 12 {
+		t.Errorf("expected only Lilia, got %+v", resp.Results)
+	}
+}
+
+func TestSearch_Pages(t *testing.T) {
+	srv, a, _ := setupFake(t)
+	srv.AddVenue(resytest.Venue{ID: 1, Name: "Crevette Uptown"})
+
+	first, err := a.Search(context.Background(), api.SearchParam{Name: "crevette", Limit: 1, Page: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	second, err := a.Search(context.Background(), api.SearchParam{Name: "crevette", Limit: 1, Page: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(first.Results) != 1 || len(second.Results) != 1 || first.Results[0].VenueID == second.Results[0].VenueID {
+		t.Errorf("expected two distinct pages, got %+v and %+v", first.Results, second.Results)
+	}
+}
+
+func TestSearch_FilteredPages(t *testing.T) {
+	srv, a, _ := setupFake(t)
+	// Resy pages these by ID, so the first page of two holds no Italian
+	for id := int64(1); id <= 4; id++ {
+		srv.AddVenue(resytest.Venue{ID: id, Name: "Trattoria " + strconv.FormatInt(id, 10), Cuisines: []string{"French"}})
+	}
+	srv.AddVenue(resytest.Venue{ID: 20, Name: "Trattoria Lucia", Cuisines: []string{"Italian"}})
+	srv.AddVenue(resytest.Venue{ID: 21, Name: "Trattoria Rosa", Cuisines: []string{"Italian"}})
+	srv.AddVenue(resytest.Venue{ID: 22, Name: "Trattoria Nina", Cuisines: []string{"Italian"}})
+
+	resp, err := a.Search(context.Background(), api.SearchParam{Name: "trattoria", Cuisine: "italian", Limit: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].VenueID != 20 || resp.Results[1].VenueID != 21 {
+		t.Fatalf("expected a full page of Italian venues, got %+v", resp.Results)
+	}
+	if hits := srv.Hits("/3/venuesearch/search"); hits != 3 {
+		t.Errorf("expected Search to stop once Limit matched, after 3 pages, got %d", hits)
+	}
+
+	// Page counts Resy's pages, so the fourth starts past the ones read above
+	resp, err = a.Search(context.Background(), api.SearchParam{Name: "trattoria", Cuisine: "italian", Limit: 2, Page: 4})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].VenueID != 22 {
+		t.Errorf("expected the last Italian venue, got %+v", resp.Results)
+	}
+}
+
+func TestSearch_FilteredCursor(t *testing.T) {
+	srv, a, _ := setupFake(t)
+	// Pages of two: [1 2] [3 4] [5 6], so the second page holds more
+	// matches than the first response has room for
+	cuisines := map[int64]string{1: "French", 2: "Italian", 3: "Italian", 4: "Italian", 5: "French", 6: "Italian"}
+	for id, cuisine := range cuisines {
+		srv.AddVenue(resytest.Venue{ID: id, Name: "Trattoria " + strconv.FormatInt(id, 10), Cuisines: []string{cuisine}})
+	}
+
+	params := api.SearchParam{Name: "trattoria", Cuisine: "italian", Limit: 2}
+	var seen []int64
+	for requests := 0; ; requests++ {
+		if requests == 5 {
+			t.Fatalf("expected the walk to end, still going after %v", seen)
+		}
+		resp, err := a.Search(context.Background(), params)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(resp.Results) > params.Limit {
+			t.Errorf("expected at most %d results, got %d", params.Limit, len(resp.Results))
+		}
+		for _, r := range resp.Results {
+			seen = append(seen, r.VenueID)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		params.Page, params.Offset = resp.NextPage, resp.NextOffset
+	}
+
+	if want := []int64{2, 3, 4, 6}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("expected every Italian venue once in order %v, got %v", want, seen)
+	}
+}
+
+func TestSearch_InvalidParams(t *testing.T) {
+	_, a, _ := setupFake(t)
+
+	if _, err := a.Search(context.Background(), api.SearchParam{RadiusMeters: 500}); err == nil {
+		t.Error("expected an error for a radius without a location")
+	}
+	if _, err := a.Search(context.Background(), api.SearchParam{PriceBand: 5}); err == nil {
+		t.Error("expected an error for an out of range price band")
+	}
+}
+
+func TestReserve(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 18:30:00", Type: "Dining Room", Token: "cfg-1830"},
+		resytest.Slot{Start: "2026-11-20 19:15:00", Type: "Dining Room", Token: "cfg-1915"},
+	)
+	auth := login(t, a, user)
+
+	resp, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if want := nyc(t, "2026-11-20 19:15"); !resp.ReservationTime.Equal(want) {
+		t.Errorf("expected closest slot %v, got %v", want, resp.ReservationTime)
+	}
+
+	bookings := srv.Bookings()
+	if len(bookings) != 1 || bookings[0].Slot.Token != "cfg-1915" || bookings[0].AuthToken != user.Token {
+		t.Fatalf("unexpected bookings: %+v", bookings)
+	}
+
+	// The response identifies the booking well enough to cancel it
+	if want := strconv.FormatInt(bookings[0].ReservationID, 10); resp.ReservationID != want {
+		t.Errorf("expected reservation ID %s, got %q", want, resp.ReservationID)
+	}
+	if resp.ReservationToken != bookings[0].ResyToken {
+		t.Errorf("expected resy token %q, got %q", bookings[0].ResyToken, resp.ReservationToken)
+	}
+	if resp.VenueID != testVenueID || resp.PartySize != 2 || resp.TableType != "Dining Room" {
+		t.Errorf("unexpected booking details %+v", resp)
+	}
+}
+
+func TestReserve_TableTypePreference(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-dining"},
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Bar Counter", Token: "cfg-bar"},
+	)
+	auth := login(t, a, user)
+
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.TableTypes = []api.TableType{api.Bar}
+	if _, err := a.Reserve(context.Background(), params); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+
+	if bookings := srv.Bookings(); len(bookings) != 1 || bookings[0].Slot.Token != "cfg-bar" {
+		t.Errorf("expected the bar slot to be booked, got %+v", bookings)
+	}
+}
+
+func TestReserve_MultipleDates(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-21 19:30:00", Type: "Dining Room", Token: "cfg-sat-1930"},
+	)
+	auth := login(t, a, user)
+
+	// Nothing is listed on Friday, so Saturday's slot should be booked
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.ReservationTimes = append(params.ReservationTimes, nyc(t, "2026-11-21 19:00"))
+	resp, err := a.Reserve(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if want := nyc(t, "2026-11-21 19:30"); !resp.ReservationTime.Equal(want) {
+		t.Errorf("expected %v, got %v", want, resp.ReservationTime)
+	}
+	if n := srv.Hits("/4/find"); n != 2 {
+		t.Errorf("expected one find per date, got %d", n)
+	}
+	if bookings := srv.Bookings(); len(bookings) != 1 || bookings[0].Slot.Token != "cfg-sat-1930" || bookings[0].Day != "2026-11-21" {
+		t.Errorf("unexpected bookings: %+v", bookings)
+	}
+}
+
+func TestReserve_MultipleDatesPriority(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-fri-1900"},
+		resytest.Slot{Start: "2026-11-21 19:00:00", Type: "Dining Room", Token: "cfg-sat-1900"},
+	)
+	auth := login(t, a, user)
+
+	// Saturday is listed first, so it wins even though Friday is earlier
+	params := reserveParam(t, auth, "2026-11-21 19:00")
+	params.ReservationTimes = append(params.ReservationTimes, nyc(t, "2026-11-20 19:00"), nyc(t, "2026-11-21 20:00"))
+	if _, err := a.Reserve(context.Background(), params); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if n := srv.Hits("/4/find"); n != 2 {
+		t.Errorf("expected one find per distinct date, got %d", n)
+	}
+	if bookings := srv.Bookings(); len(bookings) != 1 || bookings[0].Slot.Token != "cfg-sat-1900" {
+		t.Errorf("expected Saturday's slot to be booked, got %+v", bookings)
+	}
+}
+
+func TestReserve_MultipleDatesSkipsFailedDay(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-fri-1900"},
+		resytest.Slot{Start: "2026-11-21 19:00:00", Type: "Dining Room", Token: "cfg-sat-1900"},
+	)
+	auth := login(t, a, user)
+
+	// Friday fails to load, which shouldn't cost Saturday's slot
+	srv.FailFind("2026-11-20")
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.ReservationTimes = append(params.ReservationTimes, nyc(t, "2026-11-21 19:00"))
+	if _, err := a.Reserve(context.Background(), params); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if bookings := srv.Bookings(); len(bookings) != 1 || bookings[0].Slot.Token != "cfg-sat-1900" {
+		t.Errorf("expected Saturday's slot to be booked, got %+v", bookings)
+	}
+}
+
+func TestReserve_MultipleDatesAllFailed(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-21 19:00:00", Type: "Dining Room", Token: "cfg-sat-1900"},
+	)
+	auth := login(t, a, user)
+
+	// Friday fails and Sunday has no slots, so the failure is all
+	// there is to report
+	srv.FailFind("2026-11-20")
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.ReservationTimes = append(params.ReservationTimes, nyc(t, "2026-11-22 19:00"))
+	_, err := a.Reserve(context.Background(), params)
+	var netErr *api.NetworkError
+	if !errors.As(err, &netErr) || netErr.Status != http.StatusInternalServerError {
+		t.Fatalf("expected Friday's network error, got %v", err)
+	}
+	if n := srv.Hits("/4/find"); n != 2 {
+		t.Errorf("expected both dates to be tried, got %d", n)
+	}
+}
+
+func TestReserve_TimeWindow(t *testing.T) {
+	slots := []resytest.Slot{
+		{Start: "2026-11-20 18:00:00", Type: "Dining Room", Token: "cfg-1800"},
+		{Start: "2026-11-20 18:45:00", Type: "Dining Room", Token: "cfg-1845"},
+		{Start: "2026-11-20 19:30:00", Type: "Dining Room", Token: "cfg-1930"},
+		{Start: "2026-11-20 20:15:00", Type: "Dining Room", Token: "cfg-2015"},
+	}
+	tests := []struct {
+		name       string
+		earliest   string
+		latest     string
+		preference string
+		want       string
+	}{
+		{name: "prefer later", earliest: "18:30", latest: "20:00", preference: "latest", want: "cfg-1930"},
+		{name: "prefer earlier", earliest: "18:30", latest: "20:00", preference: "earliest", want: "cfg-1845"},
+		{name: "closest inside window", earliest: "18:30", latest: "20:00", want: "cfg-1845"},
+		{name: "open upper bound", earliest: "19:00", preference: "latest", want: "cfg-2015"},
+		{name: "default 30 minutes", preference: "latest", want: "cfg-1930"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, a, user := setupFake(t, slots...)
+			auth := login(t, a, user)
+
+			window, err := api.ParseTimeWindow(tt.earliest, tt.latest, tt.preference)
+			if err != nil {
+				t.Fatalf("ParseTimeWindow failed: %v", err)
+			}
+			params := reserveParam(t, auth, "2026-11-20 19:00")
+			params.Window = window
+			if _, err := a.Reserve(context.Background(), params); err != nil {
+				t.Fatalf("Reserve failed: %v", err)
+			}
+			if bookings := srv.Bookings(); len(bookings) != 1 || bookings[0].Slot.Token != tt.want {
+				t.Errorf("expected %s to be booked, got %+v", tt.want, bookings)
+			}
+		})
+	}
+}
+
+func TestReserve_TimeWindowNoFit(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+	)
+	auth := login(t, a, user)
+
+	// The target itself is listed, but it's outside the window
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.Window = api.TimeWindow{Earliest: 20 * time.Hour}
+	_, err := a.Reserve(context.Background(), params)
+	if !errors.Is(err, api.ErrNoTable) {
+		t.Fatalf("expected ErrNoTable, got %v", err)
+	}
+	if n := srv.Hits("/3/details"); n != 0 {
+		t.Errorf("expected no details calls, got %d", n)
+	}
+}
+
+func TestReserve_InvalidTimeWindow(t *testing.T) {
+	srv, a, user := setupFake(t)
+	auth := login(t, a, user)
+
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.Window = api.TimeWindow{Earliest: 20 * time.Hour, Latest: 19 * time.Hour}
+	if _, err := a.Reserve(context.Background(), params); err == nil {
+		t.Fatal("expected an error for a window that ends before it starts")
+	}
+	if n := srv.Hits("/4/find"); n != 0 {
+		t.Errorf("expected no find calls, got %d", n)
+	}
+}
+
+func TestReserve_Selector(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-dining"},
+		resytest.Slot{Start: "2026-11-20 19:30:00", Type: "Bar Counter", Token: "cfg-bar"},
+	)
+	auth := login(t, a, user)
+
+	// The default selector would take the bar first, as table type is
+	// the outer priority, but here being on time matters more
+	a.Selector = api.WeightedSelector{TimeWeight: 1, SeatingWeight: 10}
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.TableTypes = []api.TableType{api.Bar, api.DiningRoom}
+	if _, err := a.Reserve(context.Background(), params); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if bookings := srv.Bookings(); len(bookings) != 1 || bookings[0].Slot.Token != "cfg-dining" {
+		t.Errorf("expected the dining room slot to be booked, got %+v", bookings)
+	}
+}
+
+func TestReserve_SpecialRequests(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+	)
+	auth := login(t, a, user)
+
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.Requests = api.SpecialRequests{Occasion: api.Anniversary, Dietary: "No shellfish", Seating: "Booth if possible"}
+	if _, err := a.Reserve(context.Background(), params); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+
+	bookings := srv.Bookings()
+	if len(bookings) != 1 {
+		t.Fatalf("expected 1 booking, got %d", len(bookings))
+	}
+	if want := "Occasion: Anniversary. Dietary: No shellfish. Seating: Booth if possible"; bookings[0].SpecialRequest != want {
+		t.Errorf("expected special request %q, got %q", want, bookings[0].SpecialRequest)
+	}
+
+	// An unknown occasion is turned away before anything is sent
+	params.Requests = api.SpecialRequests{Occasion: "wedding"}
+	if _, err := a.Reserve(context.Background(), params); err == nil {
+		t.Fatal("expected an unknown occasion to be rejected")
+	}
+	if n := srv.Hits("/4/find"); n != 1 {
+		t.Errorf("expected no find for the rejected request, got %d in total", n)
+	}
+}
+
+func TestReserve_DryRun(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{
+			Start:              "2026-11-20 19:00:00",
+			Type:               "Dining Room",
+			Token:              "cfg-1900",
+			IsPaid:             true,
+			DepositFee:         50,
+			CancellationFee:    25,
+			CancellationCutoff: "2026-11-19T19:00:00Z",
+			Policy:             "Cancel 24 hours ahead to avoid the fee.",
+		},
+	)
+	auth := login(t, a, user)
+
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.DryRun = true
+	resp, err := a.Reserve(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+
+	if !resp.DryRun || resp.Slot == nil || resp.Slot.ConfigToken != "cfg-1900" {
+		t.Fatalf("expected a dry run for cfg-1900, got %+v", resp)
+	}
+	if resp.ReservationID != "" || resp.ReservationToken != "" {
+		t.Errorf("expected no reservation for a dry run, got %q, %q", resp.ReservationID, resp.ReservationToken)
+	}
+	if !resp.ReservationTime.Equal(nyc(t, "2026-11-20 19:00")) {
+		t.Errorf("unexpected reservation time %v", resp.ReservationTime)
+	}
+	terms := resp.Terms
+	if terms == nil || !terms.PaymentRequired || terms.DepositFee != 50 || terms.CancellationFee != 25 {
+		t.Fatalf("unexpected terms: %+v", terms)
+	}
+	if want := time.Date(2026, 11, 19, 19, 0, 0, 0, time.UTC); terms.CancellationCutoff == nil || !terms.CancellationCutoff.Equal(want) {
+		t.Errorf("expected cutoff %v, got %v", want, terms.CancellationCutoff)
+	}
+	if terms.Policy != "Cancel 24 hours ahead to avoid the fee." {
+		t.Errorf("unexpected policy %q", terms.Policy)
+	}
+
+	if n := srv.Hits("/3/details"); n != 1 {
+		t.Errorf("expected one details call, got %d", n)
+	}
+	if n := srv.Hits("/3/book"); n != 0 {
+		t.Errorf("expected no book calls, got %d", n)
+	}
+	if n := srv.Holds(); n != 0 {
+		t.Errorf("expected a dry run to hold no table, got %d holds", n)
+	}
+	if bookings := srv.Bookings(); len(bookings) != 0 {
+		t.Errorf("expected nothing booked, got %+v", bookings)
+	}
+}
+
+func TestReserve_PaymentPolicy(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-deposit", IsPaid: true, DepositFee: 50},
+		resytest.Slot{Start: "2026-11-20 19:15:00", Type: "Dining Room", Token: "cfg-free"},
+	)
+	auth := login(t, a, user)
+
+	maxDeposit := 20.0
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.Payment = api.PaymentPolicy{MaxDeposit: &maxDeposit}
+	resp, err := a.Reserve(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if want := nyc(t, "2026-11-20 19:15"); !resp.ReservationTime.Equal(want) {
+		t.Errorf("expected the slot without a deposit at %v, got %v", want, resp.ReservationTime)
+	}
+	if bookings := srv.Bookings(); len(bookings) != 1 || bookings[0].Slot.Token != "cfg-free" {
+		t.Errorf("unexpected bookings: %+v", bookings)
+	}
+	// The deposit was advertised by find, so the slot never got as far as details
+	if n := srv.Hits("/3/details"); n != 1 {
+		t.Errorf("expected one details call, got %d", n)
+	}
+}
+
+func TestReserve_PaymentPolicyRefusesAll(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900", CancellationFee: 25},
+	)
+	auth := login(t, a, user)
+
+	noFee := 0.0
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.Payment = api.PaymentPolicy{MaxCancellationFee: &noFee}
+	_, err := a.Reserve(context.Background(), params)
+	if !errors.Is(err, api.ErrPaymentPolicy) {
+		t.Fatalf("expected ErrPaymentPolicy, got %v", err)
+	}
+	var policyErr *api.PaymentPolicyError
+	if !errors.As(err, &policyErr) || policyErr.Fee != "cancellation fee" || policyErr.Amount != 25 || policyErr.Limit != 0 {
+		t.Errorf("unexpected policy error: %+v", policyErr)
+	}
+	if n := srv.Hits("/3/book"); n != 0 {
+		t.Errorf("expected no book calls, got %d", n)
+	}
+}
+
+func TestReserve_NoSlots(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+	)
+	auth := login(t, a, user)
+	srv.SetScenario(resytest.ScenarioNoSlots)
+
+	_, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+	if !errors.Is(err, api.ErrNoTable) {
+		t.Fatalf("expected ErrNoTable, got %v", err)
+	}
+	if n := srv.Hits("/3/details"); n != 0 {
+		t.Errorf("expected no details calls, got %d", n)
+	}
+}
+
+func TestReserve_UnknownVenue(t *testing.T) {
+	_, a, user := setupFake(t)
+	auth := login(t, a, user)
+
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.VenueID = 1
+	if _, err := a.Reserve(context.Background(), params); !errors.Is(err, api.ErrNoOffer) {
+		t.Fatalf("expected ErrNoOffer, got %v", err)
+	}
+}
+
+func TestReserve_SlotTakenAtBook(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+	)
+	auth := login(t, a, user)
+	srv.SetScenario(resytest.ScenarioSlotTaken)
+
+	_, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+	if !errors.Is(err, api.ErrNoTable) {
+		t.Fatalf("expected ErrNoTable, got %v", err)
+	}
+	if n := srv.Hits("/3/book"); n != 1 {
+		t.Errorf("expected 1 book attempt, got %d", n)
+	}
+	if n := len(srv.Bookings()); n != 0 {
+		t.Errorf("expected no bookings, got %d", n)
+	}
+}
+
+func TestReserve_BookUncertain(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+		resytest.Slot{Start: "2026-11-20 19:15:00", Type: "Dining Room", Token: "cfg-1915"},
+	)
+	auth := login(t, a, user)
+	srv.SetScenario(resytest.ScenarioBookLost)
+
+	_, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+	var uncertain *api.UncertainBookError
+	if !errors.As(err, &uncertain) {
+		t.Fatalf("expected an UncertainBookError, got %v", err)
+	}
+	if uncertain.Slot == nil || uncertain.Slot.ConfigToken != "cfg-1900" {
+		t.Errorf("expected the error to name cfg-1900, got %+v", uncertain.Slot)
+	}
+	if code := api.ErrorCode(err); code != "book_uncertain" {
+		t.Errorf("expected code book_uncertain, got %q", code)
+	}
+
+	// The first book went through, so the second slot must not be tried
+	if n := srv.Hits("/3/book"); n != 1 {
+		t.Errorf("expected 1 book attempt, got %d", n)
+	}
+	if n := len(srv.Bookings()); n != 1 {
+		t.Errorf("expected the one booking, got %d", n)
+	}
+
+	// The user's reservations settle it, as the burst checks them
+	list, err := a.ListReservations(context.Background(), api.ListReservationsParam{LoginResp: auth})
+	if err != nil {
+		t.Fatalf("ListReservations failed: %v", err)
+	}
+	if len(list.Reservations) != 1 || !list.Reservations[0].ReservationTime.Equal(uncertain.Slot.Start) {
+		t.Errorf("expected the lost booking at %v to be listed, got %+v", uncertain.Slot.Start, list.Reservations)
+	}
+}
+
+func TestReserve_BookServerErrorMentioningRefusal(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+	}{
+		{500, `{"status":500,"message":"Venue closed the connection"}`},
+		{503, `{"status":503,"message":"Payment service unavailable"}`},
+	}
+	for _, tt := range tests {
+		srv, a, user := setupFake(t,
+			resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+		)
+		auth := login(t, a, user)
+		srv.OverrideResponse("/3/book", tt.status, tt.body)
+
+		// A 5xx may have booked the table whatever its message says
+		_, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+		var uncertain *api.UncertainBookError
+		if !errors.As(err, &uncertain) {
+			t.Errorf("%d: expected an UncertainBookError, got %v", tt.status, err)
+		}
+		if errors.Is(err, api.ErrVenueClosed) || errors.Is(err, api.ErrPaymentDeclined) {
+			t.Errorf("%d: expected no refusal, got %v", tt.status, err)
+		}
+	}
+}
+
+func TestReserve_ErrorTaxonomy(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario resytest.Scenario
+		venue    *resytest.Venue // Replaces the seeded venue if set
+		token    string          // Replaces the login's auth token if set
+		party    int
+		want     error
+		code     string
+	}{
+		{name: "rate limited", scenario: resytest.ScenarioRateLimited, want: api.ErrRateLimited, code: "rate_limited"},
+		{name: "token revoked", token: "revoked", want: api.ErrAuthExpired, code: "auth_expired"},
+		{name: "slot taken", scenario: resytest.ScenarioSlotTaken, want: api.ErrSlotTaken, code: "slot_taken"},
+		{name: "payment declined", scenario: resytest.ScenarioPaymentDeclined, want: api.ErrPaymentDeclined, code: "payment_declined"},
+		{
+			name:  "venue closed",
+			venue: &resytest.Venue{ID: testVenueID, Name: "Crevette", ClosedDays: []string{"2026-11-21"}},
+			want:  api.ErrVenueClosed,
+			code:  "venue_closed",
+		},
+		{
+			name:  "party too large",
+			venue: &resytest.Venue{ID: testVenueID, Name: "Crevette", MaxPartySize: 4},
+			party: 6,
+			want:  api.ErrPartySize,
+			code:  "party_size_not_allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, a, user := setupFake(t,
+				resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+			)
+			auth := login(t, a, user)
+			if tt.venue != nil {
+				srv.AddVenue(*tt.venue)
+			}
+			if tt.token != "" {
+				auth.AuthToken = tt.token
+			}
+			srv.SetScenario(tt.scenario)
+
+			at := "2026-11-20 19:00"
+			if tt.venue != nil && len(tt.venue.ClosedDays) > 0 {
+				at = tt.venue.ClosedDays[0] + " 19:00"
+			}
+			params := reserveParam(t, auth, at)
+			if tt.party > 0 {
+				params.PartySize = tt.party
+			}
+
+			_, err := a.Reserve(context.Background(), params)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			if got := api.ErrorCode(err); got != tt.code {
+				t.Errorf("expected code %q, got %q", tt.code, got)
+			}
+			if n := len(srv.Bookings()); n != 0 {
+				t.Errorf("expected no bookings, got %d", n)
+			}
+		})
+	}
+}
+
+func TestReserve_RateLimitRetryAfter(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+	)
+	auth := login(t, a, user)
+	srv.SetScenario(resytest.ScenarioRateLimited)
+
+	_, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+	var rateErr *api.RateLimitError
+	if !errors.As(err, &rateErr) || rateErr.Step != "find" || rateErr.RetryAfter != 2*time.Second {
+		t.Fatalf("expected a find RateLimitError asking for 2s, got %v", err)
+	}
+}
+
+func TestReserve_SoldOutIsNotClosed(t *testing.T) {
+	srv, a, user := setupFake(t)
+	auth := login(t, a, user)
+	srv.AddVenue(resytest.Venue{ID: testVenueID, Name: "Crevette", ClosedDays: []string{"2026-11-21"}})
+
+	// Closed on one of the days only, so there may still be tables later
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.ReservationTimes = append(params.ReservationTimes, nyc(t, "2026-11-21 19:00"))
+	_, err := a.Reserve(context.Background(), params)
+	if !errors.Is(err, api.ErrNoTable) || errors.Is(err, api.ErrVenueClosed) {
+		t.Fatalf("expected ErrNoTable, got %v", err)
+	}
+	if n := srv.Hits("/4/venue/calendar"); n != 1 {
+		t.Errorf("expected 1 calendar lookup, got %d", n)
+	}
+}
+
+func TestReserve_Imperva(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+	)
+	auth := login(t, a, user)
+	srv.SetScenario(resytest.ScenarioImperva)
+
+	_, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+	if !errors.Is(err, api.ErrImperva) {
+		t.Fatalf("expected ErrImperva, got %v", err)
+	}
+	// One attempt plus two retries
+	if n := srv.Hits("/4/find"); n != 3 {
+		t.Errorf("expected 3 find attempts, got %d", n)
+	}
+}
+
+func TestReserve_CookiesFromStore(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+	)
+	auth := login(t, a, user)
+
+	if err := store.SaveCookies(context.Background(), testVenueID, nil, "test-agent/1.0", time.Hour); err != nil {
+		t.Fatalf("SaveCookies failed: %v", err)
+	}
+	rec := recordHeaders(srv, a)
+	if _, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00")); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	for _, sent := range rec.requests() {
+		if sent.userAgent != "test-agent/1.0" {
+			t.Errorf("expected stored user agent on %s, got %q", sent.path, sent.userAgent)
+		}
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	_, a, _ := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900", IsPaid: true, DepositFee: 50},
+		resytest.Slot{Start: "2026-11-21 19:00:00", Type: "Dining Room", Token: "cfg-next-day"},
+	)
+
+	resp, err := a.Availability(context.Background(), api.AvailabilityParam{
+		VenueID:   testVenueID,
+		Date:      "2026-11-20",
+		PartySize: 2,
+	})
+	if err != nil {
+		t.Fatalf("Availability failed: %v", err)
+	}
+	if len(resp.Slots) != 1 {
+		t.Fatalf("expected 1 slot, got %d", len(resp.Slots))
+	}
+	slot := resp.Slots[0]
+	if slot.ConfigToken != "cfg-1900" || slot.Payment == nil || slot.Payment.DepositFee != 50 {
+		t.Errorf("unexpected slot: %+v", slot)
+	}
+}
+
+func TestReserve_FindSchemaDrift(t *testing.T) {
+	srv, a, user := setupFake(t)
+	auth := login(t, a, user)
+	srv.OverrideResponse("/4/find", http.StatusOK,
+		`{"results":{"venues":[{"venue":{"id":{"resy":86907}},"slots":[{"date":{"start":"2026-11-20 19:00:00"},"config_v2":{"token":"cfg"}}]}]}}`)
+
+	_, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+	assertSchemaError(t, err, "find", "results.venues[0].slots[0].config")
+	if n := srv.Hits("/3/details"); n != 0 {
+		t.Errorf("expected no details calls, got %d", n)
+	}
+}
+
+func TestReserve_FindWrongType(t *testing.T) {
+	srv, a, user := setupFake(t)
+	auth := login(t, a, user)
+	srv.OverrideResponse("/4/find", http.StatusOK, `{"results":{"venues":{"86907":{}}}}`)
+
+	_, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+	assertSchemaError(t, err, "find", "results.venues")
+}
+
+func TestReserve_UnknownDetailsField(t *testing.T) {
+	slot := resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"}
+	srv, a, user := setupFake(t, slot)
+	auth := login(t, a, user)
+	srv.OverrideResponse("/3/details", http.StatusOK, `{"book_token":{"value":"bt","expires_at":"soon"}}`)
+
+	_, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+	assertSchemaError(t, err, "detail", "book_token.expires_at")
+
+	// Outside strict mode extra fields are Resy's business, and the
+	// flow carries on to the book step
+	a.StrictSchema = false
+	_, err = a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+	var schemaErr *api.SchemaError
+	if errors.As(err, &schemaErr) {
+		t.Fatalf("expected no schema error outside strict mode, got %v", err)
+	}
+	if n := srv.Hits("/3/book"); n != 1 {
+		t.Errorf("expected 1 book attempt, got %d", n)
+	}
+}
+
+func TestReserve_BookWithoutReservationID(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+		resytest.Slot{Start: "2026-11-20 19:15:00", Type: "Dining Room", Token: "cfg-1915"},
+	)
+	auth := login(t, a, user)
+	srv.OverrideResponse("/3/book", http.StatusCreated, `{"resy_token":"rt"}`)
+
+	_, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+	assertSchemaError(t, err, "book", "reservation_id")
+	// The table may have been booked, so no other slot is tried
+	if n := srv.Hits("/3/book"); n != 1 {
+		t.Errorf("expected 1 book attempt, got %d", n)
+	}
+}
+
+func TestGetVenue(t *testing.T) {
+	srv, a, _ := setupFake(t)
+	srv.AddVenue(resytest.Venue{
+		ID:            5,
+		Name:          "Carbone",
+		Locality:      "New York",
+		Slug:          "carbone",
+		Address:       "181 Thompson St",
+		TimeZone:      "America/New_York",
+		SeatingTypes:  []string{"Dining Room", "Bar"},
+		DaysInAdvance: 30,
+		ReleaseTime:   "10:00",
+		Fees:          &resytest.Fees{CancellationFee: 50, CancellationCutoffHours: 48, Policy: "$50 per person"},
+	})
+
+	venue, err := a.GetVenue(context.Background(), api.GetVenueParam{VenueID: 5})
+	if err != nil {
+		t.Fatalf("GetVenue failed: %v", err)
+	}
+	if venue.Provider != api.ProviderResy || venue.Name != "Carbone" || venue.Slug != "carbone" ||
+		venue.Address != "181 Thompson St" || venue.TimeZone != "America/New_York" || len(venue.SeatingTypes) != 2 {
+		t.Errorf("unexpected venue: %+v", venue)
+	}
+	if w := venue.BookingWindow; w == nil || w.DaysInAdvance != 30 || w.ReleaseHour != 10 || w.ReleaseMinute != 0 {
+		t.Errorf("unexpected booking window: %+v", venue.BookingWindow)
+	}
+	if f := venue.Fees; f == nil || f.CancellationFee != 50 || f.CancellationCutoff != 48*time.Hour {
+		t.Errorf("unexpected fees: %+v", venue.Fees)
+	}
+}
+
+func TestGetVenue_NoPolicies(t *testing.T) {
+	_, a, _ := setupFake(t)
+
+	// The seeded venue has no timezone, window or fees
+	venue, err := a.GetVenue(context.Background(), api.GetVenueParam{VenueID: testVenueID})
+	if err != nil {
+		t.Fatalf("GetVenue failed: %v", err)
+	}
+	if venue.TimeZone != "America/New_York" || venue.BookingWindow != nil || venue.Fees != nil {
+		t.Errorf("unexpected venue: %+v", venue)
+	}
+}
+
+func TestGetVenue_Unknown(t *testing.T) {
+	_, a, _ := setupFake(t)
+
+	if _, err := a.GetVenue(context.Background(), api.GetVenueParam{VenueID: 1}); !errors.Is(err, api.ErrNoVenue) {
+		t.Fatalf("expected ErrNoVenue, got %v", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name       string
+		slot       resytest.Slot
+		wantRefund bool
+		wantAmount float64
+	}{
+		{name: "deposit refunded", slot: resytest.Slot{IsPaid: true, DepositFee: 50}, wantRefund: true, wantAmount: 50},
+		{name: "paid, not refunded", slot: resytest.Slot{IsPaid: true, CancellationFee: 25}},
+		{name: "free", slot: resytest.Slot{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slot := tt.slot
+			slot.Start, slot.Type, slot.Token = "2026-11-20 19:00:00", "Dining Room", "cfg-1900"
+			srv, a, user := setupFake(t, slot)
+			auth := login(t, a, user)
+
+			booked, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00"))
+			if err != nil {
+				t.Fatalf("Reserve failed: %v", err)
+			}
+			cancelParam := api.CancelParam{ReservationToken: booked.ReservationToken, LoginResp: auth}
+			resp, err := a.Cancel(context.Background(), cancelParam)
+			if err != nil {
+				t.Fatalf("Cancel failed: %v", err)
+			}
+			if resp.Refund != tt.wantRefund || resp.RefundAmount != tt.wantAmount {
+				t.Errorf("expected refund %v of %v, got %+v", tt.wantRefund, tt.wantAmount, resp)
+			}
+			if bookings := srv.Bookings(); len(bookings) != 1 || !bookings[0].Cancelled {
+				t.Errorf("expected the booking to be cancelled, got %+v", bookings)
+			}
+
+			// A second cancel is refused rather than reported as done
+			_, err = a.Cancel(context.Background(), cancelParam)
+			if !errors.Is(err, api.ErrAlreadyCancelled) || api.ErrorCode(err) != "already_cancelled" {
+				t.Errorf("expected ErrAlreadyCancelled, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCancel_RefundAsBoolean(t *testing.T) {
+	srv, a, user := setupFake(t)
+	auth := login(t, a, user)
+
+	srv.OverrideResponse("/3/cancel", http.StatusOK, `{"payment": {"transaction": {"refund": true, "amount": 40}}}`)
+	resp, err := a.Cancel(context.Background(), api.CancelParam{ReservationToken: "resy-token-1", LoginResp: auth})
+	if err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if !resp.Refund || resp.RefundAmount != 40 {
+		t.Errorf("expected a refund of 40, got %+v", resp)
+	}
+
+	srv.OverrideResponse("/3/cancel", http.StatusOK, `{"payment": {"transaction": {"refund": "yes"}}}`)
+	_, err = a.Cancel(context.Background(), api.CancelParam{ReservationToken: "resy-token-1", LoginResp: auth})
+	var schemaErr *api.SchemaError
+	if !errors.As(err, &schemaErr) || schemaErr.Step != "cancel" {
+		t.Errorf("expected a cancel SchemaError for an unreadable refund, got %v", err)
+	}
+}
+
+func TestCancel_NotFound(t *testing.T) {
+	_, a, user := setupFake(t)
+	auth := login(t, a, user)
+
+	_, err := a.Cancel(context.Background(), api.CancelParam{ReservationToken: "resy-token-404", LoginResp: auth})
+	var netErr *api.NetworkError
+	if !errors.As(err, &netErr) || netErr.Status != http.StatusNotFound {
+		t.Errorf("expected a 404 NetworkError, got %v", err)
+	}
+}
+
+func TestListReservations(t *testing.T) {
+	_, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-21 20:30:00", Type: "Bar", Token: "cfg-2030"},
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+		resytest.Slot{Start: "2026-11-22 18:00:00", Type: "Patio", Token: "cfg-1800"},
+	)
+	auth := login(t, a, user)
+
+	var tokens []string
+	for _, at := range []string{"2026-11-21 20:30", "2026-11-20 19:00", "2026-11-22 18:00"} {
+		booked, err := a.Reserve(context.Background(), reserveParam(t, auth, at))
+		if err != nil {
+			t.Fatalf("Reserve at %s failed: %v", at, err)
+		}
+		tokens = append(tokens, booked.ReservationToken)
+	}
+	if _, err := a.Cancel(context.Background(), api.CancelParam{ReservationToken: tokens[2], LoginResp: auth}); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+
+	resp, err := a.ListReservations(context.Background(), api.ListReservationsParam{LoginResp: auth})
+	if err != nil {
+		t.Fatalf("ListReservations failed: %v", err)
+	}
+	want := []api.BookedReservation{
+		{VenueID: testVenueID, VenueName: "Crevette", ReservationTime: nyc(t, "2026-11-20 19:00"), PartySize: 2, TableType: "Dining Room", ReservationToken: tokens[1]},
+		{VenueID: testVenueID, VenueName: "Crevette", ReservationTime: nyc(t, "2026-11-21 20:30"), PartySize: 2, TableType: "Bar", ReservationToken: tokens[0]},
+	}
+	if len(resp.Reservations) != len(want) {
+		t.Fatalf("expected the %d uncancelled reservations, got %+v", len(want), resp.Reservations)
+	}
+	for i, got := range resp.Reservations {
+		if !got.ReservationTime.Equal(want[i].ReservationTime) {
+			t.Errorf("reservation %d: expected %v, got %v", i, want[i].ReservationTime, got.ReservationTime)
+		}
+		got.ReservationTime = want[i].ReservationTime
+		if got != want[i] {
+			t.Errorf("reservation %d: expected %+v, got %+v", i, want[i], got)
+		}
+	}
+
+	resp, err = a.ListReservations(context.Background(), api.ListReservationsParam{LoginResp: auth, Limit: 1})
+	if err != nil || len(resp.Reservations) != 1 || resp.Reservations[0].ReservationToken != tokens[1] {
+		t.Errorf("expected only the soonest reservation with a limit of 1, got %+v, %v", resp, err)
+	}
+}
+
+func TestListReservations_MalformedRow(t *testing.T) {
+	tests := []struct {
+		name string
+		row  string
+		path string
+	}{
+		{"no token", `{"day": "2026-11-20", "time_slot": "19:00:00", "venue": {"id": 86907}}`, "reservations[1].resy_token"},
+		{"bad time", `{"resy_token": "t2", "day": "2026-11-20", "time_slot": "7pm", "venue": {"id": 86907}}`, "reservations[1].time_slot"},
+		{"venue id as text", `{"resy_token": "t2", "day": "2026-11-20", "time_slot": "19:00:00", "venue": {"id": "86907"}}`, "reservations[1].venue.id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, a, user := setupFake(t)
+			auth := login(t, a, user)
+			good := `{"resy_token": "t1", "day": "2026-11-19", "time_slot": "19:00:00", "venue": {"id": 86907}}`
+			srv.OverrideResponse("/3/user/reservations", http.StatusOK, `{"reservations": [`+good+`, `+tt.row+`], "venues": {}}`)
+
+			// Leaving the row out could hide a booking, so the call fails
+			_, err := a.ListReservations(context.Background(), api.ListReservationsParam{LoginResp: auth})
+			var schemaErr *api.SchemaError
+			if !errors.As(err, &schemaErr) || schemaErr.Step != "reservations" || schemaErr.Path != tt.path {
+				t.Errorf("expected a SchemaError at %s, got %v", tt.path, err)
+			}
+		})
+	}
+}
+
+func TestErrorTaxonomy_OtherCalls(t *testing.T) {
+	srv, a, user := setupFake(t)
+	auth := login(t, a, user)
+
+	srv.OverrideResponse("/3/cancel", 419, `{"status":419,"message":"Unauthorized"}`)
+	_, err := a.Cancel(context.Background(), api.CancelParam{ReservationToken: "resy-token-1", LoginResp: auth})
+	if !errors.Is(err, api.ErrAuthExpired) {
+		t.Errorf("expected ErrAuthExpired from cancel, got %v", err)
+	}
+
+	srv.SetScenario(resytest.ScenarioRateLimited)
+	_, err = a.GetVenue(context.Background(), api.GetVenueParam{VenueID: testVenueID})
+	var rateErr *api.RateLimitError
+	if !errors.As(err, &rateErr) || rateErr.Step != "venue" || rateErr.RetryAfter != 2*time.Second {
+		t.Errorf("expected a venue RateLimitError with Retry-After, got %v", err)
+	}
+}
+
+func TestPrewarm(t *testing.T) {
+	srv, a, _ := setupFake(t)
+
+	if err := store.SaveCookies(context.Background(), testVenueID, nil, "test-agent/1.0", time.Hour); err != nil {
+		t.Fatalf("SaveCookies failed: %v", err)
+	}
+	rec := recordHeaders(srv, a)
+	if err := a.Prewarm(context.Background(), testVenueID); err != nil {
+		t.Fatalf("Prewarm failed: %v", err)
+	}
+	for _, sent := range rec.requests() {
+		if sent.userAgent != "test-agent/1.0" {
+			t.Errorf("expected stored user agent on %s, got %q", sent.path, sent.userAgent)
+		}
+	}
+	if got := srv.Hits("/3/venue"); got != 1 {
+		t.Errorf("expected one venue lookup, got %d", got)
+	}
+	if got := srv.Hits("/4/find"); got != 0 {
+		t.Errorf("expected no availability lookups, got %d", got)
+	}
+}
+
+// countingTransport counts requests before handing them to the fake
+type countingTransport struct {
+	next  http.RoundTripper
+	count int
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.count++
+	return c.next.RoundTrip(r)
+}
+
+// stalledTransport never answers, it only gives up with the request
+type stalledTransport struct{}
+
+func (stalledTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	<-r.Context().Done()
+	return nil, r.Context().Err()
+}
+
+func TestWithTransport(t *testing.T) {
+	srv, _, user := setupFake(t)
+	transport := &countingTransport{next: srv.Client().Transport}
+	a := resy.GetDefaultAPI(resy.WithTransport(transport))
+	a.BaseURL = srv.URL
+	a.APIKey = "test-key"
+
+	login(t, &a, user)
+	if transport.count != 1 {
+		t.Errorf("expected the login to go through the injected transport, got %d requests", transport.count)
+	}
+}
+
+func TestWithTimeouts(t *testing.T) {
+	a := resy.GetDefaultAPI(
+		resy.WithTransport(stalledTransport{}),
+		resy.WithTimeouts(resy.Timeouts{Login: 20 * time.Millisecond}),
+	)
+
+	_, err := a.Login(context.Background(), api.LoginParam{Email: "diner@example.com", Password: "hunter2"})
+	var netErr *api.NetworkError
+	if !errors.As(err, &netErr) || netErr.Step != "login" {
+		t.Fatalf("expected a login NetworkError, got %v", err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	srv, a, user := setupFake(t)
+	loginResp := login(t, a, user)
+
+	entry, err := a.Notify(context.Background(), api.NotifyParam{
+		VenueID:   testVenueID,
+		Date:      "2026-11-20",
+		PartySize: 2,
+		Earliest:  18*time.Hour + 30*time.Minute,
+		Latest:    21 * time.Hour,
+		LoginResp: loginResp,
+	})
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if entry.NotifyID == "" || entry.VenueID != testVenueID || entry.Earliest != 18*time.Hour+30*time.Minute || entry.Latest != 21*time.Hour {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	notifies := srv.Notifies()
+	if len(notifies) != 1 || notifies[0].Start != "18:30:00" || notifies[0].End != "21:00:00" || notifies[0].PartySize != 2 {
+		t.Fatalf("unexpected registrations %+v", notifies)
+	}
+
+	listed, err := a.ListNotifies(context.Background(), api.ListNotifiesParam{LoginResp: loginResp})
+	if err != nil {
+		t.Fatalf("ListNotifies failed: %v", err)
+	}
+	if len(listed.Entries) != 1 || listed.Entries[0] != *entry {
+		t.Errorf("expected the new entry to be listed, got %+v", listed.Entries)
+	}
+
+	if err := a.RemoveNotify(context.Background(), api.RemoveNotifyParam{NotifyID: entry.NotifyID, LoginResp: loginResp}); err != nil {
+		t.Fatalf("RemoveNotify failed: %v", err)
+	}
+	if got := len(srv.Notifies()); got != 0 {
+		t.Errorf("expected the registration to be removed, %d left", got)
+	}
+	// Already gone is not an error
+	if err := a.RemoveNotify(context.Background(), api.RemoveNotifyParam{NotifyID: entry.NotifyID, LoginResp: loginResp}); err != nil {
+		t.Errorf("expected removing a missing entry to succeed, got %v", err)
+	}
+}
+
+func TestNotify_OpenRange(t *testing.T) {
+	srv, a, user := setupFake(t)
+	loginResp := login(t, a, user)
+
+	if _, err := a.Notify(context.Background(), api.NotifyParam{VenueID: testVenueID, Date: "2026-11-20", PartySize: 4, LoginResp: loginResp}); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	notifies := srv.Notifies()
+	if len(notifies) != 1 || notifies[0].Start != "00:00:00" || notifies[0].End != "23:59:00" {
+		t.Errorf("expected the whole day, got %+v", notifies)
+	}
+}
+
+func TestNotify_Invalid(t *testing.T) {
+	srv, a, user := setupFake(t)
+	loginResp := login(t, a, user)
+
+	_, err := a.Notify(context.Background(), api.NotifyParam{VenueID: testVenueID, Date: "11/20/2026", PartySize: 2, LoginResp: loginResp})
+	if err == nil {
+		t.Fatal("expected a bad date to be rejected")
+	}
+	if got := srv.Hits("/3/notify"); got != 0 {
+		t.Errorf("expected no request for an invalid param, got %d", got)
+	}
+}
+
+func TestNotify_ExpiredToken(t *testing.T) {
+	_, a, _ := setupFake(t)
+
+	_, err := a.ListNotifies(context.Background(), api.ListNotifiesParam{LoginResp: api.LoginResponse{AuthToken: "stale"}})
+	if !errors.Is(err, api.ErrAuthExpired) {
+		t.Errorf("expected ErrAuthExpired, got %v", err)
+	}
+}
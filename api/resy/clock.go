@@ -0,0 +1,226 @@
+package resy
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limits on the samples a ClockSkew keeps. Older samples are dropped so
+// the estimate follows either clock drifting or being stepped
+const (
+	maxClockSamples = 32
+	clockSampleTTL  = time.Hour
+)
+
+// clockSamplesPerCalibration is how many requests Calibrate sends. The
+// first may pay for a new connection, later ones reuse it and so have a
+// shorter round trip and a tighter bound
+const clockSamplesPerCalibration = 3
+
+/*
+Name: ClockSkew
+Type: External Struct
+Purpose: Estimate how far Resy's clock is ahead of ours, so a job
+meant to fire when Resy releases tables fires by Resy's clock rather
+than ours. Safe for concurrent use
+Note: A Date header only has second precision and is stamped at some
+point during the round trip, so each response bounds the offset to
+[date - received, date + 1s - sent]. The estimate is the midpoint of
+the intersection of recent bounds, and its uncertainty half the
+width. Bounds are intersected newest first, and an older one that
+doesn't overlap is dropped with everything before it
+*/
+type ClockSkew struct {
+	mu      sync.Mutex
+	samples []clockSample // Oldest first
+}
+
+type clockSample struct {
+	low, high time.Duration
+	at        time.Time
+}
+
+/*
+Name: ClockEstimate
+Type: External Struct
+Purpose: A ClockSkew's current estimate. Resy's time is our time plus
+Offset, give or take Uncertainty
+*/
+type ClockEstimate struct {
+	Offset      time.Duration
+	Uncertainty time.Duration
+	Samples     int       // Samples the estimate is built from
+	UpdatedAt   time.Time // When the newest of them was taken
+}
+
+/*
+Name: NewClockSkew
+Type: External Func
+Purpose: A ClockSkew with no samples yet
+*/
+func NewClockSkew() *ClockSkew {
+	return &ClockSkew{}
+}
+
+/*
+Name: Observe
+Type: External Func
+Purpose: Add the bound from one response, given when its request was
+sent and its response received. Reports false and changes nothing if
+the response has no usable Date header
+*/
+func (c *ClockSkew) Observe(sent, received time.Time, header http.Header) bool {
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil || received.Before(sent) {
+		return false
+	}
+
+	sample := clockSample{
+		low:  date.Sub(received),
+		high: date.Add(time.Second).Sub(sent),
+		at:   received,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.samples = append(c.samples, sample)
+	c.prune(received)
+	return true
+}
+
+// prune drops samples past their TTL or the count limit. Callers hold mu
+func (c *ClockSkew) prune(now time.Time) {
+	first := 0
+	if len(c.samples) > maxClockSamples {
+		first = len(c.samples) - maxClockSamples
+	}
+	for first < len(c.samples) && now.Sub(c.samples[first].at) > clockSampleTTL {
+		first++
+	}
+	c.samples = append([]clockSample(nil), c.samples[first:]...)
+}
+
+/*
+Name: Estimate
+Type: External Func
+Purpose: The current estimate, or false if there are no samples yet
+*/
+func (c *ClockSkew) Estimate() (ClockEstimate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.samples) == 0 {
+		return ClockEstimate{}, false
+	}
+
+	newest := len(c.samples) - 1
+	low, high := c.samples[newest].low, c.samples[newest].high
+	used := 1
+	for i := newest - 1; i >= 0; i-- {
+		s := c.samples[i]
+		if s.high < low || s.low > high {
+			// The clocks moved since this sample, forget it and older ones
+			c.samples = append([]clockSample(nil), c.samples[i+1:]...)
+			break
+		}
+		if s.low > low {
+			low = s.low
+		}
+		if s.high < high {
+			high = s.high
+		}
+		used++
+	}
+
+	return ClockEstimate{
+		Offset:      low + (high-low)/2,
+		Uncertainty: (high - low) / 2,
+		Samples:     used,
+		UpdatedAt:   c.samples[len(c.samples)-1].at,
+	}, true
+}
+
+/*
+Name: Now
+Type: External Func
+Purpose: Our time corrected to Resy's clock, or plain time.Now()
+without samples
+*/
+func (c *ClockSkew) Now() time.Time {
+	if estimate, ok := c.Estimate(); ok {
+		return time.Now().Add(estimate.Offset)
+	}
+	return time.Now()
+}
+
+/*
+Name: ServiceNow
+Type: API Func
+Purpose: Resy implementation of api.ServiceClock, the time by Resy's
+clock as far as the API's ClockSkew knows, or time.Now() without one
+*/
+func (a *API) ServiceNow() time.Time {
+	if a.Clock == nil {
+		return time.Now()
+	}
+	return a.Clock.Now()
+}
+
+/*
+Name: observeClock
+Type: Internal Func
+Purpose: Feed a response's Date header to the API's ClockSkew, if it
+has one
+*/
+func (a *API) observeClock(sent time.Time, response *http.Response) {
+	if a.Clock != nil {
+		a.Clock.Observe(sent, time.Now(), response.Header)
+	}
+}
+
+/*
+Name: Calibrate
+Type: API Func
+Purpose: Sample Resy's clock into the API's ClockSkew with a few HEAD
+requests to the API root. Any response carries a Date header, so the
+status is ignored, and an error is only returned if no request got
+a response
+Note: Does nothing without a Clock
+*/
+func (a *API) Calibrate(ctx context.Context) error {
+	if a.Clock == nil {
+		return nil
+	}
+
+	calibrateCtx, calibrateCancel := context.WithTimeout(ctx, a.timeouts().Other)
+	defer calibrateCancel()
+
+	var lastErr error
+	answered := 0
+	for i := 0; i < clockSamplesPerCalibration; i++ {
+		request, err := http.NewRequestWithContext(calibrateCtx, "HEAD", a.endpoint("/"), nil)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Authorization", `ResyAPI api_key="`+a.APIKey+`"`)
+		a.addCookiesToRequest(request)
+
+		sent := time.Now()
+		response, err := a.httpClient().Do(request)
+		if err != nil {
+			lastErr = stepError(ctx, "calibrate", err)
+			if ctx.Err() != nil {
+				return lastErr
+			}
+			continue
+		}
+		a.observeClock(sent, response)
+		response.Body.Close()
+		answered++
+	}
+	if answered > 0 {
+		return nil
+	}
+	return lastErr
+}
@@ -0,0 +1,128 @@
+package resy_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api/resy"
+)
+
+// dateHeader is the Date header a server whose clock reads serverTime sends
+func dateHeader(serverTime time.Time) http.Header {
+	header := http.Header{}
+	header.Set("Date", serverTime.UTC().Format(http.TimeFormat))
+	return header
+}
+
+func TestClockSkew(t *testing.T) {
+	base := time.Date(2026, 11, 20, 9, 0, 0, 0, time.UTC)
+	ahead := 2300 * time.Millisecond
+
+	tests := []struct {
+		name            string
+		sends           []time.Duration // Local send times, after base
+		rtt             time.Duration
+		offset          time.Duration
+		wantUncertainty time.Duration // At most
+		wantSamples     int
+	}{
+		{
+			name:            "single sample",
+			sends:           []time.Duration{0},
+			rtt:             100 * time.Millisecond,
+			offset:          ahead,
+			wantUncertainty: 550 * time.Millisecond,
+			wantSamples:     1,
+		},
+		{
+			name:            "samples across a second boundary narrow the bound",
+			sends:           []time.Duration{0, 350 * time.Millisecond, 700 * time.Millisecond},
+			rtt:             40 * time.Millisecond,
+			offset:          ahead,
+			wantUncertainty: 200 * time.Millisecond,
+			wantSamples:     3,
+		},
+		{
+			name:            "clock behind",
+			sends:           []time.Duration{0, 500 * time.Millisecond},
+			rtt:             40 * time.Millisecond,
+			offset:          -ahead,
+			wantUncertainty: 300 * time.Millisecond,
+			wantSamples:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := resy.NewClockSkew()
+			for _, send := range tt.sends {
+				sent := base.Add(send)
+				// Stamped halfway through the round trip
+				stamped := sent.Add(tt.rtt / 2).Add(tt.offset)
+				if !clock.Observe(sent, sent.Add(tt.rtt), dateHeader(stamped)) {
+					t.Fatal("expected the sample to be used")
+				}
+			}
+
+			estimate, ok := clock.Estimate()
+			if !ok {
+				t.Fatal("expected an estimate")
+			}
+			if diff := estimate.Offset - tt.offset; diff > estimate.Uncertainty || -diff > estimate.Uncertainty {
+				t.Errorf("expected %v within %v ± %v", tt.offset, estimate.Offset, estimate.Uncertainty)
+			}
+			if estimate.Uncertainty > tt.wantUncertainty {
+				t.Errorf("expected uncertainty at most %v, got %v", tt.wantUncertainty, estimate.Uncertainty)
+			}
+			if estimate.Samples != tt.wantSamples {
+				t.Errorf("expected %d samples, got %d", tt.wantSamples, estimate.Samples)
+			}
+		})
+	}
+}
+
+func TestClockSkew_Stepped(t *testing.T) {
+	base := time.Date(2026, 11, 20, 9, 0, 0, 0, time.UTC)
+	clock := resy.NewClockSkew()
+
+	clock.Observe(base, base.Add(50*time.Millisecond), dateHeader(base.Add(5*time.Second)))
+	clock.Observe(base.Add(time.Minute), base.Add(time.Minute+50*time.Millisecond), dateHeader(base.Add(time.Minute)))
+
+	estimate, _ := clock.Estimate()
+	if estimate.Samples != 1 || estimate.Offset > time.Second || estimate.Offset < -time.Second {
+		t.Fatalf("expected only the newest sample to count, got %+v", estimate)
+	}
+}
+
+func TestClockSkew_NoDate(t *testing.T) {
+	clock := resy.NewClockSkew()
+	if clock.Observe(time.Now(), time.Now(), http.Header{}) {
+		t.Error("expected a response without a Date header to be ignored")
+	}
+	if _, ok := clock.Estimate(); ok {
+		t.Error("expected no estimate without samples")
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	srv, a, _ := setupFake(t)
+	a.Clock = resy.NewClockSkew()
+
+	if err := a.Calibrate(context.Background()); err != nil {
+		t.Fatalf("Calibrate failed: %v", err)
+	}
+	if got := srv.Hits("/"); got != 3 {
+		t.Errorf("expected 3 calibration requests, got %d", got)
+	}
+
+	// The fake shares our clock
+	estimate, ok := a.Clock.Estimate()
+	if !ok {
+		t.Fatal("expected an estimate")
+	}
+	if estimate.Offset > estimate.Uncertainty || -estimate.Offset > estimate.Uncertainty {
+		t.Errorf("expected an offset of 0 within %v ± %v", estimate.Offset, estimate.Uncertainty)
+	}
+}
@@ -60,6 +60,22 @@ APIKey:
 
         Authorization: ResyAPI api_key="###KEY###"
 
+    Every URL in this document is shown against the production host,
+    https://api.resy.com. The API struct sends requests to 'BaseURL'
+    instead when it is set, and through 'Client' when that is set, so
+    tests can point it at the fake server in api/resy/resytest.
+
+    Responses from the login, find, details and book steps are
+    decoded into typed structs (see payloads.go). Fields we rely on
+    are checked for presence and type first, and a mismatch is an
+    api.SchemaError naming the step and JSON path. The '...' in the
+    bodies below stands for the many fields Resy sends that we don't
+    read; these are ignored unless the API struct's 'StrictSchema' is
+    set, in which case they are reported as unknown fields too. A 2xx
+    book response without a reservation_id is reported the same way
+    rather than retried on another slot, since the table may already
+    have been booked.
+
 **********************************************************************
 
 Login: 
@@ -129,9 +145,15 @@ Search:
         Body:
             
             {
-                "query": "###NAME###"
+                "query": "###NAME###",
+                "geo": {"latitude": ###LAT###, "longitude": ###LNG###, "radius": ###M###},
+                "per_page": ###LIM###,
+                "page": ###PAGE###
             }
 
+    Where everything but "query" is only sent when asked for. The
+    body is built with encoding/json, so names with quotes are safe.
+
     We also include in this request's headers the follwoing:
 
         Headers:
@@ -165,6 +187,9 @@ Search:
                                     "region": "###REG###",
                                     "locality": "###LOC###",
                                     "neighborhood": "###NEIG###",
+                                    "cuisine": ["###CUIS###", ...],
+                                    "price_range_id": ###PRICE###,
+                                    "_geoloc": {"lat": ###LAT###, "lng": ###LNG###},
                                     ...
                                 },
                                 ...
@@ -175,7 +200,15 @@ Search:
             }
 
     The ###ID### token is relevant to later requests regarding reservations 
-    at the specified venue. 
+    at the specified venue. Neighborhood, cuisine and price filters are
+    not sent to Resy and are applied to the hits instead. When any of
+    them is set, a page of ###LIM### hits can hold fewer matches, so
+    Search asks for the following pages too, up to maxSearchPages of
+    them, until ###LIM### venues match or a page comes back short.
+    ###PAGE### is always one of Resy's unfiltered pages, and is where
+    that reading starts. Matches past ###LIM### are left for the next
+    call: NextPage names the page they're on and NextOffset how many
+    of its matches were already returned.
 
 **********************************************************************
 
@@ -256,6 +289,13 @@ Reserve:
     slot used in the next request-response interaction. The string token ###TABLETYPE###
     is the type of table that this slot corresponds to.
 
+    When the reservation times span several days, one find request is
+    sent per distinct day (in NYC time) and the slots are pooled
+    before matching. A day whose find fails is skipped, and its error
+    is only returned if no day had a slot. Each time is matched to a slot with the param's
+    TimeWindow (see the api pkg), and the details request below is 
+    then sent with the day of the slot that was picked.
+
     The next pair of HTTP messages is informally referred to as the 'config' 
     step. We send a GET request with no body. The request has a dynamic URL:
 
@@ -279,7 +319,31 @@ Reserve:
                 ...
             }
 
-    Where ###BTOKEN### is an identifier used in the next step.
+    Where ###BTOKEN### is an identifier used in the next step. For a slot
+    with fees, the body also states the terms of booking it:
+
+            {
+                ...
+                "payment":
+                    {
+                        "amount": ###DEPOSIT###,
+                        "config": { "type": "###PAYTYPE###" }
+                    },
+                "cancellation":
+                    {
+                        "fee": { "amount": ###FEE###, "date_cut_off": "###CUTOFF###" },
+                        "display": { "policy": ["###POLICY###", ...] }
+                    },
+                ...
+            }
+
+    Where ###PAYTYPE### is "free" when no card is needed and ###CUTOFF###
+    is an RFC 3339 time after which cancelling costs ###FEE###. These
+    become the BookingTerms of the ReserveResponse. Asking with
+    commit=1 holds the table for the final step. A dry run
+    (ReserveParam.DryRun) asks with commit=0 instead, which states the
+    same terms but leaves out the book_token and holds nothing, and
+    then stops here and returns the slot and its terms.
 
     The final step, denoted 'reserve', is where the reservation curated in the 
     past 2 steps is finalized and made persistent on Resy servers. It is a POST
@@ -300,7 +364,9 @@ Reserve:
 
         {"id":###PID###} 
     
-    Where ###PID### is the payment method id from the login api function response. We use login
+    Where ###PID### is the payment method id from the login api function response. Resy has one
+    free-text field for the venue, so any SpecialRequests are sent as their Note, url encoded, 
+    in an extra &special_request= parameter of the body. We use login
     headers as well as the following:
 
         Headers:
@@ -308,6 +374,297 @@ Reserve:
             Referer: https://resy.com/
 
     If the server response is any 200 code, the reservation has been made.    
+    The body identifies it:
+
+        Body:
+
+            {
+                "resy_token": "###RTOKEN###",
+                "reservation_id": ###RID###,
+                ...
+            }
+
+    ###RID### becomes the ReservationID of the ReserveResponse and 
+    ###RTOKEN###, which 'Cancel' takes, its ReservationToken.
+
+**********************************************************************
+
+Availability:
+
+    The Availability function is the 'find' step of Reserve on its own.
+    It sends the same find request for the given day and returns the
+    slots of the matching venue, stopping before the 'config' step, so
+    nothing is held on Resy's side. Alongside 'date' and 'config', each
+    slot in the find response carries a 'payment' object:
+
+        "payment":
+            {
+                ...
+                "is_paid": ###PAID###,
+                "deposit_fee": ###DEP###,
+                "cancellation_fee": ###CXL###,
+                ...
+            }
+
+    Where ###PAID### is a boolean and ###DEP### and ###CXL### are
+    dollar amounts or null. The auth token headers are optional here.
+
+**********************************************************************
+
+Cancel:
+
+    The Cancel function undoes a booked reservation. It is a POST of 
+    url-encoded form data to:
+
+        https://api.resy.com/3/cancel
+
+    With ###UERTOKEN### being the url-encoded resy_token of the 
+    reservation (returned when booking and when listing a user's
+    reservations):
+
+        Body:
+
+            resy_token=###UERTOKEN###
+
+    The request uses the standard APIKey and Login headers, plus the
+    Origin and Referer headers used by Search. The response only 
+    matters to us for refund reporting:
+
+        Body:
+
+            {
+                ...
+                "payment":
+                    {
+                        ...
+                        "transaction":
+                            {
+                                ...
+                                "refund": ###REF###,
+                                "amount": ###AMT###,
+                                ...
+                            },
+                        ...
+                    },
+                ...
+            }
+
+    Where ###REF### is 1 (or true) if a charge was refunded. The
+    'payment' object is missing entirely for free reservations.
+    Cancelling a reservation that was already cancelled fails with a
+    4xx whose message says so, which Cancel reports as
+    ErrAlreadyCancelled.
+
+**********************************************************************
+
+ListReservations:
+
+    The ListReservations function fetches the user's upcoming 
+    reservations with a GET to:
+
+        https://api.resy.com/3/user/reservations?type=upcoming&book_on_behalf_of=false&limit=###LIM###
+
+    Where the limit query value is optional. It uses the standard 
+    APIKey and Login headers. The response has the structure:
+
+        Body:
+
+            {
+                ...
+                "reservations":
+                    [
+                        ...
+                        {
+                            ...
+                            "resy_token": "###RTOKEN###",
+                            "day": "###YR###-###MT###-###DY###",
+                            "time_slot": "###HR###:###MN###:###SC###",
+                            "num_seats": ###PS###,
+                            "venue": { "id": ###ID###, ... },
+                            "config": { "type": "###TABLETYPE###", ... },
+                            ...
+                        },
+                        ...
+                    ],
+                "venues":
+                    {
+                        "###ID###": { "name": "###NAME###", ... },
+                        ...
+                    },
+                ...
+            }
+
+    Where day and time_slot are in the venue's local timezone and
+    ###RTOKEN### is the value the 'Cancel' section calls resy_token.
+    A reservation missing its token, day, time or venue id fails the
+    call with a SchemaError rather than being dropped, since the
+    burst relies on the list to tell whether an uncertain book went
+    through.
+
+**********************************************************************
+
+GetVenue:
+
+    Venue details come from a GET with no body and no login:
+
+        https://api.resy.com/3/venue?id=###ID###
+
+    The request uses the standard APIKey header and the venue's
+    Imperva cookies. A 404 means the venue doesn't exist. The
+    response has the relevant structure:
+
+        Body:
+
+            {
+                "id": {"resy": ###ID###},
+                "name": "###NAME###",
+                "url_slug": "###SLUG###",
+                "location":
+                    {
+                        "address_1": "###ADDR###",
+                        "locality": "###LOC###",
+                        "time_zone": "###TZ###",
+                        ...
+                    },
+                "config":
+                    {
+                        "lead_time_in_days": ###DAYS###,
+                        "release_time": "###HR###:###MN###",
+                        "seating_types": ["###TABLETYPE###", ...],
+                        ...
+                    },
+                "payment":
+                    {
+                        "deposit_fee": ###DEP###,
+                        "cancellation_fee": ###CXL###,
+                        "cancellation_cutoff_hours": ###HRS###,
+                        "policy": "###TEXT###"
+                    },
+                ...
+            }
+
+    Where "payment" is null for venues without fees, a
+    lead_time_in_days of 0 means Resy doesn't say when tables are
+    released, and a missing or unknown ###TZ### is taken to be NYC.
+
+**********************************************************************
+
+Errors:
+
+    Resy's error bodies have the relevant structure:
+
+        {"status": ###CODE###, "message": "###MESSAGE###"}
+
+    A failed response at any step is mapped to the most specific api
+    error it allows:
+
+        429                         RateLimitError, with Retry-After
+        401, 419 (after Login)      AuthError
+        402, or "payment" at book   ErrPaymentDeclined
+        "party size" in message     ErrPartySize
+        "closed" in message         ErrVenueClosed
+        "already" at cancel         ErrAlreadyCancelled
+        404/409/410/412 at details
+        or book                     ErrSlotTaken
+
+    and anything else is a NetworkError. Messages are only read for
+    a 4xx, so a 5xx is a NetworkError whatever it mentions. A slot taken at details or
+    book moves Reserve on to the next candidate, as does a 4xx from
+    book it can't explain; the other refusals would fail every
+    slot alike, so they end the call. A book that fails with a 5xx,
+    times out or loses its connection may have booked the table
+    anyway, so it ends the call with an UncertainBookError naming
+    the slot rather than risking a second booking.
+
+    Resy lists a closed day on /4/find like a booked-up one, with no
+    slots. When find comes back empty for every requested day,
+    Reserve asks the venue calendar:
+
+        https://api.resy.com/4/venue/calendar?venue_id=###ID###&num_seats=###PS###&start_date=###DAY###&end_date=###DAY###
+
+    whose body lists each day's inventory:
+
+        {"scheduled": [{"date": "###DAY###", "inventory": {"reservation": "closed"}, ...}]}
+
+    with "available", "sold-out" or "closed", and reports
+    ErrVenueClosed only if every requested day is "closed".
+
+**********************************************************************
+
+Connections:
+
+    Every API without its own Client shares one http.Client built on
+    NewTransport, which keeps connections alive between calls and
+    speaks HTTP/2 when Resy offers it, so a snipe doesn't pay for TCP
+    and TLS setup. WithTransport swaps the RoundTripper, for a fake
+    in tests or a transport shared with other code:
+
+        a := resy.GetDefaultAPI(resy.WithTransport(transport))
+
+    Each step runs under its own deadline, derived from the caller's
+    context, so a slow find can't eat into the time left for book.
+    DefaultTimeouts gives 10 seconds for login and search and 12 for
+    find, details and book; WithTimeouts overrides any of them.
+
+    Prewarm loads a venue's cookies and sends the venue lookup from
+    the 'GetVenue' section, discarding the response, to open a
+    connection ahead of a scheduled Reserve. A challenge on the way is
+    retried like on any other call, so cookies Imperva hands out are
+    stored for the route before the run time; if it keeps turning the
+    cookies down Prewarm fails with api.ErrImperva.
+
+    An API with a Clock (see NewClockSkew) also estimates Resy's
+    clock from the Date header of every Prewarm and Calibrate
+    response. Calibrate sends a few HEAD requests to the API root,
+    where any status will do. A Date header is only precise to the
+    second and stamped somewhere within the round trip, so each
+    response bounds the offset, and intersecting recent bounds
+    narrows it well below a second. ServiceNow is time.Now() moved
+    by the estimate.
+
+    An API with an Egress pool sends each call through one of the 
+    pool's proxies (see the egress pkg), picked by the user set with
+    egress.WithUser on the context, the auth token, or the venue. 
+    The pick is made once per call and every request of the call 
+    uses it. Cookies are loaded for that proxy only, as Imperva ties
+    them to the address that solved the challenge, and an Imperva 
+    challenge that outlasts the retries rotates the user or venue 
+    onto another proxy for its next call. Calibrate always goes 
+    direct. WithEgress sets the pool.
+
+    One API is safe to share between goroutines. Each call keeps its
+    cookies and user agent in its own cookie jar, seeded from the 
+    venue's stored cookies (or the API's Cookies and UserAgent when
+    it has none, or the call has no venue) and fed any cookies an 
+    Imperva challenge hands out while it runs, and drops the jar when
+    it returns. Nothing a call does is written back to the API, so a
+    handler booking one venue and the scheduler booking another never
+    send each other's cookies. SetCookies is meant for setup, before
+    the API is shared. When Imperva hands a call for a venue new
+    cookies and then lets it through, the call's cookies replace the
+    venue's stored ones for the proxy it went through (or direct),
+    keeping their TTL, so the next call starts from them.
+
+    An API with a Recorder (see WithRecorder and the httprec pkg) 
+    keeps every request and response of calls made under an 
+    httprec.WithAttempt context, redacted, whether sent direct or
+    through a proxy. An httprec.Replayer plays such a recording back
+    as the API's transport, so a real incident becomes a test:
+
+        exchanges, _ := httprec.ReadFile("testdata/httprec/slot_taken.jsonl")
+        a := resy.API{Client: &http.Client{Transport: httprec.NewReplayer(exchanges)}}
+
+**********************************************************************
+
+Notify:
+
+    Resy's notify list is a set of /3/notify calls made with the 
+    user's auth token: POST to join (venue_id, day, num_seats and a
+    time_preferred_start/end pair as "HH:MM:SS"), GET to list and 
+    DELETE with ?id= to leave. An open Earliest or Latest is sent 
+    as 00:00:00 or 23:59:00, since Resy wants both ends. Errors are
+    mapped as in the 'Errors' section at the "notify" step, and a 
+    404 on DELETE counts as already removed.
 
 **********************************************************************
 */
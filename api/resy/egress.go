@@ -0,0 +1,121 @@
+package resy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+
+	"github.com/21Bruce/resolved-server/egress"
+)
+
+/*
+Name: route
+Type: Internal Struct
+Purpose: The egress proxy a call was assigned and the stickiness key
+it was assigned under, carried on the call's context so every request
+the call makes, and the cookies it sends, go the same way
+Note: A nil proxy is a direct connection
+*/
+type route struct {
+	key   string
+	proxy *egress.Proxy
+}
+
+type routeKey struct{}
+
+/*
+Name: WithEgress
+Type: External Func
+Purpose: Send requests through a pool of proxies, see API.Egress
+*/
+func WithEgress(pool *egress.Pool) Option {
+	return func(a *API) {
+		a.Egress = pool
+	}
+}
+
+/*
+Name: routed
+Type: Internal Func
+Purpose: Pick the proxy for a call on behalf of the user in ctx (see
+egress.WithUser), or the holder of authToken, at venueID, and return a
+context carrying it. A context that already has a route keeps it
+Note: With no healthy proxy left the call goes direct rather than not
+at all
+*/
+func (a *API) routed(ctx context.Context, venueID int64, authToken string) context.Context {
+	if a.Egress == nil {
+		return ctx
+	}
+	if _, ok := ctx.Value(routeKey{}).(route); ok {
+		return ctx
+	}
+
+	user := egress.UserFrom(ctx)
+	if user == "" && authToken != "" {
+		// Stable per account without putting the token in logs
+		sum := sha256.Sum256([]byte(authToken))
+		user = "token:" + hex.EncodeToString(sum[:8])
+	}
+	key := a.Egress.Key(user, venueID)
+	proxy, err := a.Egress.Pick(key)
+	if err != nil {
+		log.Printf("egress: %v for %s, going direct", err, key)
+	}
+	return context.WithValue(ctx, routeKey{}, route{key: key, proxy: proxy})
+}
+
+/*
+Name: routeFrom
+Type: Internal Func
+Purpose: The route set by routed, the zero route (direct) if none
+*/
+func routeFrom(ctx context.Context) route {
+	r, _ := ctx.Value(routeKey{}).(route)
+	return r
+}
+
+/*
+Name: proxyID
+Type: Internal Func
+Purpose: The ID of the proxy ctx is routed through, empty for direct.
+Cookies are stored and loaded under it
+*/
+func proxyID(ctx context.Context) string {
+	if p := routeFrom(ctx).proxy; p != nil {
+		return p.ID
+	}
+	return ""
+}
+
+/*
+Name: clientFor
+Type: Internal Func
+Purpose: The http.Client for the proxy ctx is routed through, or the
+usual httpClient for a direct route
+*/
+func (a *API) clientFor(ctx context.Context) *http.Client {
+	if p := routeFrom(ctx).proxy; p != nil {
+		return a.recorded(a.Egress.Client(p))
+	}
+	return a.httpClient()
+}
+
+/*
+Name: rotateOnImperva
+Type: Internal Func
+Purpose: Move the key of the route ctx carries off its proxy after an
+Imperva challenge that retries didn't get past, so the next call for
+that user or venue leaves from another address
+*/
+func (a *API) rotateOnImperva(ctx context.Context) {
+	r := routeFrom(ctx)
+	if a.Egress == nil || r.proxy == nil {
+		return
+	}
+	if _, err := a.Egress.Rotate(r.key, r.proxy); err != nil {
+		log.Printf("egress: couldn't rotate %s off %s: %v", r.key, r.proxy.ID, err)
+	}
+}
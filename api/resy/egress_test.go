@@ -0,0 +1,201 @@
+package resy_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+	"github.com/21Bruce/resolved-server/api/resy"
+	"github.com/21Bruce/resolved-server/api/resy/resytest"
+	"github.com/21Bruce/resolved-server/egress"
+	"github.com/21Bruce/resolved-server/store"
+)
+
+// recordingProxy is an HTTP proxy in front of the fake that records the
+// Cookie header of every request through it
+type recordingProxy struct {
+	*httptest.Server
+	mu        sync.Mutex
+	cookies   []string
+	challenge bool
+}
+
+func newRecordingProxy(t *testing.T, srv *resytest.Server) *recordingProxy {
+	t.Helper()
+	p := &recordingProxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.cookies = append(p.cookies, r.Header.Get("Cookie"))
+		challenge := p.challenge
+		p.challenge = false
+		p.mu.Unlock()
+		if challenge {
+			w.Header().Set("X-Cdn", "Imperva")
+			w.Header().Add("Set-Cookie", "incap_ses_1=fresh; Path=/")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("Request unsuccessful. Incapsula incident ID"))
+			return
+		}
+		srv.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// challengeNext answers the next request through the proxy with an
+// Imperva challenge that hands out a fresh cookie
+func (p *recordingProxy) challengeNext() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.challenge = true
+}
+
+func (p *recordingProxy) requests() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.cookies...)
+}
+
+// setupEgress puts two recording proxies, "a" and "b", in front of the
+// fake and routes the API through them
+func setupEgress(t *testing.T, srv *resytest.Server, a *resy.API) (*egress.Pool, map[string]*recordingProxy) {
+	t.Helper()
+	proxies := map[string]*recordingProxy{
+		"a": newRecordingProxy(t, srv),
+		"b": newRecordingProxy(t, srv),
+	}
+	parsed, err := egress.ParseProxies("a=" + proxies["a"].URL + ",b=" + proxies["b"].URL)
+	if err != nil {
+		t.Fatalf("ParseProxies failed: %v", err)
+	}
+	pool := egress.NewPool(parsed)
+	resy.WithEgress(pool)(a)
+	return pool, proxies
+}
+
+func TestEgress_CookiesBoundToProxy(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+	)
+	auth := login(t, a, user)
+	pool, proxies := setupEgress(t, srv, a)
+
+	ctx := context.Background()
+	for id, value := range map[string]string{"": "direct", "a": "minted-a", "b": "minted-b"} {
+		cookies := []*http.Cookie{{Name: "incap_ses_1", Value: value}}
+		if err := store.SaveCookiesVia(ctx, testVenueID, id, cookies, "test-agent/1.0", time.Hour); err != nil {
+			t.Fatalf("SaveCookiesVia failed: %v", err)
+		}
+	}
+
+	userCtx := egress.WithUser(ctx, "user_1")
+	if _, err := a.Reserve(userCtx, reserveParam(t, auth, "2026-11-20 19:00")); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+
+	picked, _ := pool.Pick(pool.Key("user_1", testVenueID))
+	other := "a"
+	if picked.ID == "a" {
+		other = "b"
+	}
+
+	requests := proxies[picked.ID].requests()
+	if len(requests) == 0 {
+		t.Fatalf("expected the reservation to go through proxy %s", picked.ID)
+	}
+	if n := len(proxies[other].requests()); n != 0 {
+		t.Errorf("expected nothing through proxy %s, got %d requests", other, n)
+	}
+	for _, cookie := range requests {
+		if cookie != "incap_ses_1=minted-"+picked.ID {
+			t.Errorf("expected only proxy %s's cookies, got %q", picked.ID, cookie)
+		}
+	}
+}
+
+func TestEgress_RotateOnImperva(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+	)
+	auth := login(t, a, user)
+	pool, proxies := setupEgress(t, srv, a)
+
+	ctx := egress.WithUser(context.Background(), "user_1")
+	key := pool.Key("user_1", testVenueID)
+	first, _ := pool.Pick(key)
+
+	srv.SetScenario(resytest.ScenarioImperva)
+	if _, err := a.Reserve(ctx, reserveParam(t, auth, "2026-11-20 19:00")); !errors.Is(err, api.ErrImperva) {
+		t.Fatalf("expected ErrImperva, got %v", err)
+	}
+
+	next, _ := pool.Pick(key)
+	if next.ID == first.ID {
+		t.Fatalf("expected the user to move off proxy %s", first.ID)
+	}
+
+	srv.SetScenario(resytest.ScenarioNormal)
+	if _, err := a.Reserve(ctx, reserveParam(t, auth, "2026-11-20 19:00")); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if len(proxies[next.ID].requests()) == 0 {
+		t.Errorf("expected the retry to go through proxy %s", next.ID)
+	}
+}
+
+func TestEgress_PrewarmStoresRefreshedCookies(t *testing.T) {
+	srv, a, _ := setupFake(t)
+	pool, proxies := setupEgress(t, srv, a)
+
+	ctx := context.Background()
+	for _, id := range []string{"", "a", "b"} {
+		cookies := []*http.Cookie{{Name: "incap_ses_1", Value: "stale"}}
+		if err := store.SaveCookiesVia(ctx, testVenueID, id, cookies, "test-agent/1.0", time.Hour); err != nil {
+			t.Fatalf("SaveCookiesVia failed: %v", err)
+		}
+	}
+
+	picked, _ := pool.Pick(pool.Key("user_1", testVenueID))
+	proxies[picked.ID].challengeNext()
+	if err := a.Prewarm(egress.WithUser(ctx, "user_1"), testVenueID); err != nil {
+		t.Fatalf("Prewarm failed: %v", err)
+	}
+	if got := srv.Hits("/3/venue"); got != 1 {
+		t.Errorf("expected the venue lookup to be retried past the challenge, got %d", got)
+	}
+
+	// Only the route that was challenged has new cookies
+	for _, id := range []string{"", "a", "b"} {
+		stored, err := store.GetCookiesVia(ctx, testVenueID, id)
+		if err != nil {
+			t.Fatalf("GetCookiesVia(%q) failed: %v", id, err)
+		}
+		want := "stale"
+		if id == picked.ID {
+			want = "fresh"
+		}
+		if len(stored.Cookies) != 1 || stored.Cookies[0].Value != want {
+			t.Errorf("expected %s cookies for route %q, got %v", want, id, stored.Cookies)
+		}
+	}
+}
+
+func TestEgress_PrewarmImpervaUnresolved(t *testing.T) {
+	srv, a, _ := setupFake(t)
+	pool, _ := setupEgress(t, srv, a)
+
+	key := pool.Key("user_1", testVenueID)
+	first, _ := pool.Pick(key)
+	srv.SetScenario(resytest.ScenarioImperva)
+	if err := a.Prewarm(egress.WithUser(context.Background(), "user_1"), testVenueID); !errors.Is(err, api.ErrImperva) {
+		t.Fatalf("expected ErrImperva, got %v", err)
+	}
+	if next, _ := pool.Pick(key); next.ID == first.ID {
+		t.Errorf("expected the user to move off proxy %s", first.ID)
+	}
+}
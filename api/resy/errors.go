@@ -0,0 +1,102 @@
+package resy
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+/*
+Name: responseError
+Type: Internal Func
+Purpose: Turn a failed response into the most specific api error its
+status and message allow, falling back to an api.NetworkError. Rate
+limiting and a rejected auth token look the same at every step; the
+refusals depend on the message, or on the step for a bare status
+Note: 419 is the auth answer everywhere but Login, which checks for
+it first since there it means the password was wrong. The message
+is only read for a 4xx: a 5xx is a failure whatever it mentions, and
+at the book step may still have booked the table
+*/
+func responseError(step string, response *http.Response, body []byte) error {
+	status := response.StatusCode
+	message := errorMessage(body)
+	lower := strings.ToLower(message)
+
+	switch {
+	case status == http.StatusTooManyRequests:
+		return api.NewRateLimitError(step, retryAfter(response.Header))
+	case status == http.StatusUnauthorized || status == 419:
+		return api.NewAuthError(step, status)
+	case status < http.StatusBadRequest || status >= http.StatusInternalServerError:
+		return api.NewNetworkError(step, status, message)
+	case status == http.StatusPaymentRequired || (step == "book" && strings.Contains(lower, "payment")):
+		return api.NewRefusalError(step, status, api.ErrPaymentDeclined, message)
+	case strings.Contains(lower, "party size") || strings.Contains(lower, "party_size"):
+		return api.NewRefusalError(step, status, api.ErrPartySize, message)
+	case step == "cancel" && strings.Contains(lower, "already"):
+		return api.NewRefusalError(step, status, api.ErrAlreadyCancelled, message)
+	case strings.Contains(lower, "closed"):
+		return api.NewRefusalError(step, status, api.ErrVenueClosed, message)
+	case (step == "detail" || step == "book") && isGoneStatus(status):
+		return api.NewRefusalError(step, status, api.ErrSlotTaken, message)
+	}
+	return api.NewNetworkError(step, status, message)
+}
+
+/*
+Name: isGoneStatus
+Type: Internal Func
+Purpose: Report whether a details or book status means the slot or
+its book token is no longer there, which is how Resy answers when
+someone else booked the slot first
+*/
+func isGoneStatus(status int) bool {
+	switch status {
+	case http.StatusNotFound, http.StatusConflict, http.StatusGone, http.StatusPreconditionFailed:
+		return true
+	}
+	return false
+}
+
+/*
+Name: errorMessage
+Type: Internal Func
+Purpose: The message from a Resy error body, {"status": ..., "message":
+"..."}, or the start of the raw body if it isn't one
+*/
+func errorMessage(body []byte) string {
+	var payload struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Message != "" {
+		return payload.Message
+	}
+	return truncateForLog(body, 200)
+}
+
+/*
+Name: retryAfter
+Type: Internal Func
+Purpose: Parse a Retry-After header, given in seconds or as a date,
+returning 0 if it's missing or unreadable
+*/
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
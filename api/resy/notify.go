@@ -0,0 +1,202 @@
+package resy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+// notifyServiceType is Resy's service_type_id for dinner reservations,
+// the only kind the notify list is kept for here
+const notifyServiceType = "2"
+
+/*
+Name: Notify
+Type: API Func
+Purpose: Resy implementation of api.Notifier's Notify. Adds the user
+to the venue's notify list for the day, party size and time range
+Note: An open end of the range is sent as the start or end of the day,
+Resy wants both
+*/
+func (a *API) Notify(ctx context.Context, params api.NotifyParam) (*api.WaitlistEntry, error) {
+	ctx = a.begin(ctx, params.VenueID, params.LoginResp.AuthToken)
+	defer a.end(ctx)
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	latest := params.Latest
+	if latest == 0 {
+		latest = 24*time.Hour - time.Minute
+	}
+	form := url.Values{}
+	form.Set("venue_id", strconv.FormatInt(params.VenueID, 10))
+	form.Set("day", params.Date)
+	form.Set("num_seats", strconv.Itoa(params.PartySize))
+	form.Set("time_preferred_start", formatResyClock(params.Earliest))
+	form.Set("time_preferred_end", formatResyClock(latest))
+	form.Set("service_type_id", notifyServiceType)
+
+	responseBody, err := a.notifyRequest(ctx, "POST", "/3/notify", form, params.LoginResp.AuthToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var created notifyResponse
+	if err := decodePayload("notify", responseBody, &created, a.StrictSchema); err != nil {
+		return nil, err
+	}
+	entry, err := waitlistEntry(created.Notify, "notify")
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+/*
+Name: ListNotifies
+Type: API Func
+Purpose: Resy implementation of api.Notifier's ListNotifies
+*/
+func (a *API) ListNotifies(ctx context.Context, params api.ListNotifiesParam) (*api.ListNotifiesResponse, error) {
+	ctx = a.begin(ctx, 0, params.LoginResp.AuthToken)
+	defer a.end(ctx)
+	responseBody, err := a.notifyRequest(ctx, "GET", "/3/notify", nil, params.LoginResp.AuthToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var listed notifyListResponse
+	if err := decodePayload("notify", responseBody, &listed, a.StrictSchema); err != nil {
+		return nil, err
+	}
+
+	entries := make([]api.WaitlistEntry, 0, len(listed.Notifies))
+	for i, raw := range listed.Notifies {
+		entry, err := waitlistEntry(raw, fmt.Sprintf("notifies[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return &api.ListNotifiesResponse{Entries: entries}, nil
+}
+
+/*
+Name: RemoveNotify
+Type: API Func
+Purpose: Resy implementation of api.Notifier's RemoveNotify. A 404
+means Resy already dropped the entry, e.g. because the day passed
+*/
+func (a *API) RemoveNotify(ctx context.Context, params api.RemoveNotifyParam) error {
+	ctx = a.begin(ctx, 0, params.LoginResp.AuthToken)
+	defer a.end(ctx)
+	query := url.Values{}
+	query.Set("id", params.NotifyID)
+
+	_, err := a.notifyRequest(ctx, "DELETE", "/3/notify?"+query.Encode(), nil, params.LoginResp.AuthToken)
+	var netErr *api.NetworkError
+	if errors.As(err, &netErr) && netErr.Status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+/*
+Name: notifyRequest
+Type: Internal Func
+Purpose: Send one logged in request to the notify endpoints, with
+form as the body if given, and return the body of a 2xx response.
+Failures come back as the api error responseError picks
+*/
+func (a *API) notifyRequest(ctx context.Context, method, path string, form url.Values, authToken string) ([]byte, error) {
+	notifyCtx, notifyCancel := context.WithTimeout(ctx, a.timeouts().Other)
+	defer notifyCancel()
+
+	var bodyBytes []byte
+	if form != nil {
+		bodyBytes = []byte(form.Encode())
+	}
+	request, err := http.NewRequestWithContext(notifyCtx, method, a.endpoint(path), bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	if form != nil {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	request.Header.Set("Authorization", `ResyAPI api_key="`+a.APIKey+`"`)
+	request.Header.Set("X-Resy-Auth-Token", authToken)
+	request.Header.Set("X-Resy-Universal-Auth-Token", authToken)
+	request.Header.Set("Referer", "https://resy.com/")
+	request.Header.Set("Origin", "https://resy.com")
+
+	// Add Imperva cookies and user agent
+	a.addCookiesToRequest(request)
+
+	response, err := a.doRequestWithRetry(notifyCtx, a.clientFor(ctx), request, bodyBytes, 2, 0)
+	if err != nil {
+		return nil, stepError(ctx, "notify", err)
+	}
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, stepError(ctx, "notify", err)
+	}
+	if isCodeFail(response.StatusCode) {
+		return nil, responseError("notify", response, responseBody)
+	}
+	return responseBody, nil
+}
+
+/*
+Name: waitlistEntry
+Type: Internal Func
+Purpose: Convert a decoded notify entry, found at path in the
+response, to the api type
+*/
+func waitlistEntry(raw notifyEntry, path string) (api.WaitlistEntry, error) {
+	earliest, err := parseResyClock(raw.TimePreferredStart)
+	if err != nil {
+		return api.WaitlistEntry{}, api.NewSchemaError("notify", path+".time_preferred_start", fmt.Sprintf("unparseable time %q", raw.TimePreferredStart))
+	}
+	latest, err := parseResyClock(raw.TimePreferredEnd)
+	if err != nil {
+		return api.WaitlistEntry{}, api.NewSchemaError("notify", path+".time_preferred_end", fmt.Sprintf("unparseable time %q", raw.TimePreferredEnd))
+	}
+	return api.WaitlistEntry{
+		NotifyID:  strconv.FormatInt(raw.ID, 10),
+		VenueID:   raw.VenueID,
+		Date:      raw.Day,
+		PartySize: raw.NumSeats,
+		Earliest:  earliest,
+		Latest:    latest,
+	}, nil
+}
+
+// formatResyClock writes a time of day the way the notify endpoints do
+func formatResyClock(clock time.Duration) string {
+	return time.Time{}.Add(clock).Format("15:04:05")
+}
+
+// parseResyClock reads a "15:04:05" time of day, the empty string
+// being midnight
+func parseResyClock(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("15:04:05", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+}
@@ -0,0 +1,474 @@
+package resy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+/*
+Name: authResponse
+Type: Internal Struct
+Purpose: Typed body of a successful /3/auth/password response
+Note: Names and phone number may be null on accounts that never
+set them, a null payment_method_id means the account can't book
+*/
+type authResponse struct {
+	ID              int64  `json:"id" schema:"required"`
+	FirstName       string `json:"first_name"`
+	LastName        string `json:"last_name"`
+	MobileNumber    string `json:"mobile_number"`
+	EmAddress       string `json:"em_address"`
+	PaymentMethodID *int64 `json:"payment_method_id"`
+	Token           string `json:"token" schema:"required"`
+}
+
+/*
+Name: searchRequest
+Type: Internal Struct
+Purpose: Body of a /3/venuesearch/search request
+*/
+type searchRequest struct {
+	Query   string     `json:"query"`
+	Geo     *searchGeo `json:"geo,omitempty"`
+	PerPage int        `json:"per_page,omitempty"`
+	Page    int        `json:"page,omitempty"`
+}
+
+type searchGeo struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Radius    int     `json:"radius,omitempty"`
+}
+
+/*
+Name: searchResponse
+Type: Internal Struct
+Purpose: Typed body of a /3/venuesearch/search response
+*/
+type searchResponse struct {
+	Search struct {
+		Hits []searchHit `json:"hits" schema:"required"`
+	} `json:"search" schema:"required"`
+}
+
+type searchHit struct {
+	ObjectID     string   `json:"objectID" schema:"required"`
+	Name         string   `json:"name"`
+	Region       string   `json:"region"`
+	Locality     string   `json:"locality"`
+	Neighborhood string   `json:"neighborhood"`
+	Cuisine      []string `json:"cuisine"`
+	PriceRangeID int      `json:"price_range_id"`
+	GeoLoc       *struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"_geoloc"`
+}
+
+/*
+Name: findResponse
+Type: Internal Struct
+Purpose: Typed body of a /4/find response
+*/
+type findResponse struct {
+	Results struct {
+		Venues []findVenue `json:"venues" schema:"required"`
+	} `json:"results" schema:"required"`
+}
+
+type findVenue struct {
+	Venue struct {
+		ID struct {
+			Resy int64 `json:"resy" schema:"required"`
+		} `json:"id" schema:"required"`
+	} `json:"venue" schema:"required"`
+	Slots []findSlot `json:"slots" schema:"required"`
+}
+
+type findSlot struct {
+	Date struct {
+		Start string `json:"start" schema:"required"`
+	} `json:"date" schema:"required"`
+	Config struct {
+		Token string `json:"token" schema:"required"`
+		Type  string `json:"type"`
+	} `json:"config" schema:"required"`
+	Payment *struct {
+		IsPaid          bool    `json:"is_paid"`
+		DepositFee      float64 `json:"deposit_fee"`
+		CancellationFee float64 `json:"cancellation_fee"`
+	} `json:"payment"`
+}
+
+/*
+Name: detailsResponse
+Type: Internal Struct
+Purpose: Typed body of a /3/details response
+Note: cancellation and payment are left out for slots with no fee,
+date_cut_off is RFC 3339. book_token only comes back for commit=1,
+so Reserve checks for it itself when it's about to book
+*/
+type detailsResponse struct {
+	BookToken *struct {
+		Value string `json:"value" schema:"required"`
+	} `json:"book_token"`
+	Cancellation *struct {
+		Fee *struct {
+			Amount     float64 `json:"amount"`
+			DateCutOff string  `json:"date_cut_off"`
+		} `json:"fee"`
+		Display *struct {
+			Policy []string `json:"policy"`
+		} `json:"display"`
+	} `json:"cancellation"`
+	Payment *struct {
+		Amount float64 `json:"amount"`
+		Config *struct {
+			Type string `json:"type"`
+		} `json:"config"`
+	} `json:"payment"`
+}
+
+/*
+Name: bookResponse
+Type: Internal Struct
+Purpose: Typed body of a successful /3/book response
+*/
+type bookResponse struct {
+	ResyToken     string `json:"resy_token"`
+	ReservationID int64  `json:"reservation_id" schema:"required"`
+}
+
+/*
+Name: cancelResponse
+Type: Internal Struct
+Purpose: Typed body of a successful /3/cancel response
+Note: payment is left out for free reservations
+*/
+type cancelResponse struct {
+	Payment *struct {
+		Transaction *struct {
+			Refund refundFlag `json:"refund"`
+			Amount float64    `json:"amount"`
+		} `json:"transaction"`
+	} `json:"payment"`
+}
+
+/*
+Name: refundFlag
+Type: Internal Type
+Purpose: Whether a cancel refunded a charge. Resy has sent it both as
+0/1 and as a boolean, so it decodes either
+*/
+type refundFlag bool
+
+func (f *refundFlag) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", "1":
+		*f = true
+	case "false", "0", "null":
+		*f = false
+	default:
+		return &json.UnmarshalTypeError{Value: string(data), Type: reflect.TypeOf(false)}
+	}
+	return nil
+}
+
+/*
+Name: reservationsResponse
+Type: Internal Struct
+Purpose: Typed body of a /3/user/reservations response
+Note: Venue names come separately, keyed by the stringified venue id
+*/
+type reservationsResponse struct {
+	Reservations []reservationEntry `json:"reservations" schema:"required"`
+	Venues       map[string]struct {
+		Name string `json:"name"`
+	} `json:"venues"`
+}
+
+type reservationEntry struct {
+	ResyToken string `json:"resy_token" schema:"required"`
+	Day       string `json:"day" schema:"required"`
+	TimeSlot  string `json:"time_slot" schema:"required"`
+	NumSeats  int    `json:"num_seats"`
+	Venue     struct {
+		ID int64 `json:"id" schema:"required"`
+	} `json:"venue" schema:"required"`
+	Config *struct {
+		Type string `json:"type"`
+	} `json:"config"`
+}
+
+/*
+Name: venueResponse
+Type: Internal Struct
+Purpose: Typed body of a /3/venue response
+Note: release_time is "15:04" in the venue's timezone
+*/
+type venueResponse struct {
+	ID struct {
+		Resy int64 `json:"resy" schema:"required"`
+	} `json:"id" schema:"required"`
+	Name     string `json:"name" schema:"required"`
+	URLSlug  string `json:"url_slug"`
+	Location struct {
+		Address1 string `json:"address_1"`
+		Locality string `json:"locality"`
+		TimeZone string `json:"time_zone"`
+	} `json:"location"`
+	Config struct {
+		LeadTimeInDays int      `json:"lead_time_in_days"`
+		ReleaseTime    string   `json:"release_time"`
+		SeatingTypes   []string `json:"seating_types"`
+	} `json:"config"`
+	Payment *struct {
+		DepositFee              float64 `json:"deposit_fee"`
+		CancellationFee         float64 `json:"cancellation_fee"`
+		CancellationCutoffHours float64 `json:"cancellation_cutoff_hours"`
+		Policy                  string  `json:"policy"`
+	} `json:"payment"`
+}
+
+/*
+Name: notifyEntry
+Type: Internal Struct
+Purpose: One notify registration as /3/notify describes it
+Note: The times are "15:04:05" in the venue's timezone
+*/
+type notifyEntry struct {
+	ID                 int64  `json:"id" schema:"required"`
+	VenueID            int64  `json:"venue_id" schema:"required"`
+	Day                string `json:"day" schema:"required"`
+	NumSeats           int    `json:"num_seats"`
+	TimePreferredStart string `json:"time_preferred_start"`
+	TimePreferredEnd   string `json:"time_preferred_end"`
+}
+
+/*
+Name: notifyResponse
+Type: Internal Struct
+Purpose: Typed body of a successful POST /3/notify response
+*/
+type notifyResponse struct {
+	Notify notifyEntry `json:"notify" schema:"required"`
+}
+
+/*
+Name: notifyListResponse
+Type: Internal Struct
+Purpose: Typed body of a GET /3/notify response
+*/
+type notifyListResponse struct {
+	Notifies []notifyEntry `json:"notifies" schema:"required"`
+}
+
+/*
+Name: calendarResponse
+Type: Internal Struct
+Purpose: Typed body of a /4/venue/calendar response
+Note: inventory.reservation is "available", "sold-out" or "closed"
+for each day, and days past the booking window aren't listed
+*/
+type calendarResponse struct {
+	Scheduled []struct {
+		Date      string `json:"date" schema:"required"`
+		Inventory struct {
+			Reservation string `json:"reservation"`
+		} `json:"inventory"`
+	} `json:"scheduled" schema:"required"`
+}
+
+/*
+Name: decodePayload
+Type: Internal Func
+Purpose: Decode a Resy response body into one of the typed payload
+structs above, returning an api.SchemaError naming the step and JSON
+path of the first value that doesn't fit. Fields tagged
+schema:"required" must be present and non-null, every value present
+must have the declared JSON type, and when strict is set, fields the
+struct doesn't declare are rejected too
+Note: Resy pads its responses with a lot of fields we never read, so
+unknown fields are only an error in strict mode (see API.StrictSchema)
+*/
+func decodePayload(step string, body []byte, out interface{}, strict bool) error {
+	var raw interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return api.NewSchemaError(step, "", "invalid JSON: "+err.Error())
+	}
+
+	if err := checkSchema(step, "", raw, reflect.TypeOf(out).Elem(), strict); err != nil {
+		return err
+	}
+
+	// The shape has been checked, so this can only fail on values
+	// out of range for their Go type
+	if err := json.Unmarshal(body, out); err != nil {
+		path := ""
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			path = typeErr.Field
+		}
+		return api.NewSchemaError(step, path, err.Error())
+	}
+	return nil
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+/*
+Name: checkSchema
+Type: Internal Func
+Purpose: Walk a generically decoded JSON value alongside the Go type it
+is meant to decode into, reporting the first mismatch
+*/
+func checkSchema(step, path string, raw interface{}, t reflect.Type, strict bool) error {
+	if t.Kind() == reflect.Ptr {
+		if raw == nil {
+			return nil
+		}
+		t = t.Elem()
+	}
+	// Types that decode themselves, like refundFlag, check their own
+	// shape when decodePayload unmarshals them
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := raw.(map[string]interface{})
+		if !ok {
+			return api.NewSchemaError(step, path, "expected object, got "+jsonKind(raw))
+		}
+
+		known := make(map[string]bool, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			known[name] = true
+
+			fieldPath := joinPath(path, name)
+			value, present := object[name]
+			if !present || value == nil {
+				if field.Tag.Get("schema") == "required" {
+					return api.NewSchemaError(step, fieldPath, "missing field")
+				}
+				continue
+			}
+			if err := checkSchema(step, fieldPath, value, field.Type, strict); err != nil {
+				return err
+			}
+		}
+
+		if strict {
+			unknown := make([]string, 0)
+			for name := range object {
+				if !known[name] {
+					unknown = append(unknown, name)
+				}
+			}
+			if len(unknown) > 0 {
+				// Report the same field every time for a given payload
+				sort.Strings(unknown)
+				return api.NewSchemaError(step, joinPath(path, unknown[0]), "unknown field")
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		object, ok := raw.(map[string]interface{})
+		if !ok {
+			return api.NewSchemaError(step, path, "expected object, got "+jsonKind(raw))
+		}
+		// Walk the keys in order so the same field is reported every time
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := checkSchema(step, joinPath(path, key), object[key], t.Elem(), strict); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Slice:
+		list, ok := raw.([]interface{})
+		if !ok {
+			return api.NewSchemaError(step, path, "expected array, got "+jsonKind(raw))
+		}
+		for i, item := range list {
+			if err := checkSchema(step, fmt.Sprintf("%s[%d]", path, i), item, t.Elem(), strict); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.String:
+		if _, ok := raw.(string); !ok {
+			return api.NewSchemaError(step, path, "expected string, got "+jsonKind(raw))
+		}
+		return nil
+
+	case reflect.Bool:
+		if _, ok := raw.(bool); !ok {
+			return api.NewSchemaError(step, path, "expected boolean, got "+jsonKind(raw))
+		}
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, ok := raw.(json.Number)
+		if !ok {
+			return api.NewSchemaError(step, path, "expected integer, got "+jsonKind(raw))
+		}
+		if _, err := number.Int64(); err != nil {
+			return api.NewSchemaError(step, path, "expected integer, got "+number.String())
+		}
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		if _, ok := raw.(json.Number); !ok {
+			return api.NewSchemaError(step, path, "expected number, got "+jsonKind(raw))
+		}
+		return nil
+	}
+
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func jsonKind(raw interface{}) string {
+	switch raw.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	}
+	return "unknown"
+}
@@ -0,0 +1,34 @@
+package resy
+
+import (
+	"net/http"
+
+	"github.com/21Bruce/resolved-server/httprec"
+)
+
+/*
+Name: WithRecorder
+Type: External Func
+Purpose: Record the requests and responses of every call made under
+an httprec attempt (see httprec.WithAttempt), redacted, to a sink
+*/
+func WithRecorder(sink httprec.Sink) Option {
+	return func(a *API) {
+		a.Recorder = &httprec.Recorder{Sink: sink}
+	}
+}
+
+/*
+Name: recorded
+Type: Internal Func
+Purpose: Return client with its transport wrapped by the API's
+Recorder, or client itself when the API doesn't record
+*/
+func (a *API) recorded(client *http.Client) *http.Client {
+	if a.Recorder == nil {
+		return client
+	}
+	wrapped := *client
+	wrapped.Transport = a.Recorder.Wrap(client.Transport)
+	return &wrapped
+}
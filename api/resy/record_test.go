@@ -0,0 +1,105 @@
+package resy_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/21Bruce/resolved-server/api"
+	"github.com/21Bruce/resolved-server/api/resy"
+	"github.com/21Bruce/resolved-server/api/resy/resytest"
+	"github.com/21Bruce/resolved-server/httprec"
+)
+
+// replayAPI returns an API that answers from exchanges instead of a server
+func replayAPI(exchanges []httprec.Exchange) (*resy.API, *httprec.Replayer) {
+	replayer := httprec.NewReplayer(exchanges)
+	return &resy.API{APIKey: "test-key", Client: &http.Client{Transport: replayer}, StrictSchema: true}, replayer
+}
+
+func TestRecorder_RecordAndReplay(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+	)
+	auth := login(t, a, user)
+	sink, err := httprec.NewDirSink(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirSink failed: %v", err)
+	}
+	resy.WithRecorder(sink)(a)
+	srv.SetScenario(resytest.ScenarioSlotTaken)
+
+	ctx := httprec.WithAttempt(context.Background(), "res_test-1")
+	if _, err := a.Reserve(ctx, reserveParam(t, auth, "2026-11-20 19:00")); !errors.Is(err, api.ErrSlotTaken) {
+		t.Fatalf("expected ErrSlotTaken, got %v", err)
+	}
+	a.Recorder.Flush()
+
+	exchanges, err := sink.Read(ctx, "res_test-1")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	var paths []string
+	for _, ex := range exchanges {
+		paths = append(paths, ex.Method+" "+strings.SplitN(strings.TrimPrefix(ex.URL, srv.URL), "?", 2)[0])
+	}
+	if got := strings.Join(paths, ", "); got != "POST /4/find, POST /3/details, POST /3/book" {
+		t.Errorf("expected find, details and book to be recorded, got %s", got)
+	}
+
+	raw, err := os.ReadFile(sink.Path("res_test-1"))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	for _, secret := range []string{auth.AuthToken, "test-key"} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("expected %q to be redacted from the recording", secret)
+		}
+	}
+
+	replay, replayer := replayAPI(exchanges)
+	if _, err := replay.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00")); !errors.Is(err, api.ErrSlotTaken) {
+		t.Fatalf("expected the replay to fail with ErrSlotTaken, got %v", err)
+	}
+	if unused := replayer.Unused(); len(unused) != 0 {
+		t.Errorf("expected every exchange to be replayed, %d left", len(unused))
+	}
+}
+
+func TestRecorder_UnlabelledNotRecorded(t *testing.T) {
+	_, a, user := setupFake(t)
+	sink, err := httprec.NewDirSink(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirSink failed: %v", err)
+	}
+	resy.WithRecorder(sink)(a)
+
+	login(t, a, user)
+	a.Recorder.Flush()
+	entries, err := os.ReadDir(sink.Dir)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected nothing recorded without an attempt, got %v, %v", entries, err)
+	}
+}
+
+// TestReplay_SlotTakenFixture replays a snipe that lost the slot between
+// details and book
+func TestReplay_SlotTakenFixture(t *testing.T) {
+	setupFake(t) // Only for the store Reserve loads cookies from
+	exchanges, err := httprec.ReadFile("testdata/httprec/slot_taken.jsonl")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	a, replayer := replayAPI(exchanges)
+	auth := api.LoginResponse{AuthToken: "replayed", PaymentMethodID: 1}
+	if _, err := a.Reserve(context.Background(), reserveParam(t, auth, "2026-11-20 19:00")); !errors.Is(err, api.ErrSlotTaken) {
+		t.Fatalf("expected ErrSlotTaken, got %v", err)
+	}
+	if unused := replayer.Unused(); len(unused) != 0 {
+		t.Errorf("expected every exchange to be replayed, %d left", len(unused))
+	}
+}
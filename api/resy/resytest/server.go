@@ -0,0 +1,874 @@
+// Package resytest provides an in-memory fake of the Resy API for tests.
+// Point resy.API.BaseURL at Server.URL and resy.API.Client at
+// Server.Client().
+package resytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+// Scenario scripts how the fake misbehaves
+type Scenario int
+
+const (
+	// ScenarioNormal serves seeded data faithfully
+	ScenarioNormal Scenario = iota
+	// ScenarioNoSlots lists every venue on /4/find with no open slots
+	ScenarioNoSlots
+	// ScenarioImperva answers every request with an Imperva 403 challenge
+	ScenarioImperva
+	// ScenarioSlotTaken lets find and details succeed but fails /3/book
+	// with a 412, as when someone else books the slot first
+	ScenarioSlotTaken
+	// ScenarioLogin419 rejects every login with a 419, Resy's answer to
+	// bad or expired credentials
+	ScenarioLogin419
+	// ScenarioRateLimited answers every request with a 429 asking to
+	// retry after 2 seconds
+	ScenarioRateLimited
+	// ScenarioPaymentDeclined lets find and details succeed but fails
+	// /3/book with a 402, as when the card on file is refused
+	ScenarioPaymentDeclined
+	// ScenarioBookLost books the table on /3/book but answers 502, as
+	// when Resy's gateway fails after the booking went through
+	ScenarioBookLost
+)
+
+// Venue is a restaurant the fake knows about
+type Venue struct {
+	ID           int64
+	Name         string
+	Region       string
+	Locality     string
+	Neighborhood string
+	Latitude     float64
+	Longitude    float64
+	Cuisines     []string
+	PriceBand    int // Resy's price_range_id, 1 to 4
+
+	// Served by /3/venue
+	Slug          string
+	Address       string
+	TimeZone      string
+	SeatingTypes  []string
+	DaysInAdvance int    // 0 leaves the booking window out
+	ReleaseTime   string // "15:04" in TimeZone
+	Fees          *Fees
+
+	ClosedDays   []string // "2006-01-02" days /4/venue/calendar reports as closed
+	MaxPartySize int      // Larger parties are refused by /4/find, 0 for no limit
+}
+
+// Fees is a venue's deposit and cancellation policy
+type Fees struct {
+	DepositFee              float64
+	CancellationFee         float64
+	CancellationCutoffHours float64
+	Policy                  string
+}
+
+// Slot is an open time at a venue. Start is "2006-01-02 15:04:05" in
+// the venue's local time, as Resy reports it
+type Slot struct {
+	Start           string
+	Type            string
+	Token           string
+	PartySize       int // 0 matches any party size
+	IsPaid          bool
+	DepositFee      float64
+	CancellationFee float64
+	// Sent by /3/details along with the fees, both optional
+	CancellationCutoff string // RFC 3339
+	Policy             string
+}
+
+// User is an account that can log in
+type User struct {
+	ID              int64
+	Email           string
+	Password        string
+	FirstName       string
+	LastName        string
+	Mobile          string
+	PaymentMethodID int64 // 0 leaves payment_method_id null
+	Token           string
+}
+
+// Booking is a reservation made against the fake
+type Booking struct {
+	ReservationID  int64
+	ResyToken      string
+	VenueID        int64
+	Day            string
+	Slot           Slot
+	PartySize      int
+	AuthToken      string
+	SpecialRequest string // As sent to /3/book, empty if none
+	Cancelled      bool   // Set by /3/cancel
+}
+
+// Notify is a notify list registration made against the fake
+type Notify struct {
+	ID        int64
+	VenueID   int64
+	Day       string
+	PartySize int
+	Start     string // "15:04:05"
+	End       string
+	AuthToken string
+}
+
+// Server is a fake Resy. Seed it with AddVenue and AddUser, pick a
+// Scenario, and inspect what happened with Bookings and Hits
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	scenario  Scenario
+	venues    map[int64]Venue
+	slots     map[int64][]Slot
+	users     map[string]User
+	bookings  []Booking
+	notifies  []Notify
+	tokens    map[string]pendingBook
+	hits      map[string]int
+	overrides map[string]override
+	failDays  map[string]bool
+	nextID    int64
+}
+
+// override is a canned response that replaces an endpoint's handler
+type override struct {
+	status int
+	body   string
+}
+
+// pendingBook is what a book_token from /3/details stands for
+type pendingBook struct {
+	venueID   int64
+	day       string
+	slot      Slot
+	partySize int
+}
+
+// NewServer starts a fake Resy server. Callers must Close it
+func NewServer() *Server {
+	s := &Server{
+		venues:    make(map[int64]Venue),
+		slots:     make(map[int64][]Slot),
+		users:     make(map[string]User),
+		tokens:    make(map[string]pendingBook),
+		hits:      make(map[string]int),
+		overrides: make(map[string]override),
+		failDays:  make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/3/auth/password", s.handleLogin)
+	mux.HandleFunc("/3/venuesearch/search", s.handleSearch)
+	mux.HandleFunc("/4/find", s.handleFind)
+	mux.HandleFunc("/3/details", s.handleDetails)
+	mux.HandleFunc("/3/book", s.handleBook)
+	mux.HandleFunc("/3/cancel", s.handleCancel)
+	mux.HandleFunc("/3/user/reservations", s.handleReservations)
+	mux.HandleFunc("/3/venue", s.handleVenue)
+	mux.HandleFunc("/4/venue/calendar", s.handleCalendar)
+	mux.HandleFunc("/3/notify", s.handleNotify)
+	s.Server = httptest.NewServer(s.wrap(mux))
+	return s
+}
+
+// SetScenario switches how the fake behaves for subsequent requests
+func (s *Server) SetScenario(sc Scenario) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scenario = sc
+}
+
+// AddVenue registers a venue along with its open slots
+func (s *Server) AddVenue(v Venue, slots ...Slot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.venues[v.ID] = v
+	s.slots[v.ID] = append(s.slots[v.ID], slots...)
+}
+
+// AddUser registers an account. A missing Token is generated
+func (s *Server) AddUser(u User) User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u.ID == 0 {
+		u.ID = s.id()
+	}
+	if u.Token == "" {
+		u.Token = fmt.Sprintf("auth-token-%d", u.ID)
+	}
+	s.users[u.Email] = u
+	return u
+}
+
+// Bookings returns a copy of the reservations made so far
+func (s *Server) Bookings() []Booking {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Booking, len(s.bookings))
+	copy(out, s.bookings)
+	return out
+}
+
+// Notifies returns a copy of the notify registrations still held
+func (s *Server) Notifies() []Notify {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Notify, len(s.notifies))
+	copy(out, s.notifies)
+	return out
+}
+
+// Holds reports how many tables /3/details is holding for a book
+// that hasn't happened yet
+func (s *Server) Holds() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tokens)
+}
+
+// Hits reports how many requests reached an endpoint path, e.g. "/3/book"
+func (s *Server) Hits(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[path]
+}
+
+// OverrideResponse makes an endpoint path answer every request with the
+// given status and raw body, e.g. to simulate Resy changing a payload.
+// Scenarios that apply to every endpoint still take precedence
+func (s *Server) OverrideResponse(path string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[path] = override{status: status, body: body}
+}
+
+// FailFind makes /4/find for a day (YYYY-MM-DD) answer 500
+func (s *Server) FailFind(day string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failDays[day] = true
+}
+
+func (s *Server) id() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// wrap counts hits and applies scenarios that affect every endpoint
+func (s *Server) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.hits[r.URL.Path]++
+		scenario := s.scenario
+		canned, overridden := s.overrides[r.URL.Path]
+		s.mu.Unlock()
+
+		method := http.MethodPost
+		switch r.URL.Path {
+		case "/3/venue", "/4/venue/calendar", "/3/user/reservations":
+			method = http.MethodGet
+		case "/3/notify":
+			// POST, GET and DELETE are all notify calls
+			method = r.Method
+		}
+		if r.Method != method {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), `ResyAPI api_key="`) {
+			writeError(w, http.StatusUnauthorized, "missing api key")
+			return
+		}
+		if scenario == ScenarioRateLimited {
+			w.Header().Set("Retry-After", "2")
+			writeError(w, http.StatusTooManyRequests, "Too many requests")
+			return
+		}
+		if scenario == ScenarioImperva {
+			w.Header().Set("X-Cdn", "Imperva")
+			w.Header().Add("Set-Cookie", "incap_ses_000_0000000=challenge; path=/; Domain=.resy.com")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("<html><body>Request unsuccessful. Incapsula incident ID</body></html>"))
+			return
+		}
+		if overridden {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(canned.status)
+			w.Write([]byte(canned.body))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"status": status, "message": message})
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "bad form")
+		return
+	}
+
+	s.mu.Lock()
+	scenario := s.scenario
+	user, ok := s.users[r.PostForm.Get("email")]
+	s.mu.Unlock()
+
+	if scenario == ScenarioLogin419 || !ok || user.Password != r.PostForm.Get("password") {
+		writeError(w, 419, "Unauthorized")
+		return
+	}
+
+	var paymentMethodID interface{}
+	if user.PaymentMethodID != 0 {
+		paymentMethodID = user.PaymentMethodID
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":                user.ID,
+		"first_name":        user.FirstName,
+		"last_name":         user.LastName,
+		"mobile_number":     user.Mobile,
+		"em_address":        user.Email,
+		"payment_method_id": paymentMethodID,
+		"token":             user.Token,
+	})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Query string `json:"query"`
+		Geo   *struct {
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+			Radius    int     `json:"radius"`
+		} `json:"geo"`
+		PerPage int `json:"per_page"`
+		Page    int `json:"page"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, "bad request")
+		return
+	}
+	query := strings.ToLower(in.Query)
+
+	s.mu.Lock()
+	matched := make([]Venue, 0)
+	for _, v := range s.venues {
+		if query != "" && !strings.Contains(strings.ToLower(v.Name), query) {
+			continue
+		}
+		if in.Geo != nil && in.Geo.Radius > 0 &&
+			api.DistanceMeters(in.Geo.Latitude, in.Geo.Longitude, v.Latitude, v.Longitude) > float64(in.Geo.Radius) {
+			continue
+		}
+		matched = append(matched, v)
+	}
+	s.mu.Unlock()
+
+	// Venues live in a map, so order them for stable pages
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+	if in.PerPage > 0 {
+		page := in.Page
+		if page < 1 {
+			page = 1
+		}
+		from := (page - 1) * in.PerPage
+		if from > len(matched) {
+			from = len(matched)
+		}
+		to := from + in.PerPage
+		if to > len(matched) {
+			to = len(matched)
+		}
+		matched = matched[from:to]
+	}
+
+	hits := make([]map[string]interface{}, 0, len(matched))
+	for _, v := range matched {
+		cuisines := v.Cuisines
+		if cuisines == nil {
+			cuisines = []string{}
+		}
+		hits = append(hits, map[string]interface{}{
+			"objectID":       strconv.FormatInt(v.ID, 10),
+			"name":           v.Name,
+			"region":         v.Region,
+			"locality":       v.Locality,
+			"neighborhood":   v.Neighborhood,
+			"cuisine":        cuisines,
+			"price_range_id": v.PriceBand,
+			"_geoloc":        map[string]interface{}{"lat": v.Latitude, "lng": v.Longitude},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"search": map[string]interface{}{"hits": hits},
+	})
+}
+
+func (s *Server) handleFind(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Day       string `json:"day"`
+		VenueID   int64  `json:"venue_id"`
+		PartySize int    `json:"party_size"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, "bad request")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failDays[in.Day] {
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	venues := make([]interface{}, 0, 1)
+	if venue, ok := s.venues[in.VenueID]; ok && venue.MaxPartySize > 0 && in.PartySize > venue.MaxPartySize {
+		writeError(w, http.StatusBadRequest, "Party size not allowed for this venue")
+		return
+	}
+	if _, ok := s.venues[in.VenueID]; ok {
+		slots := make([]interface{}, 0)
+		if s.scenario != ScenarioNoSlots {
+			for _, slot := range s.slots[in.VenueID] {
+				if !strings.HasPrefix(slot.Start, in.Day+" ") {
+					continue
+				}
+				if slot.PartySize != 0 && slot.PartySize != in.PartySize {
+					continue
+				}
+				slots = append(slots, map[string]interface{}{
+					"date":   map[string]interface{}{"start": slot.Start},
+					"config": map[string]interface{}{"token": slot.Token, "type": slot.Type},
+					"payment": map[string]interface{}{
+						"is_paid":          slot.IsPaid,
+						"deposit_fee":      slot.DepositFee,
+						"cancellation_fee": slot.CancellationFee,
+					},
+				})
+			}
+		}
+		venues = append(venues, map[string]interface{}{
+			"venue": map[string]interface{}{"id": map[string]interface{}{"resy": in.VenueID}},
+			"slots": slots,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"results": map[string]interface{}{"venues": venues},
+	})
+}
+
+func (s *Server) handleDetails(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Commit    string `json:"commit"`
+		ConfigID  string `json:"config_id"`
+		Day       string `json:"day"`
+		PartySize string `json:"party_size"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, "bad request")
+		return
+	}
+	partySize, _ := strconv.Atoi(in.PartySize)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for venueID, slots := range s.slots {
+		for _, slot := range slots {
+			if slot.Token != in.ConfigID {
+				continue
+			}
+			// Like Resy, only commit=1 holds the table and hands out a
+			// book_token
+			bookToken := ""
+			if in.Commit == "1" {
+				bookToken = fmt.Sprintf("book-token-%d", s.id())
+				s.tokens[bookToken] = pendingBook{venueID: venueID, day: in.Day, slot: slot, partySize: partySize}
+			}
+			writeJSON(w, http.StatusOK, detailsBody(bookToken, slot))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "config not found")
+}
+
+// detailsBody is the /3/details response for a slot, with payment
+// and cancellation terms only when the slot carries any
+func detailsBody(bookToken string, slot Slot) map[string]interface{} {
+	body := map[string]interface{}{}
+	if bookToken != "" {
+		body["book_token"] = map[string]interface{}{"value": bookToken}
+	}
+
+	if slot.IsPaid || slot.DepositFee > 0 {
+		paymentType := "credit_card"
+		if slot.DepositFee > 0 {
+			paymentType = "deposit"
+		}
+		body["payment"] = map[string]interface{}{
+			"amount": slot.DepositFee,
+			"config": map[string]interface{}{"type": paymentType},
+		}
+	}
+
+	if slot.CancellationFee > 0 || slot.Policy != "" {
+		cancellation := map[string]interface{}{}
+		if slot.CancellationFee > 0 {
+			fee := map[string]interface{}{"amount": slot.CancellationFee}
+			if slot.CancellationCutoff != "" {
+				fee["date_cut_off"] = slot.CancellationCutoff
+			}
+			cancellation["fee"] = fee
+		}
+		if slot.Policy != "" {
+			cancellation["display"] = map[string]interface{}{"policy": []string{slot.Policy}}
+		}
+		body["cancellation"] = cancellation
+	}
+	return body
+}
+
+func (s *Server) handleBook(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "bad form")
+		return
+	}
+	authToken := r.Header.Get("X-Resy-Auth-Token")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.knownToken(authToken) {
+		writeError(w, 419, "Unauthorized")
+		return
+	}
+
+	pending, ok := s.tokens[r.PostForm.Get("book_token")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "book token not found")
+		return
+	}
+	if s.scenario == ScenarioSlotTaken {
+		writeError(w, http.StatusPreconditionFailed, "Sorry, this reservation is no longer available")
+		return
+	}
+	if s.scenario == ScenarioPaymentDeclined {
+		writeError(w, http.StatusPaymentRequired, "Your payment method was declined")
+		return
+	}
+	delete(s.tokens, r.PostForm.Get("book_token"))
+
+	// Booking takes the slot off the market
+	slots := s.slots[pending.venueID]
+	for i, slot := range slots {
+		if slot.Token == pending.slot.Token {
+			s.slots[pending.venueID] = append(slots[:i:i], slots[i+1:]...)
+			break
+		}
+	}
+
+	booking := Booking{
+		ReservationID:  s.id(),
+		VenueID:        pending.venueID,
+		Day:            pending.day,
+		Slot:           pending.slot,
+		PartySize:      pending.partySize,
+		AuthToken:      authToken,
+		SpecialRequest: r.PostForm.Get("special_request"),
+	}
+	booking.ResyToken = fmt.Sprintf("resy-token-%d", booking.ReservationID)
+	s.bookings = append(s.bookings, booking)
+
+	if s.scenario == ScenarioBookLost {
+		writeError(w, http.StatusBadGateway, "Bad gateway")
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"resy_token":     booking.ResyToken,
+		"reservation_id": booking.ReservationID,
+	})
+}
+
+// handleCancel cancels a booking by its resy_token. A deposit is
+// refunded, any other paid slot reports refund 0, and a free booking
+// comes back without payment, as Resy does
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "bad form")
+		return
+	}
+	authToken := r.Header.Get("X-Resy-Auth-Token")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.knownToken(authToken) {
+		writeError(w, 419, "Unauthorized")
+		return
+	}
+	for i, booking := range s.bookings {
+		if booking.ResyToken != r.PostForm.Get("resy_token") || booking.AuthToken != authToken {
+			continue
+		}
+		if booking.Cancelled {
+			writeError(w, http.StatusPreconditionFailed, "Reservation has already been cancelled")
+			return
+		}
+		s.bookings[i].Cancelled = true
+
+		body := map[string]interface{}{}
+		switch {
+		case booking.Slot.DepositFee > 0:
+			body["payment"] = map[string]interface{}{
+				"transaction": map[string]interface{}{"refund": 1, "amount": booking.Slot.DepositFee},
+			}
+		case booking.Slot.IsPaid:
+			body["payment"] = map[string]interface{}{
+				"transaction": map[string]interface{}{"refund": 0, "amount": 0},
+			}
+		}
+		writeJSON(w, http.StatusOK, body)
+		return
+	}
+	writeError(w, http.StatusNotFound, "Reservation not found")
+}
+
+// handleReservations lists the bookings an auth token holds that
+// haven't been cancelled, soonest first, up to the limit query value
+func (s *Server) handleReservations(w http.ResponseWriter, r *http.Request) {
+	authToken := r.Header.Get("X-Resy-Auth-Token")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.knownToken(authToken) {
+		writeError(w, 419, "Unauthorized")
+		return
+	}
+
+	var held []Booking
+	for _, booking := range s.bookings {
+		if booking.AuthToken == authToken && !booking.Cancelled {
+			held = append(held, booking)
+		}
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i].Slot.Start < held[j].Slot.Start })
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 && len(held) > limit {
+		held = held[:limit]
+	}
+
+	reservations := make([]map[string]interface{}, 0, len(held))
+	venues := make(map[string]interface{})
+	for _, booking := range held {
+		day, timeSlot, _ := strings.Cut(booking.Slot.Start, " ")
+		reservations = append(reservations, map[string]interface{}{
+			"resy_token": booking.ResyToken,
+			"day":        day,
+			"time_slot":  timeSlot,
+			"num_seats":  booking.PartySize,
+			"venue":      map[string]interface{}{"id": booking.VenueID},
+			"config":     map[string]interface{}{"type": booking.Slot.Type},
+		})
+		venues[strconv.FormatInt(booking.VenueID, 10)] = map[string]interface{}{"name": s.venues[booking.VenueID].Name}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"reservations": reservations, "venues": venues})
+}
+
+// knownToken reports whether an auth token belongs to a user. Callers
+// hold mu
+func (s *Server) knownToken(authToken string) bool {
+	for _, u := range s.users {
+		if u.Token != "" && u.Token == authToken {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleNotify(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "bad form")
+		return
+	}
+	authToken := r.Header.Get("X-Resy-Auth-Token")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.knownToken(authToken) {
+		writeError(w, 419, "Unauthorized")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		venueID, _ := strconv.ParseInt(r.PostForm.Get("venue_id"), 10, 64)
+		if _, ok := s.venues[venueID]; !ok {
+			writeError(w, http.StatusNotFound, "venue not found")
+			return
+		}
+		partySize, _ := strconv.Atoi(r.PostForm.Get("num_seats"))
+		notify := Notify{
+			ID:        s.id(),
+			VenueID:   venueID,
+			Day:       r.PostForm.Get("day"),
+			PartySize: partySize,
+			Start:     r.PostForm.Get("time_preferred_start"),
+			End:       r.PostForm.Get("time_preferred_end"),
+			AuthToken: authToken,
+		}
+		s.notifies = append(s.notifies, notify)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"notify": notifyBody(notify)})
+	case http.MethodGet:
+		notifies := make([]map[string]interface{}, 0)
+		for _, notify := range s.notifies {
+			if notify.AuthToken == authToken {
+				notifies = append(notifies, notifyBody(notify))
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"notifies": notifies})
+	case http.MethodDelete:
+		id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		for i, notify := range s.notifies {
+			if notify.ID == id && notify.AuthToken == authToken {
+				s.notifies = append(s.notifies[:i:i], s.notifies[i+1:]...)
+				writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "notify not found")
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func notifyBody(notify Notify) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                   notify.ID,
+		"venue_id":             notify.VenueID,
+		"day":                  notify.Day,
+		"num_seats":            notify.PartySize,
+		"time_preferred_start": notify.Start,
+		"time_preferred_end":   notify.End,
+	}
+}
+
+func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	venueID, err := strconv.ParseInt(query.Get("venue_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad venue id")
+		return
+	}
+	start, errStart := time.Parse("2006-01-02", query.Get("start_date"))
+	end, errEnd := time.Parse("2006-01-02", query.Get("end_date"))
+	if errStart != nil || errEnd != nil || end.Before(start) {
+		writeError(w, http.StatusBadRequest, "bad date range")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.venues[venueID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "venue not found")
+		return
+	}
+
+	scheduled := make([]interface{}, 0)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		reservation := "sold-out"
+		for _, slot := range s.slots[venueID] {
+			if strings.HasPrefix(slot.Start, date+" ") {
+				reservation = "available"
+				break
+			}
+		}
+		for _, closed := range v.ClosedDays {
+			if closed == date {
+				reservation = "closed"
+			}
+		}
+		scheduled = append(scheduled, map[string]interface{}{
+			"date":      date,
+			"inventory": map[string]interface{}{"reservation": reservation},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"scheduled": scheduled})
+}
+
+func (s *Server) handleVenue(w http.ResponseWriter, r *http.Request) {
+	venueID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad venue id")
+		return
+	}
+
+	s.mu.Lock()
+	v, ok := s.venues[venueID]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "venue not found")
+		return
+	}
+
+	seatingTypes := v.SeatingTypes
+	if seatingTypes == nil {
+		seatingTypes = []string{}
+	}
+	out := map[string]interface{}{
+		"id":       map[string]interface{}{"resy": v.ID},
+		"name":     v.Name,
+		"url_slug": v.Slug,
+		"location": map[string]interface{}{
+			"address_1": v.Address,
+			"locality":  v.Locality,
+			"time_zone": v.TimeZone,
+		},
+		"config": map[string]interface{}{
+			"lead_time_in_days": v.DaysInAdvance,
+			"release_time":      v.ReleaseTime,
+			"seating_types":     seatingTypes,
+		},
+		"payment": nil,
+	}
+	if v.Fees != nil {
+		out["payment"] = map[string]interface{}{
+			"deposit_fee":               v.Fees.DepositFee,
+			"cancellation_fee":          v.Fees.CancellationFee,
+			"cancellation_cutoff_hours": v.Fees.CancellationCutoffHours,
+			"policy":                    v.Fees.Policy,
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
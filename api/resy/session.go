@@ -0,0 +1,213 @@
+package resy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/21Bruce/resolved-server/store"
+)
+
+// defaultUserAgent is sent when neither the API nor a venue's stored
+// cookies name one
+const defaultUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+/*
+Name: session
+Type: Internal Struct
+Purpose: The cookies and user agent of one call, carried on the call's
+context so calls running at once, say the scheduler booking one venue
+while a handler books another, never send each other's cookies
+Note: The jar starts from the venue's stored cookies (or API.Cookies)
+and takes any Imperva cookies the call is handed along the way. It
+lives as long as the call and is never written back to the API, only
+to the venue's stored cookies (see end)
+*/
+type session struct {
+	jar       *cookiejar.Jar
+	userAgent string
+	venueID   int64
+	proxyID   string
+	refreshed atomic.Bool // Imperva handed out cookies and then let a request through
+}
+
+// refreshedCookieTTL is how long cookies written back by end are kept
+// when the venue had none stored, matching the cookie refresher
+const refreshedCookieTTL = 24 * time.Hour
+
+type sessionKey struct{}
+
+/*
+Name: begin
+Type: Internal Func
+Purpose: Set a call up: pick its route (see routed) and give it a
+fresh session, seeded for venueID if it has one
+*/
+func (a *API) begin(ctx context.Context, venueID int64, authToken string) context.Context {
+	ctx = a.routed(ctx, venueID, authToken)
+	return a.withSession(ctx, venueID)
+}
+
+/*
+Name: end
+Type: Internal Func
+Purpose: Finish a call set up by begin: write the cookies of a session
+that got past an Imperva challenge back to its venue's stored cookies,
+under the proxy the call went through, so the next call starts from
+them rather than the cookies Imperva just replaced
+Note: The stored cookies keep their remaining TTL, so the cookie
+refresher still renews them on its schedule. Calls without a venue
+have nothing to write back to
+*/
+func (a *API) end(ctx context.Context) {
+	s := sessionFrom(ctx)
+	if s == nil || s.venueID == 0 || !s.refreshed.Load() {
+		return
+	}
+	base, err := url.Parse(a.endpoint("/"))
+	if err != nil {
+		return
+	}
+
+	// The call's context may already be done
+	ctx = context.WithoutCancel(ctx)
+	ttl, err := store.GetCookieTTLVia(ctx, s.venueID, s.proxyID)
+	if err != nil || ttl <= 0 {
+		ttl = refreshedCookieTTL
+	}
+	cookies := s.jar.Cookies(base)
+	if err := store.SaveCookiesVia(ctx, s.venueID, s.proxyID, cookies, s.userAgent, ttl); err != nil {
+		log.Printf("Warning: failed to save refreshed cookies for venue %d: %v", s.venueID, err)
+		return
+	}
+	log.Printf("Saved %d refreshed cookies for venue %d", len(cookies), s.venueID)
+}
+
+/*
+Name: withSession
+Type: Internal Func
+Purpose: Return a context carrying a new session. Without a venueID it
+starts from API.Cookies and API.UserAgent; with one, from the cookies
+stored for that venue under the route ctx carries
+Note: Under an egress proxy only cookies minted through that proxy are
+used, and without any the session starts empty, since Imperva rejects
+cookies arriving from another address. Stored cookies are scoped to
+the API host whatever domain the browser recorded them under
+*/
+func (a *API) withSession(ctx context.Context, venueID int64) context.Context {
+	// cookiejar.New only fails on a bad PublicSuffixList
+	jar, _ := cookiejar.New(nil)
+	s := &session{jar: jar, userAgent: a.UserAgent, venueID: venueID, proxyID: proxyID(ctx)}
+	cookies := a.Cookies
+
+	if venueID != 0 {
+		via := s.proxyID
+		cookieData, err := store.GetCookiesVia(ctx, venueID, via)
+		switch {
+		case err != nil:
+			log.Printf("Warning: cookies not found for venue %d: %v", venueID, err)
+			if via != "" {
+				cookies = nil
+			}
+		default:
+			cookies = cookieData.Cookies
+			if cookieData.UserAgent != "" {
+				s.userAgent = cookieData.UserAgent
+			}
+			if via != "" {
+				log.Printf("Loaded %d cookies for venue %d via proxy %s", len(cookies), venueID, via)
+			} else {
+				log.Printf("Loaded %d cookies for venue %d", len(cookies), venueID)
+			}
+		}
+	}
+	if s.userAgent == "" {
+		s.userAgent = defaultUserAgent
+	}
+
+	if base, err := url.Parse(a.endpoint("/")); err == nil && len(cookies) > 0 {
+		scoped := make([]*http.Cookie, len(cookies))
+		for i, cookie := range cookies {
+			c := *cookie
+			c.Domain = ""
+			scoped[i] = &c
+		}
+		jar.SetCookies(base, scoped)
+	}
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+/*
+Name: sessionFrom
+Type: Internal Func
+Purpose: The session set by withSession, nil if none
+*/
+func sessionFrom(ctx context.Context) *session {
+	s, _ := ctx.Value(sessionKey{}).(*session)
+	return s
+}
+
+/*
+Name: addCookiesToRequest
+Type: Internal Func
+Purpose: Set the Cookie and User-Agent headers of a request from the
+session on its context, replacing any an earlier attempt set
+Note: A request made outside any session sends API.Cookies and
+API.UserAgent, which calls only ever read
+*/
+func (a *API) addCookiesToRequest(req *http.Request) {
+	req.Header.Del("Cookie")
+
+	cookies, userAgent := a.Cookies, a.UserAgent
+	if s := sessionFrom(req.Context()); s != nil {
+		cookies, userAgent = s.jar.Cookies(req.URL), s.userAgent
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+}
+
+/*
+Name: extractCookiesFromResponse
+Type: Internal Func
+Purpose: Keep the Imperva cookies a response sets in its request's
+session, so a retry within the same call sends them
+*/
+func (a *API) extractCookiesFromResponse(resp *http.Response) {
+	// Check if this is an Imperva response
+	if resp.Header.Get("X-Cdn") != "Imperva" && resp.Header.Get("Server") != "nginx" {
+		return
+	}
+	log.Printf("Imperva challenge detected, extracting cookies")
+	if resp.Request == nil {
+		return
+	}
+	s := sessionFrom(resp.Request.Context())
+	if s == nil {
+		return
+	}
+
+	var imperva []*http.Cookie
+	for _, cookie := range resp.Cookies() {
+		if strings.HasPrefix(cookie.Name, "_incap_") ||
+			strings.HasPrefix(cookie.Name, "incap_ses_") ||
+			strings.HasPrefix(cookie.Name, "_visid_") ||
+			strings.HasPrefix(cookie.Name, "visid_incap_") ||
+			strings.HasPrefix(cookie.Name, "nlbi_") {
+			imperva = append(imperva, cookie)
+		}
+	}
+	if len(imperva) > 0 {
+		s.jar.SetCookies(resp.Request.URL, imperva)
+		log.Printf("Updated cookies from Imperva response: %d cookies", len(imperva))
+	}
+}
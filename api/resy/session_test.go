@@ -0,0 +1,208 @@
+package resy_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api/resy"
+	"github.com/21Bruce/resolved-server/api/resy/resytest"
+	"github.com/21Bruce/resolved-server/store"
+)
+
+// sentHeaders is the Cookie and User-Agent of one request
+type sentHeaders struct {
+	path      string
+	cookie    string
+	userAgent string
+}
+
+// headerRecorder records the headers of every request before handing
+// it to the fake
+type headerRecorder struct {
+	next http.RoundTripper
+	mu   sync.Mutex
+	sent []sentHeaders
+}
+
+func recordHeaders(srv *resytest.Server, a *resy.API) *headerRecorder {
+	rec := &headerRecorder{next: srv.Client().Transport}
+	a.Client = &http.Client{Transport: rec}
+	return rec
+}
+
+func (h *headerRecorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	h.mu.Lock()
+	h.sent = append(h.sent, sentHeaders{path: r.URL.Path, cookie: r.Header.Get("Cookie"), userAgent: r.Header.Get("User-Agent")})
+	h.mu.Unlock()
+	return h.next.RoundTrip(r)
+}
+
+func (h *headerRecorder) requests() []sentHeaders {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]sentHeaders(nil), h.sent...)
+}
+
+func TestSession_ConcurrentVenues(t *testing.T) {
+	const otherVenueID = 90210
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+	)
+	srv.AddVenue(resytest.Venue{ID: otherVenueID, Name: "Lilia", Region: "NY", Locality: "New York"},
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "lil-1900"},
+	)
+	auth := login(t, a, user)
+	a.SetCookies([]*http.Cookie{{Name: "incap_ses_1", Value: "default"}}, "default-agent/1.0")
+
+	ctx := context.Background()
+	for _, venueID := range []int64{testVenueID, otherVenueID} {
+		cookies := []*http.Cookie{{Name: "incap_ses_1", Value: fmt.Sprintf("venue-%d", venueID)}}
+		if err := store.SaveCookies(ctx, venueID, cookies, fmt.Sprintf("agent-%d", venueID), time.Hour); err != nil {
+			t.Fatalf("SaveCookies failed: %v", err)
+		}
+	}
+	rec := recordHeaders(srv, a)
+
+	base := reserveParam(t, auth, "2026-11-20 19:00")
+	base.DryRun = true
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		for _, venueID := range []int64{testVenueID, otherVenueID} {
+			wg.Add(1)
+			go func(venueID int64) {
+				defer wg.Done()
+				params := base
+				params.VenueID = venueID
+				if _, err := a.Reserve(ctx, params); err != nil {
+					errs <- err
+				}
+			}(venueID)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+
+	requests := rec.requests()
+	if len(requests) == 0 {
+		t.Fatal("expected requests to be recorded")
+	}
+	for _, sent := range requests {
+		var venueID int64
+		if _, err := fmt.Sscanf(sent.userAgent, "agent-%d", &venueID); err != nil {
+			t.Fatalf("expected a venue's stored user agent on %s, got %q", sent.path, sent.userAgent)
+		}
+		if want := fmt.Sprintf("incap_ses_1=venue-%d", venueID); sent.cookie != want {
+			t.Errorf("expected %q alongside %q on %s, got %q", want, sent.userAgent, sent.path, sent.cookie)
+		}
+	}
+	if a.UserAgent != "default-agent/1.0" || len(a.Cookies) != 1 || a.Cookies[0].Value != "default" {
+		t.Errorf("expected the API's own cookies to be left alone, got %v %q", a.Cookies, a.UserAgent)
+	}
+}
+
+func TestSession_DefaultCookies(t *testing.T) {
+	srv, a, user := setupFake(t)
+	a.SetCookies([]*http.Cookie{{Name: "incap_ses_1", Value: "default"}}, "")
+	rec := recordHeaders(srv, a)
+
+	login(t, a, user)
+	requests := rec.requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected one login request, got %d", len(requests))
+	}
+	if requests[0].cookie != "incap_ses_1=default" {
+		t.Errorf("expected the API's cookies without a venue, got %q", requests[0].cookie)
+	}
+	if requests[0].userAgent == "" {
+		t.Error("expected a default user agent")
+	}
+}
+
+// challengeOnce answers the first request with an Imperva challenge
+// that hands out a fresh cookie, and lets the rest through
+type challengeOnce struct {
+	next       http.RoundTripper
+	mu         sync.Mutex
+	challenged bool
+}
+
+func (c *challengeOnce) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	first := !c.challenged
+	c.challenged = true
+	c.mu.Unlock()
+	if !first {
+		return c.next.RoundTrip(r)
+	}
+	header := http.Header{}
+	header.Set("X-Cdn", "Imperva")
+	header.Add("Set-Cookie", "incap_ses_1=fresh; Path=/")
+	return &http.Response{
+		StatusCode: http.StatusForbidden,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader("Request unsuccessful. Incapsula incident ID")),
+		Request:    r,
+	}, nil
+}
+
+func TestSession_WritesBackRefreshedCookies(t *testing.T) {
+	srv, a, user := setupFake(t,
+		resytest.Slot{Start: "2026-11-20 19:00:00", Type: "Dining Room", Token: "cfg-1900"},
+	)
+	auth := login(t, a, user)
+
+	ctx := context.Background()
+	cookies := []*http.Cookie{{Name: "incap_ses_1", Value: "stored"}, {Name: "visid_incap_1", Value: "visitor"}}
+	if err := store.SaveCookies(ctx, testVenueID, cookies, "test-agent/1.0", time.Hour); err != nil {
+		t.Fatalf("SaveCookies failed: %v", err)
+	}
+	a.Client = &http.Client{Transport: &challengeOnce{next: srv.Client().Transport}}
+
+	params := reserveParam(t, auth, "2026-11-20 19:00")
+	params.DryRun = true
+	if _, err := a.Reserve(ctx, params); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+
+	stored, err := store.GetCookies(ctx, testVenueID)
+	if err != nil {
+		t.Fatalf("GetCookies failed: %v", err)
+	}
+	values := make(map[string]string)
+	for _, cookie := range stored.Cookies {
+		values[cookie.Name] = cookie.Value
+	}
+	if values["incap_ses_1"] != "fresh" || values["visid_incap_1"] != "visitor" {
+		t.Errorf("expected the refreshed cookie alongside the rest, got %v", values)
+	}
+	if stored.UserAgent != "test-agent/1.0" {
+		t.Errorf("expected the stored user agent to be kept, got %q", stored.UserAgent)
+	}
+	if ttl, _ := store.GetCookieTTL(ctx, testVenueID); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("expected the stored cookies to keep their TTL, got %v", ttl)
+	}
+
+	// A call Imperva never lets through writes nothing back
+	if err := store.SaveCookies(ctx, testVenueID, cookies, "test-agent/1.0", time.Hour); err != nil {
+		t.Fatalf("SaveCookies failed: %v", err)
+	}
+	a.Client = srv.Client()
+	srv.SetScenario(resytest.ScenarioImperva)
+	if _, err := a.Reserve(ctx, params); err == nil {
+		t.Fatal("expected Reserve to fail under an Imperva challenge")
+	}
+	if stored, _ := store.GetCookies(ctx, testVenueID); stored == nil || stored.Cookies[0].Value != "stored" {
+		t.Errorf("expected the stored cookies to be left alone, got %+v", stored)
+	}
+}
@@ -0,0 +1,120 @@
+package resy
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+/*
+Name: Timeouts
+Type: Config Struct
+Purpose: Per-step deadlines for requests to Resy. Each step derives
+its own deadline from the caller's context, so a slow find can't eat
+into the time left for book, and the caller's deadline still wins if
+it's sooner
+Note: A zero field uses the matching DefaultTimeouts value. Other
+covers cancel, the reservation list, venue lookups and Prewarm
+*/
+type Timeouts struct {
+	Login   time.Duration
+	Search  time.Duration
+	Find    time.Duration
+	Details time.Duration
+	Book    time.Duration
+	Other   time.Duration
+}
+
+// DefaultTimeouts are the deadlines used for any step left unset
+var DefaultTimeouts = Timeouts{
+	Login:   10 * time.Second,
+	Search:  10 * time.Second,
+	Find:    12 * time.Second,
+	Details: 12 * time.Second,
+	Book:    12 * time.Second,
+	Other:   10 * time.Second,
+}
+
+/*
+Name: withDefaults
+Type: Internal Func
+Purpose: Fill every zero field from DefaultTimeouts
+*/
+func (t Timeouts) withDefaults() Timeouts {
+	pick := func(value, fallback time.Duration) time.Duration {
+		if value > 0 {
+			return value
+		}
+		return fallback
+	}
+	return Timeouts{
+		Login:   pick(t.Login, DefaultTimeouts.Login),
+		Search:  pick(t.Search, DefaultTimeouts.Search),
+		Find:    pick(t.Find, DefaultTimeouts.Find),
+		Details: pick(t.Details, DefaultTimeouts.Details),
+		Book:    pick(t.Book, DefaultTimeouts.Book),
+		Other:   pick(t.Other, DefaultTimeouts.Other),
+	}
+}
+
+/*
+Name: NewTransport
+Type: External Func
+Purpose: An http.Transport tuned for a long-lived Resy client:
+keep-alives with enough idle connections per host that a burst of
+find calls reuses them, HTTP/2 when the server offers it, and bounded
+dial and TLS handshake times so a dead connection fails fast
+Note: Only worth anything if it is shared. Build one per process and
+hand it to every API through WithTransport, or use GetDefaultAPI
+*/
+func NewTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// sharedClient serves every API with no Client of its own, so even a
+// zero API reuses connections between calls
+var sharedClient = &http.Client{Transport: NewTransport()}
+
+/*
+Name: Option
+Type: External Type
+Purpose: Adjust an API built by GetDefaultAPI
+*/
+type Option func(*API)
+
+/*
+Name: WithTransport
+Type: External Func
+Purpose: Send every request through the given RoundTripper, such as
+a shared NewTransport or a fake in tests
+*/
+func WithTransport(transport http.RoundTripper) Option {
+	return func(a *API) {
+		a.Client = &http.Client{Transport: transport}
+	}
+}
+
+/*
+Name: WithTimeouts
+Type: External Func
+Purpose: Override the per-step deadlines, zero fields keeping their
+defaults
+*/
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(a *API) {
+		a.Timeouts = timeouts
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+)
+
+// MaxPriceBand is the most expensive price band, shown as $$$$
+const MaxPriceBand = 4
+
+const earthRadiusMeters = 6371000
+
+/*
+Name: SearchParam.HasLocation
+Type: API Func
+Purpose: Report whether the search is anchored at a point
+*/
+func (p SearchParam) HasLocation() (bool) {
+    return p.Latitude != 0 || p.Longitude != 0
+}
+
+/*
+Name: SearchParam.Validate
+Type: API Func
+Purpose: Check the search filters are in range before anything is
+sent to a provider
+*/
+func (p SearchParam) Validate() (error) {
+    if p.Limit < 0 {
+        return fmt.Errorf("limit must not be negative")
+    }
+    if p.Page < 0 {
+        return fmt.Errorf("page must not be negative")
+    }
+    if p.Offset < 0 {
+        return fmt.Errorf("offset must not be negative")
+    }
+    if p.Latitude < -90 || p.Latitude > 90 {
+        return fmt.Errorf("latitude %v out of range", p.Latitude)
+    }
+    if p.Longitude < -180 || p.Longitude > 180 {
+        return fmt.Errorf("longitude %v out of range", p.Longitude)
+    }
+    if p.RadiusMeters < 0 {
+        return fmt.Errorf("radius must not be negative")
+    }
+    if p.RadiusMeters > 0 && !p.HasLocation() {
+        return fmt.Errorf("radius requires a latitude and longitude")
+    }
+    if p.PriceBand < 0 || p.PriceBand > MaxPriceBand {
+        return fmt.Errorf("price band must be between 1 and %d", MaxPriceBand)
+    }
+    return nil
+}
+
+/*
+Name: SearchParam.Matches
+Type: API Func
+Purpose: Report whether a result satisfies the neighborhood,
+cuisine, price band and radius filters of the search
+Note: A result without coordinates can't be ruled out by radius,
+and one without a price band can't be ruled out by price
+*/
+func (p SearchParam) Matches(r SearchResult) (bool) {
+    if p.Neighborhood != "" && !strings.EqualFold(p.Neighborhood, r.Neighborhood) {
+        return false
+    }
+    if p.Cuisine != "" {
+        found := false
+        for _, c := range r.Cuisines {
+            if strings.EqualFold(p.Cuisine, c) {
+                found = true
+                break
+            }
+        }
+        if !found {
+            return false
+        }
+    }
+    if p.PriceBand > 0 && r.PriceBand > 0 && p.PriceBand != r.PriceBand {
+        return false
+    }
+    if p.HasLocation() && p.RadiusMeters > 0 && r.hasLocation() {
+        if DistanceMeters(p.Latitude, p.Longitude, r.Latitude, r.Longitude) > float64(p.RadiusMeters) {
+            return false
+        }
+    }
+    return true
+}
+
+/*
+Name: FilterSearchResults
+Type: External Func
+Purpose: Apply a search's filters to what a provider returned, so
+every provider honors them even where the service ignores one.
+With a location, results get a DistanceMeters and come back
+nearest first. The Limit is applied last
+*/
+func FilterSearchResults(params SearchParam, results []SearchResult) ([]SearchResult) {
+    filtered := make([]SearchResult, 0, len(results))
+    for _, r := range results {
+        if params.HasLocation() && r.hasLocation() {
+            r.DistanceMeters = DistanceMeters(params.Latitude, params.Longitude, r.Latitude, r.Longitude)
+        }
+        if params.Matches(r) {
+            filtered = append(filtered, r)
+        }
+    }
+
+    if params.HasLocation() {
+        // Venues without coordinates sort after the ones we can place
+        sort.SliceStable(filtered, func(i, j int) bool {
+            placedI, placedJ := filtered[i].hasLocation(), filtered[j].hasLocation()
+            if placedI != placedJ {
+                return placedI
+            }
+            return filtered[i].DistanceMeters < filtered[j].DistanceMeters
+        })
+    }
+
+    if params.Limit > 0 && len(filtered) > params.Limit {
+        filtered = filtered[:params.Limit]
+    }
+    return filtered
+}
+
+func (r SearchResult) hasLocation() (bool) {
+    return r.Latitude != 0 || r.Longitude != 0
+}
+
+/*
+Name: DistanceMeters
+Type: External Func
+Purpose: Great-circle distance between two coordinates in meters
+*/
+func DistanceMeters(lat1, lon1, lat2, lon2 float64) (float64) {
+    rad := math.Pi / 180
+    dLat := (lat2 - lat1) * rad
+    dLon := (lon2 - lon1) * rad
+    h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+        math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+    return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+)
+
+/*
+Name: SlotPreferences
+Type: API Input Struct
+Purpose: What a user asked for, in the terms a SlotSelector ranks
+slots by: target times and table types, each in priority order, and
+the window slots must start in
+*/
+type SlotPreferences struct {
+    Times      []time.Time
+    TableTypes []TableType
+    Window     TimeWindow
+}
+
+/*
+Name: ReserveParam.Preferences
+Type: API Func
+Purpose: The slot preferences carried by a Reserve call
+*/
+func (p ReserveParam) Preferences() (SlotPreferences) {
+    return SlotPreferences{
+        Times:      p.ReservationTimes,
+        TableTypes: p.TableTypes,
+        Window:     p.Window,
+    }
+}
+
+/*
+Name: SlotSelector
+Type: API Interface
+Purpose: Rank the open slots for a Reserve call. Select returns the
+slots worth trying, best first, and leaves out any that don't fit the
+preferences at all. Providers book the first candidate they can and
+fall through to the next when a slot is taken
+Note: Implementations must be pure: no I/O, and the same input gives
+the same order
+*/
+type SlotSelector interface {
+    Select(slots []Slot, prefs SlotPreferences) []Slot
+}
+
+/*
+Name: PrioritySelector
+Type: API SlotSelector
+Purpose: The original Reserve ordering. Table types are the outer
+priority and times the inner one, so every time is tried at the
+first table type before any time at the second. For each pair the
+window's PickSlot chooses one slot, and a slot picked for an earlier
+pair isn't repeated
+*/
+type PrioritySelector struct{}
+
+func (PrioritySelector) Select(slots []Slot, prefs SlotPreferences) ([]Slot) {
+    tableTypes := prefs.TableTypes
+    if len(tableTypes) == 0 {
+        tableTypes = []TableType{""}
+    }
+
+    candidates := make([]Slot, 0)
+    picked := make(map[int]bool)
+    for _, tableType := range tableTypes {
+        for _, target := range prefs.Times {
+            i := prefs.Window.PickSlot(slots, tableType, target)
+            if i < 0 || picked[i] {
+                continue
+            }
+            picked[i] = true
+            candidates = append(candidates, slots[i])
+        }
+    }
+    return candidates
+}
+
+/*
+Name: WeightedSelector
+Type: API SlotSelector
+Purpose: Rank every fitting slot by a single cost, so a better time
+can outweigh a less preferred seating and vice versa. A slot's cost
+is the lowest, over the targets it fits, of
+    TimeWeight * minutes from the window's ideal time (see Distance)
+    + TargetWeight * the target's rank
+plus SeatingWeight * the rank of the first table type it matches.
+Ranks count from 0, and lower cost is tried first, ties keeping the
+order the provider listed the slots in
+Note: Unlike PrioritySelector, a slot matching none of the table
+types is still a candidate, ranked as if it matched one past the
+last. With no table types, seating costs nothing
+*/
+type WeightedSelector struct {
+    TimeWeight    float64
+    SeatingWeight float64
+    TargetWeight  float64
+}
+
+/*
+Name: NewWeightedSelector
+Type: API Func
+Purpose: A WeightedSelector where one step down the seating list is
+worth 30 minutes and one step down the target list is worth an hour
+*/
+func NewWeightedSelector() (WeightedSelector) {
+    return WeightedSelector{
+        TimeWeight:    1,
+        SeatingWeight: 30,
+        TargetWeight:  60,
+    }
+}
+
+func (s WeightedSelector) Select(slots []Slot, prefs SlotPreferences) ([]Slot) {
+    type scored struct {
+        slot Slot
+        cost float64
+    }
+
+    ranked := make([]scored, 0, len(slots))
+    for _, slot := range slots {
+        best := -1.0
+        for rank, target := range prefs.Times {
+            if !prefs.Window.Fits(slot, target) {
+                continue
+            }
+            cost := s.TimeWeight*prefs.Window.Distance(slot, target).Minutes() + s.TargetWeight*float64(rank)
+            if best < 0 || cost < best {
+                best = cost
+            }
+        }
+        if best < 0 {
+            continue
+        }
+        ranked = append(ranked, scored{slot: slot, cost: best + s.SeatingWeight*float64(seatingRank(slot, prefs.TableTypes))})
+    }
+
+    sort.SliceStable(ranked, func(i, j int) bool {
+        return ranked[i].cost < ranked[j].cost
+    })
+
+    candidates := make([]Slot, 0, len(ranked))
+    for _, r := range ranked {
+        candidates = append(candidates, r.slot)
+    }
+    return candidates
+}
+
+// seatingRank is the index of the first table type the slot matches,
+// or len(tableTypes) if it matches none
+func seatingRank(slot Slot, tableTypes []TableType) (int) {
+    for rank, tableType := range tableTypes {
+        if MatchesTableType(slot, tableType) {
+            return rank
+        }
+    }
+    return len(tableTypes)
+}
+
+/*
+Name: SlotSelectorByName
+Type: API Func
+Purpose: Look up a selector by the name used in configuration,
+"priority" (also the empty name) or "weighted" with its defaults
+*/
+func SlotSelectorByName(name string) (SlotSelector, error) {
+    switch strings.ToLower(name) {
+    case "", "priority":
+        return PrioritySelector{}, nil
+    case "weighted":
+        return NewWeightedSelector(), nil
+    }
+    return nil, fmt.Errorf("unknown slot selector %q, expected priority or weighted", name)
+}
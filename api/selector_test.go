@@ -0,0 +1,191 @@
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+func at(t *testing.T, value string) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation failed: %v", err)
+	}
+	tm, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	if err != nil {
+		t.Fatalf("ParseInLocation failed: %v", err)
+	}
+	return tm
+}
+
+func slot(t *testing.T, value, tableType string) api.Slot {
+	return api.Slot{Start: at(t, value), TableType: tableType, ConfigToken: value + " " + tableType}
+}
+
+func tokens(slots []api.Slot) []string {
+	out := make([]string, 0, len(slots))
+	for _, s := range slots {
+		out = append(out, s.ConfigToken)
+	}
+	return out
+}
+
+func assertOrder(t *testing.T, got []api.Slot, want []string) {
+	t.Helper()
+	gotTokens := tokens(got)
+	if len(gotTokens) != len(want) {
+		t.Fatalf("expected %v, got %v", want, gotTokens)
+	}
+	for i := range want {
+		if gotTokens[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, gotTokens)
+		}
+	}
+}
+
+func TestPrioritySelector(t *testing.T) {
+	tests := []struct {
+		name   string
+		slots  []api.Slot
+		times  []string
+		types  []api.TableType
+		window api.TimeWindow
+		want   []string
+	}{
+		{
+			name:  "closest within 30 minutes",
+			slots: []api.Slot{slot(t, "2026-11-20 18:30", "Dining Room"), slot(t, "2026-11-20 19:15", "Dining Room"), slot(t, "2026-11-20 21:00", "Dining Room")},
+			times: []string{"2026-11-20 19:00"},
+			want:  []string{"2026-11-20 19:15 Dining Room"},
+		},
+		{
+			name:  "exact match wins",
+			slots: []api.Slot{slot(t, "2026-11-20 18:50", "Dining Room"), slot(t, "2026-11-20 19:00", "Dining Room")},
+			times: []string{"2026-11-20 19:00"},
+			want:  []string{"2026-11-20 19:00 Dining Room"},
+		},
+		{
+			name:  "table type before time",
+			slots: []api.Slot{slot(t, "2026-11-20 19:00", "Dining Room"), slot(t, "2026-11-20 20:00", "Bar Counter")},
+			times: []string{"2026-11-20 19:00", "2026-11-20 20:00"},
+			types: []api.TableType{api.Bar, api.DiningRoom},
+			want:  []string{"2026-11-20 20:00 Bar Counter", "2026-11-20 19:00 Dining Room"},
+		},
+		{
+			name:  "slot picked once",
+			slots: []api.Slot{slot(t, "2026-11-20 19:00", "Dining Room")},
+			times: []string{"2026-11-20 19:00", "2026-11-20 19:15"},
+			want:  []string{"2026-11-20 19:00 Dining Room"},
+		},
+		{
+			name:  "other days ignored",
+			slots: []api.Slot{slot(t, "2026-11-21 19:00", "Dining Room")},
+			times: []string{"2026-11-20 19:00"},
+			want:  []string{},
+		},
+		{
+			name:   "window preference",
+			slots:  []api.Slot{slot(t, "2026-11-20 18:45", "Dining Room"), slot(t, "2026-11-20 19:45", "Dining Room"), slot(t, "2026-11-20 20:30", "Dining Room")},
+			times:  []string{"2026-11-20 19:00"},
+			window: api.TimeWindow{Earliest: 18*time.Hour + 30*time.Minute, Latest: 20 * time.Hour, Preference: api.PreferLatest},
+			want:   []string{"2026-11-20 19:45 Dining Room"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs := api.SlotPreferences{TableTypes: tt.types, Window: tt.window}
+			for _, value := range tt.times {
+				prefs.Times = append(prefs.Times, at(t, value))
+			}
+			assertOrder(t, api.PrioritySelector{}.Select(tt.slots, prefs), tt.want)
+		})
+	}
+}
+
+func TestWeightedSelector(t *testing.T) {
+	wide := api.TimeWindow{Earliest: 18 * time.Hour, Latest: 21 * time.Hour}
+
+	tests := []struct {
+		name     string
+		selector api.WeightedSelector
+		slots    []api.Slot
+		times    []string
+		types    []api.TableType
+		window   api.TimeWindow
+		want     []string
+	}{
+		{
+			name:     "time outweighs seating",
+			selector: api.NewWeightedSelector(),
+			slots:    []api.Slot{slot(t, "2026-11-20 20:00", "Bar Counter"), slot(t, "2026-11-20 19:00", "Dining Room")},
+			times:    []string{"2026-11-20 19:00"},
+			types:    []api.TableType{api.Bar, api.DiningRoom},
+			window:   wide,
+			want:     []string{"2026-11-20 19:00 Dining Room", "2026-11-20 20:00 Bar Counter"},
+		},
+		{
+			name:     "seating outweighs time",
+			selector: api.WeightedSelector{TimeWeight: 1, SeatingWeight: 90},
+			slots:    []api.Slot{slot(t, "2026-11-20 20:00", "Bar Counter"), slot(t, "2026-11-20 19:00", "Dining Room")},
+			times:    []string{"2026-11-20 19:00"},
+			types:    []api.TableType{api.Bar, api.DiningRoom},
+			window:   wide,
+			want:     []string{"2026-11-20 20:00 Bar Counter", "2026-11-20 19:00 Dining Room"},
+		},
+		{
+			name:     "unmatched seating ranked last",
+			selector: api.NewWeightedSelector(),
+			slots:    []api.Slot{slot(t, "2026-11-20 19:00", "Patio"), slot(t, "2026-11-20 19:20", "Bar Counter")},
+			times:    []string{"2026-11-20 19:00"},
+			types:    []api.TableType{api.Bar},
+			want:     []string{"2026-11-20 19:20 Bar Counter", "2026-11-20 19:00 Patio"},
+		},
+		{
+			name:     "later target costs more",
+			selector: api.NewWeightedSelector(),
+			slots:    []api.Slot{slot(t, "2026-11-20 19:00", "Dining Room"), slot(t, "2026-11-21 19:20", "Dining Room")},
+			times:    []string{"2026-11-21 19:00", "2026-11-20 19:00"},
+			want:     []string{"2026-11-21 19:20 Dining Room", "2026-11-20 19:00 Dining Room"},
+		},
+		{
+			name:     "ties keep listed order",
+			selector: api.NewWeightedSelector(),
+			slots:    []api.Slot{slot(t, "2026-11-20 19:15", "Dining Room"), slot(t, "2026-11-20 18:45", "Dining Room")},
+			times:    []string{"2026-11-20 19:00"},
+			want:     []string{"2026-11-20 19:15 Dining Room", "2026-11-20 18:45 Dining Room"},
+		},
+		{
+			name:     "slots outside the window dropped",
+			selector: api.NewWeightedSelector(),
+			slots:    []api.Slot{slot(t, "2026-11-20 17:00", "Dining Room"), slot(t, "2026-11-20 22:00", "Dining Room")},
+			times:    []string{"2026-11-20 19:00"},
+			window:   wide,
+			want:     []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs := api.SlotPreferences{TableTypes: tt.types, Window: tt.window}
+			for _, value := range tt.times {
+				prefs.Times = append(prefs.Times, at(t, value))
+			}
+			assertOrder(t, tt.selector.Select(tt.slots, prefs), tt.want)
+		})
+	}
+}
+
+func TestSlotSelectorByName(t *testing.T) {
+	if s, err := api.SlotSelectorByName(""); err != nil || s != (api.PrioritySelector{}) {
+		t.Errorf("expected the priority selector by default, got %v, %v", s, err)
+	}
+	if s, err := api.SlotSelectorByName("Weighted"); err != nil || s != api.NewWeightedSelector() {
+		t.Errorf("expected the default weighted selector, got %v, %v", s, err)
+	}
+	if _, err := api.SlotSelectorByName("random"); err == nil {
+		t.Error("expected an error for an unknown selector")
+	}
+}
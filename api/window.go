@@ -0,0 +1,210 @@
+package api
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// DefaultMatchWindow is how far either side of a target time a slot
+// may start when no TimeWindow bounds are given
+const DefaultMatchWindow = 30 * time.Minute
+
+/*
+Name: TimePreference
+Type: API Enum
+Purpose: Which of several acceptable slots a Reserve call books
+*/
+type TimePreference string
+
+const (
+    PreferClosest  TimePreference = "closest"
+    PreferEarliest TimePreference = "earliest"
+    PreferLatest   TimePreference = "latest"
+)
+
+/*
+Name: TimeWindow
+Type: API Input Struct
+Purpose: Per-request constraints on the slots Reserve may book.
+Earliest and Latest are times of day in the venue's timezone, given
+as the time since midnight, and apply on the day of each reservation
+time. Preference picks among the slots that fit
+Note: A zero Earliest or Latest leaves that side open. With neither
+set, a slot must start within DefaultMatchWindow of the target, which
+is how every Reserve call matched before windows existed. The empty
+Preference is PreferClosest
+*/
+type TimeWindow struct {
+    Earliest   time.Duration
+    Latest     time.Duration
+    Preference TimePreference
+}
+
+/*
+Name: ParseTimeWindow
+Type: API Func
+Purpose: Build a TimeWindow from "HH:MM" bounds and a preference
+name, any of which may be empty, and validate it
+*/
+func ParseTimeWindow(earliest, latest, preference string) (TimeWindow, error) {
+    var window TimeWindow
+    var err error
+    if window.Earliest, err = parseClock(earliest); err != nil {
+        return TimeWindow{}, fmt.Errorf("invalid earliest time %q, expected HH:MM", earliest)
+    }
+    if window.Latest, err = parseClock(latest); err != nil {
+        return TimeWindow{}, fmt.Errorf("invalid latest time %q, expected HH:MM", latest)
+    }
+    window.Preference = TimePreference(strings.ToLower(preference))
+    if err := window.Validate(); err != nil {
+        return TimeWindow{}, err
+    }
+    return window, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+    if value == "" {
+        return 0, nil
+    }
+    t, err := time.Parse("15:04", value)
+    if err != nil {
+        return 0, err
+    }
+    return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+/*
+Name: TimeWindow.IsSet
+Type: API Func
+Purpose: Report whether either bound is given, in which case the
+bounds replace the DefaultMatchWindow around each target
+*/
+func (w TimeWindow) IsSet() (bool) {
+    return w.Earliest > 0 || w.Latest > 0
+}
+
+/*
+Name: TimeWindow.Validate
+Type: API Func
+Purpose: Check the bounds are times of day in order and the
+preference is one Reserve understands
+*/
+func (w TimeWindow) Validate() (error) {
+    if w.Earliest < 0 || w.Earliest >= 24*time.Hour {
+        return fmt.Errorf("earliest time must be within the day")
+    }
+    if w.Latest < 0 || w.Latest >= 24*time.Hour {
+        return fmt.Errorf("latest time must be within the day")
+    }
+    if w.Earliest > 0 && w.Latest > 0 && w.Latest < w.Earliest {
+        return fmt.Errorf("latest time must not be before earliest time")
+    }
+    switch w.Preference {
+    case "", PreferClosest, PreferEarliest, PreferLatest:
+        return nil
+    }
+    return fmt.Errorf("unknown time preference %q, expected closest, earliest or latest", w.Preference)
+}
+
+/*
+Name: TimeWindow.PickSlot
+Type: API Func
+Purpose: Choose the slot to book for one (table type, target time)
+pair, returning its index or -1 if none fits. A slot fits when it is
+on the target's day, its table type contains tableType (any type if
+empty) and it starts inside the window. Among those, an exact match
+on the target wins under PreferClosest, otherwise the preference
+decides and ties go to the slot listed first
+*/
+func (w TimeWindow) PickSlot(slots []Slot, tableType TableType, target time.Time) (int) {
+    best := -1
+    var bestDiff time.Duration
+    for i, slot := range slots {
+        if !MatchesTableType(slot, tableType) || !w.Fits(slot, target) {
+            continue
+        }
+
+        diff := w.Distance(slot, target)
+        if best < 0 || diff < bestDiff {
+            best, bestDiff = i, diff
+        }
+    }
+    return best
+}
+
+/*
+Name: TimeWindow.Fits
+Type: API Func
+Purpose: Report whether a slot is on the target's day and starts
+inside the window (or within DefaultMatchWindow of the target when
+no bounds are set)
+Note: Days and times of day are read in the slot's own location,
+which providers set to the venue's timezone
+*/
+func (w TimeWindow) Fits(slot Slot, target time.Time) (bool) {
+    local := target.In(slot.Start.Location())
+    if slot.Start.Year() != local.Year() || slot.Start.YearDay() != local.YearDay() {
+        return false
+    }
+
+    if !w.IsSet() {
+        return absDuration(slot.Start.Sub(local)) <= DefaultMatchWindow
+    }
+    clock := time.Duration(slot.Start.Hour())*time.Hour + time.Duration(slot.Start.Minute())*time.Minute
+    return clock >= w.Earliest && (w.Latest == 0 || clock <= w.Latest)
+}
+
+/*
+Name: TimeWindow.Distance
+Type: API Func
+Purpose: How far a slot is from the ideal time for a target under
+the window's preference: the target itself for PreferClosest, the
+start of the window for PreferEarliest and its end for PreferLatest.
+Smaller is better and 0 is a perfect match
+Note: Only meaningful for slots that Fit the target. An open bound
+counts from the start or end of the target's day
+*/
+func (w TimeWindow) Distance(slot Slot, target time.Time) (time.Duration) {
+    local := target.In(slot.Start.Location())
+    ideal := local
+    switch w.Preference {
+    case PreferEarliest:
+        ideal = local.Add(-DefaultMatchWindow)
+        if w.IsSet() {
+            ideal = atClock(local, w.Earliest)
+        }
+    case PreferLatest:
+        ideal = local.Add(DefaultMatchWindow)
+        if w.IsSet() {
+            ideal = atClock(local, 24*time.Hour)
+            if w.Latest > 0 {
+                ideal = atClock(local, w.Latest)
+            }
+        }
+    }
+    return absDuration(slot.Start.Sub(ideal))
+}
+
+/*
+Name: MatchesTableType
+Type: API Func
+Purpose: Report whether a slot's seating label contains the table
+type asked for, the empty table type matching any slot
+*/
+func MatchesTableType(slot Slot, tableType TableType) (bool) {
+    return strings.Contains(strings.ToLower(slot.TableType), string(tableType))
+}
+
+// atClock returns the time of day clock on t's calendar day
+func atClock(t time.Time, clock time.Duration) (time.Time) {
+    midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+    return midnight.Add(clock)
+}
+
+func absDuration(d time.Duration) (time.Duration) {
+    if d < 0 {
+        return -d
+    }
+    return d
+}
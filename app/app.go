@@ -6,6 +6,7 @@ package app
 
 import (
     "github.com/21Bruce/resolved-server/api"
+    "context"
     "errors"
     "time"
     "strconv"
@@ -52,6 +53,10 @@ type AppCtx struct {
     // The API to run the app on
     API         api.API
 
+    // Optional registry of every provider the app can book on.
+    // When nil, API serves the default provider only
+    Providers   *api.Registry
+
     // List of internal concurrent operations, both completed
     // and running
     operations  []Operation    
@@ -71,6 +76,7 @@ reserve at interval operation by a consumer
 */
 type ReserveAtIntervalParam struct {
     Login            LoginParam
+    Provider         string
     VenueID          int64
     ReservationTimes []time.Time
     PartySize        int
@@ -86,6 +92,7 @@ reserve at time operation by a consumer
 */
 type ReserveAtTimeParam struct {
     Login            LoginParam
+    Provider         string
     VenueID          int64
     ReservationTimes []time.Time
     PartySize        int
@@ -157,10 +164,12 @@ Name: Operation
 Type: struct
 Purpose: Maintain the state associated with a running
 go thread operation
+Note: Cancel cancels the context the go thread runs under,
+which also aborts any in flight api call
 */
 type Operation struct{
     ID      int64
-    Cancel  chan<- bool
+    Cancel  context.CancelFunc
     Output  <-chan OperationResult
     Result  *OperationResult
     Status  OperationStatus
@@ -185,6 +194,34 @@ func findLastTime(times []time.Time) (*time.Time, error) {
     return &lastTime, nil
 }
 
+/*
+Name: cancelOr
+Type: Internal Func
+Purpose: Collapse an api layer cancellation into the app layer's
+ErrCancel so consumers only have one error to check for, and pass
+every other error through
+*/
+func cancelOr(err error) error {
+    if errors.Is(err, api.ErrCancelled) {
+        return ErrCancel
+    }
+    return err
+}
+
+/*
+Name: retryableAtInterval
+Type: Internal Func
+Purpose: Report whether a reserve at interval operation should try
+again after err: no table was free (including a slot taken before
+it could be booked, which wraps ErrNoTable) or every free one cost
+more than allowed
+Note: Providers wrap these sentinels, so they're matched with
+errors.Is rather than ==
+*/
+func retryableAtInterval(err error) (bool) {
+    return errors.Is(err, api.ErrNoTable) || errors.Is(err, api.ErrPaymentPolicy)
+}
+
 /*
 Name: updateOperationResult 
 Type: Internal Func
@@ -239,9 +276,8 @@ func (a *AppCtx) CancelOperation(id int64) (error) {
             // we perform all stateful changes in place,
             // i.e., on a.operations[i] instead of the for loop
             // value 'operation' 
-            a.operations[i].Cancel <- true 
+            a.operations[i].Cancel()
             a.operations[i].Status = CancelStatusType
-            close(a.operations[i].Cancel)
             return nil
         }
     }
@@ -253,8 +289,17 @@ func (a *AppCtx) CancelOperation(id int64) (error) {
 Name: ScheduleReserveAtIntervalOperation
 Type: External App Func
 Purpose: Used to Schedule a reserve at interval operation, returns ID 
+Note: The operation runs under a child of ctx, so cancelling ctx
+cancels the operation too
 */
-func (a *AppCtx) ScheduleReserveAtIntervalOperation(params ReserveAtIntervalParam) (int64, error) {
+func (a *AppCtx) ScheduleReserveAtIntervalOperation(ctx context.Context, params ReserveAtIntervalParam) (int64, error) {
+    // resolve the provider up front so a bad name fails here
+    // rather than inside the go thread
+    impl, err := a.Provider(params.Provider)
+    if err != nil {
+        return 0, err
+    }
+
     // generate a new id
     id := a.idGen
     a.idGen += 1 
@@ -271,9 +316,10 @@ func (a *AppCtx) ScheduleReserveAtIntervalOperation(params ReserveAtIntervalPara
         params.Login.Password = a.loginInfo.Password
     }
 
-    // make cancel and output channels to manage go thread 
-    cancel := make(chan bool)
-    output := make(chan OperationResult)
+    // make a cancellable context and output channel to manage go thread,
+    // output is buffered so the thread never blocks on reporting its result
+    opCtx, cancel := context.WithCancel(ctx)
+    output := make(chan OperationResult, 1)
 
     // add op to internal buffer list 
     a.operations = append(a.operations, Operation{
@@ -283,7 +329,7 @@ func (a *AppCtx) ScheduleReserveAtIntervalOperation(params ReserveAtIntervalPara
         Status: InProgressStatusType,
     })
     // run op
-    go a.reserveAtInterval(params, cancel, output)
+    go a.reserveAtInterval(opCtx, impl, params, output)
     return id, nil
 }
 
@@ -293,7 +339,7 @@ Type: Internal App Func
 Purpose: This function is intended to run on a separate thread, and tries making
 a reservation at a given interval of time
 */
-func (a *AppCtx) reserveAtInterval(params ReserveAtIntervalParam, cancel <-chan bool, output chan<- OperationResult){
+func (a *AppCtx) reserveAtInterval(ctx context.Context, impl api.API, params ReserveAtIntervalParam, output chan<- OperationResult){
 
     // find and store last time from time priority list
     lastTime, err := findLastTime(params.ReservationTimes)
@@ -307,16 +353,16 @@ func (a *AppCtx) reserveAtInterval(params ReserveAtIntervalParam, cancel <-chan
     for {
         
         // first run pre reservation auth 
-        loginResp, err := a.API.Login(api.LoginParam(params.Login))
+        loginResp, err := impl.Login(ctx, api.LoginParam(params.Login))
         
         if err != nil {
-            output<-OperationResult{Response: nil, Err: err}     
+            output<-OperationResult{Response: nil, Err: cancelOr(err)}     
             close(output)
             return
         }
 
         // next try reservation 
-        reserveResp, err := a.API.Reserve(
+        reserveResp, err := impl.Reserve(ctx,
             api.ReserveParam{
                 LoginResp: *loginResp,
                 ReservationTimes: params.ReservationTimes,
@@ -327,19 +373,19 @@ func (a *AppCtx) reserveAtInterval(params ReserveAtIntervalParam, cancel <-chan
 
         // if there was an error and it wasn't due to every time being
         // taken, then it's an issue we don't know about
-        if err != nil && err != api.ErrNoTable {
-            output<-OperationResult{Response: nil, Err: err}     
+        if err != nil && !retryableAtInterval(err) {
+            output<-OperationResult{Response: nil, Err: cancelOr(err)}     
             close(output)
             return
         }
-        if err == api.ErrNoTable {
+        if err != nil {
             // see if last time on list is still in the future,
             // since if it isn't there's no point in trying to reserve it
             if lastTime.After(time.Now()) {
                 select {
                 case <-time.After(params.RepeatInterval):
                     continue
-                case <-cancel:
+                case <-ctx.Done():
                     output<-OperationResult{Response: nil, Err: ErrCancel}     
                     close(output)
                     return
@@ -366,7 +412,11 @@ Purpose: Used to Schedule a reserve at time operation, returns ID
 Note: Most of this logic should probably be merged
 with the ScheduleReserveAtIntervalOperation func since it's similar logic
 */
-func (a *AppCtx) ScheduleReserveAtTimeOperation(params ReserveAtTimeParam) (int64, error) {
+func (a *AppCtx) ScheduleReserveAtTimeOperation(ctx context.Context, params ReserveAtTimeParam) (int64, error) {
+    impl, err := a.Provider(params.Provider)
+    if err != nil {
+        return 0, err
+    }
     id := a.idGen
     a.idGen += 1 
     if (params.Login.Email == "" || params.Login.Password == "") {
@@ -376,15 +426,15 @@ func (a *AppCtx) ScheduleReserveAtTimeOperation(params ReserveAtTimeParam) (int6
         params.Login.Email = a.loginInfo.Email
         params.Login.Password = a.loginInfo.Password
     }
-    cancel := make(chan bool)
-    output := make(chan OperationResult)
+    opCtx, cancel := context.WithCancel(ctx)
+    output := make(chan OperationResult, 1)
     a.operations = append(a.operations, Operation{
         ID: id,
         Cancel: cancel,
         Output: output,
         Status: InProgressStatusType,
     })
-    go a.reserveAtTime(params, cancel, output)
+    go a.reserveAtTime(opCtx, impl, params, output)
     return id, nil
 }
 
@@ -394,7 +444,7 @@ Type: Internal App Func
 Purpose: This function is intended to run on a separate thread, and tries making
 a reservation at a given time
 */
-func (a *AppCtx) reserveAtTime(params ReserveAtTimeParam, cancel <-chan bool, output chan<- OperationResult) {
+func (a *AppCtx) reserveAtTime(ctx context.Context, impl api.API, params ReserveAtTimeParam, output chan<- OperationResult) {
  
     // if this date is not in the future, err 
     if params.RequestTime.Before(time.Now().UTC()) {
@@ -403,23 +453,23 @@ func (a *AppCtx) reserveAtTime(params ReserveAtTimeParam, cancel <-chan bool, ou
         return
     }
 
-    minAuthTime := a.API.AuthMinExpire()
+    minAuthTime := impl.AuthMinExpire()
     authDate := params.RequestTime.Add(-1 * minAuthTime)
     if (!authDate.Before(time.Now().UTC())) {
         select {
         case <-time.After(time.Until(authDate)):
             break
-        case <-cancel:
+        case <-ctx.Done():
             output<- OperationResult{Response: nil, Err:ErrCancel}
             close(output)
             return
         }
     }
 
-    loginResp, err := a.API.Login(api.LoginParam(params.Login))
+    loginResp, err := impl.Login(ctx, api.LoginParam(params.Login))
 
     if err != nil {
-       output<- OperationResult{Response: nil, Err:err}
+       output<- OperationResult{Response: nil, Err:cancelOr(err)}
        close(output)
        return
     }
@@ -427,14 +477,14 @@ func (a *AppCtx) reserveAtTime(params ReserveAtTimeParam, cancel <-chan bool, ou
     // sleep with ability to cancel 
     select {
     case <-time.After(time.Until(params.RequestTime)):
-    case <-cancel:
+    case <-ctx.Done():
         output<- OperationResult{Response: nil, Err:ErrCancel}
         close(output)
         return
     }
 
     // reserve 
-    reserveResp, err := a.API.Reserve(
+    reserveResp, err := impl.Reserve(ctx,
         api.ReserveParam{
             LoginResp: *loginResp,
             ReservationTimes: params.ReservationTimes,
@@ -444,7 +494,7 @@ func (a *AppCtx) reserveAtTime(params ReserveAtTimeParam, cancel <-chan bool, ou
         })
 
     if err != nil {
-        output<- OperationResult{Response: nil, Err:err}
+        output<- OperationResult{Response: nil, Err:cancelOr(err)}
         close(output)
         return
     }
@@ -457,15 +507,32 @@ func (a *AppCtx) reserveAtTime(params ReserveAtTimeParam, cancel <-chan bool, ou
     return
 }
 
+/*
+Name: Provider
+Type: External App Func
+Purpose: Resolve the api for a provider name, using the
+Providers registry when one is set and API otherwise.
+The empty name means api.DefaultProvider
+*/
+func (a *AppCtx) Provider(name string) (api.API, error) {
+    if a.Providers != nil {
+        return a.Providers.Get(name)
+    }
+    if api.NormalizeProvider(name) != api.DefaultProvider {
+        return nil, fmt.Errorf("%w: %q", api.ErrUnknownProvider, name)
+    }
+    return a.API, nil
+}
+
 /*
 Name: Login 
 Type: External App Func
 Purpose: This function stores loginParams in the
 app Ctx if they pass the Login method
 */
-func (a *AppCtx) Login(params LoginParam) (error) {
+func (a *AppCtx) Login(ctx context.Context, params LoginParam) (error) {
     reqParams := api.LoginParam(params)
-    _, err :=  a.API.Login(reqParams)
+    _, err :=  a.API.Login(ctx, reqParams)
     if err != nil {
         return err
     }
@@ -479,9 +546,9 @@ Type: External App Func
 Purpose: This function performs a search using the underlying 
 api
 */
-func (a *AppCtx) Search(params SearchParam) (*SearchResponse, error) {
+func (a *AppCtx) Search(ctx context.Context, params SearchParam) (*SearchResponse, error) {
     reqParams := api.SearchParam(params)
-    resp, err :=  a.API.Search(reqParams)
+    resp, err :=  a.API.Search(ctx, reqParams)
     if err != nil {
         return nil, err
     }
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+// refusingAPI fails Reserve with each of errs in turn, then books
+type refusingAPI struct {
+	api.API
+	errs     []error
+	reserves int
+}
+
+func (f *refusingAPI) Login(ctx context.Context, params api.LoginParam) (*api.LoginResponse, error) {
+	return &api.LoginResponse{AuthToken: "token"}, nil
+}
+
+func (f *refusingAPI) Reserve(ctx context.Context, params api.ReserveParam) (*api.ReserveResponse, error) {
+	f.reserves++
+	if f.reserves <= len(f.errs) {
+		return nil, f.errs[f.reserves-1]
+	}
+	return &api.ReserveResponse{ReservationTime: params.ReservationTimes[0]}, nil
+}
+
+func TestReserveAtInterval_RetriesWrappedRefusals(t *testing.T) {
+	impl := &refusingAPI{errs: []error{
+		api.NewRefusalError("book", 412, api.ErrSlotTaken, "slot taken"),
+		api.NewPaymentPolicyError("deposit", 50, 20),
+		api.ErrNoTable,
+	}}
+	when := time.Now().Add(time.Hour)
+	output := make(chan OperationResult, 1)
+
+	var a AppCtx
+	a.reserveAtInterval(context.Background(), impl, ReserveAtIntervalParam{
+		ReservationTimes: []time.Time{when},
+		PartySize:        2,
+		RepeatInterval:   time.Millisecond,
+	}, output)
+
+	result := <-output
+	if result.Err != nil {
+		t.Fatalf("expected the operation to retry past the refusals, got %v", result.Err)
+	}
+	if impl.reserves != 4 || !result.Response.Time().Equal(when) {
+		t.Errorf("expected a booking on the fourth attempt, got %d attempts and %v", impl.reserves, result.Response)
+	}
+}
+
+func TestReserveAtInterval_StopsOnOtherErrors(t *testing.T) {
+	declined := api.NewRefusalError("book", 402, api.ErrPaymentDeclined, "card declined")
+	impl := &refusingAPI{errs: []error{declined}}
+	output := make(chan OperationResult, 1)
+
+	var a AppCtx
+	a.reserveAtInterval(context.Background(), impl, ReserveAtIntervalParam{
+		ReservationTimes: []time.Time{time.Now().Add(time.Hour)},
+		RepeatInterval:   time.Millisecond,
+	}, output)
+
+	if result := <-output; !errors.Is(result.Err, api.ErrPaymentDeclined) || impl.reserves != 1 {
+		t.Errorf("expected the declined payment after one attempt, got %v after %d", result.Err, impl.reserves)
+	}
+}
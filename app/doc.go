@@ -20,15 +20,19 @@ Public API:
 
     Here we go through the front facing functions of this pkg:
 
-        1. ScheduleReserveAtIntervalOperation(ReserveAtIntervalParam)(int64, error)
+        1. ScheduleReserveAtIntervalOperation(context.Context, ReserveAtIntervalParam)(int64, error)
 
             - Description: This func takes in a set of parameters
               specifying the date and times to reserve at, the 
               restaurant to reserve at, party size, and an interval
               to retry the reservation on and returns the id of the
-              running operation on success
+              running operation on success. The operation is bound
+              to the given context and stops if it is cancelled.
+              The Provider field picks which service to book on
+              (see Provider below) and an unknown name fails
+              before anything is scheduled
 
-        2. ScheduleReserveAtTimeOperation(ReserveAtTimeParam)(int64, error)
+        2. ScheduleReserveAtTimeOperation(context.Context, ReserveAtTimeParam)(int64, error)
 
             - Description: This func takes in a set of parameters
               specifying the date and times to reserve at, the 
@@ -42,14 +46,14 @@ Public API:
             - Description: This func takes in an id and attempts to
               cancel it.
 
-        4. Login(LoginParam)(error)
+        4. Login(context.Context, LoginParam)(error)
 
             - Description: This func takes in a set of login params 
               and attempts to call the login function of the external 
               api on it. If that call succeeds, it will store the 
               login params and use them as defaults in future requests
 
-        5. Search(SearchParam)(*SearchResponse, error)
+        5. Search(context.Context, SearchParam)(*SearchResponse, error)
 
             - Description: This func takes in a SearchParam containing
               a name and a potential limit and returns a set of 
@@ -76,6 +80,15 @@ Public API:
             - Description: Returns status corresponding to
               operation
 
+        10. Provider(string)(api.API, error)
+
+            - Description: Resolves a provider name such as "resy"
+              or "opentable" to the api that books on it, using the
+              'Providers' registry of the AppCtx when set. The empty
+              name means api.DefaultProvider, and an AppCtx without
+              a registry only knows that provider, served by 'API'.
+              Login and Search always use 'API'
+
 
 **********************************************************************
 
@@ -95,7 +108,7 @@ App Internals:
         specified time. For each of these go threads, we create an
         "Operation" struct and store it in the app context. The 
         operation struct contains the ID for the operation, a 'Cancel'
-        channel which allows us to stop the operation, an 'Output' 
+        func which cancels the context the operation runs under, an 'Output' 
         channel which allows us to read from the operation, a 'Result'
         field of type 'OperationResult' which contains a 'Timeable'
         (any struct with a Time() method) and an Err field, which 
@@ -112,15 +125,21 @@ App Internals:
 
     How To Cancel an Operation:
 
-        In order to "cancel" an operation, any time an operation is
-        sleeping, this sleeping is actually simulated using a select 
+        Each operation runs under its own context.Context, derived from
+        the context passed when it was scheduled. In order to "cancel" 
+        an operation, we call its 'Cancel' func. Any time an operation
+        is sleeping, this sleeping is actually simulated using a select 
         statement and the "time.After(d Time)" channel type. The 
         "time.After(d time.Duration)" channel is a channel which sends
         a value to its output after "d" time.Duration has passed. We
-        put this channel in a select statement with the cancel channel,
-        which will cause the thread to block until one of those two
-        channels activates. In the cancel case, we report an error of
+        put this channel in a select statement with the context's Done
+        channel, which will cause the thread to block until one of those 
+        two channels activates. In the cancel case, we report an error of
         cancelled, and in the time.After() case, we continue execution.
+        Since the same context is handed to every api call, an operation
+        cancelled mid-request stops too, and the api's ErrCancelled is
+        reported as ErrCancel. The 'Output' channel is buffered so the
+        thread can always report its result and exit.
     
     Writing Code For App Layer:
 
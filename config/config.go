@@ -8,13 +8,21 @@ import (
 	"strconv"
 	"sync"
 	"time"
+
+	"github.com/21Bruce/resolved-server/api"
 )
 
-// Venue represents a restaurant venue
+// Venue represents a restaurant venue on one reservation provider
 type Venue struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
-	Slug string `json:"slug"`
+	Provider string `json:"provider,omitempty"` // Defaults to "resy" when omitted
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+}
+
+// Ref returns the provider-qualified reference for the venue
+func (v Venue) Ref() api.VenueRef {
+	return api.VenueRef{Provider: api.NormalizeProvider(v.Provider), VenueID: v.ID}
 }
 
 // venuesFile represents the structure of venues.json
@@ -38,6 +46,18 @@ type Config struct {
 	CookieRefreshInterval time.Duration
 	Venues                []Venue
 	WebAppURL             string
+	SlotSelector          string        // How Reserve ranks open slots: "priority" or "weighted"
+	BurstLead             time.Duration // How long before a scheduled run to prewarm the provider
+	BurstWindow           time.Duration // How long after a scheduled run to keep retrying, 0 for a single attempt
+	BurstInterval         time.Duration // Pause between attempts during the burst window
+	BurstConcurrency      int           // How many scheduled jobs may burst at the same time
+	ClockCalibration      time.Duration // How often to sample Resy's clock, 0 to only sample when prewarming
+	EgressProxies         string        // Comma-separated proxy URLs for Resy traffic, each optionally "id=url"; empty for direct
+	EgressStickiness      string        // What pins traffic to a proxy: "user" or "venue"
+	EgressHealthInterval  time.Duration // How often to health check the proxies
+	EgressRotateCooldown  time.Duration // How long a user or venue avoids a proxy it was rotated off after an Imperva challenge
+	HTTPRecord            string        // Where Resy reservation attempts are recorded: "redis", "dir", or "" for off
+	HTTPRecordDir         string        // Directory recordings are written to when HTTPRecord is "dir"
 }
 
 var (
@@ -63,6 +83,18 @@ func Get() *Config {
 			CookieRefreshInterval: getEnvDuration("COOKIE_REFRESH_INTERVAL", 6*time.Hour),
 			Venues:                loadVenues(),
 			WebAppURL:             getEnv("NEXT_PUBLIC_APP_URL", "http://localhost:3000"),
+			SlotSelector:          getEnv("SLOT_SELECTOR", "priority"),
+			BurstLead:             getEnvDuration("BURST_LEAD", 10*time.Second),
+			BurstWindow:           getEnvDuration("BURST_WINDOW", 30*time.Second),
+			BurstInterval:         getEnvDuration("BURST_INTERVAL", 500*time.Millisecond),
+			BurstConcurrency:      getEnvInt("BURST_CONCURRENCY", 4, 1),
+			ClockCalibration:      getEnvDuration("CLOCK_CALIBRATION_INTERVAL", 5*time.Minute),
+			EgressProxies:         getEnv("EGRESS_PROXIES", ""),
+			EgressStickiness:      getEnv("EGRESS_STICKINESS", "user"),
+			EgressHealthInterval:  getEnvDuration("EGRESS_HEALTH_INTERVAL", time.Minute),
+			EgressRotateCooldown:  getEnvDuration("EGRESS_ROTATE_COOLDOWN", 15*time.Minute),
+			HTTPRecord:            getEnv("HTTP_RECORD", ""),
+			HTTPRecordDir:         getEnv("HTTP_RECORD_DIR", "recordings"),
 		}
 	})
 	return cfg
@@ -84,6 +116,10 @@ func loadVenues() []Venue {
 		return []Venue{}
 	}
 
+	for i := range vf.Venues {
+		vf.Venues[i].Provider = api.NormalizeProvider(vf.Venues[i].Provider)
+	}
+
 	log.Printf("Loaded %d venues from %s", len(vf.Venues), venuesPath)
 	return vf.Venues
 }
@@ -97,6 +133,18 @@ func (c *Config) VenueIDs() []int64 {
 	return ids
 }
 
+// ProviderVenueIDs returns the IDs of the configured venues on one provider
+func (c *Config) ProviderVenueIDs(provider string) []int64 {
+	provider = api.NormalizeProvider(provider)
+	var ids []int64
+	for _, v := range c.Venues {
+		if v.Provider == provider {
+			ids = append(ids, v.ID)
+		}
+	}
+	return ids
+}
+
 // getEnv returns the environment variable value or a default
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -115,6 +163,19 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return value == "true" || value == "1" || value == "yes"
 }
 
+// getEnvInt returns an integer from environment variable or default,
+// raising anything below min to min
+func getEnvInt(key string, defaultValue, min int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		value = defaultValue
+	}
+	if value < min {
+		return min
+	}
+	return value
+}
+
 // getEnvDuration returns a duration from environment variable or default
 // Accepts formats like "6h", "30m", "1h30m"
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
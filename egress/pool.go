@@ -0,0 +1,514 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Name: ErrNoHealthyProxy
+Type: External Var
+Purpose: Returned by Pick when every proxy failed its last health check
+*/
+var ErrNoHealthyProxy = errors.New("no healthy egress proxy")
+
+/*
+Name: Proxy
+Type: External Struct
+Purpose: One egress route. ID names it in logs, admin output and the
+Redis keys of cookies minted through it
+*/
+type Proxy struct {
+	ID  string
+	URL *url.URL
+}
+
+/*
+Name: Proxy.String
+Type: External Func
+Purpose: The proxy's URL with any password masked
+*/
+func (p *Proxy) String() string {
+	return p.URL.Redacted()
+}
+
+/*
+Name: Proxy.HostPort
+Type: External Func
+Purpose: The proxy as scheme://host:port, without credentials
+*/
+func (p *Proxy) HostPort() string {
+	return p.URL.Scheme + "://" + p.URL.Host
+}
+
+/*
+Name: Proxy.ChromeProxyServer
+Type: External Func
+Purpose: The proxy in the form Chrome's --proxy-server flag takes.
+Chrome always resolves names through a SOCKS5 proxy, so socks5h is
+passed as socks5
+Note: Chrome can answer an HTTP(S) proxy's auth challenge (see
+imperva.FetchCookiesVia) but can't authenticate to a SOCKS proxy at
+all, so a SOCKS proxy with credentials is an error
+*/
+func (p *Proxy) ChromeProxyServer() (string, error) {
+	scheme := p.URL.Scheme
+	if scheme == "socks5h" {
+		scheme = "socks5"
+	}
+	if scheme == "socks5" && p.URL.User != nil {
+		return "", fmt.Errorf("proxy %s: Chrome can't authenticate to a SOCKS proxy", p.ID)
+	}
+	return scheme + "://" + p.URL.Host, nil
+}
+
+/*
+Name: Stickiness
+Type: External Type
+Purpose: What requests are pinned to a proxy by
+*/
+type Stickiness string
+
+const (
+	StickyUser  Stickiness = "user"  // Each user's traffic leaves from one proxy
+	StickyVenue Stickiness = "venue" // Each venue's traffic leaves from one proxy
+)
+
+/*
+Name: ParseStickiness
+Type: External Func
+Purpose: Validate a stickiness name, "" meaning StickyUser
+*/
+func ParseStickiness(name string) (Stickiness, error) {
+	switch Stickiness(strings.ToLower(name)) {
+	case "", StickyUser:
+		return StickyUser, nil
+	case StickyVenue:
+		return StickyVenue, nil
+	}
+	return "", fmt.Errorf("unknown egress stickiness %q, expected user or venue", name)
+}
+
+/*
+Name: ParseProxies
+Type: External Func
+Purpose: Read a comma-separated proxy list. Each entry is a URL with
+scheme http, https, socks5 or socks5h, optionally prefixed by "id="
+to name it
+Note: Unnamed proxies are named by host:port
+*/
+func ParseProxies(spec string) ([]*Proxy, error) {
+	var proxies []*Proxy
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id := ""
+		if name, rest, ok := strings.Cut(entry, "="); ok && !strings.Contains(name, "://") {
+			id, entry = strings.TrimSpace(name), strings.TrimSpace(rest)
+		}
+
+		u, err := url.Parse(entry)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q", entry)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("invalid proxy %q: unsupported scheme %q", u.Redacted(), u.Scheme)
+		}
+
+		if id == "" {
+			id = u.Host
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate proxy id %q", id)
+		}
+		seen[id] = true
+		proxies = append(proxies, &Proxy{ID: id, URL: u})
+	}
+	return proxies, nil
+}
+
+/*
+Name: ProxyStatus
+Type: External Struct
+Purpose: A proxy's health as of its last check
+*/
+type ProxyStatus struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"` // Password masked
+	Healthy   bool      `json:"healthy"`
+	CheckedAt time.Time `json:"checked_at,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+/*
+Name: Pool
+Type: External Struct
+Purpose: Spread traffic over a set of proxies. A key (see Key) always
+maps to the same healthy proxy, so a user's or venue's requests keep
+leaving from one address, until Rotate moves it off a proxy that was
+challenged
+Note: Safe for concurrent use; a nil Pool routes everything directly
+*/
+type Pool struct {
+	Stickiness     Stickiness
+	RotateCooldown time.Duration          // How long a rotated key avoids the proxy it left, DefaultRotateCooldown if 0
+	NewTransport   func() *http.Transport // Base for each proxy's transport, a clone of http.DefaultTransport if nil
+
+	mu       sync.Mutex
+	proxies  []*Proxy
+	status   map[string]*ProxyStatus
+	clients  map[string]*http.Client
+	assigned map[string]string               // Key to proxy ID, set by Rotate
+	avoid    map[string]map[string]time.Time // Key to proxy IDs it left, until when
+}
+
+/*
+Name: DefaultRotateCooldown
+Type: External Const
+Purpose: How long a key avoids a proxy it was rotated off
+*/
+const DefaultRotateCooldown = 15 * time.Minute
+
+/*
+Name: healthCheckTimeout
+Type: Internal Const
+Purpose: Bound each proxy's health check request
+*/
+const healthCheckTimeout = 10 * time.Second
+
+/*
+Name: NewPool
+Type: External Func
+Purpose: Build a pool over the given proxies, all assumed healthy
+until the first Check
+*/
+func NewPool(proxies []*Proxy) *Pool {
+	p := &Pool{
+		Stickiness: StickyUser,
+		proxies:    proxies,
+		status:     make(map[string]*ProxyStatus),
+		clients:    make(map[string]*http.Client),
+		assigned:   make(map[string]string),
+		avoid:      make(map[string]map[string]time.Time),
+	}
+	for _, proxy := range proxies {
+		p.status[proxy.ID] = &ProxyStatus{ID: proxy.ID, URL: proxy.String(), Healthy: true}
+	}
+	return p
+}
+
+/*
+Name: Pool.Key
+Type: External Func
+Purpose: The stickiness key for a request on behalf of user at venueID
+Note: In user mode a request with no user falls back to the venue,
+and one with neither shares the "default" key
+*/
+func (p *Pool) Key(user string, venueID int64) string {
+	if p.Stickiness != StickyVenue && user != "" {
+		return "user:" + user
+	}
+	if venueID != 0 {
+		return fmt.Sprintf("venue:%d", venueID)
+	}
+	if user != "" {
+		return "user:" + user
+	}
+	return "default"
+}
+
+/*
+Name: Pool.Proxies
+Type: External Func
+Purpose: Every proxy in the pool
+*/
+func (p *Pool) Proxies() []*Proxy {
+	if p == nil {
+		return nil
+	}
+	return append([]*Proxy(nil), p.proxies...)
+}
+
+/*
+Name: Pool.Healthy
+Type: External Func
+Purpose: The proxies that passed their last health check
+*/
+func (p *Pool) Healthy() []*Proxy {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var healthy []*Proxy
+	for _, proxy := range p.proxies {
+		if p.status[proxy.ID].Healthy {
+			healthy = append(healthy, proxy)
+		}
+	}
+	return healthy
+}
+
+/*
+Name: Pool.Status
+Type: External Func
+Purpose: Every proxy's health, in pool order
+*/
+func (p *Pool) Status() []ProxyStatus {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]ProxyStatus, 0, len(p.proxies))
+	for _, proxy := range p.proxies {
+		out = append(out, *p.status[proxy.ID])
+	}
+	return out
+}
+
+/*
+Name: Pool.Pick
+Type: External Func
+Purpose: The proxy for a key: the one Rotate assigned it while that
+stays healthy, otherwise the healthy proxy the key hashes to highest,
+skipping any the key is avoiding
+Note: If the key is avoiding them all the avoidance is ignored. A nil
+Pool picks nil, meaning direct
+*/
+func (p *Pool) Pick(key string) (*Proxy, error) {
+	if p == nil {
+		return nil, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pickLocked(key, time.Now())
+}
+
+/*
+Name: Pool.pickLocked
+Type: Internal Func
+Purpose: Pick for callers that hold mu
+*/
+func (p *Pool) pickLocked(key string, now time.Time) (*Proxy, error) {
+	var healthy, preferred []*Proxy
+	for _, proxy := range p.proxies {
+		if !p.status[proxy.ID].Healthy {
+			continue
+		}
+		if proxy.ID == p.assigned[key] {
+			return proxy, nil
+		}
+		healthy = append(healthy, proxy)
+		if until, ok := p.avoid[key][proxy.ID]; !ok || now.After(until) {
+			preferred = append(preferred, proxy)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil, ErrNoHealthyProxy
+	}
+	if len(preferred) == 0 {
+		preferred = healthy
+	}
+
+	// Rendezvous hashing: adding or losing a proxy only moves the keys
+	// that hashed to it
+	var best *Proxy
+	var bestScore uint64
+	for _, proxy := range preferred {
+		h := fnv.New64a()
+		h.Write([]byte(key + "|" + proxy.ID))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = proxy, score
+		}
+	}
+	return best, nil
+}
+
+/*
+Name: Pool.Rotate
+Type: External Func
+Purpose: Move a key off a proxy, typically one that answered with an
+Imperva challenge, and return the proxy it now maps to. The key avoids
+the old proxy for RotateCooldown
+*/
+func (p *Pool) Rotate(key string, from *Proxy) (*Proxy, error) {
+	if p == nil || from == nil {
+		return nil, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	cooldown := p.RotateCooldown
+	if cooldown <= 0 {
+		cooldown = DefaultRotateCooldown
+	}
+	if p.avoid[key] == nil {
+		p.avoid[key] = make(map[string]time.Time)
+	}
+	p.avoid[key][from.ID] = now.Add(cooldown)
+	delete(p.assigned, key)
+
+	next, err := p.pickLocked(key, now)
+	if err != nil {
+		return nil, err
+	}
+	if next.ID != from.ID {
+		p.assigned[key] = next.ID
+		log.Printf("egress: rotated %s from %s to %s", key, from.ID, next.ID)
+	}
+	return next, nil
+}
+
+/*
+Name: Pool.Client
+Type: External Func
+Purpose: The http.Client that sends through a proxy, built once per
+proxy so its connections are reused
+Note: A nil proxy has no client
+*/
+func (p *Pool) Client(proxy *Proxy) *http.Client {
+	if p == nil || proxy == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if client, ok := p.clients[proxy.ID]; ok {
+		return client
+	}
+
+	var transport *http.Transport
+	if p.NewTransport != nil {
+		transport = p.NewTransport()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	transport.Proxy = http.ProxyURL(proxy.URL)
+	client := &http.Client{Transport: transport}
+	p.clients[proxy.ID] = client
+	return client
+}
+
+/*
+Name: Pool.Check
+Type: External Func
+Purpose: Send a HEAD request to target through every proxy at once and
+record which ones got an answer
+Note: Any status counts, a challenge page still proves the route works
+*/
+func (p *Pool) Check(ctx context.Context, target string) {
+	if p == nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, proxy := range p.proxies {
+		wg.Add(1)
+		go func(proxy *Proxy) {
+			defer wg.Done()
+			err := p.probe(ctx, proxy, target)
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			status := p.status[proxy.ID]
+			wasHealthy := status.Healthy
+			status.Healthy = err == nil
+			status.CheckedAt = time.Now()
+			status.Error = ""
+			if err != nil {
+				status.Error = err.Error()
+			}
+			if wasHealthy != status.Healthy {
+				log.Printf("egress: proxy %s healthy=%t %s", proxy.ID, status.Healthy, status.Error)
+			}
+		}(proxy)
+	}
+	wg.Wait()
+}
+
+/*
+Name: Pool.probe
+Type: Internal Func
+Purpose: Send one proxy's health check request
+*/
+func (p *Pool) probe(ctx context.Context, proxy *Proxy, target string) error {
+	probeCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(probeCtx, "HEAD", target, nil)
+	if err != nil {
+		return err
+	}
+	response, err := p.Client(proxy).Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+/*
+Name: Pool.Run
+Type: External Func
+Purpose: Check the pool against target straight away and then every
+interval until ctx is done
+*/
+func (p *Pool) Run(ctx context.Context, target string, interval time.Duration) {
+	if p == nil {
+		return
+	}
+	p.Check(ctx, target)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Check(ctx, target)
+		}
+	}
+}
+
+/*
+Name: userKey
+Type: Internal Struct
+Purpose: The context key WithUser stores the user under
+*/
+type userKey struct{}
+
+/*
+Name: WithUser
+Type: External Func
+Purpose: Mark ctx as a request on behalf of a user, for StickyUser pools
+Note: Any stable ID works; the Clerk user ID is what the server uses
+*/
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+/*
+Name: UserFrom
+Type: External Func
+Purpose: The user set by WithUser, or ""
+*/
+func UserFrom(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
@@ -0,0 +1,333 @@
+package httprec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Exchange is one request and the answer to it, as recorded
+type Exchange struct {
+	Attempt        string      `json:"attempt"`
+	Seq            int         `json:"seq"` // Order within the attempt, from 1
+	Time           time.Time   `json:"time"`
+	DurationMs     int64       `json:"duration_ms"`
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestHeader  http.Header `json:"request_header,omitempty"`
+	RequestBody    string      `json:"request_body,omitempty"`
+	Status         int         `json:"status,omitempty"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	ResponseBody   string      `json:"response_body,omitempty"`
+	Truncated      bool        `json:"truncated,omitempty"` // A body was cut at the recorder's MaxBody
+	Error          string      `json:"error,omitempty"`     // Set instead of a response when the request failed
+}
+
+// DefaultMaxBody is how much of each body a Recorder keeps
+const DefaultMaxBody = 1 << 20
+
+// SensitiveHeaders are recorded as "[redacted]". Cookie and Set-Cookie
+// keep the cookie names, which say a lot about an Imperva challenge
+var SensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"X-Resy-Auth-Token",
+	"X-Resy-Universal-Auth",
+	"X-Resy-Universal-Auth-Token",
+}
+
+// SensitiveFields are the JSON keys, form fields and query parameters
+// whose values are recorded as "[redacted]"
+var SensitiveFields = []string{
+	"password",
+	"auth_token",
+	"refresh_token",
+	"api_key",
+	"email",
+	"em_address",
+	"first_name",
+	"last_name",
+	"phone_number",
+	"mobile_number",
+}
+
+// SensitiveRootFields are only redacted at the top level of a JSON body,
+// where Resy's login answer carries the auth token. Slot and booking
+// tokens further in are kept, they're what an incident turns on
+var SensitiveRootFields = []string{"token"}
+
+const redacted = "[redacted]"
+
+type attempt struct {
+	label string
+	seq   atomic.Int64
+}
+
+type attemptKey struct{}
+
+// WithAttempt marks ctx as one attempt, labelling what a Recorder
+// records of the requests made under it. Requests without a label
+// aren't recorded
+func WithAttempt(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, attemptKey{}, &attempt{label: label})
+}
+
+// AttemptFrom returns the label set by WithAttempt, or ""
+func AttemptFrom(ctx context.Context) string {
+	if at, ok := ctx.Value(attemptKey{}).(*attempt); ok {
+		return at.label
+	}
+	return ""
+}
+
+// Recorder saves the requests of labelled attempts (see WithAttempt)
+// and their answers to a Sink, redacted. Saving happens off the request
+// path so recording doesn't slow a booking down; Flush waits for it
+type Recorder struct {
+	Sink    Sink
+	MaxBody int // Bytes of each body kept, DefaultMaxBody if 0
+
+	pending sync.WaitGroup
+}
+
+// Wrap returns a RoundTripper that records what goes through next,
+// http.DefaultTransport if nil
+func (r *Recorder) Wrap(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{recorder: r, next: next}
+}
+
+// Flush waits for every exchange recorded so far to be saved
+func (r *Recorder) Flush() {
+	r.pending.Wait()
+}
+
+func (r *Recorder) maxBody() int {
+	if r.MaxBody > 0 {
+		return r.MaxBody
+	}
+	return DefaultMaxBody
+}
+
+func (r *Recorder) save(ex *Exchange) {
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		if err := r.Sink.Append(context.Background(), ex); err != nil {
+			log.Printf("httprec: couldn't save %s #%d: %v", ex.Attempt, ex.Seq, err)
+		}
+	}()
+}
+
+type transport struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	at, ok := req.Context().Value(attemptKey{}).(*attempt)
+	if !ok || t.recorder.Sink == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	ex := &Exchange{
+		Attempt:       at.label,
+		Seq:           int(at.seq.Add(1)),
+		Time:          time.Now().UTC(),
+		Method:        req.Method,
+		URL:           req.URL.String(),
+		RequestHeader: req.Header.Clone(),
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			body.Close()
+			ex.RequestBody = string(data)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	ex.DurationMs = time.Since(ex.Time).Milliseconds()
+	if err != nil {
+		ex.Error = err.Error()
+		t.record(ex)
+		return nil, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		ex.Error = err.Error()
+		t.record(ex)
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	ex.Status = resp.StatusCode
+	ex.ResponseHeader = resp.Header.Clone()
+	ex.ResponseBody = string(data)
+	t.record(ex)
+	return resp, nil
+}
+
+func (t *transport) record(ex *Exchange) {
+	Redact(ex)
+	max := t.recorder.maxBody()
+	if len(ex.RequestBody) > max {
+		ex.RequestBody, ex.Truncated = ex.RequestBody[:max], true
+	}
+	if len(ex.ResponseBody) > max {
+		ex.ResponseBody, ex.Truncated = ex.ResponseBody[:max], true
+	}
+	t.recorder.save(ex)
+}
+
+// Redact masks the credentials and personal details in an exchange:
+// SensitiveHeaders, cookie values, and SensitiveFields in the URL and
+// in JSON or form bodies. Bodies are rewritten only if something in
+// them was masked
+func Redact(ex *Exchange) {
+	ex.URL = redactURL(ex.URL)
+	redactHeader(ex.RequestHeader)
+	redactHeader(ex.ResponseHeader)
+	ex.RequestBody = redactBody(ex.RequestBody)
+	ex.ResponseBody = redactBody(ex.ResponseBody)
+}
+
+func isSensitive(name string) bool {
+	return containsFold(SensitiveFields, name)
+}
+
+func containsFold(names []string, name string) bool {
+	for _, candidate := range names {
+		if strings.EqualFold(name, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactHeader(header http.Header) {
+	for _, name := range SensitiveHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, redacted)
+		}
+	}
+	for i, value := range header.Values("Cookie") {
+		var parts []string
+		for _, pair := range strings.Split(value, ";") {
+			name, _, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			parts = append(parts, name+"="+redacted)
+		}
+		header["Cookie"][i] = strings.Join(parts, "; ")
+	}
+	for i, value := range header.Values("Set-Cookie") {
+		pair, attrs, _ := strings.Cut(value, ";")
+		name, _, _ := strings.Cut(pair, "=")
+		redactedCookie := strings.TrimSpace(name) + "=" + redacted
+		if attrs != "" {
+			redactedCookie += ";" + attrs
+		}
+		header["Set-Cookie"][i] = redactedCookie
+	}
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return raw
+	}
+	query := u.Query()
+	if redactValues(query) {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+func redactValues(values url.Values) bool {
+	changed := false
+	for name := range values {
+		if isSensitive(name) {
+			values[name] = []string{redacted}
+			changed = true
+		}
+	}
+	return changed
+}
+
+func redactBody(body string) string {
+	trimmed := strings.TrimSpace(body)
+	if trimmed == "" {
+		return body
+	}
+
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		decoder := json.NewDecoder(strings.NewReader(trimmed))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return body
+		}
+		changed := false
+		if root, ok := value.(map[string]interface{}); ok {
+			for key, field := range root {
+				if _, isString := field.(string); isString && containsFold(SensitiveRootFields, key) {
+					root[key] = redacted
+					changed = true
+				}
+			}
+		}
+		if !redactJSON(value) && !changed {
+			return body
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return body
+		}
+		return string(data)
+	}
+
+	if strings.Contains(trimmed, "=") && !strings.ContainsAny(trimmed, " \n<") {
+		values, err := url.ParseQuery(trimmed)
+		if err != nil || !redactValues(values) {
+			return body
+		}
+		return values.Encode()
+	}
+	return body
+}
+
+func redactJSON(value interface{}) bool {
+	changed := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSensitive(key) && field != nil {
+				if _, nested := field.(map[string]interface{}); !nested {
+					v[key] = redacted
+					changed = true
+					continue
+				}
+			}
+			if redactJSON(field) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if redactJSON(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,179 @@
+package httprec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	ex := &Exchange{
+		URL: "https://api.resy.com/2/user?api_key=secret&id=7",
+		RequestHeader: http.Header{
+			"Authorization":     {`ResyAPI api_key="secret"`},
+			"X-Resy-Auth-Token": {"auth-secret"},
+			"Cookie":            {"incap_ses_1=abc; visid_incap_1=def"},
+			"Content-Type":      {"application/x-www-form-urlencoded"},
+		},
+		RequestBody: "email=diner%40example.com&password=hunter2",
+		ResponseHeader: http.Header{
+			"Set-Cookie": {"incap_ses_1=xyz; path=/; Domain=.resy.com"},
+		},
+		ResponseBody: `{"id":7,"token":"auth-secret","first_name":"Dana","payment_methods":[{"id":1}],"slot":{"token":"cfg-1900"}}`,
+	}
+	Redact(ex)
+
+	for _, secret := range []string{"secret", "auth-secret", "hunter2", "diner", "Dana", "abc", "def", "xyz"} {
+		for _, recorded := range []string{ex.URL, ex.RequestBody, ex.ResponseBody,
+			strings.Join(ex.RequestHeader.Values("Authorization"), ""),
+			strings.Join(ex.RequestHeader.Values("X-Resy-Auth-Token"), ""),
+			strings.Join(ex.RequestHeader.Values("Cookie"), ""),
+			strings.Join(ex.ResponseHeader.Values("Set-Cookie"), ""),
+		} {
+			if strings.Contains(recorded, secret) {
+				t.Errorf("expected %q to be redacted, got %q", secret, recorded)
+			}
+		}
+	}
+	if got := ex.RequestHeader.Get("Cookie"); got != "incap_ses_1=[redacted]; visid_incap_1=[redacted]" {
+		t.Errorf("expected cookie names to be kept, got %q", got)
+	}
+	if got := ex.ResponseHeader.Get("Set-Cookie"); got != "incap_ses_1=[redacted]; path=/; Domain=.resy.com" {
+		t.Errorf("expected cookie attributes to be kept, got %q", got)
+	}
+	if !strings.Contains(ex.ResponseBody, `"token":"cfg-1900"`) || !strings.Contains(ex.URL, "id=7") {
+		t.Errorf("expected fields that aren't sensitive to be kept, got %s %s", ex.URL, ex.ResponseBody)
+	}
+
+	// Bodies with nothing to mask are left byte for byte
+	plain := &Exchange{ResponseBody: `{"b":1, "a":2}`}
+	Redact(plain)
+	if plain.ResponseBody != `{"b":1, "a":2}` {
+		t.Errorf("expected an untouched body, got %q", plain.ResponseBody)
+	}
+}
+
+// memorySink keeps exchanges in memory, newest first to check reads sort
+type memorySink struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+func (s *memorySink) Append(ctx context.Context, ex *Exchange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exchanges = append([]Exchange{*ex}, s.exchanges...)
+	return nil
+}
+
+func (s *memorySink) Read(ctx context.Context, attempt string) ([]Exchange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := append([]Exchange(nil), s.exchanges...)
+	sortExchanges(out)
+	return out, nil
+}
+
+func TestRecorder(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte("echo " + string(body)))
+	}))
+	defer upstream.Close()
+
+	sink := &memorySink{}
+	recorder := &Recorder{Sink: sink, MaxBody: 8}
+	client := &http.Client{Transport: recorder.Wrap(nil)}
+
+	ctx := WithAttempt(context.Background(), "res_1-1")
+	for _, body := range []string{"one", "two"} {
+		req, _ := http.NewRequestWithContext(ctx, "POST", upstream.URL+"/3/book", strings.NewReader(body))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(got) != "echo "+body {
+			t.Errorf("expected the response body to reach the caller, got %q", got)
+		}
+	}
+	recorder.Flush()
+
+	// Seq is per attempt, so the sink's order doesn't matter
+	exchanges, _ := sink.Read(ctx, "res_1-1")
+	if len(exchanges) != 2 {
+		t.Fatalf("expected 2 exchanges, got %d", len(exchanges))
+	}
+	first := exchanges[0]
+	if first.Seq != 1 || first.Attempt != "res_1-1" || first.RequestBody != "one" || first.Status != http.StatusOK {
+		t.Errorf("unexpected first exchange %+v", first)
+	}
+	if first.ResponseBody != "echo one" || first.Truncated {
+		t.Errorf("expected the whole response, got %q", first.ResponseBody)
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	replayer := NewReplayer([]Exchange{
+		{Method: "POST", URL: "https://api.resy.com/4/find", Status: 200, ResponseBody: "first"},
+		{Method: "GET", URL: "https://api.resy.com/3/venue?id=1", Status: 404},
+		{Method: "POST", URL: "https://api.resy.com/4/find", Status: 500, ResponseBody: "second"},
+		{Method: "POST", URL: "https://api.resy.com/3/book", Error: "dial tcp: i/o timeout"},
+	})
+	client := &http.Client{Transport: replayer}
+
+	// Hosts are ignored and repeats are answered in order
+	for _, want := range []string{"first", "second"} {
+		resp, err := client.Post("http://127.0.0.1:1/4/find", "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatalf("replay failed: %v", err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(got) != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+
+	if _, err := client.Post("http://127.0.0.1:1/3/book", "", nil); err == nil || !strings.Contains(err.Error(), "i/o timeout") {
+		t.Errorf("expected the recorded error, got %v", err)
+	}
+	if _, err := client.Get("http://127.0.0.1:1/3/venue?id=2"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("expected ErrNotRecorded for another query, got %v", err)
+	}
+	if unused := replayer.Unused(); len(unused) != 1 || unused[0].Status != 404 {
+		t.Errorf("expected the venue lookup left over, got %+v", unused)
+	}
+}
+
+func TestDirSink(t *testing.T) {
+	sink, err := NewDirSink(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirSink failed: %v", err)
+	}
+	ctx := context.Background()
+	for _, seq := range []int{2, 1, 3} {
+		if err := sink.Append(ctx, &Exchange{Attempt: "res/1 2", Seq: seq, Method: "GET"}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	if path := sink.Path("res/1 2"); !strings.HasSuffix(path, "res_1_2.jsonl") {
+		t.Errorf("expected a safe file name, got %s", path)
+	}
+	exchanges, err := sink.Read(ctx, "res/1 2")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	for i, ex := range exchanges {
+		if ex.Seq != i+1 {
+			t.Errorf("exchanges out of order: got %d at position %d", ex.Seq, i)
+		}
+	}
+}
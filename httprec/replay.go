@@ -0,0 +1,108 @@
+package httprec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ErrNotRecorded is returned by a Replayer for a request that no unused
+// exchange matches
+var ErrNotRecorded = errors.New("httprec: no recorded exchange for request")
+
+// Replayer is an http.RoundTripper that answers from recorded exchanges
+// rather than the network. A request gets the first unused exchange with
+// the same method, path and query, so repeated requests get their
+// recorded answers in order. Hosts are ignored, so a recording made
+// against api.resy.com replays against any base URL
+type Replayer struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+	used      []bool
+}
+
+// NewReplayer returns a Replayer over exchanges, in the order given
+func NewReplayer(exchanges []Exchange) *Replayer {
+	return &Replayer{
+		exchanges: exchanges,
+		used:      make([]bool, len(exchanges)),
+	}
+}
+
+// RoundTrip answers req with its recorded response, or the recorded
+// error of a request that failed
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	want := replayKey(req.Method, req.URL.String())
+
+	r.mu.Lock()
+	var ex *Exchange
+	for i := range r.exchanges {
+		if !r.used[i] && replayKey(r.exchanges[i].Method, r.exchanges[i].URL) == want {
+			r.used[i] = true
+			ex = &r.exchanges[i]
+			break
+		}
+	}
+	r.mu.Unlock()
+
+	if ex == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotRecorded, want)
+	}
+	if ex.Error != "" {
+		if strings.Contains(ex.Error, context.DeadlineExceeded.Error()) {
+			return nil, fmt.Errorf("%s: %w", ex.Error, context.DeadlineExceeded)
+		}
+		return nil, errors.New(ex.Error)
+	}
+
+	header := ex.ResponseHeader.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", ex.Status, http.StatusText(ex.Status)),
+		StatusCode:    ex.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(ex.ResponseBody)),
+		ContentLength: int64(len(ex.ResponseBody)),
+		Request:       req,
+	}, nil
+}
+
+// Unused returns the exchanges no request has been answered with yet
+func (r *Replayer) Unused() []Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Exchange
+	for i, ex := range r.exchanges {
+		if !r.used[i] {
+			unused = append(unused, ex)
+		}
+	}
+	return unused
+}
+
+// replayKey is what a request is matched on: method, path and query,
+// the query redacted as it would have been when recorded
+func replayKey(method, raw string) string {
+	u, err := url.Parse(redactURL(raw))
+	if err != nil {
+		return method + " " + raw
+	}
+	key := method + " " + u.Path
+	if u.RawQuery != "" {
+		key += "?" + u.Query().Encode()
+	}
+	return key
+}
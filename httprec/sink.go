@@ -0,0 +1,152 @@
+package httprec
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/21Bruce/resolved-server/store"
+)
+
+// Sink stores recorded exchanges by attempt. A Recorder appends from
+// many goroutines at once
+type Sink interface {
+	Append(ctx context.Context, ex *Exchange) error
+	Read(ctx context.Context, attempt string) ([]Exchange, error)
+}
+
+// DirSink writes each attempt to its own file in Dir, one JSON exchange
+// per line, so a recording can be copied straight into testdata
+type DirSink struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+// NewDirSink returns a DirSink writing under dir, creating it if need be
+func NewDirSink(dir string) (*DirSink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirSink{Dir: dir}, nil
+}
+
+// Path returns the file an attempt is written to
+func (s *DirSink) Path(attempt string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, attempt)
+	return filepath.Join(s.Dir, name+".jsonl")
+}
+
+// Append adds an exchange to its attempt's file
+func (s *DirSink) Append(ctx context.Context, ex *Exchange) error {
+	data, err := json.Marshal(ex)
+	if err != nil {
+		return fmt.Errorf("failed to marshal exchange: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(s.Path(ex.Attempt), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Read returns an attempt's exchanges in order
+func (s *DirSink) Read(ctx context.Context, attempt string) ([]Exchange, error) {
+	return ReadFile(s.Path(attempt))
+}
+
+// RedisSink keeps recordings in Redis through the store package, for
+// store.RecordingTTL
+type RedisSink struct{}
+
+// Append adds an exchange to its attempt's list
+func (RedisSink) Append(ctx context.Context, ex *Exchange) error {
+	data, err := json.Marshal(ex)
+	if err != nil {
+		return fmt.Errorf("failed to marshal exchange: %w", err)
+	}
+	return store.AppendRecording(ctx, ex.Attempt, data)
+}
+
+// Read returns an attempt's exchanges in order
+func (RedisSink) Read(ctx context.Context, attempt string) ([]Exchange, error) {
+	values, err := store.GetRecording(ctx, attempt)
+	if err != nil {
+		return nil, err
+	}
+	exchanges := make([]Exchange, 0, len(values))
+	for _, value := range values {
+		var ex Exchange
+		if err := json.Unmarshal(value, &ex); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal exchange: %w", err)
+		}
+		exchanges = append(exchanges, ex)
+	}
+	sortExchanges(exchanges)
+	return exchanges, nil
+}
+
+// ReadFile reads a recording written by DirSink
+func ReadFile(path string) ([]Exchange, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Decode(file)
+}
+
+// Decode reads exchanges written one JSON object per line and returns
+// them in order
+func Decode(r io.Reader) ([]Exchange, error) {
+	var exchanges []Exchange
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*DefaultMaxBody)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var ex Exchange
+		if err := json.Unmarshal([]byte(line), &ex); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal exchange: %w", err)
+		}
+		exchanges = append(exchanges, ex)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sortExchanges(exchanges)
+	return exchanges, nil
+}
+
+// sortExchanges orders exchanges by attempt and then Seq. They are
+// saved concurrently, so may arrive out of order
+func sortExchanges(exchanges []Exchange) {
+	sort.SliceStable(exchanges, func(i, j int) bool {
+		if exchanges[i].Attempt != exchanges[j].Attempt {
+			return exchanges[i].Attempt < exchanges[j].Attempt
+		}
+		return exchanges[i].Seq < exchanges[j].Seq
+	})
+}
@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/21Bruce/resolved-server/egress"
+	"github.com/chromedp/cdproto/fetch"
 	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
 )
@@ -17,6 +20,7 @@ import (
 type CookieData struct {
 	Cookies   []*http.Cookie
 	UserAgent string
+	ProxyID   string // Egress proxy the challenge was solved through, empty for direct
 }
 
 // DefaultUserAgent is used for browser automation
@@ -28,6 +32,15 @@ func FetchCookies(venueID int64) (*CookieData, error) {
 	return FetchCookiesWithRetry(venueID, 3)
 }
 
+// FetchCookiesVia fetches a venue's Imperva cookies with the browser's traffic
+// sent through an egress proxy, nil meaning direct. Imperva binds cookies to
+// the address that solved the challenge, so they are only good through that proxy.
+// An HTTP(S) proxy's credentials are given when it asks for them; a SOCKS
+// proxy with credentials can't be used, since Chrome doesn't support that
+func FetchCookiesVia(venueID int64, proxy *egress.Proxy) (*CookieData, error) {
+	return fetchCookiesWithRetry(venueID, proxy, 3)
+}
+
 // FetchCookiesVenueURL builds a Resy venue URL for cookie fetch/scrape.
 func FetchCookiesVenueURL(venueID int64) string {
 	slug := resolveVenueSlug(venueID)
@@ -39,6 +52,17 @@ func FetchCookiesVenueURL(venueID int64) string {
 
 // FetchCookiesWithRetry attempts to fetch cookies with retry logic for transient failures
 func FetchCookiesWithRetry(venueID int64, maxRetries int) (*CookieData, error) {
+	return fetchCookiesWithRetry(venueID, nil, maxRetries)
+}
+
+func fetchCookiesWithRetry(venueID int64, proxy *egress.Proxy, maxRetries int) (*CookieData, error) {
+	if proxy != nil {
+		// No number of retries gets Chrome through a proxy it can't use
+		if _, err := proxy.ChromeProxyServer(); err != nil {
+			return nil, err
+		}
+	}
+
 	var lastErr error
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
@@ -47,7 +71,7 @@ func FetchCookiesWithRetry(venueID int64, maxRetries int) (*CookieData, error) {
 			time.Sleep(time.Duration(attempt*2) * time.Second) // Exponential backoff
 		}
 
-		cookieData, err := fetchCookiesOnce(venueID)
+		cookieData, err := fetchCookiesOnce(venueID, proxy)
 		if err == nil {
 			return cookieData, nil
 		}
@@ -60,7 +84,7 @@ func FetchCookiesWithRetry(venueID int64, maxRetries int) (*CookieData, error) {
 }
 
 // fetchCookiesOnce performs a single attempt to fetch cookies
-func fetchCookiesOnce(venueID int64) (*CookieData, error) {
+func fetchCookiesOnce(venueID int64, proxy *egress.Proxy) (*CookieData, error) {
 	// Build the venue URL
 	venueURL := FetchCookiesVenueURL(venueID)
 
@@ -70,6 +94,15 @@ func fetchCookiesOnce(venueID int64) (*CookieData, error) {
 
 	// Build chrome options for headless operation
 	opts := buildChromeOptions()
+	proxyID := ""
+	if proxy != nil {
+		proxyServer, err := proxy.ChromeProxyServer()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, chromedp.ProxyServer(proxyServer))
+		proxyID = proxy.ID
+	}
 
 	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, opts...)
 	defer allocCancel()
@@ -81,8 +114,16 @@ func fetchCookiesOnce(venueID int64) (*CookieData, error) {
 	var cookies []*http.Cookie
 	var userAgent string
 
+	var actions []chromedp.Action
+	if proxy != nil && proxy.URL.User != nil {
+		// Chrome takes no credentials on the command line, so answer the
+		// proxy's auth challenge instead
+		answerProxyAuth(chromeCtx, proxy.URL.User)
+		actions = append(actions, fetch.Enable().WithHandleAuthRequests(true))
+	}
+
 	// Navigate to the venue page and wait for Imperva challenge to complete
-	err := chromedp.Run(chromeCtx,
+	actions = append(actions,
 		chromedp.Navigate(venueURL),
 		// Wait for page to load and Imperva challenge to complete
 		chromedp.Sleep(5*time.Second), // Initial wait for Imperva challenge
@@ -126,7 +167,7 @@ func fetchCookiesOnce(venueID int64) (*CookieData, error) {
 		}),
 	)
 
-	if err != nil {
+	if err := chromedp.Run(chromeCtx, actions...); err != nil {
 		return nil, fmt.Errorf("failed to fetch cookies: %w", err)
 	}
 
@@ -138,14 +179,43 @@ func fetchCookiesOnce(venueID int64) (*CookieData, error) {
 		impervaCookies = cookies
 	}
 
-	log.Printf("Fetched %d cookies for venue %d", len(impervaCookies), venueID)
+	if proxyID != "" {
+		log.Printf("Fetched %d cookies for venue %d via proxy %s", len(impervaCookies), venueID, proxyID)
+	} else {
+		log.Printf("Fetched %d cookies for venue %d", len(impervaCookies), venueID)
+	}
 
 	return &CookieData{
 		Cookies:   impervaCookies,
 		UserAgent: userAgent,
+		ProxyID:   proxyID,
 	}, nil
 }
 
+// answerProxyAuth gives the proxy's credentials whenever Chrome is challenged
+// for them, and lets every other request paused by the Fetch domain through.
+// Chrome only pauses requests once fetch.Enable has run with auth handling on
+func answerProxyAuth(chromeCtx context.Context, user *url.Userinfo) {
+	password, _ := user.Password()
+	chromedp.ListenTarget(chromeCtx, func(ev interface{}) {
+		// Listeners mustn't block, so each answer is sent on its own
+		switch ev := ev.(type) {
+		case *fetch.EventAuthRequired:
+			response := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseCancelAuth}
+			if ev.AuthChallenge != nil && ev.AuthChallenge.Source == fetch.AuthChallengeSourceProxy {
+				response = &fetch.AuthChallengeResponse{
+					Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+					Username: user.Username(),
+					Password: password,
+				}
+			}
+			go chromedp.Run(chromeCtx, fetch.ContinueWithAuth(ev.RequestID, response))
+		case *fetch.EventRequestPaused:
+			go chromedp.Run(chromeCtx, fetch.ContinueRequest(ev.RequestID))
+		}
+	})
+}
+
 // buildChromeOptions constructs Chrome options for headless operation
 func buildChromeOptions() []chromedp.ExecAllocatorOption {
 	opts := []chromedp.ExecAllocatorOption{
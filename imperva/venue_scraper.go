@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/21Bruce/resolved-server/api"
 	"github.com/21Bruce/resolved-server/config"
 	"github.com/21Bruce/resolved-server/store"
 	"github.com/chromedp/chromedp"
@@ -342,10 +343,18 @@ func parseHTMLContent(venueID int64, html string) (*store.BookingWindow, error)
 func resolveVenueSlug(venueID int64) string {
 	cfg := config.Get()
 	for _, venue := range cfg.Venues {
-		if venue.ID == venueID && venue.Slug != "" {
+		// Slugs are Resy URL paths; other providers' IDs may collide
+		if venue.Provider == api.ProviderResy && venue.ID == venueID && venue.Slug != "" {
 			return venue.Slug
 		}
 	}
+
+	// Fall back to venue details cached from GetVenue
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if venue, err := store.GetVenue(ctx, api.ProviderResy, venueID); err == nil {
+		return venue.Slug
+	}
 	return ""
 }
 
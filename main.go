@@ -18,9 +18,12 @@ import (
 	"time"
 
 	"github.com/21Bruce/resolved-server/api"
+	"github.com/21Bruce/resolved-server/api/opentable"
 	"github.com/21Bruce/resolved-server/api/resy"
 	"github.com/21Bruce/resolved-server/app"
 	"github.com/21Bruce/resolved-server/config"
+	"github.com/21Bruce/resolved-server/egress"
+	"github.com/21Bruce/resolved-server/httprec"
 	"github.com/21Bruce/resolved-server/imperva"
 	"github.com/21Bruce/resolved-server/store"
 	"github.com/gorilla/securecookie"
@@ -31,8 +34,10 @@ const maxLogLines = 500
 
 // Structures for JSON responses
 type SearchResponse struct {
-	Results []api.SearchResult `json:"results"`
-	Error   string             `json:"error,omitempty"`
+	Results    []api.SearchResult `json:"results"`
+	NextPage   int                `json:"next_page,omitempty"` // Pass back as page, with next_offset as offset, for more
+	NextOffset int                `json:"next_offset,omitempty"`
+	Error      string             `json:"error,omitempty"`
 }
 
 type LoginRequest struct {
@@ -47,22 +52,55 @@ type LoginResponse struct {
 }
 
 type ReserveRequest struct {
-	VenueID          int64    `json:"venue_id"`
-	ReservationTime  string   `json:"reservation_time"` // datetime-local format in NYC time: YYYY-MM-DDTHH:MM
-	PartySize        int      `json:"party_size"`
-	TablePreferences []string `json:"table_preferences"`
-	IsImmediate      bool     `json:"is_immediate"`
-	RequestTime      string   `json:"request_time"`  // datetime-local format in NYC time: YYYY-MM-DDTHH:MM
-	AutoSchedule     bool     `json:"auto_schedule"` // If true, automatically calculate optimal run time from venue's booking window
+	Provider             string   `json:"provider,omitempty"` // "resy" (default) or "opentable"
+	VenueID              int64    `json:"venue_id"`
+	Venue                string   `json:"venue,omitempty"`             // Provider-qualified alternative to provider + venue_id, e.g. "opentable:1001"
+	ReservationTime      string   `json:"reservation_time"`            // datetime-local format in NYC time: YYYY-MM-DDTHH:MM
+	ReservationTimes     []string `json:"reservation_times,omitempty"` // Further targets in priority order after reservation_time, may be on other days
+	EarliestTime         string   `json:"earliest_time,omitempty"`     // HH:MM in NYC time, slots before it are never booked
+	LatestTime           string   `json:"latest_time,omitempty"`       // HH:MM in NYC time, slots after it are never booked
+	TimePreference       string   `json:"time_preference,omitempty"`   // "closest" (default), "earliest" or "latest"
+	PartySize            int      `json:"party_size"`
+	TablePreferences     []string `json:"table_preferences"`
+	IsImmediate          bool     `json:"is_immediate"`
+	RequestTime          string   `json:"request_time"`                     // datetime-local format in NYC time: YYYY-MM-DDTHH:MM
+	AutoSchedule         bool     `json:"auto_schedule"`                    // If true, automatically calculate optimal run time from venue's booking window
+	DryRun               bool     `json:"dry_run"`                          // If true, go as far as the booking step and report what would have been booked
+	MaxDeposit           *float64 `json:"max_deposit,omitempty"`            // Skip slots asking a larger deposit, no limit if omitted
+	AllowCancellationFee *bool    `json:"allow_cancellation_fee,omitempty"` // If false, skip slots with a cancellation fee, defaults to true
+	Occasion             string   `json:"occasion,omitempty"`               // "birthday", "anniversary", "date_night", "business_meal" or "celebration"
+	DietaryNotes         string   `json:"dietary_notes,omitempty"`          // Allergies and dietary requirements, passed on to the venue
+	SeatingRequest       string   `json:"seating_request,omitempty"`        // Free-text seating wish passed on to the venue, e.g. "quiet corner"
 }
 
 type ReserveResponse struct {
-	ReservationTime string `json:"reservation_time,omitempty"`
-	ReservationID   string `json:"reservation_id,omitempty"`
-	ScheduledFor    string `json:"scheduled_for,omitempty"` // When the sniper will run (for auto_schedule)
-	Error           string `json:"error,omitempty"`
+	ReservationTime       string            `json:"reservation_time,omitempty"`
+	DryRun                bool              `json:"dry_run,omitempty"` // Nothing was booked, the response says what would have been
+	Provider              string            `json:"provider,omitempty"`
+	VenueID               int64             `json:"venue_id,omitempty"`
+	PartySize             int               `json:"party_size,omitempty"`
+	TableType             string            `json:"table_type,omitempty"`
+	Terms                 *api.BookingTerms `json:"terms,omitempty"`
+	ProviderReservationID string            `json:"provider_reservation_id,omitempty"` // The provider's own reference for the booking
+	ReservationToken      string            `json:"reservation_token,omitempty"`       // Pass to /api/cancel with the provider
+	ReservationID         string            `json:"reservation_id,omitempty"`          // ID of the scheduled job, not the provider's booking
+	ScheduledFor          string            `json:"scheduled_for,omitempty"`           // When the sniper will run (for auto_schedule)
+	Code                  string            `json:"code,omitempty"`                    // Stable, machine-readable failure reason, see api.ErrorCode
+	Error                 string            `json:"error,omitempty"`
 }
 
+// Codes /api/reserve sends for failures before the provider is called.
+// Provider failures use api.ErrorCode. Like those, never rename one
+const (
+	codeInvalidRequest   = "invalid_request"
+	codeNotLoggedIn      = "not_logged_in"
+	codeAccountNotLinked = "account_not_linked"
+	codeUnknownProvider  = "unknown_provider"
+	codeSchedulingFailed = "scheduling_failed"
+	// The provider has no notify list, see api.Notifier
+	codeNotifyUnsupported = "notify_unsupported"
+)
+
 type BookingWindowResponse struct {
 	VenueID       int64  `json:"venue_id"`
 	DaysInAdvance int    `json:"days_in_advance"`
@@ -71,8 +109,18 @@ type BookingWindowResponse struct {
 	Error         string `json:"error,omitempty"`
 }
 
+type AvailabilityResponse struct {
+	Provider  string     `json:"provider,omitempty"`
+	VenueID   int64      `json:"venue_id"`
+	Date      string     `json:"date"`
+	PartySize int        `json:"party_size"`
+	Slots     []api.Slot `json:"slots"`
+	Error     string     `json:"error,omitempty"`
+}
+
 type SelectVenueRequest struct {
-	VenueID int64 `json:"venue_id"`
+	Provider string `json:"provider,omitempty"`
+	VenueID  int64  `json:"venue_id"`
 }
 
 type SelectVenueResponse struct {
@@ -83,6 +131,7 @@ type SelectVenueResponse struct {
 // Admin request/response types
 type CookieImportRequest struct {
 	VenueID   int64        `json:"venue_id"`
+	Proxy     string       `json:"proxy,omitempty"` // ID of the egress proxy the cookies were minted through, empty for direct
 	Cookies   []CookieData `json:"cookies"`
 	UserAgent string       `json:"user_agent"`
 	TTLHours  int          `json:"ttl_hours"` // Optional, defaults to 24
@@ -96,16 +145,33 @@ type CookieData struct {
 }
 
 type CookieStatusResponse struct {
-	VenueID   int64     `json:"venue_id"`
-	Exists    bool      `json:"exists"`
+	VenueID   int64               `json:"venue_id"`
+	Exists    bool                `json:"exists"`
+	ExpiresAt time.Time           `json:"expires_at,omitempty"` // Of the direct cookies, or the requested proxy's
+	TTL       string              `json:"ttl,omitempty"`
+	Proxies   []CookieProxyStatus `json:"proxies,omitempty"` // Every stored set, direct ("") and per egress proxy
+	Error     string              `json:"error,omitempty"`
+}
+
+type CookieProxyStatus struct {
+	Proxy     string    `json:"proxy"` // Empty for cookies minted over a direct connection
 	ExpiresAt time.Time `json:"expires_at,omitempty"`
 	TTL       string    `json:"ttl,omitempty"`
-	Error     string    `json:"error,omitempty"`
 }
 
 type HealthResponse struct {
-	Status string `json:"status"`
-	Redis  string `json:"redis"`
+	Status    string           `json:"status"`
+	Redis     string           `json:"redis"`
+	ClockSkew *ClockSkewStatus `json:"clock_skew,omitempty"`
+}
+
+// ClockSkewStatus is the estimate of how far Resy's clock is ahead of
+// ours: the true offset is within offset_ms ± uncertainty_ms
+type ClockSkewStatus struct {
+	OffsetMs      int64  `json:"offset_ms"`
+	UncertaintyMs int64  `json:"uncertainty_ms"`
+	Samples       int    `json:"samples"`
+	UpdatedAt     string `json:"updated_at"`
 }
 
 // Reservation list response types
@@ -116,13 +182,73 @@ type ReservationListResponse struct {
 
 type ReservationSummary struct {
 	ID               string   `json:"id"`
+	Provider         string   `json:"provider"`
 	VenueID          int64    `json:"venue_id"`
 	VenueName        string   `json:"venue_name"`
 	ReservationTime  string   `json:"reservation_time"`
+	ReservationTimes []string `json:"reservation_times,omitempty"` // Every target in priority order, when there is more than one
+	EarliestTime     string   `json:"earliest_time,omitempty"`
+	LatestTime       string   `json:"latest_time,omitempty"`
+	TimePreference   string   `json:"time_preference,omitempty"`
 	PartySize        int      `json:"party_size"`
 	RunTime          string   `json:"run_time"`
 	CreatedAt        string   `json:"created_at"`
 	TablePreferences []string `json:"table_preferences"`
+	DryRun           bool     `json:"dry_run,omitempty"`
+	Occasion         string   `json:"occasion,omitempty"`
+	DietaryNotes     string   `json:"dietary_notes,omitempty"`
+	SeatingRequest   string   `json:"seating_request,omitempty"`
+}
+
+type AttemptsResponse struct {
+	Attempts []store.Attempt `json:"attempts"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type OutcomeResponse struct {
+	Outcome *store.Outcome `json:"outcome,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+type RecordingResponse struct {
+	Attempt   string             `json:"attempt"`
+	Exchanges []httprec.Exchange `json:"exchanges"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// Notify request/response types
+type NotifyRequest struct {
+	Provider     string `json:"provider,omitempty"` // "resy" (default)
+	VenueID      int64  `json:"venue_id"`
+	Venue        string `json:"venue,omitempty"` // Provider-qualified alternative to provider + venue_id, e.g. "resy:12345"
+	Date         string `json:"date"`            // YYYY-MM-DD
+	PartySize    int    `json:"party_size"`
+	EarliestTime string `json:"earliest_time,omitempty"` // HH:MM in the venue's timezone, empty for no bound
+	LatestTime   string `json:"latest_time,omitempty"`   // HH:MM in the venue's timezone, empty for no bound
+}
+
+type NotifySummary struct {
+	ID           string `json:"id"`
+	Provider     string `json:"provider"`
+	VenueID      int64  `json:"venue_id"`
+	VenueName    string `json:"venue_name"`
+	Date         string `json:"date"`
+	PartySize    int    `json:"party_size"`
+	EarliestTime string `json:"earliest_time,omitempty"`
+	LatestTime   string `json:"latest_time,omitempty"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type NotifyResponse struct {
+	Notify  *NotifySummary `json:"notify,omitempty"`
+	Message string         `json:"message,omitempty"`
+	Code    string         `json:"code,omitempty"` // Same codes as /api/reserve, plus notify_unsupported
+	Error   string         `json:"error,omitempty"`
+}
+
+type NotifyListResponse struct {
+	Notifies []NotifySummary `json:"notifies"`
+	Error    string          `json:"error,omitempty"`
 }
 
 type CancelReservationResponse struct {
@@ -131,15 +257,18 @@ type CancelReservationResponse struct {
 }
 
 type AdminStatusResponse struct {
-	Venues              []VenueStatus `json:"venues"`
-	PendingReservations int64         `json:"pending_reservations"`
-	Error               string        `json:"error,omitempty"`
+	Venues              []VenueStatus        `json:"venues"`
+	PendingReservations int64                `json:"pending_reservations"`
+	ClockSkew           *ClockSkewStatus     `json:"clock_skew,omitempty"`
+	Egress              []egress.ProxyStatus `json:"egress,omitempty"`
+	Error               string               `json:"error,omitempty"`
 }
 
 type VenueStatus struct {
-	VenueID      int64  `json:"venue_id"`
-	CookieStatus string `json:"cookie_status"`
-	TTL          string `json:"ttl,omitempty"`
+	VenueID      int64             `json:"venue_id"`
+	CookieStatus string            `json:"cookie_status"`
+	TTL          string            `json:"ttl,omitempty"`
+	Proxies      map[string]string `json:"proxies,omitempty"` // Egress proxy ID to the TTL of its cookies, or "missing"
 }
 
 // Resy link request/response types
@@ -153,6 +282,48 @@ type ResyLinkResponse struct {
 	Error   string `json:"error,omitempty"`
 }
 
+// OpenTable books as a guest, so linking takes contact details, not a
+// password. Its link endpoints answer with the Resy link response types
+type OpenTableLinkRequest struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+}
+
+type ResyCancelRequest struct {
+	ResyToken string `json:"resy_token"`
+}
+
+// CancelRequest names a booking as a scheduled job's outcome does. The
+// answer is a ResyCancelResponse whatever the provider
+type CancelRequest struct {
+	Provider         string `json:"provider,omitempty"` // Defaults to "resy"
+	ReservationToken string `json:"reservation_token"`
+}
+
+type ResyCancelResponse struct {
+	Message      string  `json:"message,omitempty"`
+	Refund       bool    `json:"refund"`
+	RefundAmount float64 `json:"refund_amount,omitempty"`
+	Code         string  `json:"code,omitempty"` // See api.ErrorCode, for the provider's failures
+	Error        string  `json:"error,omitempty"`
+}
+
+type ResyReservationsResponse struct {
+	Reservations []ResyReservationSummary `json:"reservations"`
+	Error        string                   `json:"error,omitempty"`
+}
+
+type ResyReservationSummary struct {
+	VenueID         int64  `json:"venue_id"`
+	VenueName       string `json:"venue_name"`
+	ReservationTime string `json:"reservation_time"`
+	PartySize       int    `json:"party_size"`
+	TableType       string `json:"table_type,omitempty"`
+	ResyToken       string `json:"resy_token"`
+}
+
 type ResyStatusResponse struct {
 	Linked bool   `json:"linked"`
 	Error  string `json:"error,omitempty"`
@@ -164,14 +335,18 @@ var s *securecookie.SecureCookie
 var logLines []string
 var logMu sync.Mutex
 
+// Scheduled jobs currently being attempted, keyed by reservation ID
+var inflightJobs = make(map[string]context.CancelFunc)
+var inflightMu sync.Mutex
+
 // NYC timezone for parsing user input times
 var nycLocation *time.Location
 
-// Venue name lookup map (loaded from venues.json)
-var venueNames map[int64]string
+// Venue name lookup map (loaded from venues.json), keyed by provider-qualified venue ID
+var venueNames map[string]string
 
 func loadVenueNames() {
-	venueNames = make(map[int64]string)
+	venueNames = make(map[string]string)
 	data, err := os.ReadFile("venues.json")
 	if err != nil {
 		log.Printf("Warning: Could not load venues.json: %v", err)
@@ -179,8 +354,9 @@ func loadVenueNames() {
 	}
 	var venues struct {
 		Venues []struct {
-			ID   int64  `json:"id"`
-			Name string `json:"name"`
+			Provider string `json:"provider"`
+			ID       int64  `json:"id"`
+			Name     string `json:"name"`
 		} `json:"venues"`
 	}
 	if err := json.Unmarshal(data, &venues); err != nil {
@@ -188,17 +364,110 @@ func loadVenueNames() {
 		return
 	}
 	for _, v := range venues.Venues {
-		venueNames[v.ID] = v.Name
+		venueNames[api.VenueRef{Provider: v.Provider, VenueID: v.ID}.String()] = v.Name
 	}
 }
 
-func getVenueName(venueID int64) string {
-	if name, ok := venueNames[venueID]; ok {
+// getVenueName prefers the names in venues.json and falls back to the
+// provider's venue details
+func getVenueName(ctx context.Context, appCtx *app.AppCtx, provider string, venueID int64) string {
+	if name, ok := venueNames[api.VenueRef{Provider: provider, VenueID: venueID}.String()]; ok {
 		return name
 	}
+
+	// Names are only decoration, so don't hold a listing up for long
+	lookupCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	if venue, err := lookupVenue(lookupCtx, appCtx, provider, venueID); err == nil && venue.Name != "" {
+		return venue.Name
+	}
 	return fmt.Sprintf("Venue %d", venueID)
 }
 
+// getVenueNames is getVenueName for a listing: each distinct venue is
+// looked up once, all of them side by side. Names are keyed by
+// VenueRef.String()
+func getVenueNames(ctx context.Context, appCtx *app.AppCtx, refs []api.VenueRef) map[string]string {
+	var distinct []api.VenueRef
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if key := ref.String(); !seen[key] {
+			seen[key] = true
+			distinct = append(distinct, ref)
+		}
+	}
+
+	resolved := make([]string, len(distinct))
+	var wg sync.WaitGroup
+	for i, ref := range distinct {
+		wg.Add(1)
+		go func(i int, ref api.VenueRef) {
+			defer wg.Done()
+			resolved[i] = getVenueName(ctx, appCtx, ref.Provider, ref.VenueID)
+		}(i, ref)
+	}
+	wg.Wait()
+
+	names := make(map[string]string, len(distinct))
+	for i, ref := range distinct {
+		names[ref.String()] = resolved[i]
+	}
+	return names
+}
+
+// lookupVenue returns a provider's venue details from the Redis cache,
+// fetching and caching them through GetVenue on a miss. A venue that
+// fails to resolve isn't asked about again for store.VenueMissTTL
+func lookupVenue(ctx context.Context, appCtx *app.AppCtx, provider string, venueID int64) (*api.Venue, error) {
+	provider = api.NormalizeProvider(provider)
+	if venue, err := store.GetVenue(ctx, provider, venueID); err == nil {
+		return venue, nil
+	}
+	if missed, _ := store.VenueMissed(ctx, provider, venueID); missed {
+		return nil, fmt.Errorf("venue %s failed to resolve recently", api.VenueRef{Provider: provider, VenueID: venueID})
+	}
+
+	impl, err := appCtx.Provider(provider)
+	if err != nil {
+		return nil, err
+	}
+	venue, err := impl.GetVenue(ctx, api.GetVenueParam{VenueID: venueID})
+	if err != nil {
+		// ctx may be what ran out, the marker should still be written
+		if err := store.SaveVenueMiss(context.WithoutCancel(ctx), provider, venueID); err != nil {
+			appendLog("Warning: failed to record venue miss for " + api.VenueRef{Provider: provider, VenueID: venueID}.String() + ": " + err.Error())
+		}
+		return nil, err
+	}
+
+	venue.Provider = provider
+	if err := store.SaveVenue(ctx, venue); err != nil {
+		appendLog("Warning: failed to cache venue " + api.VenueRef{Provider: provider, VenueID: venueID}.String() + ": " + err.Error())
+	}
+	return venue, nil
+}
+
+// bookingWindowFor works out when a venue releases tables, from its venue
+// details where the provider publishes them and otherwise, for Resy, by
+// scraping the venue page
+func bookingWindowFor(ctx context.Context, appCtx *app.AppCtx, provider string, venueID int64) (*store.BookingWindow, error) {
+	provider = api.NormalizeProvider(provider)
+	venue, err := lookupVenue(ctx, appCtx, provider, venueID)
+	if err == nil {
+		if bw := store.BookingWindowFromVenue(venue); bw != nil {
+			return bw, nil
+		}
+	}
+
+	if provider == api.ProviderResy {
+		return imperva.GetOrScrapeBookingWindow(ctx, venueID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%s does not publish a booking window for venue %d", provider, venueID)
+}
+
 func init() {
 	// Load NYC timezone
 	var err error
@@ -222,8 +491,33 @@ func init() {
 func main() {
 	cfg := config.Get()
 
+	selector, err := api.SlotSelectorByName(cfg.SlotSelector)
+	if err != nil {
+		log.Fatalf("Invalid SLOT_SELECTOR: %v", err)
+	}
+
 	resyAPI := resy.GetDefaultAPI()
-	appCtx := app.AppCtx{API: &resyAPI}
+	resyAPI.Selector = selector
+	resyAPI.Clock = resy.NewClockSkew()
+	egressPool, err := newEgressPool(cfg)
+	if err != nil {
+		log.Fatalf("Invalid egress configuration: %v", err)
+	}
+	resyAPI.Egress = egressPool
+	recordingSink, err := newRecordingSink(cfg)
+	if err != nil {
+		log.Fatalf("Invalid recording configuration: %v", err)
+	}
+	if recordingSink != nil {
+		resy.WithRecorder(recordingSink)(&resyAPI)
+	}
+	openTableAPI := opentable.GetDefaultAPI()
+	openTableAPI.Selector = selector
+
+	providers := api.NewRegistry()
+	providers.Register(api.ProviderResy, &resyAPI)
+	providers.Register(api.ProviderOpenTable, &openTableAPI)
+	appCtx := app.AppCtx{API: &resyAPI, Providers: providers}
 
 	// Health endpoint
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -233,8 +527,9 @@ func main() {
 			redisStatus = "disconnected"
 		}
 		sendJSONResponse(w, HealthResponse{
-			Status: "ok",
-			Redis:  redisStatus,
+			Status:    "ok",
+			Redis:     redisStatus,
+			ClockSkew: clockSkewStatus(resyAPI.Clock),
 		}, http.StatusOK)
 	})
 
@@ -277,8 +572,14 @@ func main() {
 			ttl = time.Duration(req.TTLHours) * time.Hour
 		}
 
+		// Cookies only work through the proxy that minted them
+		if req.Proxy != "" && proxyByID(egressPool, req.Proxy) == nil {
+			sendJSONResponse(w, map[string]string{"error": "Unknown egress proxy: " + req.Proxy}, http.StatusBadRequest)
+			return
+		}
+
 		ctx := context.Background()
-		if err := store.SaveCookies(ctx, req.VenueID, httpCookies, req.UserAgent, ttl); err != nil {
+		if err := store.SaveCookiesVia(ctx, req.VenueID, req.Proxy, httpCookies, req.UserAgent, ttl); err != nil {
 			appendLog("Failed to save cookies for venue " + strconv.FormatInt(req.VenueID, 10) + ": " + err.Error())
 			sendJSONResponse(w, map[string]string{"error": "Failed to save cookies: " + err.Error()}, http.StatusInternalServerError)
 			return
@@ -309,31 +610,53 @@ func main() {
 
 		ctx := context.Background()
 
+		// ?proxy= narrows the request to the cookies minted through one
+		// egress proxy (empty for direct); without it every set is covered
+		query := r.URL.Query()
+		proxyID, oneProxy := query.Get("proxy"), query.Has("proxy")
+
 		switch r.Method {
 		case http.MethodGet:
-			exists, err := store.CookieExists(ctx, venueID)
+			proxyIDs, err := store.CookieProxies(ctx, venueID)
 			if err != nil {
 				sendJSONResponse(w, CookieStatusResponse{VenueID: venueID, Error: err.Error()}, http.StatusInternalServerError)
 				return
 			}
 
-			resp := CookieStatusResponse{VenueID: venueID, Exists: exists}
-			if exists {
-				ttl, _ := store.GetCookieTTL(ctx, venueID)
-				resp.TTL = ttl.String()
-				cookieData, _ := store.GetCookies(ctx, venueID)
-				if cookieData != nil {
-					resp.ExpiresAt = cookieData.ExpiresAt
+			resp := CookieStatusResponse{VenueID: venueID}
+			for _, id := range proxyIDs {
+				if oneProxy && id != proxyID {
+					continue
+				}
+				status := CookieProxyStatus{Proxy: id}
+				if ttl, err := store.GetCookieTTLVia(ctx, venueID, id); err == nil && ttl > 0 {
+					status.TTL = ttl.String()
+				}
+				if cookieData, _ := store.GetCookiesVia(ctx, venueID, id); cookieData != nil {
+					status.ExpiresAt = cookieData.ExpiresAt
+				}
+				resp.Exists = true
+				resp.Proxies = append(resp.Proxies, status)
+				if id == proxyID {
+					resp.TTL = status.TTL
+					resp.ExpiresAt = status.ExpiresAt
 				}
 			}
 			sendJSONResponse(w, resp, http.StatusOK)
 
 		case http.MethodDelete:
-			if err := store.DeleteCookies(ctx, venueID); err != nil {
+			what := "venue " + strconv.FormatInt(venueID, 10)
+			if oneProxy {
+				err = store.DeleteCookiesVia(ctx, venueID, proxyID)
+				what += " via proxy " + strconv.Quote(proxyID)
+			} else {
+				err = store.DeleteCookies(ctx, venueID)
+			}
+			if err != nil {
 				sendJSONResponse(w, map[string]string{"error": err.Error()}, http.StatusInternalServerError)
 				return
 			}
-			appendLog("Deleted cookies for venue " + strconv.FormatInt(venueID, 10))
+			appendLog("Deleted cookies for " + what)
 			sendJSONResponse(w, map[string]string{"message": "Cookies deleted"}, http.StatusOK)
 
 		default:
@@ -341,6 +664,39 @@ func main() {
 		}
 	}, cfg))
 
+	// Recorded HTTP traffic of a Resy attempt: /admin/recordings/{attempt}
+	http.HandleFunc("/admin/recordings/", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		if !validateAdminToken(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		attempt := strings.TrimPrefix(r.URL.Path, "/admin/recordings/")
+		if attempt == "" {
+			sendJSONResponse(w, RecordingResponse{Error: "Attempt required"}, http.StatusBadRequest)
+			return
+		}
+		if recordingSink == nil {
+			sendJSONResponse(w, RecordingResponse{Attempt: attempt, Error: "Recording is off, set HTTP_RECORD"}, http.StatusNotFound)
+			return
+		}
+
+		exchanges, err := recordingSink.Read(r.Context(), attempt)
+		if errors.Is(err, os.ErrNotExist) || (err == nil && len(exchanges) == 0) {
+			sendJSONResponse(w, RecordingResponse{Attempt: attempt, Error: "Recording not found"}, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			sendJSONResponse(w, RecordingResponse{Attempt: attempt, Error: "Failed to read recording"}, http.StatusInternalServerError)
+			return
+		}
+		sendJSONResponse(w, RecordingResponse{Attempt: attempt, Exchanges: exchanges}, http.StatusOK)
+	}, cfg))
+
 	http.HandleFunc("/admin/status", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -361,13 +717,13 @@ func main() {
 			return
 		}
 
-		// Get venue IDs from config
-		venueIDs := cfg.VenueIDs()
+		// Imperva cookies only exist for Resy venues
+		venueIDs := cfg.ProviderVenueIDs(api.ProviderResy)
 		venues := make([]VenueStatus, 0, len(venueIDs))
 
 		for _, venueID := range venueIDs {
 			status := VenueStatus{VenueID: venueID}
-			exists, _ := store.CookieExists(ctx, venueID)
+			exists, _ := store.CookieExistsVia(ctx, venueID, "")
 			if exists {
 				ttl, _ := store.GetCookieTTL(ctx, venueID)
 				status.CookieStatus = "valid"
@@ -375,12 +731,23 @@ func main() {
 			} else {
 				status.CookieStatus = "missing"
 			}
+			for _, proxy := range egressPool.Proxies() {
+				if status.Proxies == nil {
+					status.Proxies = make(map[string]string)
+				}
+				status.Proxies[proxy.ID] = "missing"
+				if ttl, err := store.GetCookieTTLVia(ctx, venueID, proxy.ID); err == nil && ttl > 0 {
+					status.Proxies[proxy.ID] = ttl.String()
+				}
+			}
 			venues = append(venues, status)
 		}
 
 		sendJSONResponse(w, AdminStatusResponse{
 			Venues:              venues,
 			PendingReservations: pendingCount,
+			ClockSkew:           clockSkewStatus(resyAPI.Clock),
+			Egress:              egressPool.Status(),
 		}, http.StatusOK)
 	}, cfg))
 
@@ -392,8 +759,17 @@ func main() {
 		}
 
 		var searchRequest struct {
-			Name  string `json:"name"`
-			Limit int    `json:"limit"`
+			Provider     string  `json:"provider"`
+			Name         string  `json:"name"`
+			Limit        int     `json:"limit"`
+			Page         int     `json:"page"`
+			Offset       int     `json:"offset"`
+			Latitude     float64 `json:"latitude"`
+			Longitude    float64 `json:"longitude"`
+			RadiusMeters int     `json:"radius_meters"`
+			Neighborhood string  `json:"neighborhood"`
+			Cuisine      string  `json:"cuisine"`
+			PriceBand    int     `json:"price_band"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&searchRequest); err != nil {
@@ -402,17 +778,35 @@ func main() {
 		}
 
 		searchParam := api.SearchParam{
-			Name:  searchRequest.Name,
-			Limit: searchRequest.Limit,
+			Name:         searchRequest.Name,
+			Limit:        searchRequest.Limit,
+			Page:         searchRequest.Page,
+			Offset:       searchRequest.Offset,
+			Latitude:     searchRequest.Latitude,
+			Longitude:    searchRequest.Longitude,
+			RadiusMeters: searchRequest.RadiusMeters,
+			Neighborhood: searchRequest.Neighborhood,
+			Cuisine:      searchRequest.Cuisine,
+			PriceBand:    searchRequest.PriceBand,
+		}
+		if err := searchParam.Validate(); err != nil {
+			sendJSONResponse(w, SearchResponse{Error: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		provider, err := appCtx.Provider(searchRequest.Provider)
+		if err != nil {
+			sendJSONResponse(w, SearchResponse{Error: err.Error()}, http.StatusBadRequest)
+			return
 		}
 
-		results, err := appCtx.API.Search(searchParam)
+		results, err := provider.Search(r.Context(), searchParam)
 		if err != nil {
 			sendJSONResponse(w, SearchResponse{Error: err.Error()}, http.StatusInternalServerError)
 			return
 		}
 
-		sendJSONResponse(w, SearchResponse{Results: results.Results}, http.StatusOK)
+		sendJSONResponse(w, SearchResponse{Results: results.Results, NextPage: results.NextPage, NextOffset: results.NextOffset}, http.StatusOK)
 	}, cfg))
 
 	// Select Venue API endpoint
@@ -433,7 +827,13 @@ func main() {
 			session = make(map[string]string)
 		}
 
+		if _, err := appCtx.Provider(selectReq.Provider); err != nil {
+			sendJSONResponse(w, SelectVenueResponse{Error: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
 		session["venue_id"] = strconv.FormatInt(selectReq.VenueID, 10)
+		session["provider"] = api.NormalizeProvider(selectReq.Provider)
 
 		encoded, err := s.Encode("session", session)
 		if err != nil {
@@ -471,7 +871,7 @@ func main() {
 			Password: loginReq.Password,
 		}
 
-		loginResp, err := appCtx.API.Login(loginParam)
+		loginResp, err := appCtx.API.Login(r.Context(), loginParam)
 		if err != nil {
 			switch err {
 			case api.ErrLoginWrong:
@@ -521,7 +921,7 @@ func main() {
 
 		var reserveReq ReserveRequest
 		if err := json.NewDecoder(r.Body).Decode(&reserveReq); err != nil {
-			sendJSONResponse(w, ReserveResponse{Error: "Invalid request format"}, http.StatusBadRequest)
+			sendJSONResponse(w, ReserveResponse{Code: codeInvalidRequest, Error: "Invalid request format"}, http.StatusBadRequest)
 			return
 		}
 
@@ -530,14 +930,26 @@ func main() {
 		var clerkUserID string
 		var session map[string]string
 
+		// A provider-qualified venue overrides provider + venue_id
+		providerName := reserveReq.Provider
+		venueID := reserveReq.VenueID
+		if reserveReq.Venue != "" {
+			ref, err := api.ParseVenueRef(reserveReq.Venue)
+			if err != nil {
+				sendJSONResponse(w, ReserveResponse{Code: codeInvalidRequest, Error: "Invalid venue. Use provider:id, e.g. resy:12345"}, http.StatusBadRequest)
+				return
+			}
+			providerName, venueID = ref.Provider, ref.VenueID
+		}
+
 		// Check for Clerk user ID header (new auth flow)
 		clerkUserID = r.Header.Get("X-Clerk-User-Id")
 		if clerkUserID != "" {
-			// Fetch Resy credentials from Redis
+			// Fetch the provider's credentials from Redis
 			ctx := context.Background()
-			creds, err := store.GetResyCredentials(ctx, clerkUserID)
+			creds, err := store.GetCredentials(ctx, providerName, clerkUserID)
 			if err != nil {
-				sendJSONResponse(w, ReserveResponse{Error: "Resy account not linked. Please link your Resy account first."}, http.StatusUnauthorized)
+				sendJSONResponse(w, ReserveResponse{Code: codeAccountNotLinked, Error: accountNotLinkedMessage(providerName)}, http.StatusUnauthorized)
 				return
 			}
 			authToken = creds.AuthToken
@@ -547,14 +959,14 @@ func main() {
 			var err error
 			session, err = getSession(r)
 			if err != nil {
-				sendJSONResponse(w, ReserveResponse{Error: "Unauthorized. Please log in."}, http.StatusUnauthorized)
+				sendJSONResponse(w, ReserveResponse{Code: codeNotLoggedIn, Error: "Unauthorized. Please log in."}, http.StatusUnauthorized)
 				return
 			}
 
 			var ok bool
 			authToken, ok = session["auth_token"]
 			if !ok || authToken == "" {
-				sendJSONResponse(w, ReserveResponse{Error: "Authentication token missing. Please log in."}, http.StatusUnauthorized)
+				sendJSONResponse(w, ReserveResponse{Code: codeNotLoggedIn, Error: "Authentication token missing. Please log in."}, http.StatusUnauthorized)
 				return
 			}
 
@@ -564,30 +976,62 @@ func main() {
 			}
 		}
 
-		venueID := reserveReq.VenueID
 		if venueID == 0 {
 			// Only try session lookup for legacy flow (non-Clerk users)
 			if session == nil {
-				sendJSONResponse(w, ReserveResponse{Error: "Venue ID missing. Please select a restaurant."}, http.StatusBadRequest)
+				sendJSONResponse(w, ReserveResponse{Code: codeInvalidRequest, Error: "Venue ID missing. Please select a restaurant."}, http.StatusBadRequest)
 				return
 			}
 			venueIDStr, ok := session["venue_id"]
 			if !ok || venueIDStr == "" {
-				sendJSONResponse(w, ReserveResponse{Error: "Venue ID missing. Please select a restaurant first."}, http.StatusBadRequest)
+				sendJSONResponse(w, ReserveResponse{Code: codeInvalidRequest, Error: "Venue ID missing. Please select a restaurant first."}, http.StatusBadRequest)
 				return
 			}
 			parsedVenueID, err := strconv.ParseInt(venueIDStr, 10, 64)
 			if err != nil {
-				sendJSONResponse(w, ReserveResponse{Error: "Invalid Venue ID"}, http.StatusBadRequest)
+				sendJSONResponse(w, ReserveResponse{Code: codeInvalidRequest, Error: "Invalid Venue ID"}, http.StatusBadRequest)
 				return
 			}
 			venueID = parsedVenueID
+			providerName = session["provider"]
+		}
+
+		providerName = api.NormalizeProvider(providerName)
+		provider, err := appCtx.Provider(providerName)
+		if err != nil {
+			sendJSONResponse(w, ReserveResponse{Code: codeUnknownProvider, Error: "Unknown provider: " + providerName}, http.StatusBadRequest)
+			return
+		}
+
+		// A session comes from /api/login, which only logs in to Resy
+		if session != nil && providerName != api.ProviderResy {
+			sendJSONResponse(w, ReserveResponse{Code: codeAccountNotLinked, Error: accountNotLinkedMessage(providerName)}, http.StatusUnauthorized)
+			return
+		}
+
+		// Parse the reservation times (NYC timezone, converted to UTC) in priority order
+		reservationTimes, err := parseReservationTimes(reserveReq)
+		if err != nil {
+			sendJSONResponse(w, ReserveResponse{Code: codeInvalidRequest, Error: "Invalid reservation time format. Use YYYY-MM-DDTHH:MM"}, http.StatusBadRequest)
+			return
 		}
+		reservationTime := reservationTimes[0]
 
-		// Parse the reservation time (NYC timezone, converted to UTC)
-		reservationTime, err := parseTimeNYC(reserveReq.ReservationTime)
+		window, err := api.ParseTimeWindow(reserveReq.EarliestTime, reserveReq.LatestTime, reserveReq.TimePreference)
 		if err != nil {
-			sendJSONResponse(w, ReserveResponse{Error: "Invalid reservation time format. Use YYYY-MM-DDTHH:MM"}, http.StatusBadRequest)
+			sendJSONResponse(w, ReserveResponse{Code: codeInvalidRequest, Error: "Invalid time window: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		payment := paymentPolicy(reserveReq.MaxDeposit, reserveReq.AllowCancellationFee)
+		if err := payment.Validate(); err != nil {
+			sendJSONResponse(w, ReserveResponse{Code: codeInvalidRequest, Error: "Invalid payment limits: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		requests := specialRequests(reserveReq.Occasion, reserveReq.DietaryNotes, reserveReq.SeatingRequest)
+		if err := requests.Validate(); err != nil {
+			sendJSONResponse(w, ReserveResponse{Code: codeInvalidRequest, Error: "Invalid special requests: " + err.Error()}, http.StatusBadRequest)
 			return
 		}
 
@@ -596,24 +1040,31 @@ func main() {
 			if reserveReq.AutoSchedule {
 				// Auto-calculate run time from venue's booking window
 				ctx := context.Background()
-				bw, err := imperva.GetOrScrapeBookingWindow(ctx, venueID)
+				bw, err := bookingWindowFor(ctx, &appCtx, providerName, venueID)
 				if err != nil {
 					appendLog("Failed to get booking window for venue " + strconv.FormatInt(venueID, 10) + ": " + err.Error())
-					sendJSONResponse(w, ReserveResponse{Error: "Failed to determine booking window: " + err.Error()}, http.StatusInternalServerError)
+					sendJSONResponse(w, ReserveResponse{Code: codeSchedulingFailed, Error: "Failed to determine booking window: " + err.Error()}, http.StatusInternalServerError)
 					return
 				}
 
-				requestTime, err = bw.CalculateRunTime(reservationTime)
-				if err != nil {
-					sendJSONResponse(w, ReserveResponse{Error: "Failed to calculate run time: " + err.Error()}, http.StatusInternalServerError)
-					return
+				// Run once the last of the target days has opened, so every
+				// target is bookable when the attempt is made
+				for _, target := range reservationTimes {
+					runTime, err := bw.CalculateRunTime(target)
+					if err != nil {
+						sendJSONResponse(w, ReserveResponse{Code: codeSchedulingFailed, Error: "Failed to calculate run time: " + err.Error()}, http.StatusInternalServerError)
+						return
+					}
+					if runTime.After(requestTime) {
+						requestTime = runTime
+					}
 				}
 
 				appendLog("Auto-scheduled: venue " + strconv.FormatInt(venueID, 10) + " opens " + strconv.Itoa(bw.DaysInAdvance) + " days ahead at " + strconv.Itoa(bw.ReleaseHour) + ":" + fmt.Sprintf("%02d", bw.ReleaseMinute))
 			} else {
 				requestTime, err = parseTimeNYC(reserveReq.RequestTime)
 				if err != nil {
-					sendJSONResponse(w, ReserveResponse{Error: "Invalid request time format. Use YYYY-MM-DDTHH:MM"}, http.StatusBadRequest)
+					sendJSONResponse(w, ReserveResponse{Code: codeInvalidRequest, Error: "Invalid request time format. Use YYYY-MM-DDTHH:MM"}, http.StatusBadRequest)
 					return
 				}
 			}
@@ -629,43 +1080,51 @@ func main() {
 			// Attempt reservation now
 			reserveParam := api.ReserveParam{
 				VenueID:          venueID,
-				ReservationTimes: []time.Time{reservationTime},
+				ReservationTimes: reservationTimes,
+				Window:           window,
 				PartySize:        reserveReq.PartySize,
 				LoginResp:        api.LoginResponse{AuthToken: authToken, PaymentMethodID: paymentMethodID},
 				TableTypes:       tableTypes,
+				DryRun:           reserveReq.DryRun,
+				Payment:          payment,
+				Requests:         requests,
 			}
 
-			appendLog("Attempting immediate reservation for venue " + strconv.FormatInt(venueID, 10))
-			appendLog("Reservation details: party_size=" + strconv.Itoa(reserveReq.PartySize) + ", time=" + reservationTime.Format("2006-01-02 15:04"))
+			appendLog("Attempting immediate reservation for venue " + api.VenueRef{Provider: providerName, VenueID: venueID}.String())
+			appendLog("Reservation details: party_size=" + strconv.Itoa(reserveReq.PartySize) + ", times=" + formatTimes(reservationTimes, "2006-01-02 15:04"))
 			if paymentMethodID == 0 {
 				appendLog("Warning: No payment method ID found in session - booking step may fail")
 			}
-			reserveResp, err := appCtx.API.Reserve(reserveParam)
+			reserveCtx := egress.WithUser(r.Context(), clerkUserID)
+			if recordsTraffic(provider) {
+				label := "immediate-" + strconv.FormatInt(time.Now().UnixMilli(), 10)
+				reserveCtx = httprec.WithAttempt(reserveCtx, label)
+				appendLog("Recording immediate reservation as " + label)
+			}
+			reserveResp, err := provider.Reserve(reserveCtx, reserveParam)
 			if err != nil {
 				appendLog("Immediate reservation failed: " + err.Error())
 
-				// Check for specific error types using errors.Is/As
-				var netErr *api.NetworkError
-				if errors.As(err, &netErr) {
-					appendLog("Network error details - Step: " + netErr.Step + ", Status: " + strconv.Itoa(netErr.Status) + ", Message: " + netErr.Message)
-					sendJSONResponse(w, ReserveResponse{Error: "Network error at " + netErr.Step + " step: " + netErr.Message}, http.StatusInternalServerError)
-				} else if errors.Is(err, api.ErrNetwork) {
-					sendJSONResponse(w, ReserveResponse{Error: "Network error. Please try again later."}, http.StatusInternalServerError)
-				} else if errors.Is(err, api.ErrNoTable) {
-					sendJSONResponse(w, ReserveResponse{Error: "No available tables found for the selected time."}, http.StatusBadRequest)
-				} else if errors.Is(err, api.ErrImperva) {
-					sendJSONResponse(w, ReserveResponse{Error: "Imperva challenge: please refresh cookies via /admin/cookies/import"}, http.StatusServiceUnavailable)
-				} else if errors.Is(err, api.ErrNoOffer) {
-					sendJSONResponse(w, ReserveResponse{Error: "No reservations available for this date."}, http.StatusBadRequest)
-				} else {
-					sendJSONResponse(w, ReserveResponse{Error: "An unexpected error occurred: " + err.Error()}, http.StatusInternalServerError)
-				}
+				errResp, status := reserveErrorResponse(err, providerName)
+				sendJSONResponse(w, errResp, status)
 				return
 			}
 
-			appendLog("Immediate reservation successful")
+			if reserveResp.DryRun {
+				appendLog("Immediate dry run would have booked " + describeSlot(reserveResp))
+			} else {
+				appendLog("Immediate reservation successful")
+			}
 			sendJSONResponse(w, ReserveResponse{
-				ReservationTime: reserveResp.ReservationTime.In(nycLocation).Format("2006-01-02 3:04 PM EST"),
+				ReservationTime:       reserveResp.ReservationTime.In(nycLocation).Format("2006-01-02 3:04 PM EST"),
+				DryRun:                reserveResp.DryRun,
+				Provider:              providerName,
+				VenueID:               reserveResp.VenueID,
+				PartySize:             reserveResp.PartySize,
+				TableType:             reserveResp.TableType,
+				Terms:                 reserveResp.Terms,
+				ProviderReservationID: reserveResp.ReservationID,
+				ReservationToken:      reserveResp.ReservationToken,
 			}, http.StatusOK)
 		} else {
 			// Schedule for later - save to Redis
@@ -678,22 +1137,33 @@ func main() {
 			}
 
 			scheduledRes := &store.ScheduledReservation{
-				ID:               resID,
-				VenueID:          venueID,
-				ReservationTime:  reservationTime,
-				PartySize:        reserveReq.PartySize,
-				TablePreferences: reserveReq.TablePreferences,
-				AuthToken:        authToken,
-				PaymentMethodID:  paymentMethodID,
-				ClerkUserID:      clerkUserID,
-				UsageType:        usageType,
-				RunTime:          requestTime,
-				CreatedAt:        time.Now().UTC(),
+				ID:                   resID,
+				Provider:             providerName,
+				VenueID:              venueID,
+				ReservationTime:      reservationTime,
+				ReservationTimes:     reservationTimes,
+				EarliestTime:         reserveReq.EarliestTime,
+				LatestTime:           reserveReq.LatestTime,
+				TimePreference:       reserveReq.TimePreference,
+				PartySize:            reserveReq.PartySize,
+				TablePreferences:     reserveReq.TablePreferences,
+				AuthToken:            authToken,
+				PaymentMethodID:      paymentMethodID,
+				ClerkUserID:          clerkUserID,
+				UsageType:            usageType,
+				DryRun:               reserveReq.DryRun,
+				MaxDeposit:           reserveReq.MaxDeposit,
+				AllowCancellationFee: reserveReq.AllowCancellationFee,
+				Occasion:             string(requests.Occasion),
+				DietaryNotes:         requests.Dietary,
+				SeatingRequest:       requests.Seating,
+				RunTime:              requestTime,
+				CreatedAt:            time.Now().UTC(),
 			}
 
 			if err := store.SaveReservation(ctx, scheduledRes); err != nil {
 				appendLog("Failed to schedule reservation: " + err.Error())
-				sendJSONResponse(w, ReserveResponse{Error: "Failed to schedule reservation: " + err.Error()}, http.StatusInternalServerError)
+				sendJSONResponse(w, ReserveResponse{Code: codeSchedulingFailed, Error: "Failed to schedule reservation: " + err.Error()}, http.StatusInternalServerError)
 				return
 			}
 
@@ -725,10 +1195,16 @@ func main() {
 			return
 		}
 
+		providerName := api.NormalizeProvider(r.URL.Query().Get("provider"))
+		if _, err := appCtx.Provider(providerName); err != nil {
+			sendJSONResponse(w, BookingWindowResponse{Error: "Unknown provider: " + providerName}, http.StatusBadRequest)
+			return
+		}
+
 		ctx := context.Background()
-		bw, err := imperva.GetOrScrapeBookingWindow(ctx, venueID)
+		bw, err := bookingWindowFor(ctx, &appCtx, providerName, venueID)
 		if err != nil {
-			appendLog("Failed to get booking window for venue " + strconv.FormatInt(venueID, 10) + ": " + err.Error())
+			appendLog("Failed to get booking window for venue " + api.VenueRef{Provider: providerName, VenueID: venueID}.String() + ": " + err.Error())
 			sendJSONResponse(w, BookingWindowResponse{Error: "Failed to get booking window: " + err.Error()}, http.StatusInternalServerError)
 			return
 		}
@@ -741,6 +1217,87 @@ func main() {
 		}, http.StatusOK)
 	}, cfg))
 
+	// Availability endpoint - list open slots for a venue without booking
+	http.HandleFunc("/api/availability/", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extract venue ID from path: /api/availability/{venue_id}?date=YYYY-MM-DD&party_size=N
+		pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/availability/"), "/")
+		if len(pathParts) == 0 || pathParts[0] == "" {
+			sendJSONResponse(w, AvailabilityResponse{Error: "Venue ID required"}, http.StatusBadRequest)
+			return
+		}
+
+		venueID, err := strconv.ParseInt(pathParts[0], 10, 64)
+		if err != nil {
+			sendJSONResponse(w, AvailabilityResponse{Error: "Invalid venue ID"}, http.StatusBadRequest)
+			return
+		}
+
+		date := r.URL.Query().Get("date")
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			sendJSONResponse(w, AvailabilityResponse{VenueID: venueID, Error: "Invalid date format. Use YYYY-MM-DD"}, http.StatusBadRequest)
+			return
+		}
+
+		partySize := 2
+		if ps := r.URL.Query().Get("party_size"); ps != "" {
+			partySize, err = strconv.Atoi(ps)
+			if err != nil || partySize <= 0 {
+				sendJSONResponse(w, AvailabilityResponse{VenueID: venueID, Date: date, Error: "Invalid party size"}, http.StatusBadRequest)
+				return
+			}
+		}
+
+		providerName := api.NormalizeProvider(r.URL.Query().Get("provider"))
+		provider, err := appCtx.Provider(providerName)
+		if err != nil {
+			sendJSONResponse(w, AvailabilityResponse{VenueID: venueID, Error: "Unknown provider: " + providerName}, http.StatusBadRequest)
+			return
+		}
+
+		// Auth is optional here: use linked credentials or a session token when we have them
+		var authToken string
+		if clerkUserID := r.Header.Get("X-Clerk-User-Id"); clerkUserID != "" {
+			if creds, err := store.GetCredentials(r.Context(), providerName, clerkUserID); err == nil {
+				authToken = creds.AuthToken
+			}
+		} else if session, err := getSession(r); err == nil && providerName == api.ProviderResy {
+			// Session tokens are always Resy's, see /api/login
+			authToken = session["auth_token"]
+		}
+
+		availability, err := provider.Availability(r.Context(), api.AvailabilityParam{
+			VenueID:   venueID,
+			Date:      date,
+			PartySize: partySize,
+			LoginResp: api.LoginResponse{AuthToken: authToken},
+		})
+		if err != nil {
+			appendLog("Availability lookup failed for venue " + strconv.FormatInt(venueID, 10) + ": " + err.Error())
+			resp := AvailabilityResponse{Provider: providerName, VenueID: venueID, Date: date, PartySize: partySize}
+			if errors.Is(err, api.ErrImperva) {
+				resp.Error = "Imperva challenge: please refresh cookies via /admin/cookies/import"
+				sendJSONResponse(w, resp, http.StatusServiceUnavailable)
+			} else {
+				resp.Error = "Failed to fetch availability: " + err.Error()
+				sendJSONResponse(w, resp, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		sendJSONResponse(w, AvailabilityResponse{
+			Provider:  providerName,
+			VenueID:   venueID,
+			Date:      date,
+			PartySize: partySize,
+			Slots:     availability.Slots,
+		}, http.StatusOK)
+	}, cfg))
+
 	// List all scheduled reservations
 	http.HandleFunc("/api/reservations", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -767,25 +1324,48 @@ func main() {
 			return
 		}
 
+		refs := make([]api.VenueRef, 0, len(reservations))
+		for _, res := range reservations {
+			refs = append(refs, api.VenueRef{Provider: res.Provider, VenueID: res.VenueID})
+		}
+		names := getVenueNames(r.Context(), &appCtx, refs)
+
 		summaries := make([]ReservationSummary, 0, len(reservations))
 		for _, res := range reservations {
 			summaries = append(summaries, ReservationSummary{
 				ID:               res.ID,
+				Provider:         api.NormalizeProvider(res.Provider),
 				VenueID:          res.VenueID,
-				VenueName:        getVenueName(res.VenueID),
+				VenueName:        names[api.VenueRef{Provider: res.Provider, VenueID: res.VenueID}.String()],
 				ReservationTime:  res.ReservationTime.In(nycLocation).Format("2006-01-02 3:04 PM"),
+				ReservationTimes: alternativeTimes(res),
+				EarliestTime:     res.EarliestTime,
+				LatestTime:       res.LatestTime,
+				TimePreference:   res.TimePreference,
 				PartySize:        res.PartySize,
 				RunTime:          res.RunTime.In(nycLocation).Format("2006-01-02 3:04 PM EST"),
 				CreatedAt:        res.CreatedAt.In(nycLocation).Format("2006-01-02 3:04 PM"),
 				TablePreferences: res.TablePreferences,
+				DryRun:           res.DryRun,
+				Occasion:         res.Occasion,
+				DietaryNotes:     res.DietaryNotes,
+				SeatingRequest:   res.SeatingRequest,
 			})
 		}
 
 		sendJSONResponse(w, ReservationListResponse{Reservations: summaries}, http.StatusOK)
 	}, cfg))
 
-	// Cancel a scheduled reservation
+	// Cancel a scheduled reservation, or list its booking attempts
 	http.HandleFunc("/api/reservations/", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/attempts") {
+			handleReservationAttempts(w, r)
+			return
+		}
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/outcome") {
+			handleReservationOutcome(w, r)
+			return
+		}
 		if r.Method != http.MethodDelete {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -821,10 +1401,37 @@ func main() {
 			return
 		}
 
+		// Abort the booking attempt if the scheduler is running it right now
+		if cancelInflightJob(resID) {
+			appendLog("Aborted in-flight booking attempt for reservation " + resID)
+		}
+
 		appendLog("Cancelled reservation: " + resID)
 		sendJSONResponse(w, CancelReservationResponse{Message: "Reservation cancelled"}, http.StatusOK)
 	}, cfg))
 
+	// Notify endpoints - join a provider's notify list for a venue and
+	// list what the user has joined
+	http.HandleFunc("/api/notify", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			createNotify(w, r, appCtx)
+		case http.MethodGet:
+			listNotifies(w, r, appCtx)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}, cfg))
+
+	// Leave a notify list: DELETE /api/notify/{id}
+	http.HandleFunc("/api/notify/", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		deleteNotify(w, r, appCtx)
+	}, cfg))
+
 	// Logs endpoint
 	http.HandleFunc("/api/logs", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -860,7 +1467,7 @@ func main() {
 			Password: linkReq.Password,
 		}
 
-		loginResp, err := appCtx.API.Login(loginParam)
+		loginResp, err := appCtx.API.Login(r.Context(), loginParam)
 		if err != nil {
 			switch err {
 			case api.ErrLoginWrong:
@@ -893,46 +1500,145 @@ func main() {
 		sendJSONResponse(w, ResyLinkResponse{Message: "Resy account linked successfully"}, http.StatusOK)
 	}, cfg))
 
-	// Resy Status endpoint - check if a Clerk user has linked their Resy account
-	http.HandleFunc("/api/resy/status", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
+	// Cancel endpoint - cancel a booked reservation on any provider, e.g.
+	// one from a scheduled job's outcome, which carries both fields.
+	// Scheduled jobs that haven't run yet are removed via DELETE /api/reservations/{id} instead.
+	http.HandleFunc("/api/cancel", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		clerkUserID := r.Header.Get("X-Clerk-User-Id")
-		if clerkUserID == "" {
-			sendJSONResponse(w, ResyStatusResponse{Error: "Unauthorized"}, http.StatusUnauthorized)
+		var cancelReq CancelRequest
+		if err := json.NewDecoder(r.Body).Decode(&cancelReq); err != nil {
+			sendJSONResponse(w, ResyCancelResponse{Error: "Invalid request format"}, http.StatusBadRequest)
 			return
 		}
+		if cancelReq.ReservationToken == "" {
+			sendJSONResponse(w, ResyCancelResponse{Error: "reservation_token is required"}, http.StatusBadRequest)
+			return
+		}
+		cancelBooking(w, r, appCtx, cancelReq.Provider, cancelReq.ReservationToken)
+	}, cfg))
 
-		ctx := context.Background()
-		exists, err := store.ResyCredentialsExist(ctx, clerkUserID)
-		if err != nil {
-			sendJSONResponse(w, ResyStatusResponse{Error: "Failed to check status"}, http.StatusInternalServerError)
+	// Resy Cancel endpoint - /api/cancel for Resy, kept for existing callers
+	http.HandleFunc("/api/resy/cancel", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		sendJSONResponse(w, ResyStatusResponse{Linked: exists}, http.StatusOK)
+		var cancelReq ResyCancelRequest
+		if err := json.NewDecoder(r.Body).Decode(&cancelReq); err != nil {
+			sendJSONResponse(w, ResyCancelResponse{Error: "Invalid request format"}, http.StatusBadRequest)
+			return
+		}
+		if cancelReq.ResyToken == "" {
+			sendJSONResponse(w, ResyCancelResponse{Error: "resy_token is required"}, http.StatusBadRequest)
+			return
+		}
+		cancelBooking(w, r, appCtx, api.ProviderResy, cancelReq.ResyToken)
 	}, cfg))
 
-	// Resy Unlink endpoint - remove linked Resy account
-	http.HandleFunc("/api/resy/unlink", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
+	// Resy Reservations endpoint - list the upcoming reservations a linked user holds on Resy
+	http.HandleFunc("/api/resy/reservations", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
 		clerkUserID := r.Header.Get("X-Clerk-User-Id")
 		if clerkUserID == "" {
-			sendJSONResponse(w, ResyLinkResponse{Error: "Unauthorized"}, http.StatusUnauthorized)
+			sendJSONResponse(w, ResyReservationsResponse{Error: "Unauthorized"}, http.StatusUnauthorized)
 			return
 		}
 
-		ctx := context.Background()
-		if err := store.DeleteResyCredentials(ctx, clerkUserID); err != nil {
-			appendLog("Failed to unlink Resy account for user " + clerkUserID + ": " + err.Error())
-			sendJSONResponse(w, ResyLinkResponse{Error: "Failed to unlink account"}, http.StatusInternalServerError)
+		creds, err := store.GetResyCredentials(r.Context(), clerkUserID)
+		if err != nil {
+			sendJSONResponse(w, ResyReservationsResponse{Error: "Resy account not linked. Please link your Resy account first."}, http.StatusUnauthorized)
+			return
+		}
+
+		listResp, err := appCtx.API.ListReservations(egress.WithUser(r.Context(), clerkUserID), api.ListReservationsParam{
+			LoginResp: api.LoginResponse{AuthToken: creds.AuthToken, PaymentMethodID: creds.PaymentMethodID},
+		})
+		if err != nil {
+			appendLog("Failed to list Resy reservations for user " + clerkUserID + ": " + err.Error())
+			if errors.Is(err, api.ErrAuthExpired) {
+				sendJSONResponse(w, ResyReservationsResponse{Error: "Resy session expired. Please re-link your Resy account."}, http.StatusUnauthorized)
+			} else {
+				sendJSONResponse(w, ResyReservationsResponse{Error: "Failed to fetch reservations from Resy"}, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		var unnamed []api.VenueRef
+		for _, res := range listResp.Reservations {
+			if res.VenueName == "" {
+				unnamed = append(unnamed, api.VenueRef{Provider: api.ProviderResy, VenueID: res.VenueID})
+			}
+		}
+		names := getVenueNames(r.Context(), &appCtx, unnamed)
+
+		summaries := make([]ResyReservationSummary, 0, len(listResp.Reservations))
+		for _, res := range listResp.Reservations {
+			venueName := res.VenueName
+			if venueName == "" {
+				venueName = names[api.VenueRef{Provider: api.ProviderResy, VenueID: res.VenueID}.String()]
+			}
+			summaries = append(summaries, ResyReservationSummary{
+				VenueID:         res.VenueID,
+				VenueName:       venueName,
+				ReservationTime: res.ReservationTime.In(nycLocation).Format("2006-01-02 3:04 PM"),
+				PartySize:       res.PartySize,
+				TableType:       res.TableType,
+				ResyToken:       res.ReservationToken,
+			})
+		}
+
+		sendJSONResponse(w, ResyReservationsResponse{Reservations: summaries}, http.StatusOK)
+	}, cfg))
+
+	// Resy Status endpoint - check if a Clerk user has linked their Resy account
+	http.HandleFunc("/api/resy/status", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		clerkUserID := r.Header.Get("X-Clerk-User-Id")
+		if clerkUserID == "" {
+			sendJSONResponse(w, ResyStatusResponse{Error: "Unauthorized"}, http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.Background()
+		exists, err := store.ResyCredentialsExist(ctx, clerkUserID)
+		if err != nil {
+			sendJSONResponse(w, ResyStatusResponse{Error: "Failed to check status"}, http.StatusInternalServerError)
+			return
+		}
+
+		sendJSONResponse(w, ResyStatusResponse{Linked: exists}, http.StatusOK)
+	}, cfg))
+
+	// Resy Unlink endpoint - remove linked Resy account
+	http.HandleFunc("/api/resy/unlink", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		clerkUserID := r.Header.Get("X-Clerk-User-Id")
+		if clerkUserID == "" {
+			sendJSONResponse(w, ResyLinkResponse{Error: "Unauthorized"}, http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.Background()
+		if err := store.DeleteResyCredentials(ctx, clerkUserID); err != nil {
+			appendLog("Failed to unlink Resy account for user " + clerkUserID + ": " + err.Error())
+			sendJSONResponse(w, ResyLinkResponse{Error: "Failed to unlink account"}, http.StatusInternalServerError)
 			return
 		}
 
@@ -940,16 +1646,131 @@ func main() {
 		sendJSONResponse(w, ResyLinkResponse{Message: "Resy account unlinked successfully"}, http.StatusOK)
 	}, cfg))
 
+	// OpenTable Link endpoint - register the user as an OpenTable guest
+	// and keep the guest token for their OpenTable bookings
+	http.HandleFunc("/api/opentable/link", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		clerkUserID := r.Header.Get("X-Clerk-User-Id")
+		if clerkUserID == "" {
+			sendJSONResponse(w, ResyLinkResponse{Error: "Unauthorized"}, http.StatusUnauthorized)
+			return
+		}
+
+		var linkReq OpenTableLinkRequest
+		if err := json.NewDecoder(r.Body).Decode(&linkReq); err != nil {
+			sendJSONResponse(w, ResyLinkResponse{Error: "Invalid request format"}, http.StatusBadRequest)
+			return
+		}
+
+		provider, err := appCtx.Provider(api.ProviderOpenTable)
+		if err != nil {
+			sendJSONResponse(w, ResyLinkResponse{Error: "OpenTable is not available"}, http.StatusNotFound)
+			return
+		}
+
+		loginResp, err := provider.Login(r.Context(), api.LoginParam{
+			FirstName: strings.TrimSpace(linkReq.FirstName),
+			LastName:  strings.TrimSpace(linkReq.LastName),
+			Email:     strings.TrimSpace(linkReq.Email),
+			Mobile:    strings.TrimSpace(linkReq.Phone),
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, api.ErrLoginWrong):
+				sendJSONResponse(w, ResyLinkResponse{Error: "OpenTable needs a first name, last name, email and phone number it accepts"}, http.StatusBadRequest)
+			case errors.Is(err, api.ErrNetwork):
+				sendJSONResponse(w, ResyLinkResponse{Error: "Network error. Please try again later."}, http.StatusInternalServerError)
+			default:
+				sendJSONResponse(w, ResyLinkResponse{Error: "Failed to register with OpenTable"}, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// OpenTable keeps card details per booking, so there is no payment method to store
+		creds := &store.Credentials{
+			Provider:    api.ProviderOpenTable,
+			ClerkUserID: clerkUserID,
+			AuthToken:   loginResp.AuthToken,
+		}
+		if err := store.SaveCredentials(context.Background(), creds); err != nil {
+			appendLog("Failed to save OpenTable credentials for user " + clerkUserID + ": " + err.Error())
+			sendJSONResponse(w, ResyLinkResponse{Error: "Failed to save credentials"}, http.StatusInternalServerError)
+			return
+		}
+
+		appendLog("Linked OpenTable account for Clerk user " + clerkUserID)
+		sendJSONResponse(w, ResyLinkResponse{Message: "OpenTable account linked successfully"}, http.StatusOK)
+	}, cfg))
+
+	// OpenTable Status endpoint - check if the user has linked OpenTable
+	http.HandleFunc("/api/opentable/status", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		clerkUserID := r.Header.Get("X-Clerk-User-Id")
+		if clerkUserID == "" {
+			sendJSONResponse(w, ResyStatusResponse{Error: "Unauthorized"}, http.StatusUnauthorized)
+			return
+		}
+
+		exists, err := store.CredentialsExist(context.Background(), api.ProviderOpenTable, clerkUserID)
+		if err != nil {
+			sendJSONResponse(w, ResyStatusResponse{Error: "Failed to check status"}, http.StatusInternalServerError)
+			return
+		}
+
+		sendJSONResponse(w, ResyStatusResponse{Linked: exists}, http.StatusOK)
+	}, cfg))
+
+	// OpenTable Unlink endpoint - forget the user's OpenTable guest token
+	http.HandleFunc("/api/opentable/unlink", requireInternalToken(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		clerkUserID := r.Header.Get("X-Clerk-User-Id")
+		if clerkUserID == "" {
+			sendJSONResponse(w, ResyLinkResponse{Error: "Unauthorized"}, http.StatusUnauthorized)
+			return
+		}
+
+		if err := store.DeleteCredentials(context.Background(), api.ProviderOpenTable, clerkUserID); err != nil {
+			appendLog("Failed to unlink OpenTable account for user " + clerkUserID + ": " + err.Error())
+			sendJSONResponse(w, ResyLinkResponse{Error: "Failed to unlink account"}, http.StatusInternalServerError)
+			return
+		}
+
+		appendLog("Unlinked OpenTable account for Clerk user " + clerkUserID)
+		sendJSONResponse(w, ResyLinkResponse{Message: "OpenTable account unlinked successfully"}, http.StatusOK)
+	}, cfg))
+
 	// Create cancellable context for scheduler
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Start the scheduling goroutine (Redis-backed)
-	go handleScheduledReservations(ctx, appCtx, cfg)
+	go handleScheduledReservations(ctx, appCtx, cfg, egressPool)
+
+	// Keep the estimate of Resy's clock fresh (if enabled)
+	if cfg.ClockCalibration > 0 {
+		go handleClockCalibration(ctx, &resyAPI, cfg)
+	}
+
+	// Health check the egress proxies (if any)
+	if egressPool != nil {
+		go egressPool.Run(ctx, resy.DefaultBaseURL, cfg.EgressHealthInterval)
+	}
 
 	// Start the cookie refresh goroutine (if enabled)
 	if cfg.CookieRefreshEnabled {
-		go handleCookieRefresh(ctx, cfg)
+		go handleCookieRefresh(ctx, cfg, egressPool)
 	}
 
 	// Create server for graceful shutdown
@@ -980,15 +1801,21 @@ func main() {
 	appendLog("Server stopped")
 }
 
-func handleScheduledReservations(ctx context.Context, appCtx app.AppCtx, cfg *config.Config) {
+func handleScheduledReservations(ctx context.Context, appCtx app.AppCtx, cfg *config.Config, pool *egress.Pool) {
+	// Due jobs run side by side, but only BurstConcurrency of them burst
+	// at once
+	bursts := make(chan struct{}, cfg.BurstConcurrency)
+	var jobs sync.WaitGroup
+	defer jobs.Wait()
+
 	for {
 		select {
 		case <-ctx.Done():
 			appendLog("Scheduler shutting down")
 			return
 		default:
-			// Get the next scheduled reservation
-			nextRes, err := store.GetNextReservation(ctx)
+			// Get the next scheduled reservation that isn't already running
+			nextRes, err := store.GetNextReservationExcept(ctx, inflightJobIDs())
 			if err != nil || nextRes == nil {
 				// No pending reservations, check again in 30 seconds (shorter for faster shutdown response)
 				select {
@@ -1000,11 +1827,13 @@ func handleScheduledReservations(ctx context.Context, appCtx app.AppCtx, cfg *co
 				continue
 			}
 
-			now := time.Now().UTC()
+			// Run times are on the provider's clock, which may not agree with ours
+			scheduledProvider, _ := appCtx.Provider(api.NormalizeProvider(nextRes.Provider))
+			now := serviceNow(scheduledProvider).UTC()
 
-			if nextRes.RunTime.After(now) {
-				// Sleep until the scheduled time (max 30 seconds to allow for faster shutdown response)
-				sleepDuration := nextRes.RunTime.Sub(now)
+			if untilRun := nextRes.RunTime.Sub(now); untilRun > cfg.BurstLead {
+				// Sleep until it's time to prewarm (max 30 seconds to allow for faster shutdown response)
+				sleepDuration := untilRun - cfg.BurstLead
 				if sleepDuration > 30*time.Second {
 					sleepDuration = 30 * time.Second
 				}
@@ -1017,61 +1846,764 @@ func handleScheduledReservations(ctx context.Context, appCtx app.AppCtx, cfg *co
 				continue
 			}
 
-			// Time to attempt booking
-			appendLog("Attempting scheduled reservation " + nextRes.ID + " for venue " + strconv.FormatInt(nextRes.VenueID, 10))
+			// Within the lead time: the job gets its own goroutine and the
+			// scheduler moves on to the next one. It stays tracked until
+			// it's been removed from the store, so it's never picked twice
+			jobCtx := trackInflightJob(egress.WithUser(ctx, nextRes.ClerkUserID), nextRes.ID)
+			jobs.Add(1)
+			go func(res *store.ScheduledReservation) {
+				defer jobs.Done()
+				defer untrackInflightJob(res.ID)
+				runScheduledReservation(ctx, jobCtx, appCtx, cfg, pool, res, bursts)
+			}(nextRes)
+		}
+	}
+}
 
-			// Get auth credentials - refresh from Redis if Clerk user
-			authToken := nextRes.AuthToken
-			paymentMethodID := nextRes.PaymentMethodID
-			if nextRes.ClerkUserID != "" {
-				// Fetch fresh credentials from Redis for Clerk users
-				creds, err := store.GetResyCredentials(ctx, nextRes.ClerkUserID)
-				if err != nil {
-					appendLog("Failed to get Resy credentials for user " + nextRes.ClerkUserID + ": " + err.Error())
-					// Delete the reservation since we can't execute it
-					store.DeleteReservation(ctx, nextRes.ID)
-					continue
-				}
-				authToken = creds.AuthToken
-				paymentMethodID = creds.PaymentMethodID
-			}
+// runScheduledReservation prewarms the provider for one scheduled job,
+// waits for its run time and a free slot in bursts, then books it and
+// records the outcome. ctx is the scheduler's, jobCtx is cancelled
+// when the job is
+func runScheduledReservation(ctx, jobCtx context.Context, appCtx app.AppCtx, cfg *config.Config, pool *egress.Pool, nextRes *store.ScheduledReservation, bursts chan struct{}) {
+	scheduledProvider, _ := appCtx.Provider(api.NormalizeProvider(nextRes.Provider))
+	if nextRes.RunTime.After(serviceNow(scheduledProvider)) {
+		// Get the provider ready, then wait out the last few seconds so
+		// the first attempt fires on time
+		prewarmReservation(ctx, appCtx, cfg, pool, nextRes)
+		select {
+		case <-jobCtx.Done():
+			return
+		case <-time.After(nextRes.RunTime.Sub(serviceNow(scheduledProvider))):
+		}
 
-			// Convert table preferences
-			var tableTypes []api.TableType
-			for _, pref := range nextRes.TablePreferences {
-				tableTypes = append(tableTypes, api.TableType(pref))
-			}
+		// The job may have been cancelled while we waited
+		if _, err := store.GetReservation(ctx, nextRes.ID); err != nil {
+			return
+		}
+	}
 
-			reserveParam := api.ReserveParam{
-				VenueID:          nextRes.VenueID,
-				ReservationTimes: []time.Time{nextRes.ReservationTime},
-				PartySize:        nextRes.PartySize,
-				LoginResp:        api.LoginResponse{AuthToken: authToken, PaymentMethodID: paymentMethodID},
-				TableTypes:       tableTypes,
-			}
+	select {
+	case bursts <- struct{}{}:
+		defer func() { <-bursts }()
+	case <-jobCtx.Done():
+		return
+	}
+
+	// Time to attempt booking
+	providerName := api.NormalizeProvider(nextRes.Provider)
+	appendLog("Attempting scheduled reservation " + nextRes.ID + " for venue " + api.VenueRef{Provider: providerName, VenueID: nextRes.VenueID}.String())
+
+	provider, err := appCtx.Provider(providerName)
+	if err != nil {
+		appendLog("Cannot run scheduled reservation " + nextRes.ID + ": " + err.Error())
+		saveOutcome(ctx, nextRes, nil, err)
+		store.DeleteReservation(ctx, nextRes.ID)
+		return
+	}
+
+	// Get auth credentials - refresh from Redis if Clerk user
+	authToken := nextRes.AuthToken
+	paymentMethodID := nextRes.PaymentMethodID
+	if nextRes.ClerkUserID != "" {
+		// Fetch fresh credentials from Redis for Clerk users
+		creds, err := store.GetCredentials(ctx, providerName, nextRes.ClerkUserID)
+		if err != nil {
+			appendLog("Failed to get " + providerName + " credentials for user " + nextRes.ClerkUserID + ": " + err.Error())
+			// Delete the reservation since we can't execute it
+			saveOutcome(ctx, nextRes, nil, err)
+			store.DeleteReservation(ctx, nextRes.ID)
+			return
+		}
+		authToken = creds.AuthToken
+		paymentMethodID = creds.PaymentMethodID
+	}
+
+	window, err := nextRes.Window()
+	if err != nil {
+		appendLog("Cannot run scheduled reservation " + nextRes.ID + ": " + err.Error())
+		saveOutcome(ctx, nextRes, nil, err)
+		store.DeleteReservation(ctx, nextRes.ID)
+		return
+	}
+
+	// Convert table preferences
+	var tableTypes []api.TableType
+	for _, pref := range nextRes.TablePreferences {
+		tableTypes = append(tableTypes, api.TableType(pref))
+	}
+
+	reserveParam := api.ReserveParam{
+		VenueID:          nextRes.VenueID,
+		ReservationTimes: nextRes.Targets(),
+		Window:           window,
+		DryRun:           nextRes.DryRun,
+		Payment:          paymentPolicy(nextRes.MaxDeposit, nextRes.AllowCancellationFee),
+		Requests:         specialRequests(nextRes.Occasion, nextRes.DietaryNotes, nextRes.SeatingRequest),
+		PartySize:        nextRes.PartySize,
+		LoginResp:        api.LoginResponse{AuthToken: authToken, PaymentMethodID: paymentMethodID},
+		TableTypes:       tableTypes,
+	}
+
+	reserveResp, err := runBurst(jobCtx, provider, reserveParam, nextRes, cfg)
+
+	if errors.Is(err, api.ErrCancelled) && ctx.Err() != nil {
+		// Shutting down mid-attempt: leave the job queued so it runs after restart
+		appendLog("Scheduler shutting down, leaving reservation " + nextRes.ID + " queued")
+		return
+	}
 
-			_, err = appCtx.API.Reserve(reserveParam)
+	if errors.Is(err, api.ErrCancelled) {
+		appendLog("Scheduled reservation " + nextRes.ID + " was cancelled during booking")
+	} else if err != nil {
+		appendLog("Failed to book scheduled reservation " + nextRes.ID + ": " + err.Error())
+	} else if reserveResp.DryRun {
+		// A dry run books nothing, so it doesn't count towards usage
+		appendLog("Dry run for scheduled reservation " + nextRes.ID + " would have booked " + describeSlot(reserveResp))
+	} else {
+		appendLog("Successfully booked scheduled reservation " + nextRes.ID + ": " + describeSlot(reserveResp))
+		notifyUsageIncrement(ctx, cfg, nextRes)
+	}
+	saveOutcome(ctx, nextRes, reserveResp, err)
+
+	// Remove the reservation from Redis (regardless of success/failure)
+	if err := store.DeleteReservation(ctx, nextRes.ID); err != nil {
+		appendLog("Failed to delete reservation " + nextRes.ID + " from store: " + err.Error())
+	}
+}
+
+// reserveErrorResponse describes a failed Reserve call for /api/reserve,
+// pairing the provider's error with its api.ErrorCode and a status
+func reserveErrorResponse(err error, providerName string) (ReserveResponse, int) {
+	code := api.ErrorCode(err)
+
+	var netErr *api.NetworkError
+	var schemaErr *api.SchemaError
+	switch {
+	case errors.Is(err, api.ErrRateLimited):
+		return ReserveResponse{Code: code, Error: providerName + " is rate limiting requests. Please try again shortly."}, http.StatusTooManyRequests
+	case errors.Is(err, api.ErrAuthExpired):
+		return ReserveResponse{Code: code, Error: "Your " + providerName + " session has expired. Please log in again."}, http.StatusUnauthorized
+	case errors.Is(err, api.ErrSlotTaken):
+		return ReserveResponse{Code: code, Error: "The table was taken before it could be booked."}, http.StatusConflict
+	case errors.Is(err, api.ErrPaymentDeclined):
+		return ReserveResponse{Code: code, Error: "Your payment method was declined: " + err.Error()}, http.StatusPaymentRequired
+	case errors.Is(err, api.ErrVenueClosed):
+		return ReserveResponse{Code: code, Error: "The restaurant is closed on the selected date."}, http.StatusBadRequest
+	case errors.Is(err, api.ErrPartySize):
+		return ReserveResponse{Code: code, Error: "The restaurant doesn't take parties of this size."}, http.StatusBadRequest
+	case errors.Is(err, api.ErrBookUncertain):
+		return ReserveResponse{Code: code, Error: "The booking request failed after it was sent and may have gone through. Check your " + providerName + " reservations before trying again."}, http.StatusBadGateway
+	case errors.As(err, &netErr):
+		appendLog("Network error details - Step: " + netErr.Step + ", Status: " + strconv.Itoa(netErr.Status) + ", Message: " + netErr.Message)
+		return ReserveResponse{Code: code, Error: "Network error at " + netErr.Step + " step: " + netErr.Message}, http.StatusInternalServerError
+	case errors.Is(err, api.ErrNetwork):
+		return ReserveResponse{Code: code, Error: "Network error. Please try again later."}, http.StatusInternalServerError
+	case errors.As(err, &schemaErr):
+		return ReserveResponse{Code: code, Error: "Unexpected response from " + providerName + " at " + schemaErr.Step + " step; its API may have changed"}, http.StatusBadGateway
+	case errors.Is(err, api.ErrPaymentPolicy):
+		return ReserveResponse{Code: code, Error: "No table within your payment limits: " + err.Error()}, http.StatusBadRequest
+	case errors.Is(err, api.ErrNoTable):
+		return ReserveResponse{Code: code, Error: "No available tables found for the selected time."}, http.StatusBadRequest
+	case errors.Is(err, api.ErrImperva):
+		return ReserveResponse{Code: code, Error: "Imperva challenge: please refresh cookies via /admin/cookies/import"}, http.StatusServiceUnavailable
+	case errors.Is(err, api.ErrNoOffer):
+		return ReserveResponse{Code: code, Error: "No reservations available for this date."}, http.StatusBadRequest
+	case errors.Is(err, api.ErrCancelled):
+		// Client went away or the request deadline passed; nobody may be listening
+		return ReserveResponse{Code: code, Error: "Reservation request was cancelled."}, http.StatusRequestTimeout
+	}
+	return ReserveResponse{Code: code, Error: "An unexpected error occurred: " + err.Error()}, http.StatusInternalServerError
+}
+
+// handleReservationAttempts serves GET /api/reservations/{id}/attempts.
+// Attempts are kept after the reservation itself is deleted, so
+// ownership is checked against the attempts rather than the reservation
+func handleReservationAttempts(w http.ResponseWriter, r *http.Request) {
+	resID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/reservations/"), "/attempts")
+	if resID == "" || strings.Contains(resID, "/") {
+		sendJSONResponse(w, AttemptsResponse{Error: "Reservation ID required"}, http.StatusBadRequest)
+		return
+	}
+
+	attempts, err := store.GetAttempts(r.Context(), resID)
+	if err != nil {
+		sendJSONResponse(w, AttemptsResponse{Error: "Failed to fetch attempts"}, http.StatusInternalServerError)
+		return
+	}
+
+	// Verify ownership if Clerk user ID is provided
+	clerkUserID := r.Header.Get("X-Clerk-User-Id")
+	if clerkUserID != "" && len(attempts) > 0 && attempts[0].ClerkUserID != clerkUserID {
+		sendJSONResponse(w, AttemptsResponse{Error: "Reservation not found"}, http.StatusNotFound)
+		return
+	}
+
+	sendJSONResponse(w, AttemptsResponse{Attempts: attempts}, http.StatusOK)
+}
+
+// handleReservationOutcome serves GET /api/reservations/{id}/outcome:
+// how a scheduled job finished and, if it booked, the provider's
+// reservation details needed to cancel it
+func handleReservationOutcome(w http.ResponseWriter, r *http.Request) {
+	resID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/reservations/"), "/outcome")
+	if resID == "" || strings.Contains(resID, "/") {
+		sendJSONResponse(w, OutcomeResponse{Error: "Reservation ID required"}, http.StatusBadRequest)
+		return
+	}
+
+	// Missing until the job has run, and again once it expires
+	outcome, err := store.GetOutcome(r.Context(), resID)
+	if err != nil {
+		sendJSONResponse(w, OutcomeResponse{Error: "No outcome for this reservation"}, http.StatusNotFound)
+		return
+	}
+
+	// Verify ownership if Clerk user ID is provided
+	clerkUserID := r.Header.Get("X-Clerk-User-Id")
+	if clerkUserID != "" && outcome.ClerkUserID != clerkUserID {
+		sendJSONResponse(w, OutcomeResponse{Error: "Reservation not found"}, http.StatusNotFound)
+		return
+	}
+
+	sendJSONResponse(w, OutcomeResponse{Outcome: outcome}, http.StatusOK)
+}
+
+// createNotify serves POST /api/notify. Registrations are kept per Clerk
+// user, so unlike /api/reserve there is no session fallback
+func createNotify(w http.ResponseWriter, r *http.Request, appCtx app.AppCtx) {
+	var notifyReq NotifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&notifyReq); err != nil {
+		sendJSONResponse(w, NotifyResponse{Code: codeInvalidRequest, Error: "Invalid request format"}, http.StatusBadRequest)
+		return
+	}
+
+	clerkUserID := r.Header.Get("X-Clerk-User-Id")
+	if clerkUserID == "" {
+		sendJSONResponse(w, NotifyResponse{Code: codeNotLoggedIn, Error: "Unauthorized"}, http.StatusUnauthorized)
+		return
+	}
+
+	// A provider-qualified venue overrides provider + venue_id
+	providerName := notifyReq.Provider
+	venueID := notifyReq.VenueID
+	if notifyReq.Venue != "" {
+		ref, err := api.ParseVenueRef(notifyReq.Venue)
+		if err != nil {
+			sendJSONResponse(w, NotifyResponse{Code: codeInvalidRequest, Error: "Invalid venue. Use provider:id, e.g. resy:12345"}, http.StatusBadRequest)
+			return
+		}
+		providerName, venueID = ref.Provider, ref.VenueID
+	}
+	if venueID == 0 {
+		sendJSONResponse(w, NotifyResponse{Code: codeInvalidRequest, Error: "Venue ID missing. Please select a restaurant."}, http.StatusBadRequest)
+		return
+	}
+
+	providerName = api.NormalizeProvider(providerName)
+	provider, err := appCtx.Provider(providerName)
+	if err != nil {
+		sendJSONResponse(w, NotifyResponse{Code: codeUnknownProvider, Error: "Unknown provider: " + providerName}, http.StatusBadRequest)
+		return
+	}
+	notifier, ok := provider.(api.Notifier)
+	if !ok {
+		sendJSONResponse(w, NotifyResponse{Code: codeNotifyUnsupported, Error: providerName + " has no notify list"}, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := time.Parse("2006-01-02", notifyReq.Date); err != nil {
+		sendJSONResponse(w, NotifyResponse{Code: codeInvalidRequest, Error: "Invalid date format. Use YYYY-MM-DD"}, http.StatusBadRequest)
+		return
+	}
+	// Both are YYYY-MM-DD, so they compare as strings
+	if notifyReq.Date < time.Now().In(nycLocation).Format("2006-01-02") {
+		sendJSONResponse(w, NotifyResponse{Code: codeInvalidRequest, Error: "Date is in the past"}, http.StatusBadRequest)
+		return
+	}
+	if notifyReq.PartySize < 1 {
+		sendJSONResponse(w, NotifyResponse{Code: codeInvalidRequest, Error: "party_size must be at least 1"}, http.StatusBadRequest)
+		return
+	}
+	window, err := api.ParseTimeWindow(notifyReq.EarliestTime, notifyReq.LatestTime, "")
+	if err != nil {
+		sendJSONResponse(w, NotifyResponse{Code: codeInvalidRequest, Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	creds, err := store.GetCredentials(r.Context(), providerName, clerkUserID)
+	if err != nil {
+		sendJSONResponse(w, NotifyResponse{Code: codeAccountNotLinked, Error: accountNotLinkedMessage(providerName)}, http.StatusUnauthorized)
+		return
+	}
+
+	entry, err := notifier.Notify(egress.WithUser(r.Context(), clerkUserID), api.NotifyParam{
+		VenueID:   venueID,
+		Date:      notifyReq.Date,
+		PartySize: notifyReq.PartySize,
+		Earliest:  window.Earliest,
+		Latest:    window.Latest,
+		LoginResp: api.LoginResponse{AuthToken: creds.AuthToken, PaymentMethodID: creds.PaymentMethodID},
+	})
+	if err != nil {
+		appendLog("Failed to join notify list for venue " + strconv.FormatInt(venueID, 10) + ": " + err.Error())
+		resp, status := reserveErrorResponse(err, providerName)
+		sendJSONResponse(w, NotifyResponse{Code: resp.Code, Error: resp.Error}, status)
+		return
+	}
+
+	reg := &store.NotifyRegistration{
+		ID:               store.GenerateNotifyID(),
+		ClerkUserID:      clerkUserID,
+		Provider:         providerName,
+		VenueID:          venueID,
+		Date:             notifyReq.Date,
+		PartySize:        notifyReq.PartySize,
+		EarliestTime:     notifyReq.EarliestTime,
+		LatestTime:       notifyReq.LatestTime,
+		ProviderNotifyID: entry.NotifyID,
+		CreatedAt:        time.Now().UTC(),
+	}
+	if err := store.SaveNotify(r.Context(), reg); err != nil {
+		// Don't leave the user on a list they can't see or leave
+		_ = notifier.RemoveNotify(egress.WithUser(context.WithoutCancel(r.Context()), clerkUserID), api.RemoveNotifyParam{
+			NotifyID:  entry.NotifyID,
+			LoginResp: api.LoginResponse{AuthToken: creds.AuthToken},
+		})
+		sendJSONResponse(w, NotifyResponse{Error: "Failed to save notify registration"}, http.StatusInternalServerError)
+		return
+	}
+
+	appendLog("Joined " + providerName + " notify list for venue " + strconv.FormatInt(venueID, 10) + " on " + reg.Date + " (" + reg.ID + ")")
+	summary := notifySummary(reg, getVenueName(r.Context(), &appCtx, reg.Provider, reg.VenueID))
+	sendJSONResponse(w, NotifyResponse{Notify: &summary, Message: "Added to notify list"}, http.StatusCreated)
+}
+
+// listNotifies serves GET /api/notify, the caller's registrations
+// soonest date first
+func listNotifies(w http.ResponseWriter, r *http.Request, appCtx app.AppCtx) {
+	clerkUserID := r.Header.Get("X-Clerk-User-Id")
+	if clerkUserID == "" {
+		sendJSONResponse(w, NotifyListResponse{Error: "Unauthorized"}, http.StatusUnauthorized)
+		return
+	}
+
+	regs, err := store.GetNotifiesByClerkUser(r.Context(), clerkUserID)
+	if err != nil {
+		sendJSONResponse(w, NotifyListResponse{Error: "Failed to fetch notify registrations"}, http.StatusInternalServerError)
+		return
+	}
+
+	refs := make([]api.VenueRef, 0, len(regs))
+	for _, reg := range regs {
+		refs = append(refs, api.VenueRef{Provider: reg.Provider, VenueID: reg.VenueID})
+	}
+	names := getVenueNames(r.Context(), &appCtx, refs)
+
+	summaries := make([]NotifySummary, 0, len(regs))
+	for _, reg := range regs {
+		summaries = append(summaries, notifySummary(reg, names[api.VenueRef{Provider: reg.Provider, VenueID: reg.VenueID}.String()]))
+	}
+	sendJSONResponse(w, NotifyListResponse{Notifies: summaries}, http.StatusOK)
+}
+
+// deleteNotify serves DELETE /api/notify/{id}: the entry is removed from
+// the provider's list first, then from the store. If the user has since
+// unlinked the account there is nothing to remove it with, so only the
+// stored registration goes
+func deleteNotify(w http.ResponseWriter, r *http.Request, appCtx app.AppCtx) {
+	notifyID := strings.TrimPrefix(r.URL.Path, "/api/notify/")
+	if notifyID == "" || strings.Contains(notifyID, "/") {
+		sendJSONResponse(w, NotifyResponse{Code: codeInvalidRequest, Error: "Notify ID required"}, http.StatusBadRequest)
+		return
+	}
+
+	clerkUserID := r.Header.Get("X-Clerk-User-Id")
+	if clerkUserID == "" {
+		sendJSONResponse(w, NotifyResponse{Code: codeNotLoggedIn, Error: "Unauthorized"}, http.StatusUnauthorized)
+		return
+	}
+
+	reg, err := store.GetNotify(r.Context(), notifyID)
+	if err != nil || reg.ClerkUserID != clerkUserID {
+		sendJSONResponse(w, NotifyResponse{Error: "Notify registration not found"}, http.StatusNotFound)
+		return
+	}
+
+	providerName := api.NormalizeProvider(reg.Provider)
+	provider, err := appCtx.Provider(providerName)
+	if err != nil {
+		sendJSONResponse(w, NotifyResponse{Code: codeUnknownProvider, Error: "Unknown provider: " + providerName}, http.StatusBadRequest)
+		return
+	}
+	if notifier, ok := provider.(api.Notifier); ok {
+		if creds, err := store.GetCredentials(r.Context(), providerName, clerkUserID); err == nil {
+			err = notifier.RemoveNotify(egress.WithUser(r.Context(), clerkUserID), api.RemoveNotifyParam{
+				NotifyID:  reg.ProviderNotifyID,
+				LoginResp: api.LoginResponse{AuthToken: creds.AuthToken, PaymentMethodID: creds.PaymentMethodID},
+			})
 			if err != nil {
-				appendLog("Failed to book scheduled reservation " + nextRes.ID + ": " + err.Error())
-			} else {
-				appendLog("Successfully booked scheduled reservation " + nextRes.ID)
-				notifyUsageIncrement(ctx, cfg, nextRes)
+				appendLog("Failed to leave notify list for " + reg.ID + ": " + err.Error())
+				resp, status := reserveErrorResponse(err, providerName)
+				sendJSONResponse(w, NotifyResponse{Code: resp.Code, Error: resp.Error}, status)
+				return
 			}
+		}
+	}
+
+	if err := store.DeleteNotify(r.Context(), reg); err != nil {
+		sendJSONResponse(w, NotifyResponse{Error: "Failed to delete notify registration"}, http.StatusInternalServerError)
+		return
+	}
+
+	appendLog("Left notify list: " + reg.ID)
+	sendJSONResponse(w, NotifyResponse{Message: "Removed from notify list"}, http.StatusOK)
+}
+
+// notifySummary is the API view of a stored notify registration
+func notifySummary(reg *store.NotifyRegistration, venueName string) NotifySummary {
+	return NotifySummary{
+		ID:           reg.ID,
+		Provider:     api.NormalizeProvider(reg.Provider),
+		VenueID:      reg.VenueID,
+		VenueName:    venueName,
+		Date:         reg.Date,
+		PartySize:    reg.PartySize,
+		EarliestTime: reg.EarliestTime,
+		LatestTime:   reg.LatestTime,
+		CreatedAt:    reg.CreatedAt.In(nycLocation).Format("2006-01-02 3:04 PM"),
+	}
+}
 
-			// Remove the reservation from Redis (regardless of success/failure)
-			if err := store.DeleteReservation(ctx, nextRes.ID); err != nil {
-				appendLog("Failed to delete reservation " + nextRes.ID + " from store: " + err.Error())
+// newEgressPool builds the proxy pool Resy traffic is sent through from
+// the EGRESS_* settings, nil when no proxies are configured
+func newEgressPool(cfg *config.Config) (*egress.Pool, error) {
+	proxies, err := egress.ParseProxies(cfg.EgressProxies)
+	if err != nil || len(proxies) == 0 {
+		return nil, err
+	}
+	stickiness, err := egress.ParseStickiness(cfg.EgressStickiness)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := egress.NewPool(proxies)
+	pool.Stickiness = stickiness
+	pool.RotateCooldown = cfg.EgressRotateCooldown
+	pool.NewTransport = resy.NewTransport
+	appendLog("Routing Resy traffic through " + strconv.Itoa(len(proxies)) + " egress proxies, sticky by " + string(stickiness))
+	return pool, nil
+}
+
+// newRecordingSink picks where Resy reservation attempts are recorded
+// from the HTTP_RECORD settings, nil when recording is off
+func newRecordingSink(cfg *config.Config) (httprec.Sink, error) {
+	switch strings.ToLower(cfg.HTTPRecord) {
+	case "", "off":
+		return nil, nil
+	case "redis":
+		appendLog("Recording Resy reservation attempts to Redis")
+		return httprec.RedisSink{}, nil
+	case "dir":
+		sink, err := httprec.NewDirSink(cfg.HTTPRecordDir)
+		if err != nil {
+			return nil, err
+		}
+		appendLog("Recording Resy reservation attempts to " + cfg.HTTPRecordDir)
+		return sink, nil
+	}
+	return nil, fmt.Errorf("unknown HTTP_RECORD %q, expected redis or dir", cfg.HTTPRecord)
+}
+
+// proxyByID returns the pool's proxy with the given ID, or nil
+func proxyByID(pool *egress.Pool, id string) *egress.Proxy {
+	for _, proxy := range pool.Proxies() {
+		if proxy.ID == id {
+			return proxy
+		}
+	}
+	return nil
+}
+
+// prewarmReservation lets the job's provider set up ahead of the run
+// time, if it knows how. For Resy that includes the Imperva cookies of
+// the route the job will use: they're refreshed if they're missing or
+// about to expire, and fetched again if Imperva turns them down, so a
+// stale cookie isn't first found by a booking attempt. Failures are
+// only logged, Reserve does its own setup anyway
+func prewarmReservation(ctx context.Context, appCtx app.AppCtx, cfg *config.Config, pool *egress.Pool, res *store.ScheduledReservation) {
+	providerName := api.NormalizeProvider(res.Provider)
+	provider, err := appCtx.Provider(providerName)
+	if err != nil {
+		return
+	}
+	prewarmer, ok := provider.(api.Prewarmer)
+	if !ok {
+		return
+	}
+	refresh := cfg.CookieRefreshEnabled && providerName == api.ProviderResy
+	route := jobRoute(pool, res)
+	if refresh {
+		refreshCookiesIfNeeded(ctx, res.VenueID, route)
+	}
+
+	err = prewarmReservationOnce(ctx, prewarmer, res)
+	if refresh && errors.Is(err, api.ErrImperva) {
+		// Drop the cookies that were turned down and get new ones for
+		// the route the job has now, Resy may have moved it off a proxy
+		proxyID := ""
+		if route != nil {
+			proxyID = route.ID
+		}
+		if err := store.DeleteCookiesVia(ctx, res.VenueID, proxyID); err != nil {
+			appendLog("Failed to drop rejected cookies for reservation " + res.ID + ": " + err.Error())
+		}
+		refreshCookiesIfNeeded(ctx, res.VenueID, jobRoute(pool, res))
+		err = prewarmReservationOnce(ctx, prewarmer, res)
+	}
+	if err != nil {
+		appendLog("Prewarm for reservation " + res.ID + " failed: " + err.Error())
+		return
+	}
+	appendLog("Prewarmed provider for reservation " + res.ID)
+}
+
+// prewarmReservationOnce warms the proxy the job's Reserve calls will be
+// routed through
+func prewarmReservationOnce(ctx context.Context, prewarmer api.Prewarmer, res *store.ScheduledReservation) error {
+	prewarmCtx, cancel := context.WithTimeout(egress.WithUser(ctx, res.ClerkUserID), 5*time.Second)
+	defer cancel()
+	return prewarmer.Prewarm(prewarmCtx, res.VenueID)
+}
+
+// jobRoute is the proxy a scheduled job's Resy calls leave through, nil
+// for direct. Picks are sticky, so it's the one Reserve will get
+func jobRoute(pool *egress.Pool, res *store.ScheduledReservation) *egress.Proxy {
+	if pool == nil {
+		return nil
+	}
+	proxy, err := pool.Pick(pool.Key(res.ClerkUserID, res.VenueID))
+	if err != nil {
+		return nil
+	}
+	return proxy
+}
+
+// runBurst makes Reserve attempts for a scheduled reservation from its
+// run time until one books, one fails in a way retrying won't fix, or
+// the burst window closes, since tables often appear a few seconds
+// after release. A job that starts after its window still gets one
+// attempt. Every attempt is recorded in the store
+func runBurst(ctx context.Context, provider api.API, params api.ReserveParam, res *store.ScheduledReservation, cfg *config.Config) (*api.ReserveResponse, error) {
+	// The window is on the provider's clock, move its end onto ours
+	deadline := res.RunTime.Add(cfg.BurstWindow).Add(time.Since(serviceNow(provider)))
+	for number := 1; ; number++ {
+		started := time.Now()
+		label := res.ID + "-" + strconv.Itoa(number)
+		resp, err := provider.Reserve(httprec.WithAttempt(ctx, label), params)
+		if !recordsTraffic(provider) {
+			label = ""
+		}
+		recordAttempt(context.WithoutCancel(ctx), res, number, label, started, resp, err)
+
+		retryable := retryableInBurst(err)
+		if errors.Is(err, api.ErrBookUncertain) {
+			// The book may have gone through, and booking again could
+			// take a second table. Only carry on once the user's
+			// reservations show it didn't
+			booked, checkErr := findUncertainBooking(ctx, provider, params, err)
+			switch {
+			case checkErr != nil:
+				appendLog("Could not check reservations after an uncertain book for " + res.ID + ": " + checkErr.Error())
+			case booked != nil:
+				appendLog("Scheduled reservation " + res.ID + " was booked despite the failed book request")
+				return booked, nil
+			default:
+				retryable = true
 			}
 		}
+
+		next := started.Add(cfg.BurstInterval)
+		var rateErr *api.RateLimitError
+		if errors.As(err, &rateErr) && rateErr.RetryAfter > cfg.BurstInterval {
+			// Hammering a provider that asked us to back off only makes it worse
+			next = time.Now().Add(rateErr.RetryAfter)
+		}
+		if err == nil || !retryable || !next.Before(deadline) {
+			if number > 1 {
+				appendLog("Scheduled reservation " + res.ID + " made " + strconv.Itoa(number) + " attempts")
+			}
+			return resp, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, api.NewCancelError("burst", ctx.Err())
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// serviceNow is the time by the provider's clock if it keeps track of
+// one, otherwise ours
+func serviceNow(provider api.API) time.Time {
+	if clock, ok := provider.(api.ServiceClock); ok {
+		return clock.ServiceNow()
+	}
+	return time.Now()
+}
+
+// retryableInBurst reports whether another attempt might succeed: the
+// venue may list (or free up) tables moments later, and network errors
+// and rate limits are often transient. Anything else, like a closed
+// venue or a declined card, will fail the same way again. A book that
+// may have gone through is never retried blindly, see runBurst
+func retryableInBurst(err error) bool {
+	if errors.Is(err, api.ErrBookUncertain) {
+		return false
 	}
+	return errors.Is(err, api.ErrNoOffer) ||
+		errors.Is(err, api.ErrNoTable) ||
+		errors.Is(err, api.ErrPaymentPolicy) ||
+		errors.Is(err, api.ErrRateLimited) ||
+		errors.Is(err, api.ErrNetwork)
+}
+
+// findUncertainBooking looks through the user's upcoming reservations
+// for the table a book with an uncertain outcome was after, returning
+// nil if it isn't there
+func findUncertainBooking(ctx context.Context, provider api.API, params api.ReserveParam, err error) (*api.ReserveResponse, error) {
+	var uncertain *api.UncertainBookError
+	if !errors.As(err, &uncertain) || uncertain.Slot == nil {
+		return nil, errors.New("no slot to look for")
+	}
+
+	list, err := provider.ListReservations(ctx, api.ListReservationsParam{LoginResp: params.LoginResp})
+	if err != nil {
+		return nil, err
+	}
+	for _, booked := range list.Reservations {
+		if booked.VenueID == params.VenueID && booked.ReservationTime.Equal(uncertain.Slot.Start) {
+			return &api.ReserveResponse{
+				ReservationTime:  booked.ReservationTime,
+				Slot:             uncertain.Slot,
+				VenueID:          booked.VenueID,
+				PartySize:        booked.PartySize,
+				TableType:        booked.TableType,
+				ReservationToken: booked.ReservationToken,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// attemptOutcome names the result of one Reserve call for the attempt
+// log: "booked", "dry_run", or the error's api.ErrorCode
+func attemptOutcome(resp *api.ReserveResponse, err error) string {
+	switch {
+	case err == nil && resp.DryRun:
+		return "dry_run"
+	case err == nil:
+		return "booked"
+	}
+	return api.ErrorCode(err)
+}
+
+// recordsTraffic reports whether a provider records the HTTP traffic of
+// labelled attempts (see httprec.WithAttempt)
+func recordsTraffic(provider api.API) bool {
+	resyAPI, ok := provider.(*resy.API)
+	return ok && resyAPI.Recorder != nil
+}
+
+// recordAttempt stores one attempt of a scheduled reservation, with the
+// label its traffic was recorded under if it was
+func recordAttempt(ctx context.Context, res *store.ScheduledReservation, number int, recording string, started time.Time, resp *api.ReserveResponse, err error) {
+	attempt := &store.Attempt{
+		ReservationID: res.ID,
+		ClerkUserID:   res.ClerkUserID,
+		Number:        number,
+		Recording:     recording,
+		StartedAt:     started.UTC(),
+		DurationMs:    time.Since(started).Milliseconds(),
+		Outcome:       attemptOutcome(resp, err),
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if err := store.RecordAttempt(ctx, attempt); err != nil {
+		appendLog("Failed to record attempt " + strconv.Itoa(number) + " for reservation " + res.ID + ": " + err.Error())
+	}
+}
+
+// saveOutcome stores how a scheduled reservation finished, with what was
+// booked so it can be shown and cancelled once the job is gone
+func saveOutcome(ctx context.Context, res *store.ScheduledReservation, resp *api.ReserveResponse, err error) {
+	outcome := &store.Outcome{
+		ReservationID: res.ID,
+		ClerkUserID:   res.ClerkUserID,
+		Provider:      api.NormalizeProvider(res.Provider),
+		Outcome:       attemptOutcome(resp, err),
+		FinishedAt:    time.Now().UTC(),
+	}
+	if err != nil {
+		outcome.Error = err.Error()
+	} else {
+		outcome.Booking = store.NewBooking(resp)
+	}
+	if err := store.SaveOutcome(ctx, outcome); err != nil {
+		appendLog("Failed to save outcome for reservation " + res.ID + ": " + err.Error())
+	}
+}
+
+// trackInflightJob derives a cancellable context for a scheduled job and
+// registers it so cancelInflightJob can abort the attempt
+func trackInflightJob(ctx context.Context, id string) context.Context {
+	jobCtx, cancel := context.WithCancel(ctx)
+	inflightMu.Lock()
+	inflightJobs[id] = cancel
+	inflightMu.Unlock()
+	return jobCtx
+}
+
+// untrackInflightJob releases a job's context once its attempt is over
+func untrackInflightJob(id string) {
+	inflightMu.Lock()
+	cancel, ok := inflightJobs[id]
+	delete(inflightJobs, id)
+	inflightMu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// inflightJobIDs returns the IDs of the jobs the scheduler is running
+func inflightJobIDs() map[string]bool {
+	inflightMu.Lock()
+	defer inflightMu.Unlock()
+	ids := make(map[string]bool, len(inflightJobs))
+	for id := range inflightJobs {
+		ids[id] = true
+	}
+	return ids
+}
+
+// cancelInflightJob cancels a running job, returning false if it wasn't running
+func cancelInflightJob(id string) bool {
+	inflightMu.Lock()
+	cancel, ok := inflightJobs[id]
+	inflightMu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
 }
 
 // handleCookieRefresh periodically refreshes Imperva cookies for known venues
-func handleCookieRefresh(ctx context.Context, cfg *config.Config) {
+func handleCookieRefresh(ctx context.Context, cfg *config.Config, pool *egress.Pool) {
 	appendLog("Cookie refresh goroutine started (interval: " + cfg.CookieRefreshInterval.String() + ")")
 
 	// Run immediately on startup
-	refreshAllCookies(ctx, cfg)
+	refreshAllCookies(ctx, cfg, pool)
 
 	// Then run periodically
 	ticker := time.NewTicker(cfg.CookieRefreshInterval)
@@ -1083,47 +2615,91 @@ func handleCookieRefresh(ctx context.Context, cfg *config.Config) {
 			appendLog("Cookie refresh goroutine shutting down")
 			return
 		case <-ticker.C:
-			refreshAllCookies(ctx, cfg)
+			refreshAllCookies(ctx, cfg, pool)
 		}
 	}
 }
 
-// refreshAllCookies checks and refreshes cookies for all known venues
-func refreshAllCookies(ctx context.Context, cfg *config.Config) {
-	venueIDs := cfg.VenueIDs()
-	appendLog("Starting cookie refresh check for " + strconv.Itoa(len(venueIDs)) + " venues")
+// handleClockCalibration samples Resy's clock on startup and then
+// every ClockCalibration, so scheduled jobs fire by Resy's clock
+func handleClockCalibration(ctx context.Context, resyAPI *resy.API, cfg *config.Config) {
+	ticker := time.NewTicker(cfg.ClockCalibration)
+	defer ticker.Stop()
+
+	for {
+		if err := resyAPI.Calibrate(ctx); err != nil && ctx.Err() == nil {
+			appendLog("Clock calibration against Resy failed: " + err.Error())
+		}
 
-	for _, venueID := range venueIDs {
 		select {
 		case <-ctx.Done():
 			return
-		default:
-			refreshCookiesIfNeeded(ctx, venueID)
+		case <-ticker.C:
+		}
+	}
+}
+
+// clockSkewStatus reports a clock estimate, or nil before the first sample
+func clockSkewStatus(clock *resy.ClockSkew) *ClockSkewStatus {
+	if clock == nil {
+		return nil
+	}
+	estimate, ok := clock.Estimate()
+	if !ok {
+		return nil
+	}
+	return &ClockSkewStatus{
+		OffsetMs:      estimate.Offset.Milliseconds(),
+		UncertaintyMs: estimate.Uncertainty.Milliseconds(),
+		Samples:       estimate.Samples,
+		UpdatedAt:     estimate.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// refreshAllCookies checks and refreshes cookies for all known Resy venues,
+// through each healthy egress proxy when there are any since cookies only
+// work from the address that minted them
+func refreshAllCookies(ctx context.Context, cfg *config.Config, pool *egress.Pool) {
+	venueIDs := cfg.ProviderVenueIDs(api.ProviderResy)
+	routes := []*egress.Proxy{nil}
+	if pool != nil {
+		routes = pool.Healthy()
+	}
+	appendLog("Starting cookie refresh check for " + strconv.Itoa(len(venueIDs)) + " venues over " + strconv.Itoa(len(routes)) + " routes")
+
+	for _, venueID := range venueIDs {
+		for _, proxy := range routes {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				refreshCookiesIfNeeded(ctx, venueID, proxy)
+			}
 		}
 	}
 
 	appendLog("Cookie refresh check completed")
 }
 
-// refreshCookiesIfNeeded checks if cookies need refreshing and fetches new ones if so
-func refreshCookiesIfNeeded(ctx context.Context, venueID int64) {
+// refreshCookiesIfNeeded checks if a venue's cookies for a route (nil for
+// direct) need refreshing and fetches new ones through it if so
+func refreshCookiesIfNeeded(ctx context.Context, venueID int64, proxy *egress.Proxy) {
 	venueIDStr := strconv.FormatInt(venueID, 10)
+	proxyID := ""
+	if proxy != nil {
+		proxyID = proxy.ID
+		venueIDStr += " via proxy " + proxyID
+	}
 
-	// Check if cookies exist and their TTL
-	exists, err := store.CookieExists(ctx, venueID)
+	// A negative TTL means there are no cookies
+	ttl, err := store.GetCookieTTLVia(ctx, venueID, proxyID)
 	if err != nil {
-		appendLog("Error checking cookie existence for venue " + venueIDStr + ": " + err.Error())
+		appendLog("Error getting cookie TTL for venue " + venueIDStr + ": " + err.Error())
 		return
 	}
 
 	// If cookies exist, check if they're expiring soon (within 2 hours)
-	if exists {
-		ttl, err := store.GetCookieTTL(ctx, venueID)
-		if err != nil {
-			appendLog("Error getting cookie TTL for venue " + venueIDStr + ": " + err.Error())
-			return
-		}
-
+	if ttl > 0 {
 		// Only refresh if TTL is less than 2 hours
 		if ttl > 2*time.Hour {
 			appendLog("Cookies for venue " + venueIDStr + " still valid (TTL: " + ttl.String() + "), skipping refresh")
@@ -1136,14 +2712,14 @@ func refreshCookiesIfNeeded(ctx context.Context, venueID int64) {
 	}
 
 	// Fetch new cookies using headless browser
-	cookieData, err := imperva.FetchCookies(venueID)
+	cookieData, err := imperva.FetchCookiesVia(venueID, proxy)
 	if err != nil {
 		appendLog("Failed to fetch cookies for venue " + venueIDStr + ": " + err.Error())
 		return
 	}
 
 	// Save cookies to Redis with 24 hour TTL
-	if err := store.SaveCookies(ctx, venueID, cookieData.Cookies, cookieData.UserAgent, 24*time.Hour); err != nil {
+	if err := store.SaveCookiesVia(ctx, venueID, cookieData.ProxyID, cookieData.Cookies, cookieData.UserAgent, 24*time.Hour); err != nil {
 		appendLog("Failed to save cookies for venue " + venueIDStr + ": " + err.Error())
 		return
 	}
@@ -1293,6 +2869,87 @@ func getSession(r *http.Request) (map[string]string, error) {
 	return value, nil
 }
 
+// accountNotLinkedMessage is the error shown when a Clerk user has no linked account on a provider
+func accountNotLinkedMessage(provider string) string {
+	if api.NormalizeProvider(provider) == api.ProviderResy {
+		return "Resy account not linked. Please link your Resy account first."
+	}
+	if api.NormalizeProvider(provider) == api.ProviderOpenTable {
+		return "OpenTable account not linked. Please link your OpenTable account first."
+	}
+	return "No " + provider + " account linked for this user."
+}
+
+// cancelBooking cancels a booked reservation with the provider that holds
+// it and answers the request, for the cancel endpoints
+func cancelBooking(w http.ResponseWriter, r *http.Request, appCtx app.AppCtx, providerName, reservationToken string) {
+	providerName = api.NormalizeProvider(providerName)
+	provider, err := appCtx.Provider(providerName)
+	if err != nil {
+		sendJSONResponse(w, ResyCancelResponse{Code: codeUnknownProvider, Error: "Unknown provider: " + providerName}, http.StatusBadRequest)
+		return
+	}
+
+	loginResp, err := providerAuthFromRequest(r, providerName)
+	if err != nil {
+		sendJSONResponse(w, ResyCancelResponse{Error: err.Error()}, http.StatusUnauthorized)
+		return
+	}
+
+	cancelResp, err := provider.Cancel(egress.WithUser(r.Context(), r.Header.Get("X-Clerk-User-Id")), api.CancelParam{
+		ReservationToken: reservationToken,
+		LoginResp:        loginResp,
+	})
+	if err != nil {
+		appendLog("Failed to cancel " + providerName + " reservation: " + err.Error())
+		var netErr *api.NetworkError
+		code := api.ErrorCode(err)
+		if errors.As(err, &netErr) && netErr.Status == http.StatusNotFound {
+			sendJSONResponse(w, ResyCancelResponse{Code: code, Error: "Reservation not found"}, http.StatusNotFound)
+		} else if errors.Is(err, api.ErrAlreadyCancelled) {
+			sendJSONResponse(w, ResyCancelResponse{Code: code, Error: "Reservation was already cancelled"}, http.StatusConflict)
+		} else if errors.Is(err, api.ErrImperva) {
+			sendJSONResponse(w, ResyCancelResponse{Code: code, Error: "Imperva challenge: please refresh cookies via /admin/cookies/import"}, http.StatusServiceUnavailable)
+		} else {
+			sendJSONResponse(w, ResyCancelResponse{Code: code, Error: "Failed to cancel reservation: " + err.Error()}, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	appendLog("Cancelled " + providerName + " reservation (refund: " + strconv.FormatBool(cancelResp.Refund) + ")")
+	sendJSONResponse(w, ResyCancelResponse{
+		Message:      "Reservation cancelled",
+		Refund:       cancelResp.Refund,
+		RefundAmount: cancelResp.RefundAmount,
+	}, http.StatusOK)
+}
+
+// providerAuthFromRequest resolves the caller's auth for a provider from
+// linked Clerk credentials, falling back to the legacy session cookie,
+// which only holds a Resy login
+func providerAuthFromRequest(r *http.Request, provider string) (api.LoginResponse, error) {
+	if clerkUserID := r.Header.Get("X-Clerk-User-Id"); clerkUserID != "" {
+		creds, err := store.GetCredentials(r.Context(), provider, clerkUserID)
+		if err != nil {
+			return api.LoginResponse{}, errors.New(accountNotLinkedMessage(provider))
+		}
+		return api.LoginResponse{AuthToken: creds.AuthToken, PaymentMethodID: creds.PaymentMethodID}, nil
+	}
+	if api.NormalizeProvider(provider) != api.ProviderResy {
+		return api.LoginResponse{}, errors.New(accountNotLinkedMessage(provider))
+	}
+
+	session, err := getSession(r)
+	if err != nil || session["auth_token"] == "" {
+		return api.LoginResponse{}, errors.New("Unauthorized. Please log in.")
+	}
+	loginResp := api.LoginResponse{AuthToken: session["auth_token"]}
+	if pmIDStr := session["payment_method_id"]; pmIDStr != "" {
+		loginResp.PaymentMethodID, _ = strconv.ParseInt(pmIDStr, 10, 64)
+	}
+	return loginResp, nil
+}
+
 // parseTimeNYC parses a datetime-local format string as NYC time and returns UTC
 func parseTimeNYC(timeStr string) (time.Time, error) {
 	// datetime-local format: "2025-12-25T19:00"
@@ -1303,6 +2960,89 @@ func parseTimeNYC(timeStr string) (time.Time, error) {
 	return t.UTC(), nil // Convert to UTC for storage/processing
 }
 
+// parseReservationTimes collects a request's targets in priority order:
+// reservation_time first, then each of reservation_times
+func parseReservationTimes(req ReserveRequest) ([]time.Time, error) {
+	values := req.ReservationTimes
+	if req.ReservationTime != "" || len(values) == 0 {
+		values = append([]string{req.ReservationTime}, values...)
+	}
+
+	times := make([]time.Time, 0, len(values))
+	for _, value := range values {
+		t, err := parseTimeNYC(value)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// formatTimes renders times in NYC time, comma separated
+func formatTimes(times []time.Time, layout string) string {
+	formatted := make([]string, 0, len(times))
+	for _, t := range times {
+		formatted = append(formatted, t.In(nycLocation).Format(layout))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// alternativeTimes lists a scheduled reservation's targets for display,
+// or nil when it only has the one shown as reservation_time
+func alternativeTimes(res *store.ScheduledReservation) []string {
+	targets := res.Targets()
+	if len(targets) < 2 {
+		return nil
+	}
+	formatted := make([]string, 0, len(targets))
+	for _, t := range targets {
+		formatted = append(formatted, t.In(nycLocation).Format("2006-01-02 3:04 PM"))
+	}
+	return formatted
+}
+
+// paymentPolicy builds the payment limits for a reservation from its
+// request fields, where a missing allow_cancellation_fee allows fees
+func paymentPolicy(maxDeposit *float64, allowCancellationFee *bool) api.PaymentPolicy {
+	policy := api.PaymentPolicy{MaxDeposit: maxDeposit}
+	if allowCancellationFee != nil && !*allowCancellationFee {
+		noFee := 0.0
+		policy.MaxCancellationFee = &noFee
+	}
+	return policy
+}
+
+// specialRequests builds the notes sent with a booking from its request
+// fields. The occasion is normalised here and checked by Validate
+func specialRequests(occasion, dietary, seating string) api.SpecialRequests {
+	return api.SpecialRequests{
+		Occasion: api.Occasion(strings.ToLower(strings.TrimSpace(occasion))),
+		Dietary:  strings.TrimSpace(dietary),
+		Seating:  strings.TrimSpace(seating),
+	}
+}
+
+// describeSlot summarises the slot and terms of a Reserve result for the log
+func describeSlot(resp *api.ReserveResponse) string {
+	description := resp.ReservationTime.In(nycLocation).Format("2006-01-02 3:04 PM")
+	if resp.TableType != "" {
+		description += " (" + resp.TableType + ")"
+	}
+	if terms := resp.Terms; terms != nil {
+		if terms.DepositFee > 0 {
+			description += fmt.Sprintf(", deposit $%.2f", terms.DepositFee)
+		}
+		if terms.CancellationFee > 0 {
+			description += fmt.Sprintf(", cancellation fee $%.2f", terms.CancellationFee)
+		}
+		if terms.PaymentRequired {
+			description += ", card required"
+		}
+	}
+	return description
+}
+
 // appendLog adds a log message to both the standard log and in-memory slice
 func appendLog(message string) {
 	logMu.Lock()
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Attempt records one Reserve call made while running a scheduled reservation
+type Attempt struct {
+	ReservationID string    `json:"reservation_id"`
+	ClerkUserID   string    `json:"clerk_user_id,omitempty"` // Owner of the reservation, for access checks once it's gone
+	Number        int       `json:"number"`                  // 1 for the first attempt of a run
+	StartedAt     time.Time `json:"started_at"`
+	DurationMs    int64     `json:"duration_ms"`
+	Outcome       string    `json:"outcome"` // "booked", "dry_run" or an api.ErrorCode such as "no_table"
+	Error         string    `json:"error,omitempty"`
+	Recording     string    `json:"recording,omitempty"` // httprec attempt label its HTTP traffic was recorded under, if any
+}
+
+const (
+	AttemptsKeyPrefix = "attempts:"
+	AttemptsTTL       = 7 * 24 * time.Hour // Kept for a week after the last attempt
+)
+
+// AttemptsKey returns the Redis key for a reservation's attempt list
+func AttemptsKey(reservationID string) string {
+	return fmt.Sprintf("%s%s", AttemptsKeyPrefix, reservationID)
+}
+
+// RecordAttempt appends an attempt to its reservation's list in Redis.
+// The list outlives the reservation itself so results can be reviewed
+func RecordAttempt(ctx context.Context, attempt *Attempt) error {
+	jsonData, err := json.Marshal(attempt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attempt: %w", err)
+	}
+
+	key := AttemptsKey(attempt.ReservationID)
+	pipe := GetClient().TxPipeline()
+	pipe.RPush(ctx, key, jsonData)
+	pipe.Expire(ctx, key, AttemptsTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetAttempts returns a reservation's attempts, oldest first
+func GetAttempts(ctx context.Context, reservationID string) ([]Attempt, error) {
+	values, err := GetClient().LRange(ctx, AttemptsKey(reservationID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := make([]Attempt, 0, len(values))
+	for _, value := range values {
+		var attempt Attempt
+		if err := json.Unmarshal([]byte(value), &attempt); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal attempt: %w", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, nil
+}
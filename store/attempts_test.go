@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecordAndGetAttempts(t *testing.T) {
+	mr := setupTestRedis(t)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		attempt := &Attempt{
+			ReservationID: "res_burst",
+			Number:        i,
+			StartedAt:     time.Now().UTC(),
+			Outcome:       "no_table",
+		}
+		if err := RecordAttempt(ctx, attempt); err != nil {
+			t.Fatalf("RecordAttempt failed: %v", err)
+		}
+	}
+
+	attempts, err := GetAttempts(ctx, "res_burst")
+	if err != nil {
+		t.Fatalf("GetAttempts failed: %v", err)
+	}
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(attempts))
+	}
+	for i, attempt := range attempts {
+		if attempt.Number != i+1 {
+			t.Errorf("attempts out of order: got %d at position %d", attempt.Number, i)
+		}
+	}
+
+	if ttl := mr.TTL(AttemptsKey("res_burst")); ttl != AttemptsTTL {
+		t.Errorf("expected TTL %v, got %v", AttemptsTTL, ttl)
+	}
+
+	// A reservation that never ran has no attempts
+	attempts, err = GetAttempts(ctx, "res_unknown")
+	if err != nil || len(attempts) != 0 {
+		t.Errorf("expected no attempts, got %v, %v", attempts, err)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/21Bruce/resolved-server/api"
 )
 
 // BookingWindow represents when reservations open for a venue
@@ -61,6 +63,22 @@ func BookingWindowExists(ctx context.Context, venueID int64) (bool, error) {
 	return result > 0, nil
 }
 
+// BookingWindowFromVenue converts the booking window a provider reports
+// through GetVenue, returning nil if it didn't report one
+func BookingWindowFromVenue(venue *api.Venue) *BookingWindow {
+	if venue.BookingWindow == nil {
+		return nil
+	}
+	return &BookingWindow{
+		VenueID:       venue.VenueID,
+		DaysInAdvance: venue.BookingWindow.DaysInAdvance,
+		ReleaseHour:   venue.BookingWindow.ReleaseHour,
+		ReleaseMinute: venue.BookingWindow.ReleaseMinute,
+		Timezone:      venue.TimeZone,
+		ScrapedAt:     time.Now().UTC(),
+	}
+}
+
 // CalculateRunTime calculates when to attempt booking based on booking window
 // Given a desired reservation time, returns the optimal time to run the sniper
 func (bw *BookingWindow) CalculateRunTime(reservationTime time.Time) (time.Time, error) {
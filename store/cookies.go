@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -11,14 +13,21 @@ import (
 type CookieData struct {
 	Cookies   []*http.Cookie `json:"cookies"`
 	UserAgent string         `json:"user_agent"`
+	ProxyID   string         `json:"proxy_id,omitempty"` // Egress proxy the cookies were minted through, empty for direct
 	ExpiresAt time.Time      `json:"expires_at"`
 }
 
 // SaveCookies stores cookies for a venue with a TTL
 func SaveCookies(ctx context.Context, venueID int64, cookies []*http.Cookie, userAgent string, ttl time.Duration) error {
+	return SaveCookiesVia(ctx, venueID, "", cookies, userAgent, ttl)
+}
+
+// SaveCookiesVia stores cookies for a venue minted through an egress proxy
+func SaveCookiesVia(ctx context.Context, venueID int64, proxyID string, cookies []*http.Cookie, userAgent string, ttl time.Duration) error {
 	data := CookieData{
 		Cookies:   cookies,
 		UserAgent: userAgent,
+		ProxyID:   proxyID,
 		ExpiresAt: time.Now().Add(ttl),
 	}
 
@@ -27,12 +36,18 @@ func SaveCookies(ctx context.Context, venueID int64, cookies []*http.Cookie, use
 		return err
 	}
 
-	return GetClient().Set(ctx, CookieKey(venueID), jsonData, ttl).Err()
+	return GetClient().Set(ctx, ProxyCookieKey(venueID, proxyID), jsonData, ttl).Err()
 }
 
 // GetCookies retrieves cookies for a venue
 func GetCookies(ctx context.Context, venueID int64) (*CookieData, error) {
-	jsonData, err := GetClient().Get(ctx, CookieKey(venueID)).Bytes()
+	return GetCookiesVia(ctx, venueID, "")
+}
+
+// GetCookiesVia retrieves a venue's cookies minted through an egress proxy.
+// Cookies from another proxy or a direct connection are never returned
+func GetCookiesVia(ctx context.Context, venueID int64, proxyID string) (*CookieData, error) {
+	jsonData, err := GetClient().Get(ctx, ProxyCookieKey(venueID, proxyID)).Bytes()
 	if err != nil {
 		return nil, err
 	}
@@ -45,23 +60,82 @@ func GetCookies(ctx context.Context, venueID int64) (*CookieData, error) {
 	return &data, nil
 }
 
-// DeleteCookies removes cookies for a venue
+// DeleteCookies removes all of a venue's cookies, direct and per proxy
 func DeleteCookies(ctx context.Context, venueID int64) error {
-	return GetClient().Del(ctx, CookieKey(venueID)).Err()
+	proxyIDs, err := CookieProxies(ctx, venueID)
+	if err != nil || len(proxyIDs) == 0 {
+		return err
+	}
+	keys := make([]string, len(proxyIDs))
+	for i, proxyID := range proxyIDs {
+		keys[i] = ProxyCookieKey(venueID, proxyID)
+	}
+	return GetClient().Del(ctx, keys...).Err()
 }
 
-// CookieExists checks if cookies exist for a venue
+// DeleteCookiesVia removes a venue's cookies minted through an egress proxy
+func DeleteCookiesVia(ctx context.Context, venueID int64, proxyID string) error {
+	return GetClient().Del(ctx, ProxyCookieKey(venueID, proxyID)).Err()
+}
+
+// CookieExists checks if cookies exist for a venue, direct or through any proxy
 func CookieExists(ctx context.Context, venueID int64) (bool, error) {
-	result, err := GetClient().Exists(ctx, CookieKey(venueID)).Result()
+	proxyIDs, err := CookieProxies(ctx, venueID)
+	if err != nil {
+		return false, err
+	}
+	return len(proxyIDs) > 0, nil
+}
+
+// CookieExistsVia checks if cookies minted through an egress proxy exist
+// for a venue
+func CookieExistsVia(ctx context.Context, venueID int64, proxyID string) (bool, error) {
+	result, err := GetClient().Exists(ctx, ProxyCookieKey(venueID, proxyID)).Result()
 	if err != nil {
 		return false, err
 	}
 	return result > 0, nil
 }
 
+// CookieProxies returns the proxies a venue has cookies for, sorted, with
+// "" for cookies minted over a direct connection
+func CookieProxies(ctx context.Context, venueID int64) ([]string, error) {
+	var proxyIDs []string
+	seen := make(map[string]bool)
+	direct, err := CookieExistsVia(ctx, venueID, "")
+	if err != nil {
+		return nil, err
+	}
+	if direct {
+		proxyIDs = append(proxyIDs, "")
+	}
+
+	prefix := CookieKey(venueID) + "@"
+	iter := GetClient().Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		// SCAN may return a key more than once
+		proxyID := strings.TrimPrefix(iter.Val(), prefix)
+		if !seen[proxyID] {
+			seen[proxyID] = true
+			proxyIDs = append(proxyIDs, proxyID)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(proxyIDs)
+	return proxyIDs, nil
+}
+
 // GetCookieTTL returns the remaining TTL for a venue's cookies
 func GetCookieTTL(ctx context.Context, venueID int64) (time.Duration, error) {
-	return GetClient().TTL(ctx, CookieKey(venueID)).Result()
+	return GetCookieTTLVia(ctx, venueID, "")
+}
+
+// GetCookieTTLVia returns the remaining TTL for a venue's cookies minted
+// through an egress proxy, negative if there are none
+func GetCookieTTLVia(ctx context.Context, venueID int64, proxyID string) (time.Duration, error) {
+	return GetClient().TTL(ctx, ProxyCookieKey(venueID, proxyID)).Result()
 }
 
 
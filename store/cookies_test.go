@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCookieProxies(t *testing.T) {
+	setupTestRedis(t)
+	ctx := context.Background()
+
+	cookies := []*http.Cookie{{Name: "incap_ses_1", Value: "abc"}}
+	for _, proxyID := range []string{"", "res-1", "dc-2"} {
+		if err := SaveCookiesVia(ctx, 86907, proxyID, cookies, "ua", time.Hour); err != nil {
+			t.Fatalf("SaveCookiesVia(%q) failed: %v", proxyID, err)
+		}
+	}
+	// Another venue whose ID shares a prefix
+	if err := SaveCookiesVia(ctx, 869070, "res-1", cookies, "ua", time.Hour); err != nil {
+		t.Fatalf("SaveCookiesVia failed: %v", err)
+	}
+
+	proxyIDs, err := CookieProxies(ctx, 86907)
+	if err != nil {
+		t.Fatalf("CookieProxies failed: %v", err)
+	}
+	if want := []string{"", "dc-2", "res-1"}; !reflect.DeepEqual(proxyIDs, want) {
+		t.Errorf("expected proxies %q, got %q", want, proxyIDs)
+	}
+
+	// Only proxy cookies left still count as existing
+	if err := DeleteCookiesVia(ctx, 86907, ""); err != nil {
+		t.Fatalf("DeleteCookiesVia failed: %v", err)
+	}
+	if exists, err := CookieExists(ctx, 86907); err != nil || !exists {
+		t.Errorf("expected proxy cookies to exist, got %v, %v", exists, err)
+	}
+	if exists, _ := CookieExistsVia(ctx, 86907, ""); exists {
+		t.Error("expected direct cookies to be deleted")
+	}
+
+	if err := DeleteCookies(ctx, 86907); err != nil {
+		t.Fatalf("DeleteCookies failed: %v", err)
+	}
+	if exists, _ := CookieExists(ctx, 86907); exists {
+		t.Error("expected every proxy's cookies to be deleted")
+	}
+	if exists, _ := CookieExistsVia(ctx, 869070, "res-1"); !exists {
+		t.Error("expected another venue's cookies to be kept")
+	}
+}
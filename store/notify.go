@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NotifyRegistration records a user's interest in a venue's tables, held
+// on the provider's notify list under ProviderNotifyID
+type NotifyRegistration struct {
+	ID               string    `json:"id"`
+	ClerkUserID      string    `json:"clerk_user_id"`
+	Provider         string    `json:"provider,omitempty"` // Empty means "resy"
+	VenueID          int64     `json:"venue_id"`
+	Date             string    `json:"date"` // YYYY-MM-DD
+	PartySize        int       `json:"party_size"`
+	EarliestTime     string    `json:"earliest_time,omitempty"` // HH:MM in the venue's timezone, empty for no bound
+	LatestTime       string    `json:"latest_time,omitempty"`   // HH:MM in the venue's timezone, empty for no bound
+	ProviderNotifyID string    `json:"provider_notify_id"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+const (
+	NotifyKeyPrefix     = "notify:"
+	NotifyUserKeyPrefix = "notify:user:"
+	// Kept this long past the start of the day, enough for any timezone
+	NotifyGracePeriod = 48 * time.Hour
+)
+
+// NotifyKey returns the Redis key for a notify registration
+func NotifyKey(id string) string {
+	return fmt.Sprintf("%s%s", NotifyKeyPrefix, id)
+}
+
+// NotifyUserKey returns the Redis key for the set of a user's registration IDs
+func NotifyUserKey(clerkUserID string) string {
+	return fmt.Sprintf("%s%s", NotifyUserKeyPrefix, clerkUserID)
+}
+
+// GenerateNotifyID creates a unique ID for a notify registration
+func GenerateNotifyID() string {
+	return fmt.Sprintf("ntf_%d", time.Now().UnixNano())
+}
+
+// SaveNotify stores a notify registration and indexes it under its user.
+// It expires once its date has passed, as the provider drops it then too
+func SaveNotify(ctx context.Context, reg *NotifyRegistration) error {
+	day, err := time.Parse("2006-01-02", reg.Date)
+	if err != nil {
+		return fmt.Errorf("invalid notify date %q: %w", reg.Date, err)
+	}
+	jsonData, err := json.Marshal(reg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notify registration: %w", err)
+	}
+
+	expiresAt := day.Add(NotifyGracePeriod)
+	pipe := GetClient().TxPipeline()
+	pipe.Set(ctx, NotifyKey(reg.ID), jsonData, 0)
+	pipe.ExpireAt(ctx, NotifyKey(reg.ID), expiresAt)
+	pipe.SAdd(ctx, NotifyUserKey(reg.ClerkUserID), reg.ID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetNotify retrieves a notify registration by ID
+func GetNotify(ctx context.Context, id string) (*NotifyRegistration, error) {
+	jsonData, err := GetClient().Get(ctx, NotifyKey(id)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var reg NotifyRegistration
+	if err := json.Unmarshal(jsonData, &reg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal notify registration: %w", err)
+	}
+	return &reg, nil
+}
+
+// GetNotifiesByClerkUser returns a user's notify registrations, soonest
+// date first. IDs whose registration has expired are dropped from the index
+func GetNotifiesByClerkUser(ctx context.Context, clerkUserID string) ([]*NotifyRegistration, error) {
+	ids, err := GetClient().SMembers(ctx, NotifyUserKey(clerkUserID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	regs := make([]*NotifyRegistration, 0, len(ids))
+	for _, id := range ids {
+		reg, err := GetNotify(ctx, id)
+		if errors.Is(err, redis.Nil) {
+			_ = GetClient().SRem(ctx, NotifyUserKey(clerkUserID), id).Err()
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		regs = append(regs, reg)
+	}
+
+	// Dates are YYYY-MM-DD so they sort as strings
+	sort.Slice(regs, func(i, j int) bool {
+		if regs[i].Date != regs[j].Date {
+			return regs[i].Date < regs[j].Date
+		}
+		return regs[i].CreatedAt.Before(regs[j].CreatedAt)
+	})
+	return regs, nil
+}
+
+// DeleteNotify removes a notify registration and its index entry
+func DeleteNotify(ctx context.Context, reg *NotifyRegistration) error {
+	pipe := GetClient().TxPipeline()
+	pipe.Del(ctx, NotifyKey(reg.ID))
+	pipe.SRem(ctx, NotifyUserKey(reg.ClerkUserID), reg.ID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSaveAndGetNotifies(t *testing.T) {
+	mr := setupTestRedis(t)
+	ctx := context.Background()
+
+	later := &NotifyRegistration{
+		ID:               "ntf_later",
+		ClerkUserID:      "user_123",
+		VenueID:          1505,
+		Date:             "2099-03-02",
+		PartySize:        2,
+		ProviderNotifyID: "77",
+		CreatedAt:        time.Now().UTC(),
+	}
+	sooner := &NotifyRegistration{
+		ID:               "ntf_sooner",
+		ClerkUserID:      "user_123",
+		VenueID:          1505,
+		Date:             "2099-03-01",
+		PartySize:        2,
+		EarliestTime:     "18:00",
+		LatestTime:       "21:00",
+		ProviderNotifyID: "78",
+		CreatedAt:        time.Now().UTC(),
+	}
+	other := &NotifyRegistration{ID: "ntf_other", ClerkUserID: "user_456", VenueID: 1505, Date: "2099-03-01", PartySize: 4}
+	for _, reg := range []*NotifyRegistration{later, sooner, other} {
+		if err := SaveNotify(ctx, reg); err != nil {
+			t.Fatalf("SaveNotify failed: %v", err)
+		}
+	}
+
+	if ttl := mr.TTL(NotifyKey("ntf_sooner")); ttl <= 0 {
+		t.Errorf("expected the registration to expire, got TTL %v", ttl)
+	}
+
+	regs, err := GetNotifiesByClerkUser(ctx, "user_123")
+	if err != nil {
+		t.Fatalf("GetNotifiesByClerkUser failed: %v", err)
+	}
+	if len(regs) != 2 || regs[0].ID != "ntf_sooner" || regs[1].ID != "ntf_later" {
+		t.Fatalf("expected the user's registrations soonest first, got %+v", regs)
+	}
+	if regs[0].EarliestTime != "18:00" || regs[0].ProviderNotifyID != "78" {
+		t.Errorf("registration not round-tripped: %+v", regs[0])
+	}
+
+	// An expired registration drops out of the index
+	mr.Del(NotifyKey("ntf_later"))
+	regs, err = GetNotifiesByClerkUser(ctx, "user_123")
+	if err != nil || len(regs) != 1 {
+		t.Fatalf("expected one registration left, got %v, %v", regs, err)
+	}
+	if ok, _ := mr.SIsMember(NotifyUserKey("user_123"), "ntf_later"); ok {
+		t.Error("expected the expired ID to be pruned from the index")
+	}
+
+	if err := DeleteNotify(ctx, sooner); err != nil {
+		t.Fatalf("DeleteNotify failed: %v", err)
+	}
+	if _, err := GetNotify(ctx, "ntf_sooner"); err == nil {
+		t.Error("expected the registration to be gone")
+	}
+	regs, _ = GetNotifiesByClerkUser(ctx, "user_123")
+	if len(regs) != 0 {
+		t.Errorf("expected no registrations left, got %d", len(regs))
+	}
+}
+
+func TestSaveNotify_BadDate(t *testing.T) {
+	setupTestRedis(t)
+
+	if err := SaveNotify(context.Background(), &NotifyRegistration{ID: "ntf_bad", Date: "03/01/2099"}); err == nil {
+		t.Error("expected an unparseable date to be rejected")
+	}
+}
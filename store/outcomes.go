@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+// Outcome records how a scheduled reservation finished. Like its
+// attempts, it outlives the reservation itself
+type Outcome struct {
+	ReservationID string    `json:"reservation_id"`
+	ClerkUserID   string    `json:"clerk_user_id,omitempty"` // Owner of the reservation, for access checks once it's gone
+	Provider      string    `json:"provider"`
+	Outcome       string    `json:"outcome"` // "booked", "dry_run" or an api.ErrorCode such as "no_table"
+	Error         string    `json:"error,omitempty"`
+	FinishedAt    time.Time `json:"finished_at"`
+	Booking       *Booking  `json:"booking,omitempty"` // What was booked, or would have been for a dry run
+}
+
+// Booking describes a table booked (or, for a dry run, that would have
+// been) with enough detail to show it and to cancel it later
+type Booking struct {
+	VenueID               int64             `json:"venue_id"`
+	ReservationTime       time.Time         `json:"reservation_time"`
+	PartySize             int               `json:"party_size"`
+	TableType             string            `json:"table_type,omitempty"`
+	ProviderReservationID string            `json:"provider_reservation_id,omitempty"` // The provider's own reference, e.g. Resy's reservation_id
+	ReservationToken      string            `json:"reservation_token,omitempty"`       // What the provider's cancel takes, e.g. Resy's resy_token
+	Terms                 *api.BookingTerms `json:"terms,omitempty"`
+}
+
+const (
+	OutcomeKeyPrefix = "outcome:"
+	OutcomeTTL       = 30 * 24 * time.Hour // Minimum; a booking is also kept until a day after its table
+)
+
+// OutcomeKey returns the Redis key for a reservation's outcome
+func OutcomeKey(reservationID string) string {
+	return fmt.Sprintf("%s%s", OutcomeKeyPrefix, reservationID)
+}
+
+// NewBooking describes the table a successful Reserve call booked
+func NewBooking(resp *api.ReserveResponse) *Booking {
+	return &Booking{
+		VenueID:               resp.VenueID,
+		ReservationTime:       resp.ReservationTime.UTC(),
+		PartySize:             resp.PartySize,
+		TableType:             resp.TableType,
+		ProviderReservationID: resp.ReservationID,
+		ReservationToken:      resp.ReservationToken,
+		Terms:                 resp.Terms,
+	}
+}
+
+// SaveOutcome stores a reservation's outcome, replacing any earlier one
+func SaveOutcome(ctx context.Context, outcome *Outcome) error {
+	jsonData, err := json.Marshal(outcome)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outcome: %w", err)
+	}
+
+	// Keep a booking around until it can no longer be cancelled
+	ttl := OutcomeTTL
+	if outcome.Booking != nil {
+		if untilAfter := time.Until(outcome.Booking.ReservationTime.Add(24 * time.Hour)); untilAfter > ttl {
+			ttl = untilAfter
+		}
+	}
+	return GetClient().Set(ctx, OutcomeKey(outcome.ReservationID), jsonData, ttl).Err()
+}
+
+// GetOutcome returns a reservation's outcome, redis.Nil if it hasn't
+// finished or has expired
+func GetOutcome(ctx context.Context, reservationID string) (*Outcome, error) {
+	jsonData, err := GetClient().Get(ctx, OutcomeKey(reservationID)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var outcome Outcome
+	if err := json.Unmarshal(jsonData, &outcome); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal outcome: %w", err)
+	}
+	return &outcome, nil
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSaveAndGetOutcome(t *testing.T) {
+	mr := setupTestRedis(t)
+	ctx := context.Background()
+
+	booked := time.Now().Add(60 * 24 * time.Hour).UTC().Truncate(time.Second)
+	outcome := &Outcome{
+		ReservationID: "res_booked",
+		Provider:      "resy",
+		Outcome:       "booked",
+		FinishedAt:    time.Now().UTC(),
+		Booking: NewBooking(&api.ReserveResponse{
+			ReservationTime:  booked,
+			VenueID:          86907,
+			PartySize:        2,
+			TableType:        "Dining Room",
+			ReservationID:    "1001",
+			ReservationToken: "resy-token-1001",
+			Terms:            &api.BookingTerms{PaymentRequired: true, CancellationFee: 25},
+		}),
+	}
+	if err := SaveOutcome(ctx, outcome); err != nil {
+		t.Fatalf("SaveOutcome failed: %v", err)
+	}
+
+	got, err := GetOutcome(ctx, "res_booked")
+	if err != nil {
+		t.Fatalf("GetOutcome failed: %v", err)
+	}
+	if got.Booking == nil || got.Booking.ReservationToken != "resy-token-1001" || got.Booking.ProviderReservationID != "1001" {
+		t.Fatalf("expected the booking to round-trip, got %+v", got.Booking)
+	}
+	if !got.Booking.ReservationTime.Equal(booked) || got.Booking.Terms == nil || got.Booking.Terms.CancellationFee != 25 {
+		t.Errorf("unexpected booking details %+v", got.Booking)
+	}
+
+	// A booking further out than OutcomeTTL is kept until after its table
+	if ttl := mr.TTL(OutcomeKey("res_booked")); ttl <= OutcomeTTL {
+		t.Errorf("expected a TTL past the reservation, got %v", ttl)
+	}
+
+	// Failures are kept for OutcomeTTL
+	if err := SaveOutcome(ctx, &Outcome{ReservationID: "res_failed", Outcome: "no_table"}); err != nil {
+		t.Fatalf("SaveOutcome failed: %v", err)
+	}
+	if ttl := mr.TTL(OutcomeKey("res_failed")); ttl != OutcomeTTL {
+		t.Errorf("expected TTL %v, got %v", OutcomeTTL, ttl)
+	}
+
+	if _, err := GetOutcome(ctx, "res_unknown"); !errors.Is(err, redis.Nil) {
+		t.Errorf("expected redis.Nil for a reservation without an outcome, got %v", err)
+	}
+}
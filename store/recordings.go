@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	RecordingKeyPrefix = "httprec:"
+	RecordingTTL       = 7 * 24 * time.Hour // Kept as long as the attempt log
+)
+
+// RecordingKey returns the Redis key for an attempt's recorded HTTP exchanges
+func RecordingKey(attempt string) string {
+	return fmt.Sprintf("%s%s", RecordingKeyPrefix, attempt)
+}
+
+// AppendRecording appends one encoded exchange to an attempt's recording
+func AppendRecording(ctx context.Context, attempt string, data []byte) error {
+	key := RecordingKey(attempt)
+	pipe := GetClient().TxPipeline()
+	pipe.RPush(ctx, key, data)
+	pipe.Expire(ctx, key, RecordingTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetRecording returns an attempt's encoded exchanges in the order they
+// were appended, none if it has no recording
+func GetRecording(ctx context.Context, attempt string) ([][]byte, error) {
+	values, err := GetClient().LRange(ctx, RecordingKey(attempt), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]byte, len(values))
+	for i, value := range values {
+		out[i] = []byte(value)
+	}
+	return out, nil
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAppendAndGetRecording(t *testing.T) {
+	mr := setupTestRedis(t)
+	ctx := context.Background()
+
+	for _, data := range []string{`{"seq":1}`, `{"seq":2}`} {
+		if err := AppendRecording(ctx, "res_burst-1", []byte(data)); err != nil {
+			t.Fatalf("AppendRecording failed: %v", err)
+		}
+	}
+
+	values, err := GetRecording(ctx, "res_burst-1")
+	if err != nil {
+		t.Fatalf("GetRecording failed: %v", err)
+	}
+	if len(values) != 2 || string(values[0]) != `{"seq":1}` || string(values[1]) != `{"seq":2}` {
+		t.Errorf("expected both exchanges in order, got %q", values)
+	}
+	if ttl := mr.TTL(RecordingKey("res_burst-1")); ttl != RecordingTTL {
+		t.Errorf("expected TTL %v, got %v", RecordingTTL, ttl)
+	}
+
+	values, err = GetRecording(ctx, "res_unknown-1")
+	if err != nil || len(values) != 0 {
+		t.Errorf("expected no recording, got %q, %v", values, err)
+	}
+}
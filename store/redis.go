@@ -73,6 +73,17 @@ func CookieKey(venueID int64) string {
 	return fmt.Sprintf("%s%d", CookieKeyPrefix, venueID)
 }
 
+// ProxyCookieKey returns the Redis key for a venue's cookies minted
+// through an egress proxy. Imperva ties its cookies to the address that
+// solved the challenge, so each proxy keeps its own. An empty proxyID is
+// a direct connection and shares CookieKey
+func ProxyCookieKey(venueID int64, proxyID string) string {
+	if proxyID == "" {
+		return CookieKey(venueID)
+	}
+	return fmt.Sprintf("%s%d@%s", CookieKeyPrefix, venueID, proxyID)
+}
+
 // ReservationKey returns the Redis key for a reservation
 func ReservationKey(id string) string {
 	return fmt.Sprintf("%s%s", ReservationKeyPrefix, id)
@@ -7,22 +7,48 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/21Bruce/resolved-server/api"
 	"github.com/redis/go-redis/v9"
 )
 
 // ScheduledReservation represents a reservation scheduled for future execution
 type ScheduledReservation struct {
-	ID               string    `json:"id"`
-	VenueID          int64     `json:"venue_id"`
-	ReservationTime  time.Time `json:"reservation_time"`
-	PartySize        int       `json:"party_size"`
-	TablePreferences []string  `json:"table_preferences"`
-	AuthToken        string    `json:"auth_token"`
-	PaymentMethodID  int64     `json:"payment_method_id,omitempty"`
-	ClerkUserID      string    `json:"clerk_user_id,omitempty"` // Clerk user ID for credential lookup
-	UsageType        string    `json:"usage_type,omitempty"`    // "immediate" or "concierge"
-	RunTime          time.Time `json:"run_time"`                // When to attempt the reservation
-	CreatedAt        time.Time `json:"created_at"`
+	ID                   string      `json:"id"`
+	Provider             string      `json:"provider,omitempty"` // Empty means "resy" (written before providers existed)
+	VenueID              int64       `json:"venue_id"`
+	ReservationTime      time.Time   `json:"reservation_time"`
+	ReservationTimes     []time.Time `json:"reservation_times,omitempty"` // Every target in priority order, may span several days; empty means just ReservationTime
+	EarliestTime         string      `json:"earliest_time,omitempty"`     // HH:MM in the venue's timezone, empty for no bound
+	LatestTime           string      `json:"latest_time,omitempty"`       // HH:MM in the venue's timezone, empty for no bound
+	TimePreference       string      `json:"time_preference,omitempty"`   // "closest" (default), "earliest" or "latest"
+	PartySize            int         `json:"party_size"`
+	TablePreferences     []string    `json:"table_preferences"`
+	AuthToken            string      `json:"auth_token"`
+	PaymentMethodID      int64       `json:"payment_method_id,omitempty"`
+	ClerkUserID          string      `json:"clerk_user_id,omitempty"`          // Clerk user ID for credential lookup
+	UsageType            string      `json:"usage_type,omitempty"`             // "immediate" or "concierge"
+	DryRun               bool        `json:"dry_run,omitempty"`                // Stop before booking and only log what would have been booked
+	MaxDeposit           *float64    `json:"max_deposit,omitempty"`            // Largest deposit to accept, nil for no limit
+	AllowCancellationFee *bool       `json:"allow_cancellation_fee,omitempty"` // nil or true allows slots with a cancellation fee
+	Occasion             string      `json:"occasion,omitempty"`               // api.Occasion sent with the booking, empty for none
+	DietaryNotes         string      `json:"dietary_notes,omitempty"`          // Passed on to the venue with the booking
+	SeatingRequest       string      `json:"seating_request,omitempty"`        // Passed on to the venue with the booking
+	RunTime              time.Time   `json:"run_time"`                         // When to attempt the reservation
+	CreatedAt            time.Time   `json:"created_at"`
+}
+
+// Targets returns the times to try in priority order, falling back to
+// ReservationTime for reservations saved with a single target
+func (r *ScheduledReservation) Targets() []time.Time {
+	if len(r.ReservationTimes) > 0 {
+		return r.ReservationTimes
+	}
+	return []time.Time{r.ReservationTime}
+}
+
+// Window returns the time-window constraints to reserve with
+func (r *ScheduledReservation) Window() (api.TimeWindow, error) {
+	return api.ParseTimeWindow(r.EarliestTime, r.LatestTime, r.TimePreference)
 }
 
 // SaveReservation stores a scheduled reservation in Redis
@@ -100,29 +126,40 @@ func GetPendingReservations(ctx context.Context) ([]*ScheduledReservation, error
 
 // GetNextReservation returns the earliest pending reservation
 func GetNextReservation(ctx context.Context) (*ScheduledReservation, error) {
+	return GetNextReservationExcept(ctx, nil)
+}
+
+// GetNextReservationExcept returns the earliest pending reservation whose
+// ID isn't in skip, e.g. one the scheduler is already running
+func GetNextReservationExcept(ctx context.Context, skip map[string]bool) (*ScheduledReservation, error) {
+next:
 	for {
-		// Get the first (earliest) reservation ID from the sorted set
-		ids, err := GetClient().ZRange(ctx, PendingSetKey, 0, 0).Result()
+		// Only the skipped IDs can come before the one we want
+		ids, err := GetClient().ZRange(ctx, PendingSetKey, 0, int64(len(skip))).Result()
 		if err != nil {
 			return nil, err
 		}
 
-		if len(ids) == 0 {
-			return nil, nil // No pending reservations
-		}
+		for _, id := range ids {
+			if skip[id] {
+				continue
+			}
 
-		res, err := GetReservation(ctx, ids[0])
-		if err == nil {
-			return res, nil
-		}
+			res, err := GetReservation(ctx, id)
+			if err == nil {
+				return res, nil
+			}
 
-		if errors.Is(err, redis.Nil) {
-			// Stale sorted-set entry without payload, remove and retry
-			_ = GetClient().ZRem(ctx, PendingSetKey, ids[0]).Err()
-			continue
+			if errors.Is(err, redis.Nil) {
+				// Stale sorted-set entry without payload, remove and retry
+				_ = GetClient().ZRem(ctx, PendingSetKey, id).Err()
+				continue next
+			}
+
+			return nil, err
 		}
 
-		return nil, err
+		return nil, nil // No pending reservations
 	}
 }
 
@@ -178,6 +215,3 @@ func GetReservationsByClerkUser(ctx context.Context, clerkUserID string) ([]*Sch
 
 	return reservations, nil
 }
-
-
-
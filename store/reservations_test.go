@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/21Bruce/resolved-server/api"
 	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 )
@@ -80,6 +81,59 @@ func TestSaveAndGetReservation(t *testing.T) {
 	}
 }
 
+func TestReservationTargets(t *testing.T) {
+	setupTestRedis(t)
+	ctx := context.Background()
+
+	friday := time.Date(2026, 11, 20, 19, 0, 0, 0, time.UTC)
+	saturday := friday.Add(24 * time.Hour)
+
+	// Reservations saved before multi-date targets only have ReservationTime
+	legacy := &ScheduledReservation{ReservationTime: friday}
+	if targets := legacy.Targets(); len(targets) != 1 || !targets[0].Equal(friday) {
+		t.Errorf("legacy targets: got %v, want [%v]", targets, friday)
+	}
+
+	res := &ScheduledReservation{
+		ID:               "test_res_targets",
+		VenueID:          89607,
+		ReservationTime:  saturday,
+		ReservationTimes: []time.Time{saturday, friday},
+		PartySize:        2,
+		RunTime:          time.Now().Add(1 * time.Hour).UTC(),
+		CreatedAt:        time.Now().UTC(),
+	}
+	if err := SaveReservation(ctx, res); err != nil {
+		t.Fatalf("SaveReservation failed: %v", err)
+	}
+
+	retrieved, err := GetReservation(ctx, res.ID)
+	if err != nil {
+		t.Fatalf("GetReservation failed: %v", err)
+	}
+	targets := retrieved.Targets()
+	if len(targets) != 2 || !targets[0].Equal(saturday) || !targets[1].Equal(friday) {
+		t.Errorf("targets not kept in priority order: got %v", targets)
+	}
+}
+
+func TestReservationWindow(t *testing.T) {
+	res := &ScheduledReservation{EarliestTime: "18:30", LatestTime: "20:00", TimePreference: "latest"}
+	window, err := res.Window()
+	if err != nil {
+		t.Fatalf("Window failed: %v", err)
+	}
+	if window.Earliest != 18*time.Hour+30*time.Minute || window.Latest != 20*time.Hour || window.Preference != api.PreferLatest {
+		t.Errorf("unexpected window: %+v", window)
+	}
+
+	// Reservations saved before windows existed have no constraints
+	legacy := &ScheduledReservation{}
+	if window, err := legacy.Window(); err != nil || window.IsSet() {
+		t.Errorf("expected an unset window, got %+v, %v", window, err)
+	}
+}
+
 func TestDeleteReservation(t *testing.T) {
 	setupTestRedis(t)
 	ctx := context.Background()
@@ -167,6 +221,54 @@ func TestGetNextReservation(t *testing.T) {
 	}
 }
 
+func TestGetNextReservationExcept(t *testing.T) {
+	setupTestRedis(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	for i, id := range []string{"res_first", "res_second", "res_third"} {
+		res := &ScheduledReservation{
+			ID:              id,
+			VenueID:         89607,
+			ReservationTime: now.Add(48 * time.Hour),
+			PartySize:       2,
+			RunTime:         now.Add(time.Duration(i+1) * time.Hour),
+			CreatedAt:       now,
+		}
+		if err := SaveReservation(ctx, res); err != nil {
+			t.Fatalf("SaveReservation %s failed: %v", id, err)
+		}
+	}
+
+	next, err := GetNextReservationExcept(ctx, map[string]bool{"res_first": true, "res_second": true})
+	if err != nil {
+		t.Fatalf("GetNextReservationExcept failed: %v", err)
+	}
+	if next == nil || next.ID != "res_third" {
+		t.Fatalf("Expected res_third past the skipped ones, got %+v", next)
+	}
+
+	// A stale entry among the skipped ones doesn't hide what follows
+	if err := GetClient().Del(ctx, ReservationKey("res_second")).Err(); err != nil {
+		t.Fatalf("Del failed: %v", err)
+	}
+	next, err = GetNextReservationExcept(ctx, map[string]bool{"res_first": true})
+	if err != nil {
+		t.Fatalf("GetNextReservationExcept failed: %v", err)
+	}
+	if next == nil || next.ID != "res_third" {
+		t.Fatalf("Expected res_third past the stale entry, got %+v", next)
+	}
+
+	next, err = GetNextReservationExcept(ctx, map[string]bool{"res_first": true, "res_third": true})
+	if err != nil {
+		t.Fatalf("GetNextReservationExcept failed: %v", err)
+	}
+	if next != nil {
+		t.Errorf("Expected nothing once every pending reservation is skipped, got %s", next.ID)
+	}
+}
+
 func TestGetNextReservationEmpty(t *testing.T) {
 	setupTestRedis(t)
 	ctx := context.Background()
@@ -8,23 +8,32 @@ import (
 	"log"
 	"strconv"
 
+	"github.com/21Bruce/resolved-server/api"
 	"github.com/21Bruce/resolved-server/config"
 )
 
-// ResyCredentials stores a user's linked Resy account credentials
-type ResyCredentials struct {
+// Credentials stores a user's linked account on one reservation provider
+type Credentials struct {
+	Provider        string `json:"provider,omitempty"` // Empty means "resy"
 	ClerkUserID     string `json:"clerk_user_id"`
 	AuthToken       string `json:"auth_token"`
 	PaymentMethodID int64  `json:"payment_method_id"`
 }
 
+// ResyCredentials stores a user's linked Resy account credentials
+type ResyCredentials = Credentials
+
 type resyCredentialsRecord struct {
+	Provider        string `json:"provider,omitempty"`
 	ClerkUserID     string `json:"clerk_user_id"`
 	AuthToken       string `json:"auth_token"`
 	PaymentMethodID string `json:"payment_method_id"`
 }
 
-const ResyCredentialsKeyPrefix = "resy_credentials:"
+const (
+	ResyCredentialsKeyPrefix = "resy_credentials:"
+	CredentialsKeyPrefix     = "credentials:"
+)
 
 var errResyCredentialsKeyMissing = errors.New("resy credentials key not configured")
 
@@ -33,8 +42,24 @@ func ResyCredentialsKey(clerkUserID string) string {
 	return fmt.Sprintf("%s%s", ResyCredentialsKeyPrefix, clerkUserID)
 }
 
+// CredentialsKey returns the Redis key for a user's credentials on a provider.
+// Resy keeps its original key so existing links keep working
+func CredentialsKey(provider, clerkUserID string) string {
+	provider = api.NormalizeProvider(provider)
+	if provider == api.ProviderResy {
+		return ResyCredentialsKey(clerkUserID)
+	}
+	return fmt.Sprintf("%s%s:%s", CredentialsKeyPrefix, provider, clerkUserID)
+}
+
 // SaveResyCredentials stores Resy credentials for a Clerk user
 func SaveResyCredentials(ctx context.Context, creds *ResyCredentials) error {
+	creds.Provider = api.ProviderResy
+	return SaveCredentials(ctx, creds)
+}
+
+// SaveCredentials stores credentials for a Clerk user under creds.Provider
+func SaveCredentials(ctx context.Context, creds *Credentials) error {
 	key := config.Get().ResyCredentialsKey
 	if len(key) == 0 {
 		return errResyCredentialsKeyMissing
@@ -50,7 +75,9 @@ func SaveResyCredentials(ctx context.Context, creds *ResyCredentials) error {
 		return err
 	}
 
+	provider := api.NormalizeProvider(creds.Provider)
 	record := resyCredentialsRecord{
+		Provider:        provider,
 		ClerkUserID:     creds.ClerkUserID,
 		AuthToken:       encryptedAuthToken,
 		PaymentMethodID: encryptedPaymentID,
@@ -61,18 +88,24 @@ func SaveResyCredentials(ctx context.Context, creds *ResyCredentials) error {
 		return err
 	}
 
-	redisKey := ResyCredentialsKey(creds.ClerkUserID)
+	redisKey := CredentialsKey(provider, creds.ClerkUserID)
 	return GetClient().Set(ctx, redisKey, jsonData, 0).Err()
 }
 
 // GetResyCredentials retrieves Resy credentials for a Clerk user
 func GetResyCredentials(ctx context.Context, clerkUserID string) (*ResyCredentials, error) {
+	return GetCredentials(ctx, api.ProviderResy, clerkUserID)
+}
+
+// GetCredentials retrieves a Clerk user's credentials for a provider
+func GetCredentials(ctx context.Context, provider, clerkUserID string) (*Credentials, error) {
 	key := config.Get().ResyCredentialsKey
 	if len(key) == 0 {
 		return nil, errResyCredentialsKeyMissing
 	}
 
-	jsonData, err := GetClient().Get(ctx, ResyCredentialsKey(clerkUserID)).Bytes()
+	provider = api.NormalizeProvider(provider)
+	jsonData, err := GetClient().Get(ctx, CredentialsKey(provider, clerkUserID)).Bytes()
 	if err != nil {
 		return nil, err
 	}
@@ -129,15 +162,16 @@ func GetResyCredentials(ctx context.Context, clerkUserID string) (*ResyCredentia
 		resolvedClerkID = rawClerkID
 	}
 
-	creds := &ResyCredentials{
+	creds := &Credentials{
+		Provider:        provider,
 		ClerkUserID:     resolvedClerkID,
 		AuthToken:       authToken,
 		PaymentMethodID: paymentMethodID,
 	}
 
 	if needsReencrypt {
-		if err := SaveResyCredentials(ctx, creds); err != nil {
-			log.Printf("Warning: failed to re-encrypt Resy credentials for %s: %v", clerkUserID, err)
+		if err := SaveCredentials(ctx, creds); err != nil {
+			log.Printf("Warning: failed to re-encrypt %s credentials for %s: %v", provider, clerkUserID, err)
 		}
 	}
 
@@ -160,12 +194,22 @@ func coercePaymentMethodID(value interface{}) (string, error) {
 
 // DeleteResyCredentials removes Resy credentials for a Clerk user
 func DeleteResyCredentials(ctx context.Context, clerkUserID string) error {
-	return GetClient().Del(ctx, ResyCredentialsKey(clerkUserID)).Err()
+	return DeleteCredentials(ctx, api.ProviderResy, clerkUserID)
+}
+
+// DeleteCredentials removes a Clerk user's credentials for a provider
+func DeleteCredentials(ctx context.Context, provider, clerkUserID string) error {
+	return GetClient().Del(ctx, CredentialsKey(provider, clerkUserID)).Err()
 }
 
 // ResyCredentialsExist checks if a user has linked their Resy account
 func ResyCredentialsExist(ctx context.Context, clerkUserID string) (bool, error) {
-	count, err := GetClient().Exists(ctx, ResyCredentialsKey(clerkUserID)).Result()
+	return CredentialsExist(ctx, api.ProviderResy, clerkUserID)
+}
+
+// CredentialsExist checks if a user has linked an account on a provider
+func CredentialsExist(ctx context.Context, provider, clerkUserID string) (bool, error) {
+	count, err := GetClient().Exists(ctx, CredentialsKey(provider, clerkUserID)).Result()
 	if err != nil {
 		return false, err
 	}
@@ -109,3 +109,50 @@ func TestResyCredentialsPlaintextAutoMigration(t *testing.T) {
 		t.Fatalf("Expected encrypted payment_method_id after migration, got %v", migrated["payment_method_id"])
 	}
 }
+
+func TestCredentialsArePerProvider(t *testing.T) {
+	t.Setenv("RESY_CREDENTIALS_KEY", testResyCredentialsKey)
+	setupTestRedis(t)
+	ctx := context.Background()
+
+	resyCreds := &Credentials{Provider: "resy", ClerkUserID: "user_789", AuthToken: "resy_token", PaymentMethodID: 1}
+	otCreds := &Credentials{Provider: "opentable", ClerkUserID: "user_789", AuthToken: "ot_token"}
+
+	if err := SaveCredentials(ctx, resyCreds); err != nil {
+		t.Fatalf("SaveCredentials(resy) failed: %v", err)
+	}
+	if err := SaveCredentials(ctx, otCreds); err != nil {
+		t.Fatalf("SaveCredentials(opentable) failed: %v", err)
+	}
+
+	// Resy keeps its legacy key so existing links still resolve
+	if CredentialsKey("resy", "user_789") != ResyCredentialsKey("user_789") {
+		t.Errorf("Resy credentials key changed: %s", CredentialsKey("resy", "user_789"))
+	}
+
+	got, err := GetResyCredentials(ctx, "user_789")
+	if err != nil {
+		t.Fatalf("GetResyCredentials failed: %v", err)
+	}
+	if got.AuthToken != "resy_token" {
+		t.Errorf("Resy AuthToken mismatch: got %s", got.AuthToken)
+	}
+
+	got, err = GetCredentials(ctx, "opentable", "user_789")
+	if err != nil {
+		t.Fatalf("GetCredentials(opentable) failed: %v", err)
+	}
+	if got.AuthToken != "ot_token" || got.Provider != "opentable" {
+		t.Errorf("Unexpected OpenTable credentials: %+v", got)
+	}
+
+	if err := DeleteCredentials(ctx, "opentable", "user_789"); err != nil {
+		t.Fatalf("DeleteCredentials failed: %v", err)
+	}
+	if exists, _ := CredentialsExist(ctx, "opentable", "user_789"); exists {
+		t.Error("Expected OpenTable credentials to be deleted")
+	}
+	if exists, _ := ResyCredentialsExist(ctx, "user_789"); !exists {
+		t.Error("Expected Resy credentials to survive deleting OpenTable's")
+	}
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+)
+
+const (
+	VenueKeyPrefix     = "venue:"
+	VenueMissKeyPrefix = "venue_miss:"
+	VenueTTL           = 24 * time.Hour  // Venue details rarely change
+	VenueMissTTL       = 5 * time.Minute // Short, a failed lookup may only have been a slow one
+)
+
+// VenueKey returns the Redis key for a provider's venue details
+func VenueKey(provider string, venueID int64) string {
+	return VenueKeyPrefix + api.VenueRef{Provider: provider, VenueID: venueID}.String()
+}
+
+// VenueMissKey returns the Redis key marking a venue whose lookup failed
+func VenueMissKey(provider string, venueID int64) string {
+	return VenueMissKeyPrefix + api.VenueRef{Provider: provider, VenueID: venueID}.String()
+}
+
+// SaveVenue caches venue details from GetVenue
+func SaveVenue(ctx context.Context, venue *api.Venue) error {
+	jsonData, err := json.Marshal(venue)
+	if err != nil {
+		return fmt.Errorf("failed to marshal venue: %w", err)
+	}
+
+	return GetClient().Set(ctx, VenueKey(venue.Provider, venue.VenueID), jsonData, VenueTTL).Err()
+}
+
+// GetVenue retrieves cached venue details, returning redis.Nil if there are none
+func GetVenue(ctx context.Context, provider string, venueID int64) (*api.Venue, error) {
+	jsonData, err := GetClient().Get(ctx, VenueKey(provider, venueID)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var venue api.Venue
+	if err := json.Unmarshal(jsonData, &venue); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal venue: %w", err)
+	}
+
+	return &venue, nil
+}
+
+// SaveVenueMiss records that looking a venue up failed, so it isn't
+// asked about again for VenueMissTTL
+func SaveVenueMiss(ctx context.Context, provider string, venueID int64) error {
+	return GetClient().Set(ctx, VenueMissKey(provider, venueID), "1", VenueMissTTL).Err()
+}
+
+// VenueMissed reports whether a lookup of the venue failed within the
+// last VenueMissTTL
+func VenueMissed(ctx context.Context, provider string, venueID int64) (bool, error) {
+	n, err := GetClient().Exists(ctx, VenueMissKey(provider, venueID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/21Bruce/resolved-server/api"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestVenueRoundTrip(t *testing.T) {
+	mr := setupTestRedis(t)
+	ctx := context.Background()
+
+	venue := &api.Venue{
+		Provider:      api.ProviderResy,
+		VenueID:       89607,
+		Name:          "Crevette",
+		TimeZone:      "America/New_York",
+		SeatingTypes:  []string{"Dining Room", "Bar"},
+		BookingWindow: &api.BookingWindow{DaysInAdvance: 14, ReleaseHour: 9},
+		Fees:          &api.FeePolicy{CancellationFee: 25, CancellationCutoff: 24 * time.Hour},
+	}
+	if err := SaveVenue(ctx, venue); err != nil {
+		t.Fatalf("SaveVenue failed: %v", err)
+	}
+
+	// An empty provider means Resy, so both spellings hit the same entry
+	got, err := GetVenue(ctx, "", 89607)
+	if err != nil {
+		t.Fatalf("GetVenue failed: %v", err)
+	}
+	if got.Name != "Crevette" || got.BookingWindow.DaysInAdvance != 14 || got.Fees.CancellationCutoff != 24*time.Hour {
+		t.Errorf("unexpected venue: %+v", got)
+	}
+
+	if ttl := mr.TTL(VenueKey(api.ProviderResy, 89607)); ttl != VenueTTL {
+		t.Errorf("expected TTL %v, got %v", VenueTTL, ttl)
+	}
+
+	if _, err := GetVenue(ctx, api.ProviderOpenTable, 89607); err != redis.Nil {
+		t.Errorf("expected redis.Nil for another provider's venue, got %v", err)
+	}
+}
+
+func TestVenueMiss(t *testing.T) {
+	mr := setupTestRedis(t)
+	ctx := context.Background()
+
+	if missed, err := VenueMissed(ctx, api.ProviderResy, 89607); err != nil || missed {
+		t.Fatalf("expected no miss before one is saved, got %v, %v", missed, err)
+	}
+	if err := SaveVenueMiss(ctx, "", 89607); err != nil {
+		t.Fatalf("SaveVenueMiss failed: %v", err)
+	}
+	if missed, err := VenueMissed(ctx, api.ProviderResy, 89607); err != nil || !missed {
+		t.Errorf("expected the miss to be recorded, got %v, %v", missed, err)
+	}
+	if missed, _ := VenueMissed(ctx, api.ProviderOpenTable, 89607); missed {
+		t.Error("expected another provider's venue not to be marked")
+	}
+	if ttl := mr.TTL(VenueMissKey(api.ProviderResy, 89607)); ttl != VenueMissTTL {
+		t.Errorf("expected TTL %v, got %v", VenueMissTTL, ttl)
+	}
+
+	mr.FastForward(VenueMissTTL)
+	if missed, _ := VenueMissed(ctx, api.ProviderResy, 89607); missed {
+		t.Error("expected the miss to expire")
+	}
+}